@@ -0,0 +1,97 @@
+// Package archive stores compressed copies of expired directories in object storage before they are deleted, and
+// compresses old directories in place.
+package archive
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Config selects and configures an archive backend for a company.
+type Config struct {
+	// Type is the backend: "s3", "gcs" or "azure".
+	Type string `json:"type"`
+	// Bucket is the bucket, or for Azure the container, objects are stored in.
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to every object key.
+	Prefix string `json:"prefix,omitempty"`
+	Region string `json:"region,omitempty"`
+	// Account is the Azure storage account.
+	Account string `json:"account,omitempty"`
+	// Endpoint overrides the backend's default endpoint, e.g. for S3-compatible stores or emulators. Buckets are
+	// then addressed path-style.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Credentials is where the gcs and azure backends get their credentials: empty for their environment variables,
+	// "metadata" for the metadata service of the VM we run on, or for gcs the path of a service account key file.
+	// See NewGCS and NewAzure.
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// Check returns an error if config is missing something every backend of its type needs.
+func (c Config) Check() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("missing bucket")
+	}
+	switch c.Type {
+	case "s3", "gcs":
+	case "azure":
+		if c.Account == "" {
+			return fmt.Errorf("azure needs an account")
+		}
+	default:
+		return fmt.Errorf("unknown archive type %q", c.Type)
+	}
+	if c.Credentials != "" && c.Type == "s3" {
+		return fmt.Errorf("credentials is not supported for s3, which reads them from the environment")
+	}
+	return nil
+}
+
+// Backend stores archive objects.
+type Backend interface {
+	// Put stores size bytes read from body under key. md5sum is the MD5 of the body; Put must not return nil
+	// unless the backend has confirmed it received exactly those bytes.
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, md5sum []byte) error
+}
+
+// NewBackend returns the backend described by config.
+func NewBackend(config Config) (Backend, error) {
+	if err := config.Check(); err != nil {
+		return nil, fmt.Errorf("archive config: %v", err)
+	}
+	switch config.Type {
+	case "gcs":
+		return NewGCS(config)
+	case "azure":
+		return NewAzure(config)
+	default:
+		return NewS3(config)
+	}
+}
+
+// Directory writes dir as a gzipped tar to a temporary file and uploads it to backend under key. The temporary file
+// is always removed. It returns the size of the uploaded archive.
+func Directory(ctx context.Context, backend Backend, key string, dir string) (int64, error) {
+	tmp, err := ioutil.TempFile("", "deleter-archive-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	hash := md5.New()
+	if err := WriteTarGz(io.MultiWriter(tmp, hash), dir); err != nil {
+		return 0, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, backend.Put(ctx, key, tmp, size, hash.Sum(nil))
+}
@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureVersion is the Blob service REST API version requests are made against.
+const azureVersion = "2020-10-02"
+
+// Azure uploads archives to an Azure Blob Storage container as block blobs, each with a single Put Blob request.
+// Single requests are limited to 5000 MiB by Azure.
+type Azure struct {
+	Account   string
+	Container string
+	Endpoint  string
+	// Exactly one of SharedKey, SAS and Tokens authenticates requests.
+	SharedKey []byte
+	SAS       string
+	Tokens    TokenSource
+	Client    *http.Client
+}
+
+// NewAzure returns an Azure backend for config. Requests are authenticated with a managed identity token from the
+// instance metadata service if config's Credentials is "metadata", and otherwise with AZURE_STORAGE_KEY, the
+// account's shared key, or AZURE_STORAGE_SAS_TOKEN, a shared access signature.
+func NewAzure(config Config) (*Azure, error) {
+	azure := &Azure{
+		Account:   config.Account,
+		Container: config.Bucket,
+		Endpoint:  config.Endpoint,
+		Client:    &http.Client{Timeout: time.Hour},
+	}
+	switch {
+	case config.Credentials == "metadata":
+		azure.Tokens = CachedToken(MetadataToken{Azure: true})
+	case config.Credentials != "":
+		return nil, fmt.Errorf("azure archive credentials must be empty or metadata")
+	case os.Getenv("AZURE_STORAGE_KEY") != "":
+		key, err := base64.StdEncoding.DecodeString(os.Getenv("AZURE_STORAGE_KEY"))
+		if err != nil {
+			return nil, fmt.Errorf("AZURE_STORAGE_KEY: %v", err)
+		}
+		azure.SharedKey = key
+	case os.Getenv("AZURE_STORAGE_SAS_TOKEN") != "":
+		azure.SAS = strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	default:
+		return nil, fmt.Errorf("azure archive needs credentials, AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN")
+	}
+	return azure, nil
+}
+
+// Put uploads the blob with a Content-MD5 header, so Azure rejects it if the bytes were corrupted in transit, and
+// then checks the MD5 Azure reports for what it stored as well.
+func (a *Azure) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, md5sum []byte) error {
+	target := a.blobURL(key)
+	req, err := http.NewRequest(http.MethodPut, target.String(), ioutil.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	encoded := base64.StdEncoding.EncodeToString(md5sum)
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("Content-MD5", encoded)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", azureVersion)
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	switch {
+	case a.Tokens != nil:
+		token, _, err := a.Tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case a.SharedKey != nil:
+		a.sign(req)
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = a.redactedURL(key)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("azure put %s: %s: %s", a.redactedURL(key), resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if stored := resp.Header.Get("Content-MD5"); stored != "" && stored != encoded {
+		return fmt.Errorf("azure put %s: Content-MD5 %s does not match upload MD5 %s", a.redactedURL(key), stored, encoded)
+	}
+	return nil
+}
+
+func (a *Azure) blobURL(key string) *url.URL {
+	base := &url.URL{Scheme: "https", Host: a.Account + ".blob.core.windows.net"}
+	if a.Endpoint != "" {
+		if endpoint, err := url.Parse(a.Endpoint); err == nil {
+			base = endpoint
+		}
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/" + a.Container + "/" + key
+	base.RawPath = escapePath(base.Path)
+	if a.SAS != "" {
+		base.RawQuery = a.SAS
+	}
+	return base
+}
+
+// redactedURL is the blob's URL without the SAS, which is a secret, for errors.
+func (a *Azure) redactedURL(key string) string {
+	target := a.blobURL(key)
+	target.RawQuery = ""
+	return target.String()
+}
+
+// sign adds a Shared Key Authorization header to req.
+func (a *Azure) sign(req *http.Request) {
+	var headers []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			headers = append(headers, lower)
+		}
+	}
+	sort.Strings(headers)
+	var canonical strings.Builder
+	for _, name := range headers {
+		canonical.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	canonical.WriteString("/" + a.Account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		canonical.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date; X-Ms-Date is used instead.
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonical.String(),
+	}, "\n")
+	mac := hmac.New(sha256.New, a.SharedKey)
+	mac.Write([]byte(stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", a.Account, base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+}
@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAzurePut(t *testing.T) {
+	body := []byte("archive data")
+	sum := md5.Sum(body)
+	encoded := base64.StdEncoding.EncodeToString(sum[:])
+	var got *http.Request
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		received, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-MD5", encoded)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	azure := &Azure{Account: "acct", Container: "archive", Endpoint: srv.URL, SAS: "sv=2020-10-02&sig=secret", Client: srv.Client()}
+	if err := azure.Put(context.Background(), "acme/cam/2020.tar.gz", bytes.NewReader(body), int64(len(body)), sum[:]); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != http.MethodPut || got.URL.Path != "/archive/acme/cam/2020.tar.gz" || got.URL.RawQuery != "sv=2020-10-02&sig=secret" {
+		t.Errorf("got %s %s", got.Method, got.URL)
+	}
+	for name, want := range map[string]string{
+		"Content-Md5":    encoded,
+		"X-Ms-Blob-Type": "BlockBlob",
+		"X-Ms-Version":   azureVersion,
+		"Authorization":  "",
+	} {
+		if got.Header.Get(name) != want {
+			t.Errorf("header %s is %q, want %q", name, got.Header.Get(name), want)
+		}
+	}
+	if got.Header.Get("X-Ms-Date") == "" || !bytes.Equal(received, body) {
+		t.Errorf("uploaded %q dated %q", received, got.Header.Get("X-Ms-Date"))
+	}
+}
+
+func TestAzurePutWithToken(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	azure := &Azure{Account: "acct", Container: "archive", Endpoint: srv.URL, Tokens: StaticToken("managed"), Client: srv.Client()}
+	if err := azure.Put(context.Background(), "key", bytes.NewReader(nil), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer managed" {
+		t.Errorf("got Authorization %q", authorization)
+	}
+}
+
+func TestAzurePutErrors(t *testing.T) {
+	body := []byte("archive data")
+	sum := md5.Sum(body)
+	for _, test := range []struct {
+		status int
+		md5    string
+		err    string
+	}{
+		{http.StatusForbidden, "", "403 Forbidden: AuthenticationFailed"},
+		// Put Blob answers 201; a 200 is something other than an upload, such as a proxy.
+		{http.StatusOK, "", "200 OK"},
+		{http.StatusCreated, "1B2M2Y8AsgTpgAmY7PhCfg==", "does not match"},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			if test.md5 != "" {
+				w.Header().Set("Content-MD5", test.md5)
+			}
+			w.WriteHeader(test.status)
+			fmt.Fprintln(w, "AuthenticationFailed")
+		}))
+		azure := &Azure{Account: "acct", Container: "archive", Endpoint: srv.URL, SAS: "sig=secret", Client: srv.Client()}
+		err := azure.Put(context.Background(), "key", bytes.NewReader(body), int64(len(body)), sum[:])
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("got %v, want an error containing %q", err, test.err)
+		}
+		// The SAS signature must not end up in logs.
+		if err != nil && strings.Contains(err.Error(), "secret") {
+			t.Errorf("error %q holds the SAS signature", err)
+		}
+	}
+}
+
+func TestAzurePutUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	azure := &Azure{Account: "acct", Container: "archive", Endpoint: srv.URL, SAS: "sig=secret", Client: srv.Client()}
+	err := azure.Put(context.Background(), "key", bytes.NewReader(nil), 0, nil)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("got %v, want an error without the SAS signature", err)
+	}
+}
+
+func TestAzureSharedKey(t *testing.T) {
+	key := []byte("shared key")
+	azure := &Azure{Account: "acct", Container: "archive", SharedKey: key}
+	req, err := http.NewRequest(http.MethodPut, "https://acct.blob.core.windows.net/archive/acme/2020.tar.gz?comp=block&blockid=b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.ContentLength = 12
+	req.Header.Set("Content-MD5", "md5")
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("X-Ms-Version", azureVersion)
+	req.Header.Set("X-Ms-Date", "Fri, 01 Jan 2021 00:00:00 GMT")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	azure.sign(req)
+	// The string to sign as Azure documents it for the Shared Key scheme: the standard headers, the x-ms- headers
+	// sorted and lowercased, then the resource with its query parameters sorted.
+	stringToSign := "PUT\n\n\n12\nmd5\napplication/gzip\n\n\n\n\n\n\n" +
+		"x-ms-blob-type:BlockBlob\nx-ms-date:Fri, 01 Jan 2021 00:00:00 GMT\nx-ms-version:" + azureVersion + "\n" +
+		"/acct/archive/acme/2020.tar.gz\nblockid:b\ncomp:block"
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	want := "SharedKey acct:" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got Authorization %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package archive
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the OAuth 2.0 bearer tokens the gcs and azure backends authenticate with. Set a backend's
+// Tokens to plug in a source of your own.
+type TokenSource interface {
+	// Token returns a token and when it expires.
+	Token(ctx context.Context) (string, time.Time, error)
+}
+
+// StaticToken is a token that is used as it is, e.g. one handed to us in an environment variable. It never expires
+// as far as we know.
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, time.Time, error) {
+	return string(t), time.Time{}, nil
+}
+
+// cachedToken reuses a token until shortly before it expires.
+type cachedToken struct {
+	source TokenSource
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// CachedToken wraps source so that a token is only fetched again when the last one is about to expire.
+func CachedToken(source TokenSource) TokenSource {
+	return &cachedToken{source: source}
+}
+
+func (c *cachedToken) Token(ctx context.Context) (string, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && (c.expiry.IsZero() || time.Until(c.expiry) > time.Minute) {
+		return c.token, c.expiry, nil
+	}
+	token, expiry, err := c.source.Token(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	c.token, c.expiry = token, expiry
+	return token, expiry, nil
+}
+
+// MetadataToken fetches tokens for the VM's own identity from the cloud metadata service: the GCE metadata server
+// for gcs, and Azure's instance metadata service, for a managed identity, for azure.
+type MetadataToken struct {
+	// Azure selects Azure's service rather than GCE's.
+	Azure  bool
+	Client *http.Client
+}
+
+func (m MetadataToken) Token(ctx context.Context) (string, time.Time, error) {
+	var req *http.Request
+	var err error
+	if m.Azure {
+		req, err = http.NewRequest(http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource="+url.QueryEscape("https://storage.azure.com/"), nil)
+		if err == nil {
+			req.Header.Set("Metadata", "true")
+		}
+	} else {
+		req, err = http.NewRequest(http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fetchToken(ctx, m.Client, req.WithContext(ctx))
+}
+
+// ServiceAccountToken exchanges a signed JWT for tokens of a GCP service account, from the JSON key file GCP hands
+// out for it.
+type ServiceAccountToken struct {
+	Email    string
+	Key      *rsa.PrivateKey
+	TokenURI string
+	Scope    string
+	Client   *http.Client
+}
+
+// NewServiceAccountToken reads a service account key file.
+func NewServiceAccountToken(path string, scope string) (*ServiceAccountToken, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Email      string `json:"client_email"`
+		PrivateKey string `json:"private_key"`
+		TokenURI   string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%s: no private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: private key is not RSA", path)
+	}
+	if file.TokenURI == "" {
+		file.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &ServiceAccountToken{Email: file.Email, Key: key, TokenURI: file.TokenURI, Scope: scope}, nil
+}
+
+func (s *ServiceAccountToken) Token(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   s.Email,
+		"scope": s.Scope,
+		"aud":   s.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequest(http.MethodPost, s.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return fetchToken(ctx, s.Client, req.WithContext(ctx))
+}
+
+// fetchToken sends a token request and decodes the OAuth 2.0 token response.
+func fetchToken(ctx context.Context, client *http.Client, req *http.Request) (string, time.Time, error) {
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("token request to %s: %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		// Azure writes expires_in as a string.
+		ExpiresIn json.RawMessage `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("token request to %s: %v", req.URL.Host, err)
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token request to %s: no access_token", req.URL.Host)
+	}
+	var expiry time.Time
+	if seconds, err := strconv.Atoi(strings.Trim(string(token.ExpiresIn), `"`)); err == nil {
+		expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token.AccessToken, expiry, nil
+}
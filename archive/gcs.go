@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gcsScope is the OAuth scope GCS uploads need.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCS uploads archives to a Google Cloud Storage bucket with single PUT requests to its XML API, and lists, reads and
+// deletes objects as a Store.
+type GCS struct {
+	Bucket   string
+	Endpoint string
+	Tokens   TokenSource
+	Client   *http.Client
+}
+
+// NewGCS returns a GCS backend for config. Tokens come from the service account key file named by config's
+// Credentials, from the GCE metadata server if it is "metadata", or else from GOOGLE_OAUTH_ACCESS_TOKEN or the key
+// file named by GOOGLE_APPLICATION_CREDENTIALS.
+func NewGCS(config Config) (*GCS, error) {
+	gcs := &GCS{
+		Bucket:   config.Bucket,
+		Endpoint: config.Endpoint,
+		Client:   &http.Client{Timeout: time.Hour},
+	}
+	var tokens TokenSource
+	switch {
+	case config.Credentials == "metadata":
+		tokens = MetadataToken{}
+	case config.Credentials != "":
+		source, err := NewServiceAccountToken(config.Credentials, gcsScope)
+		if err != nil {
+			return nil, err
+		}
+		tokens = source
+	case os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN") != "":
+		tokens = StaticToken(os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"))
+	case os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "":
+		source, err := NewServiceAccountToken(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), gcsScope)
+		if err != nil {
+			return nil, err
+		}
+		tokens = source
+	default:
+		return nil, fmt.Errorf("gcs archive needs credentials, GOOGLE_OAUTH_ACCESS_TOKEN or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	gcs.Tokens = CachedToken(tokens)
+	return gcs, nil
+}
+
+// Put uploads the object with a Content-MD5 header, so GCS rejects it if the bytes were corrupted in transit, and
+// then checks the MD5 GCS reports for what it stored as well.
+func (g *GCS) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, md5sum []byte) error {
+	token, _, err := g.Tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	target := g.objectURL(key)
+	req, err := http.NewRequest(http.MethodPut, target.String(), ioutil.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	encoded := base64.StdEncoding.EncodeToString(md5sum)
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("Content-MD5", encoded)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("gcs put %s: %s: %s", target, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	for _, header := range resp.Header.Values("X-Goog-Hash") {
+		for _, hash := range strings.Split(header, ",") {
+			if value := strings.TrimPrefix(strings.TrimSpace(hash), "md5="); value != strings.TrimSpace(hash) && value != encoded {
+				return fmt.Errorf("gcs put %s: MD5 %s does not match upload MD5 %s", target, value, encoded)
+			}
+		}
+	}
+	return nil
+}
+
+// List pages through the JSON API's object listing.
+func (g *GCS) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	token := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name,size,updated),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		target := g.baseURL()
+		target.Path = strings.TrimSuffix(target.Path, "/") + "/storage/v1/b/" + g.Bucket + "/o"
+		target.RawPath = ""
+		target.RawQuery = canonicalQuery(query)
+		body, err := g.do(ctx, http.MethodGet, target, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Items []struct {
+				Name    string    `json:"name"`
+				Size    string    `json:"size"`
+				Updated time.Time `json:"updated"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("gcs list %s: %v", target, err)
+		}
+		for _, item := range result.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			if err := fn(Object{Key: item.Name, Size: size, Modified: item.Updated}); err != nil {
+				return err
+			}
+		}
+		if result.NextPageToken == "" {
+			return nil
+		}
+		token = result.NextPageToken
+	}
+}
+
+func (g *GCS) Get(ctx context.Context, key string) ([]byte, error) {
+	return g.do(ctx, http.MethodGet, g.objectURL(key), http.StatusOK)
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	_, err := g.do(ctx, http.MethodDelete, g.objectURL(key), http.StatusNoContent, http.StatusNotFound)
+	return err
+}
+
+// do sends an authorized request without a body and returns the response body, or an error unless the response has
+// one of the expected statuses.
+func (g *GCS) do(ctx context.Context, method string, target *url.URL, expected ...int) ([]byte, error) {
+	token, _, err := g.Tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !containsStatus(expected, resp.StatusCode) {
+		return nil, fmt.Errorf("gcs %s %s: %s: %s", strings.ToLower(method), target, resp.Status, strings.TrimSpace(string(body[:min(len(body), 4096)])))
+	}
+	return body, nil
+}
+
+func (g *GCS) baseURL() *url.URL {
+	if g.Endpoint != "" {
+		if endpoint, err := url.Parse(g.Endpoint); err == nil {
+			return endpoint
+		}
+	}
+	return &url.URL{Scheme: "https", Host: "storage.googleapis.com"}
+}
+
+func (g *GCS) objectURL(key string) *url.URL {
+	base := g.baseURL()
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/" + g.Bucket + "/" + key
+	base.RawPath = escapePath(base.Path)
+	return base
+}
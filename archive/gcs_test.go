@@ -0,0 +1,142 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testGCS returns a GCS backend for the bucket archive on srv, with the token "token".
+func testGCS(srv *httptest.Server) *GCS {
+	return &GCS{Bucket: "archive", Endpoint: srv.URL, Tokens: StaticToken("token"), Client: srv.Client()}
+}
+
+func TestGCSPut(t *testing.T) {
+	body := []byte("archive data")
+	sum := md5.Sum(body)
+	encoded := base64.StdEncoding.EncodeToString(sum[:])
+	var got *http.Request
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		received, _ = io.ReadAll(r.Body)
+		w.Header().Set("X-Goog-Hash", "crc32c=n03x6A==,md5="+encoded)
+	}))
+	defer srv.Close()
+	err := testGCS(srv).Put(context.Background(), "acme/cam/2020 12.tar.gz", bytes.NewReader(body), int64(len(body)), sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != http.MethodPut || got.URL.EscapedPath() != "/archive/acme/cam/2020%2012.tar.gz" {
+		t.Errorf("got %s %s", got.Method, got.URL.EscapedPath())
+	}
+	for name, want := range map[string]string{
+		"Authorization": "Bearer token",
+		"Content-Md5":   encoded,
+		"Content-Type":  "application/gzip",
+	} {
+		if got.Header.Get(name) != want {
+			t.Errorf("header %s is %q, want %q", name, got.Header.Get(name), want)
+		}
+	}
+	if got.ContentLength != int64(len(body)) || !bytes.Equal(received, body) {
+		t.Errorf("uploaded %q with length %d", received, got.ContentLength)
+	}
+}
+
+func TestGCSPutErrors(t *testing.T) {
+	body := []byte("archive data")
+	sum := md5.Sum(body)
+	for _, test := range []struct {
+		status int
+		hash   string
+		err    string
+	}{
+		{http.StatusForbidden, "", "403 Forbidden: AccessDenied"},
+		// GCS stored something other than what was sent.
+		{http.StatusOK, "md5=1B2M2Y8AsgTpgAmY7PhCfg==", "does not match"},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			if test.hash != "" {
+				w.Header().Set("X-Goog-Hash", test.hash)
+			}
+			w.WriteHeader(test.status)
+			fmt.Fprintln(w, "AccessDenied")
+		}))
+		err := testGCS(srv).Put(context.Background(), "acme/2020.tar.gz", bytes.NewReader(body), int64(len(body)), sum[:])
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("got %v, want an error containing %q", err, test.err)
+		}
+	}
+}
+
+func TestGCSPutTokenError(t *testing.T) {
+	gcs := &GCS{Bucket: "archive", Tokens: &countingToken{err: fmt.Errorf("no credentials")}}
+	if err := gcs.Put(context.Background(), "key", bytes.NewReader(nil), 0, nil); err == nil || err.Error() != "no credentials" {
+		t.Errorf("got %v, want the token source's error", err)
+	}
+}
+
+func TestGCSStore(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/archive/o":
+			if r.URL.Query().Get("prefix") != "acme/" {
+				http.Error(w, "bad prefix", http.StatusBadRequest)
+			} else if r.URL.Query().Get("pageToken") == "" {
+				fmt.Fprint(w, `{"items": [{"name": "acme/a", "size": "3", "updated": "2020-12-01T00:00:00Z"}], "nextPageToken": "next"}`)
+			} else {
+				fmt.Fprint(w, `{"items": [{"name": "acme/b", "size": "5", "updated": "2020-12-02T00:00:00Z"}]}`)
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/archive/acme/a":
+			fmt.Fprint(w, "abc")
+		case r.Method == http.MethodDelete && r.URL.Path == "/archive/acme/a":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	gcs := testGCS(srv)
+	var listed []string
+	err := gcs.List(context.Background(), "acme/", func(object Object) error {
+		listed = append(listed, fmt.Sprintf("%s %d %s", object.Key, object.Size, object.Modified.Format("2006-01-02")))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(listed) != "[acme/a 3 2020-12-01 acme/b 5 2020-12-02]" {
+		t.Errorf("listed %q, want both pages", listed)
+	}
+	if data, err := gcs.Get(context.Background(), "acme/a"); err != nil || string(data) != "abc" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if _, err := gcs.Get(context.Background(), "acme/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v, want a 404", err)
+	}
+	// Deleting an object that is already gone is not an error.
+	for _, key := range []string{"acme/a", "acme/missing"} {
+		if err := gcs.Delete(context.Background(), key); err != nil {
+			t.Errorf("delete %s: %v", key, err)
+		}
+	}
+	if fmt.Sprint(deleted) != "[/archive/acme/a]" {
+		t.Errorf("deleted %q", deleted)
+	}
+}
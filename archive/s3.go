@@ -0,0 +1,244 @@
+package archive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 uploads archives to an S3 bucket with single PUT requests signed with AWS Signature Version 4, and lists, reads
+// and deletes objects as a Store. Credentials are
+// taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, if set, AWS_SESSION_TOKEN. Single PUTs are limited to
+// 5 GB by S3.
+type S3 struct {
+	Bucket   string
+	Region   string
+	Endpoint string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	Client *http.Client
+}
+
+// NewS3 returns an S3 backend for config, with credentials from the environment.
+func NewS3(config Config) (*S3, error) {
+	s3 := &S3{
+		Bucket:       config.Bucket,
+		Region:       config.Region,
+		Endpoint:     config.Endpoint,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Client:       &http.Client{Timeout: time.Hour},
+	}
+	if s3.Region == "" {
+		s3.Region = "us-east-1"
+	}
+	if s3.AccessKey == "" || s3.SecretKey == "" {
+		return nil, fmt.Errorf("s3 archive needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s3, nil
+}
+
+// Put uploads the object with a Content-MD5 header, so S3 rejects it if the bytes were corrupted in transit. The
+// ETag isn't checked: it is only the MD5 for single-part uploads without SSE-KMS.
+func (s *S3) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, md5sum []byte) error {
+	target := s.objectURL(key)
+	// Send the path exactly as it is signed.
+	target.RawPath = escapePath(target.Path)
+	req, err := http.NewRequest(http.MethodPut, target.String(), ioutil.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum))
+	s.sign(req, time.Now().UTC())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("s3 put %s: %s: %s", target, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// List pages through ListObjectsV2.
+func (s *S3) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		target := s.objectURL("")
+		target.RawPath = escapePath(target.Path)
+		target.RawQuery = canonicalQuery(query)
+		body, err := s.do(ctx, http.MethodGet, target, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("s3 list %s: %v", target, err)
+		}
+		for _, object := range result.Contents {
+			if err := fn(Object{Key: object.Key, Size: object.Size, Modified: object.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	target := s.objectURL(key)
+	target.RawPath = escapePath(target.Path)
+	return s.do(ctx, http.MethodGet, target, http.StatusOK)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	target := s.objectURL(key)
+	target.RawPath = escapePath(target.Path)
+	_, err := s.do(ctx, http.MethodDelete, target, http.StatusNoContent)
+	return err
+}
+
+// do sends a signed request without a body and returns the response body, or an error unless the response has one
+// of the expected statuses.
+func (s *S3) do(ctx context.Context, method string, target *url.URL, expected ...int) ([]byte, error) {
+	req, err := http.NewRequest(method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	s.sign(req, time.Now().UTC())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !containsStatus(expected, resp.StatusCode) {
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", strings.ToLower(method), target, resp.Status, strings.TrimSpace(string(body[:min(len(body), 4096)])))
+	}
+	return body, nil
+}
+
+func (s *S3) objectURL(key string) *url.URL {
+	if s.Endpoint != "" {
+		base, err := url.Parse(s.Endpoint)
+		if err == nil {
+			base.Path = strings.TrimSuffix(base.Path, "/") + "/" + s.Bucket + "/" + key
+			return base
+		}
+	}
+	return &url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", s.Bucket, s.Region),
+		Path:   "/" + key,
+	}
+}
+
+// sign adds AWS Signature Version 4 headers to req. Unless req already has an X-Amz-Content-Sha256 header the
+// payload is left unsigned; Content-MD5 covers its integrity.
+func (s *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = "UNSIGNED-PAYLOAD"
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// escapePath URI-encodes each segment of path the way Signature Version 4 expects.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = strings.Replace(url.QueryEscape(segment), "+", "%20", -1)
+	}
+	return strings.Join(segments, "/")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Object is one object in a Store.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Store is an object store whose objects can be listed, read and deleted, for pruning data kept in a bucket rather
+// than on disk.
+type Store interface {
+	// List calls fn for every object whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object. Deleting an object that doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore returns the store described by config. Only s3 and gcs are supported.
+func NewStore(config Config) (Store, error) {
+	if err := config.Check(); err != nil {
+		return nil, fmt.Errorf("storage config: %v", err)
+	}
+	switch config.Type {
+	case "s3":
+		return NewS3(config)
+	case "gcs":
+		return NewGCS(config)
+	default:
+		return nil, fmt.Errorf("storage type %q is not supported", config.Type)
+	}
+}
+
+// canonicalQuery encodes query parameters sorted by name, with spaces as %20, which is the form both S3 signatures
+// and GCS accept.
+func canonicalQuery(values url.Values) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range values[name] {
+			parts = append(parts, queryEscape(name)+"="+queryEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func queryEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// WriteTarGz writes the tree rooted at root to w as a gzipped tar. Entry names are relative to root's parent, so
+// the archive unpacks to a directory named like root. Symlinks are stored as links, not followed.
+func WriteTarGz(w io.Writer, root string) error {
+	gz := gzip.NewWriter(w)
+	if err := writeTar(gz, root); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// WriteTarZst is WriteTarGz with zstd compression instead of gzip.
+func WriteTarZst(w io.Writer, root string) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := writeTar(zw, root); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// CompressInPlace writes dir as a zstd-compressed tar to target and returns the archive's size. The archive is
+// written under a temporary name and synced before it is renamed to target, so target only ever holds a complete
+// archive. An existing target is never replaced, since it may hold files that are no longer in dir. Removing dir is
+// left to the caller.
+func CompressInPlace(dir string, target string) (int64, error) {
+	if _, err := os.Lstat(target); err == nil {
+		return 0, &os.PathError{Op: "compress", Path: target, Err: os.ErrExist}
+	}
+	tmp := target + ".partial"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	err = WriteTarZst(file, dir)
+	var size int64
+	if err == nil {
+		size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return size, nil
+}
+
+func writeTar(w io.Writer, root string) error {
+	tw := tar.NewWriter(w)
+	parent := filepath.Dir(root)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(parent, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ContainsDir reports whether the zstd-compressed tar at archivePath, as written by CompressInPlace, holds everything
+// under dir with the same contents. It is true of what is left of a directory whose removal was cut short after it
+// was compressed, and false of one that has changed since.
+func ContainsDir(archivePath string, dir string) (bool, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	zr, err := zstd.NewReader(file)
+	if err != nil {
+		return false, err
+	}
+	defer zr.Close()
+	// Regular files are kept by the hash of their contents, symlinks by their target and directories by "".
+	entries := map[string][]byte{}
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			hash := sha256.New()
+			if _, err := io.Copy(hash, tr); err != nil {
+				return false, err
+			}
+			entries[header.Name] = hash.Sum(nil)
+		case tar.TypeSymlink:
+			entries[header.Name] = []byte(header.Linkname)
+		default:
+			entries[header.Name] = nil
+		}
+	}
+	parent := filepath.Dir(dir)
+	contained := true
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(parent, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if info.IsDir() {
+			name += "/"
+		}
+		want, ok := entries[name]
+		if !ok {
+			contained = false
+			return io.EOF
+		}
+		var got []byte
+		switch {
+		case info.Mode().IsRegular():
+			if got, err = fileHash(path); err != nil {
+				return err
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			got = []byte(link)
+		}
+		if !bytes.Equal(got, want) {
+			contained = false
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return contained, nil
+}
+
+// fileHash returns the SHA-256 of the contents of the file at path.
+func fileHash(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// adminAPI is the daemon's HTTP admin API. Every request must carry "Authorization: Bearer <token>".
+//
+//	POST /v1/run[?company=ID...]       prune every company, or the named ones, now
+//	GET  /v1/status                    latest pass outcome for every company
+//	POST /v1/companies/ID/pause        stop pruning a company until resumed
+//	POST /v1/companies/ID/resume
+//	GET  /v1/config                    the config in effect
+//	GET  /v1/history[?company=ID&removed=true&limit=N]
+//	                                   past passes, most recent first, with -history
+type adminAPI struct {
+	p     *pruner.Pruner
+	token string
+}
+
+func (a *adminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(a.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	route := r.Method + " " + r.URL.Path
+	switch {
+	case route == "POST /v1/run":
+		if !a.p.Trigger(r.URL.Query()["company"]...) {
+			http.Error(w, "too many runs already waiting", http.StatusServiceUnavailable)
+			return
+		}
+		log.WithField("companies", r.URL.Query()["company"]).Infoln("Admin API triggered a pass")
+		w.WriteHeader(http.StatusAccepted)
+	case route == "GET /v1/status":
+		writeJSON(w, a.p.Status())
+	case route == "GET /v1/config":
+		writeJSON(w, a.p.Config())
+	case route == "GET /v1/history":
+		a.history(w, r)
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/v1/companies/"):
+		company, action := splitCompanyPath(strings.TrimPrefix(r.URL.Path, "/v1/companies/"))
+		switch action {
+		case "pause":
+			a.p.Pause(company)
+		case "resume":
+			a.p.Resume(company)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		log.WithField("company_id", company).Infof("Admin API: %s", action)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// history serves the passes recorded in the History matching the request's query.
+func (a *adminAPI) history(w http.ResponseWriter, r *http.Request) {
+	if a.p.History == nil {
+		http.Error(w, "no history is kept, run with -history", http.StatusNotFound)
+		return
+	}
+	params := r.URL.Query()
+	query := pruner.HistoryQuery{Company: params.Get("company"), Removed: params.Get("removed") == "true", Limit: 20}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "bad limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+	summaries, err := a.p.History.Query(query)
+	if err != nil {
+		log.Errorln("Could not read history.", err)
+		http.Error(w, "could not read history", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, summaries)
+}
+
+// splitCompanyPath splits "ID/action" into the company id and action.
+func splitCompanyPath(path string) (string, string) {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "", ""
+	}
+	return path[:i], path[i+1:]
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Errorln("Could not write admin API response.", err)
+	}
+}
+
+// serveAdmin serves the admin API on addr in the background.
+func serveAdmin(addr string, p *pruner.Pruner, token string) {
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, &adminAPI{p: p, token: token}))
+	}()
+}
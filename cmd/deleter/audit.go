@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// verifyAuditCommand implements "deleter verify-audit-log <file>": check the hash chain of an audit log, exiting
+// non-zero if it is broken.
+func verifyAuditCommand(args []string) {
+	flags := flag.NewFlagSet("verify-audit-log", flag.ContinueOnError)
+	parseFlags(flags, args)
+	if flags.NArg() != 1 {
+		log.Fatal("usage: deleter verify-audit-log <file>")
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal("Could not open audit log.", err)
+	}
+	defer file.Close()
+	last, err := pruner.VerifyAuditLog(file)
+	if err != nil {
+		log.Fatal("Audit log failed verification. ", err)
+	}
+	log.Infof("Audit log verified, %d records, last hash %s", last.Seq, last.Hash)
+}
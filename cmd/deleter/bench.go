@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/notify"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// benchCommand implements "deleter bench -baseDir dir": walk the tree with each traversal, removing nothing, and
+// compare how long they take and what they cost.
+func benchCommand(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var baseDir, names string
+	var rounds int
+	var stat bool
+	flags.StringVar(&baseDir, "baseDir", "", "Directory to walk (required)")
+	flags.StringVar(&names, "traversal", "", "Comma-separated traversals to compare (default all)")
+	flags.IntVar(&rounds, "rounds", 3, "Walks per traversal. The first of a cold tree also fills the page cache")
+	flags.BoolVar(&stat, "stat", false, "Also stat every file, as mtime mode does")
+	parseFlags(flags, args)
+	if baseDir == "" {
+		log.Fatal("bench needs -baseDir")
+	}
+	traversals := pruner.Traversals
+	if names != "" {
+		traversals = nil
+		for _, name := range strings.Split(names, ",") {
+			traversal, ok := findTraversal(strings.TrimSpace(name))
+			if !ok {
+				log.Fatalf("Unknown traversal %q.", name)
+			}
+			traversals = append(traversals, traversal)
+		}
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	defer out.Flush()
+	fmt.Fprintln(out, "TRAVERSAL\tROUND\tDIRS\tFILES\tERRORS\tWALL\tUSER\tSYSTEM\tSYSCALLS\tALLOCATED\tMALLOCS\tPEAK HEAP\t")
+	for _, traversal := range traversals {
+		for round := 1; round <= rounds; round++ {
+			result, err := pruner.Bench(baseDir, traversal, stat)
+			if err != nil {
+				log.Fatalf("Walk with %s failed. %v", traversal.Name, err)
+			}
+			syscalls := "-"
+			if result.Syscalls >= 0 {
+				syscalls = fmt.Sprint(result.Syscalls)
+			}
+			fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t\n", result.Traversal, round,
+				result.Dirs, result.Files, result.Errors, result.Wall.Round(time.Microsecond),
+				result.User.Round(time.Microsecond), result.System.Round(time.Microsecond), syscalls,
+				notify.FormatBytes(int64(result.Allocated)), result.Mallocs, notify.FormatBytes(int64(result.PeakHeap)))
+		}
+	}
+}
+
+func findTraversal(name string) (pruner.Traversal, bool) {
+	for _, traversal := range pruner.Traversals {
+		if traversal.Name == name {
+			return traversal, true
+		}
+	}
+	return pruner.Traversal{}, false
+}
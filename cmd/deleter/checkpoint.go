@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// readCheckpoint loads the checkpoint left at path by a pass that stopped early, or returns nil if there is none.
+func readCheckpoint(path string) (*pruner.Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint pruner.Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// writeCheckpoint saves where summary's pass stopped, or removes the checkpoint if it finished.
+func writeCheckpoint(path string, summary pruner.Summary) error {
+	checkpoint := summary.Checkpoint()
+	if checkpoint == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves half a checkpoint.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+	// Drivers for -config-db-driver.
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// commonFlags are the flags every command that reads the config and the data tree shares.
+type commonFlags struct {
+	baseDirs       stringList
+	configLocation string
+	logLevel       string
+	logFormat      string
+	timezone       string
+	followSymlinks bool
+	oneFileSystem  bool
+	fastFS         bool
+	maxMemory      string
+	journalPath    string
+	minAge         time.Duration
+	correlationID  string
+	// companies and excludeCompanies narrow the pass down to a subset of the company directories.
+	companies        stringList
+	excludeCompanies stringList
+	// The optional database holding company entries, merged over the config document.
+	dbDriver string
+	dbDSN    string
+	dbQuery  string
+	db       *sql.DB
+}
+
+func (c *commonFlags) register(flags *flag.FlagSet) {
+	flags.Var(&c.baseDirs, "baseDir", "Directory holding the company directories, default /tmp/foo. May be repeated or comma-separated to process several in one run")
+	flags.StringVar(&c.configLocation, "config", "", "Config file path, http(s) URL, consul:// or etcd:// key, or - for stdin")
+	flags.StringVar(&c.logLevel, "level", "debug", "Logging level")
+	flags.StringVar(&c.logFormat, "log-format", "text", "Log format, text or json")
+	flags.StringVar(&c.timezone, "timezone", "UTC", "IANA zone directory dates are written in, for companies whose config has none")
+	flags.BoolVar(&c.followSymlinks, "follow-symlinks", false, "Walk into symlinked directories that lead somewhere inside -baseDir")
+	flags.Var(&c.companies, "company", "Only process these company ids. May be repeated or comma-separated")
+	flags.Var(&c.excludeCompanies, "exclude-company", "Leave these company ids alone. May be repeated or comma-separated")
+	flags.BoolVar(&c.oneFileSystem, "one-file-system", false, "Leave alone directories on a different filesystem from their company directory, such as mounted volumes")
+	flags.BoolVar(&c.fastFS, "fast-fs", false, "Read directories with getdents64 and remove with unlinkat, bypassing per-entry overhead. Linux only")
+	flags.StringVar(&c.maxMemory, "max-memory", "", "Memory to try to stay within, e.g. 512MiB: directories are read in batches sized to fit it and garbage is collected harder as it gets close. Advisory, not a hard limit")
+	flags.StringVar(&c.correlationID, "correlation-id", os.Getenv("DELETER_CORRELATION_ID"), "ID tying this run to whatever started it, added to every log line, report, event and audit record, default $DELETER_CORRELATION_ID")
+	flags.DurationVar(&c.minAge, "min-age", 0, "Never remove anything younger than this, whatever a company's retention, e.g. 24h, 0 for no minimum")
+	flags.StringVar(&c.journalPath, "journal", "", "Record each removal in this file before it starts and after it finishes, and first finish any an interrupted run left partly done that would still be removed")
+	flags.StringVar(&c.dbDriver, "config-db-driver", "", "Also read company entries from a database: postgres or mysql")
+	flags.StringVar(&c.dbDSN, "config-db-dsn", os.Getenv("DELETER_CONFIG_DB_DSN"), "Data source name for -config-db-driver, default $DELETER_CONFIG_DB_DSN")
+	flags.StringVar(&c.dbQuery, "config-db-query", "SELECT * FROM deleter_company_config", "Query returning one row per company, with columns named after config fields")
+}
+
+// loadConfig reads the config document and, if -config-db-driver is set, merges in the company entries from the
+// database.
+func (c *commonFlags) loadConfig() (pruner.Config, error) {
+	config, err := readConfig(c.configLocation)
+	if err != nil || c.dbDriver == "" {
+		return config, err
+	}
+	companies, err := c.loadDatabase()
+	if err != nil {
+		return pruner.Config{}, err
+	}
+	return pruner.MergeCompanies(config, companies), nil
+}
+
+// loadDatabase reads the company entries from the config database.
+func (c *commonFlags) loadDatabase() ([]pruner.CompanyConfig, error) {
+	var err error
+	if c.db == nil {
+		if c.db, err = sql.Open(c.dbDriver, c.dbDSN); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	companies, err := pruner.LoadSQLCompanies(ctx, c.db, c.dbQuery)
+	if err != nil {
+		return nil, fmt.Errorf("config database: %v", err)
+	}
+	return companies, nil
+}
+
+// setupLogging applies -level and -log-format to the standard logger.
+func (c *commonFlags) setupLogging() {
+	level, err := log.ParseLevel(c.logLevel)
+	if err != nil {
+		log.Fatal("Invalid Logging Level")
+	}
+	log.SetLevel(level)
+	switch c.logFormat {
+	case "text":
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		log.Fatal("Invalid log format, expected text or json")
+	}
+}
+
+// correlationHook adds a correlation_id field to every log line.
+type correlationHook string
+
+func (h correlationHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h correlationHook) Fire(entry *log.Entry) error {
+	entry.Data["correlation_id"] = string(h)
+	return nil
+}
+
+// bases returns the -baseDir values, or the default if there are none.
+func (c *commonFlags) bases() []string {
+	if bases := c.baseDirs.values(); len(bases) > 0 {
+		return bases
+	}
+	return []string{"/tmp/foo"}
+}
+
+// basePruner returns a Pruner for the -baseDir values and config, without any of the other flags applied.
+func (c *commonFlags) basePruner(config pruner.Config) *pruner.Pruner {
+	bases := c.bases()
+	p := pruner.New(bases[0], config)
+	p.BaseDirs = bases[1:]
+	return p
+}
+
+// newPruner sets up logging, reads the config, and returns a Pruner for it.
+func (c *commonFlags) newPruner() *pruner.Pruner {
+	c.setupLogging()
+	config, err := c.loadConfig()
+	if err != nil {
+		// Not much we can do if we can't read the configuration.
+		log.Fatal("Could not open config.", err)
+	}
+	log.Debugln("Config= ", config)
+	p := c.basePruner(config)
+	p.Location, err = time.LoadLocation(c.timezone)
+	if err != nil {
+		log.Fatal("Invalid timezone. ", err)
+	}
+	p.FollowSymlinks = c.followSymlinks
+	p.OneFileSystem = c.oneFileSystem
+	p.MinAge = c.minAge
+	if c.correlationID != "" {
+		p.CorrelationID = c.correlationID
+		log.AddHook(correlationHook(c.correlationID))
+	}
+	if c.fastFS {
+		if p.FS, err = pruner.NewFastFileSystem(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if c.maxMemory != "" {
+		size, err := pruner.ParseSize(c.maxMemory)
+		if err != nil {
+			log.Fatal("Invalid -max-memory.", err)
+		}
+		p.MaxMemory = int64(size)
+		debug.SetMemoryLimit(p.MaxMemory)
+	}
+	if c.journalPath != "" {
+		if p.Journal, err = pruner.OpenJournal(c.journalPath); err != nil {
+			log.Fatal("Could not open journal.", err)
+		}
+	}
+	p.Companies = c.companies.values()
+	p.ExcludeCompanies = c.excludeCompanies.values()
+	for _, company := range p.Companies {
+		found := false
+		for _, base := range p.Bases() {
+			if info, err := os.Stat(filepath.Join(base, company)); err == nil && info.IsDir() {
+				found = true
+			}
+		}
+		if !found {
+			log.Warnf("-company %s has no directory under %s", company, strings.Join(p.Bases(), ", "))
+		}
+	}
+	return p
+}
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// values splits comma-separated values and drops empty ones.
+func (l stringList) values() []string {
+	var values []string
+	for _, value := range l {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
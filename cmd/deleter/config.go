@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+const (
+	defaultConfigPath = "resources/config.json"
+	configLocationEnv = "DELETER_CONFIG"
+	configContentEnv  = "DELETER_CONFIG_JSON"
+)
+
+// readConfig loads and parses the configuration. See readConfigData for where it comes from. Sources whose names
+// end in .yaml, .yml or .toml are read in that format, and everything else as JSON. ${VAR} references are expanded
+// and DELETER_DEFAULT_ and DELETER_COMPANY_ environment variables override what the document says.
+func readConfig(location string) (pruner.Config, error) {
+	data, source, err := readConfigData(location)
+	if err != nil {
+		return pruner.Config{}, err
+	}
+	format := pruner.FormatFromName(source)
+	if data, err = pruner.ExpandEnv(data, format, os.LookupEnv); err != nil {
+		return pruner.Config{}, fmt.Errorf("%s: %v", source, err)
+	}
+	config, err := pruner.ParseConfigFormat(data, format)
+	if err != nil {
+		return pruner.Config{}, fmt.Errorf("%s: %v", source, err)
+	}
+	if err := pruner.ApplyEnvOverrides(&config, os.Environ()); err != nil {
+		return pruner.Config{}, err
+	}
+	return config, nil
+}
+
+// readConfigData reads the configuration document from the first source that is set, in order:
+// the -config flag, the DELETER_CONFIG environment variable (a location, same syntax as the flag),
+// the DELETER_CONFIG_JSON environment variable (the config document itself), and finally resources/config.json.
+// It also returns a name for the source to use in messages.
+func readConfigData(location string) ([]byte, string, error) {
+	location = configLocation(location)
+	switch {
+	case location != "":
+		data, err := readConfigLocation(location)
+		return data, location, err
+	case os.Getenv(configContentEnv) != "":
+		return []byte(os.Getenv(configContentEnv)), configContentEnv, nil
+	default:
+		data, err := ioutil.ReadFile(defaultConfigPath)
+		return data, defaultConfigPath, err
+	}
+}
+
+// configLocation returns the config location in effect: the -config flag, or else DELETER_CONFIG. It is empty when
+// the config comes from DELETER_CONFIG_JSON or the default file.
+func configLocation(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	return os.Getenv(configLocationEnv)
+}
+
+// readConfigLocation reads a config document from a file path, an http(s) URL, a Consul or etcd key (see
+// kvLocation), or stdin when location is "-".
+func readConfigLocation(location string) ([]byte, error) {
+	if location == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	if kv, ok := parseKVLocation(location); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		data, _, err := kv.read(ctx)
+		return data, err
+	}
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		client := http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", location, resp.Status)
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+	return ioutil.ReadFile(location)
+}
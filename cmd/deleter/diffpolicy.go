@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/notify"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// diffPolicyCommand implements "deleter diff-policy -new-config new.json": estimate, per company, how much more or
+// less the next pass would remove under the proposed config than under the current one.
+func diffPolicyCommand(args []string) {
+	flags := flag.NewFlagSet("diff-policy", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	var newConfig string
+	var all bool
+	flags.StringVar(&newConfig, "new-config", "", "Proposed config, in any form -config accepts (required)")
+	flags.BoolVar(&all, "all", false, "List companies the change doesn't affect too")
+	parseFlags(flags, args)
+	if newConfig == "" {
+		log.Fatal("diff-policy needs -new-config")
+	}
+	p := common.newPruner()
+	next, err := readConfig(newConfig)
+	if err == nil && common.dbDriver != "" {
+		var companies []pruner.CompanyConfig
+		if companies, err = common.loadDatabase(); err == nil {
+			next = pruner.MergeCompanies(next, companies)
+		}
+	}
+	if err != nil {
+		log.Fatal("Could not open new config.", err)
+	}
+	for _, change := range pruner.ConfigChanges(p.Config(), next) {
+		log.Info("Config change: ", change)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	impacts, err := p.PolicyImpact(ctx, next)
+	if err != nil {
+		log.Fatal("Could not estimate the change.", err)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "COMPANY\tRETENTION\tDIRS NOW\tDIRS PROPOSED\tBYTES NOW\tBYTES PROPOSED\tCHANGE")
+	var current, proposed pruner.CompanyStats
+	changed := 0
+	for _, impact := range impacts {
+		current.DirsDeleted += impact.Current.DirsDeleted
+		current.BytesFreed += impact.Current.BytesFreed
+		proposed.DirsDeleted += impact.Proposed.DirsDeleted
+		proposed.BytesFreed += impact.Proposed.BytesFreed
+		if impact.Changed() {
+			changed++
+		} else if !all {
+			continue
+		}
+		retention := impact.ProposedRetention
+		if impact.CurrentRetention != impact.ProposedRetention {
+			retention = impact.CurrentRetention + " -> " + impact.ProposedRetention
+		}
+		fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", impact.Company, retention,
+			impact.Current.DirsDeleted, impact.Proposed.DirsDeleted,
+			notify.FormatBytes(impact.Current.BytesFreed), notify.FormatBytes(impact.Proposed.BytesFreed),
+			byteChange(impact.Proposed.BytesFreed-impact.Current.BytesFreed))
+	}
+	fmt.Fprintf(out, "TOTAL (%d of %d changed)\t\t%d\t%d\t%s\t%s\t%s\n", changed, len(impacts),
+		current.DirsDeleted, proposed.DirsDeleted,
+		notify.FormatBytes(current.BytesFreed), notify.FormatBytes(proposed.BytesFreed),
+		byteChange(proposed.BytesFreed-current.BytesFreed))
+}
+
+// byteChange formats a difference in bytes with its sign.
+func byteChange(delta int64) string {
+	switch {
+	case delta > 0:
+		return "+" + notify.FormatBytes(delta)
+	case delta < 0:
+		return "-" + notify.FormatBytes(-delta)
+	}
+	return "none"
+}
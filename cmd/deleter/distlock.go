@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// heldLock is a lock taken in Redis or etcd, which expires unless it is refreshed.
+type heldLock interface {
+	refresh(ctx context.Context) error
+	release(ctx context.Context) error
+}
+
+// lockStore takes locks in Redis or etcd.
+type lockStore interface {
+	acquire(ctx context.Context, key, owner string, ttl time.Duration) (heldLock, bool, error)
+}
+
+// distLocker is a pruner.Locker backed by Redis or etcd. Locks expire after ttl unless refreshed, so an instance
+// that dies mid-pass only holds up its companies until then; a running instance refreshes its locks every ttl/3.
+type distLocker struct {
+	store  lockStore
+	prefix string
+	owner  string
+	ttl    time.Duration
+}
+
+// newDistLocker returns a Locker for redis://host:port/prefix or etcd://host:port/prefix; etcd+https:// connects
+// over TLS. Redis logs in with $REDIS_PASSWORD if it is set.
+func newDistLocker(location string, ttl time.Duration) (pruner.Locker, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := lockOwner()
+	if err != nil {
+		return nil, err
+	}
+	locker := &distLocker{prefix: strings.TrimPrefix(u.Path, "/"), owner: owner, ttl: ttl}
+	if locker.prefix == "" {
+		locker.prefix = "deleter/"
+	} else if !strings.HasSuffix(locker.prefix, "/") {
+		locker.prefix += "/"
+	}
+	switch {
+	case u.Scheme == "redis":
+		locker.store = redisStore{addr: u.Host}
+	case strings.HasPrefix(u.Scheme, "etcd"):
+		kv, ok := parseKVLocation(location)
+		if !ok {
+			return nil, fmt.Errorf("invalid etcd location %s", location)
+		}
+		locker.store = etcdStore{kv}
+	default:
+		return nil, fmt.Errorf("unsupported lock store %s, want redis:// or etcd://", location)
+	}
+	return locker, nil
+}
+
+// lockOwner identifies this process in the locks it holds.
+func lockOwner() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(nonce)), nil
+}
+
+func (l *distLocker) TryLock(ctx context.Context, key string) (context.Context, func(), bool, error) {
+	key = l.prefix + key
+	lock, ok, err := l.store.acquire(ctx, key, l.owner, l.ttl)
+	if err != nil || !ok {
+		return nil, nil, ok, err
+	}
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		refreshed := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+				err := lock.refresh(context.Background())
+				if err == nil {
+					refreshed = time.Now()
+					continue
+				}
+				// A lock that could not be refreshed on two ticks in a row may expire before the next one.
+				if errors.Is(err, pruner.ErrLockLost) || time.Since(refreshed)+l.ttl/3 >= l.ttl {
+					log.WithField("lock", key).Errorln("Lost lock, stopping.", err)
+					cancel(pruner.ErrLockLost)
+					return
+				}
+				log.WithField("lock", key).Warnln("Could not refresh lock, trying again.", err)
+			case <-done:
+				return
+			}
+		}
+	}()
+	unlock := func() {
+		close(done)
+		cancel(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := lock.release(ctx); err != nil {
+			log.WithField("lock", key).Errorln("Could not release lock, it will expire by itself.", err)
+		}
+	}
+	return lockCtx, unlock, true, nil
+}
+
+// redisStore takes locks with SET NX, and only refreshes or releases a key while it still holds our owner string.
+type redisStore struct {
+	addr string
+}
+
+const (
+	redisRefresh = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisRelease = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+type redisLock struct {
+	store redisStore
+	key   string
+	owner string
+	ttl   time.Duration
+}
+
+func (s redisStore) acquire(ctx context.Context, key, owner string, ttl time.Duration) (heldLock, bool, error) {
+	reply, err := s.do(ctx, "SET", key, owner, "NX", "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	return redisLock{s, key, owner, ttl}, true, nil
+}
+
+func (l redisLock) refresh(ctx context.Context) error {
+	reply, err := l.store.do(ctx, "EVAL", redisRefresh, "1", l.key, l.owner, strconv.FormatInt(int64(l.ttl/time.Millisecond), 10))
+	if err == nil && (reply == nil || *reply != "1") {
+		err = fmt.Errorf("%s: %w", l.key, pruner.ErrLockLost)
+	}
+	return err
+}
+
+func (l redisLock) release(ctx context.Context) error {
+	_, err := l.store.do(ctx, "EVAL", redisRelease, "1", l.key, l.owner)
+	return err
+}
+
+// do runs one command on a fresh connection and returns its reply, nil for a null reply.
+func (s redisStore) do(ctx context.Context, args ...string) (*string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	reader := bufio.NewReader(conn)
+	if password := os.Getenv("REDIS_PASSWORD"); password != "" {
+		if _, err := redisCommand(conn, reader, "AUTH", password); err != nil {
+			return nil, err
+		}
+	}
+	return redisCommand(conn, reader, args...)
+}
+
+func redisCommand(w io.Writer, r *bufio.Reader, args ...string) (*string, error) {
+	command := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(w, command); err != nil {
+		return nil, err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		value := line[1:]
+		return &value, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		value := string(data[:n])
+		return &value, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// etcdStore takes locks by creating the key under a lease, which expires by itself unless kept alive.
+type etcdStore struct {
+	kv kvLocation
+}
+
+type etcdLock struct {
+	store etcdStore
+	lease string
+}
+
+func (s etcdStore) acquire(ctx context.Context, key, owner string, ttl time.Duration) (heldLock, bool, error) {
+	var grant struct {
+		ID string `json:"ID"`
+	}
+	if err := s.kv.etcdPost(ctx, "/v3/lease/grant", map[string]interface{}{"TTL": int64(ttl / time.Second)}, &grant); err != nil {
+		return nil, false, err
+	}
+	lock := etcdLock{s, grant.ID}
+	var txn struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	request := map[string]interface{}{
+		"compare": []interface{}{map[string]interface{}{"key": []byte(key), "target": "CREATE", "create_revision": "0"}},
+		"success": []interface{}{map[string]interface{}{"request_put": map[string]interface{}{"key": []byte(key), "value": []byte(owner), "lease": grant.ID}}},
+	}
+	err := s.kv.etcdPost(ctx, "/v3/kv/txn", request, &txn)
+	if err != nil || !txn.Succeeded {
+		lock.release(ctx)
+		return nil, false, err
+	}
+	return lock, true, nil
+}
+
+func (l etcdLock) refresh(ctx context.Context) error {
+	var response struct {
+		Result struct {
+			TTL string `json:"TTL"`
+		} `json:"result"`
+	}
+	err := l.store.kv.etcdPost(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": l.lease}, &response)
+	if err == nil && (response.Result.TTL == "" || response.Result.TTL == "0") {
+		err = fmt.Errorf("lease %s has expired: %w", l.lease, pruner.ErrLockLost)
+	}
+	return err
+}
+
+func (l etcdLock) release(ctx context.Context) error {
+	var response struct{}
+	return l.store.kv.etcdPost(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": l.lease}, &response)
+}
+
+// shardFilter returns a pruner.Shard that keeps the companies the ring over members assigns to self, or to the
+// hostname if self is empty.
+func shardFilter(members stringList, self string) func(string) bool {
+	names := members.values()
+	if self == "" {
+		var err error
+		if self, err = os.Hostname(); err != nil {
+			log.Fatal("Could not get hostname for -shard-id.", err)
+		}
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == self
+	}
+	if !found {
+		log.Fatalf("-shard-id %s is not one of -shard-members", self)
+	}
+	ring := pruner.NewRing(names)
+	log.Infof("Pruning the companies of shard %s of %d", self, len(names))
+	return func(company string) bool { return ring.Owner(company) == self }
+}
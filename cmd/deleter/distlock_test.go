@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+func TestRedisCommand(t *testing.T) {
+	for _, test := range []struct {
+		reply string
+		want  string
+		null  bool
+		err   string
+	}{
+		{reply: "+OK\r\n", want: "OK"},
+		{reply: ":1\r\n", want: "1"},
+		{reply: "$5\r\nowner\r\n", want: "owner"},
+		{reply: "$0\r\n\r\n", want: ""},
+		{reply: "$-1\r\n", null: true},
+		{reply: "-ERR unknown command\r\n", err: "redis: ERR unknown command"},
+		{reply: "\r\n", err: "redis: empty reply"},
+		{reply: "*1\r\n", err: "unexpected reply"},
+		// The connection closes partway through a reply.
+		{reply: "$5\r\now", err: "EOF"},
+		{reply: "", err: "EOF"},
+	} {
+		var sent strings.Builder
+		reply, err := redisCommand(&sent, bufio.NewReader(strings.NewReader(test.reply)), "GET", "deleter/company/acme")
+		if sent.String() != "*2\r\n$3\r\nGET\r\n$20\r\ndeleter/company/acme\r\n" {
+			t.Errorf("sent %q", sent.String())
+		}
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got %v, want an error containing %q", test.reply, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", test.reply, err)
+		case test.null:
+			if reply != nil {
+				t.Errorf("%q: got %q, want a null reply", test.reply, *reply)
+			}
+		case reply == nil || *reply != test.want:
+			t.Errorf("%q: got %v, want %q", test.reply, reply, test.want)
+		}
+	}
+}
+
+// fakeRedis answers the commands sent to it with replies in turn. The commands are recorded with their arguments
+// joined by spaces.
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	replies  []string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, replies ...string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{listener: listener, replies: replies}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			r.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return r
+}
+
+// serve answers the commands on conn until it is closed.
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(reader, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(reader, "$%d\r\n", &size); err != nil {
+				return
+			}
+			arg := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, arg); err != nil {
+				return
+			}
+			args[i] = string(arg[:size])
+		}
+		r.mu.Lock()
+		r.commands = append(r.commands, strings.Join(args, " "))
+		if len(r.replies) == 0 {
+			r.mu.Unlock()
+			return
+		}
+		io.WriteString(conn, r.replies[0])
+		r.replies = r.replies[1:]
+		r.mu.Unlock()
+	}
+}
+
+func TestRedisAcquire(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD", "")
+	// The first SET finds the key taken, the second takes it.
+	r := newFakeRedis(t, "$-1\r\n", "+OK\r\n")
+	store := redisStore{addr: r.listener.Addr().String()}
+	if _, ok, err := store.acquire(context.Background(), "deleter/company/acme", "a", time.Minute); ok || err != nil {
+		t.Errorf("acquire of a held lock got %v, %v", ok, err)
+	}
+	if _, ok, err := store.acquire(context.Background(), "deleter/company/acme", "b", time.Minute); !ok || err != nil {
+		t.Errorf("acquire of a free lock got %v, %v", ok, err)
+	}
+	want := "[SET deleter/company/acme a NX PX 60000 SET deleter/company/acme b NX PX 60000]"
+	if fmt.Sprint(r.commands) != want {
+		t.Errorf("sent %v, want %v", r.commands, want)
+	}
+}
+
+func TestRedisAcquireError(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD", "")
+	r := newFakeRedis(t, "-READONLY You can't write against a read only replica.\r\n")
+	_, ok, err := redisStore{addr: r.listener.Addr().String()}.acquire(context.Background(), "deleter/company/acme", "a", time.Minute)
+	if ok || err == nil || !strings.Contains(err.Error(), "READONLY") {
+		t.Errorf("got %v, %v, want the error reply", ok, err)
+	}
+}
+
+func TestRedisRefresh(t *testing.T) {
+	t.Setenv("REDIS_PASSWORD", "secret")
+	// The first refresh extends the lock. By the second the key has expired or belongs to someone else, so the
+	// script returns 0.
+	r := newFakeRedis(t, "+OK\r\n", ":1\r\n", "+OK\r\n", ":0\r\n")
+	lock := redisLock{redisStore{addr: r.listener.Addr().String()}, "deleter/company/acme", "a", time.Minute}
+	if err := lock.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.refresh(context.Background()); !errors.Is(err, pruner.ErrLockLost) {
+		t.Errorf("got %v, want %v", err, pruner.ErrLockLost)
+	}
+	if len(r.commands) != 4 || r.commands[0] != "AUTH secret" || !strings.HasPrefix(r.commands[1], "EVAL ") {
+		t.Errorf("sent %q, want AUTH before each EVAL", r.commands)
+	}
+}
+
+// fakeEtcd answers the lease and txn requests of etcdStore. A txn fails if its key is in held, and is refused if
+// its lease isn't in leases, as etcd does for a lease that has been revoked. With revokeAll, leases are revoked as
+// soon as they are granted.
+type fakeEtcd struct {
+	mu        sync.Mutex
+	held      map[string]bool
+	leases    map[string]bool
+	revokeAll bool
+	granted   int
+	revoked   []string
+}
+
+func (e *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var request struct {
+		ID      string `json:"ID"`
+		Success []struct {
+			Put struct {
+				Key   []byte `json:"key"`
+				Lease string `json:"lease"`
+			} `json:"request_put"`
+		} `json:"success"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		e.granted++
+		id := fmt.Sprint(e.granted)
+		e.leases[id] = !e.revokeAll
+		fmt.Fprintf(w, `{"ID": %q, "TTL": "30"}`, id)
+	case "/v3/kv/txn":
+		put := request.Success[0].Put
+		if !e.leases[put.Lease] {
+			http.Error(w, `{"error": "etcdserver: requested lease not found", "code": 5}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"succeeded": %v}`, !e.held[string(put.Key)])
+	case "/v3/lease/keepalive":
+		if e.leases[request.ID] {
+			fmt.Fprintf(w, `{"result": {"ID": %q, "TTL": "30"}}`, request.ID)
+		} else {
+			fmt.Fprintf(w, `{"result": {"ID": %q}}`, request.ID)
+		}
+	case "/v3/lease/revoke":
+		delete(e.leases, request.ID)
+		e.revoked = append(e.revoked, request.ID)
+		fmt.Fprint(w, `{}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeEtcd(t *testing.T, held ...string) (*fakeEtcd, etcdStore) {
+	etcd := &fakeEtcd{held: map[string]bool{}, leases: map[string]bool{}}
+	for _, key := range held {
+		etcd.held[key] = true
+	}
+	srv := httptest.NewServer(etcd)
+	t.Cleanup(srv.Close)
+	return etcd, etcdStore{testLocation(t, "etcd", srv, "deleter/")}
+}
+
+func TestEtcdAcquire(t *testing.T) {
+	etcd, store := newFakeEtcd(t, "deleter/company/acme")
+	if _, ok, err := store.acquire(context.Background(), "deleter/company/acme", "a", time.Minute); ok || err != nil {
+		t.Errorf("acquire of a held lock got %v, %v", ok, err)
+	}
+	// The lease granted for the failed txn isn't left to expire by itself.
+	if fmt.Sprint(etcd.revoked) != "[1]" {
+		t.Errorf("revoked leases %v, want [1]", etcd.revoked)
+	}
+	lock, ok, err := store.acquire(context.Background(), "deleter/company/other", "a", time.Minute)
+	if !ok || err != nil {
+		t.Fatalf("acquire of a free lock got %v, %v", ok, err)
+	}
+	if err := lock.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(etcd.revoked) != "[1 2]" {
+		t.Errorf("revoked leases %v, want [1 2]", etcd.revoked)
+	}
+}
+
+func TestEtcdLeaseRevoked(t *testing.T) {
+	etcd, store := newFakeEtcd(t)
+	lock, ok, err := store.acquire(context.Background(), "deleter/company/acme", "a", time.Minute)
+	if !ok || err != nil {
+		t.Fatalf("acquire got %v, %v", ok, err)
+	}
+	// The lease is revoked behind our back, e.g. by an operator or after it expired during a partition.
+	etcd.mu.Lock()
+	etcd.leases = map[string]bool{}
+	etcd.mu.Unlock()
+	if err := lock.refresh(context.Background()); !errors.Is(err, pruner.ErrLockLost) {
+		t.Errorf("refresh got %v, want %v", err, pruner.ErrLockLost)
+	}
+}
+
+func TestEtcdAcquireTxnRefused(t *testing.T) {
+	etcd, store := newFakeEtcd(t)
+	// The lease is gone by the time the txn uses it.
+	etcd.revokeAll = true
+	_, ok, err := store.acquire(context.Background(), "deleter/company/acme", "a", time.Minute)
+	if ok || err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v, %v, want the txn's error", ok, err)
+	}
+}
+
+func TestShardFilter(t *testing.T) {
+	members := stringList{"deleter-0,deleter-1", "deleter-2"}
+	filters := map[string]func(string) bool{}
+	for _, self := range members.values() {
+		filters[self] = shardFilter(members, self)
+	}
+	owned := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		company := fmt.Sprintf("company-%d", i)
+		var owners []string
+		for self, filter := range filters {
+			if filter(company) {
+				owners = append(owners, self)
+			}
+		}
+		if len(owners) != 1 {
+			t.Fatalf("%s is owned by %q, want exactly one member", company, owners)
+		}
+		owned[owners[0]]++
+	}
+	// Each member gets roughly a third of the companies.
+	for self, n := range owned {
+		if n < 700 || n > 1300 {
+			t.Errorf("%s owns %d of 3000 companies", self, n)
+		}
+	}
+	if len(owned) != 3 {
+		t.Errorf("companies are owned by %v, want all three members", owned)
+	}
+}
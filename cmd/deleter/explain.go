@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// explainCommand implements "deleter explain [path...]". Without paths it lists every company directory with the
+// config entry it gets, its retention, and the cutoff a pass run now would use. With paths it says whether a pass
+// would remove each of them, and why.
+func explainCommand(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	var asOf string
+	flags.StringVar(&asOf, "as-of", "", "Explain a pass run at this time (2006-01-02 or RFC 3339) instead of now")
+	parseFlags(flags, args)
+	p := common.newPruner()
+	if asOf != "" {
+		asOfTime, err := parseDate(asOf)
+		if err != nil {
+			log.Fatal("Invalid -as-of time. ", err)
+		}
+		p.Clock = pruner.FixedClock(asOfTime)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer out.Flush()
+	if flags.NArg() > 0 {
+		explainPaths(out, p, flags.Args())
+		return
+	}
+	explanations, err := p.Explain()
+	if err != nil {
+		log.Fatal("Could not open base directory.", err)
+	}
+	fmt.Fprintln(out, "COMPANY\tCONFIG\tRETENTION\tDELETE BEFORE\tNOTES")
+	for _, e := range explanations {
+		source := "default"
+		if e.Explicit {
+			source = "explicit"
+			if e.Config.Id != e.Company {
+				source = e.Config.Id
+			}
+			if e.Config.Group != "" {
+				source += " (group " + e.Config.Group + ")"
+			}
+		}
+		company := filepath.Join(e.BaseDir, e.Company)
+		if e.Err != nil {
+			fmt.Fprintf(out, "%s\t%s\t%s\t-\terror: %v\n", company, source, e.Config.Retention, e.Err)
+			continue
+		}
+		var notes []string
+		if e.Config.OnLegalHold() {
+			notes = append(notes, "legal hold")
+		}
+		if e.Config.Exempt() {
+			notes = append(notes, "exempt, retention never")
+		}
+		if e.Floored {
+			notes = append(notes, "raised to minKeepDays "+e.Config.MinKeepDays)
+		}
+		if e.MinAged {
+			notes = append(notes, "raised to -min-age "+p.MinAge.String())
+		}
+		if e.Config.MinKeepCount > 0 {
+			notes = append(notes, fmt.Sprintf("keeps newest %d", e.Config.MinKeepCount))
+		}
+		if e.Config.HasSubtenants() {
+			notes = append(notes, fmt.Sprintf("sub-tenants, %d with their own retention", len(e.Config.SubtenantRetention)))
+		}
+		if len(e.Config.Categories) > 0 {
+			notes = append(notes, fmt.Sprintf("%d categories", len(e.Config.Categories)))
+		}
+		if e.Config.Mode == pruner.ModeMtime {
+			notes = append(notes, "mtime mode")
+		}
+		if e.Config.MinDepth > 0 {
+			notes = append(notes, fmt.Sprintf("removes from depth %d", e.Config.MinDepth))
+		}
+		if e.Config.Workers > 1 {
+			notes = append(notes, fmt.Sprintf("%d workers", e.Config.Workers))
+		}
+		cutoff := "never"
+		if !e.Cutoff.IsZero() {
+			cutoff = e.Cutoff.Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", company, source, e.Retention, cutoff, strings.Join(notes, ", "))
+	}
+}
+
+// explainPaths prints whether a pass would remove each path.
+func explainPaths(out *tabwriter.Writer, p *pruner.Pruner, paths []string) {
+	fmt.Fprintln(out, "PATH\tDATE\tDELETE BEFORE\tREMOVE\tREASON")
+	for _, path := range paths {
+		e, err := p.ExplainPath(path)
+		if err != nil {
+			fmt.Fprintf(out, "%s\t-\t-\t-\terror: %v\n", path, err)
+			continue
+		}
+		date, cutoff := "-", "-"
+		if !e.Date.IsZero() {
+			date = e.Date.Format(time.RFC3339)
+		}
+		if !e.Cutoff.IsZero() {
+			cutoff = e.Cutoff.Format(time.RFC3339)
+		}
+		remove := "no"
+		if e.Remove {
+			remove = "yes"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", path, date, cutoff, remove, e.Reason)
+	}
+}
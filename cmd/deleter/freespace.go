@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// freeSpaceCommand implements "deleter free-space": remove the oldest data across companies, regardless of
+// retention but within each company's minimum, until each base directory's filesystem has enough free space.
+func freeSpaceCommand(args []string) {
+	flags := flag.NewFlagSet("free-space", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	var targetSpec, auditPath string
+	var dryRun bool
+	flags.StringVar(&targetSpec, "target", "", "Free space to reach, e.g. 500GB, 1.5TiB or 10% (required)")
+	flags.StringVar(&auditPath, "audit-log", "", "Append a hash-chained record of every removal to this file")
+	flags.BoolVar(&dryRun, "dry-run", false, "Log what would be removed without removing anything")
+	parseFlags(flags, args)
+	if targetSpec == "" {
+		log.Fatal("free-space needs -target")
+	}
+	target, err := pruner.ParseFreeSpaceTarget(targetSpec)
+	if err != nil {
+		log.Fatal("Invalid -target. ", err)
+	}
+
+	p := common.newPruner()
+	p.DryRun = dryRun
+	if auditPath != "" {
+		p.Audit, err = pruner.OpenAuditLog(auditPath)
+		if err != nil {
+			log.Fatal("Could not open audit log.", err)
+		}
+		defer p.Audit.Close()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	summary, err := p.FreeSpace(ctx, target)
+	log.WithField("run_id", summary.RunID).Infoln(summary)
+	for _, base := range p.Bases() {
+		if free, total, spaceErr := p.FS.DiskSpace(base); spaceErr == nil {
+			log.Infof("%s: %d of %d bytes free", base, free, total)
+		}
+	}
+	switch {
+	case err == pruner.ErrTargetNotMet:
+		log.Errorln(err)
+		if p.Audit != nil {
+			p.Audit.Close()
+		}
+		stop()
+		os.Exit(exitPartial)
+	case err != nil:
+		log.Fatal("Could not free space. ", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/testtree"
+)
+
+// genTreeCommand implements "deleter gen-tree -baseDir dir": write a synthetic tree to disk, e.g. to benchmark
+// passes against.
+func genTreeCommand(args []string) {
+	flags := flag.NewFlagSet("gen-tree", flag.ContinueOnError)
+	var baseDir, end string
+	var spec testtree.Spec
+	flags.StringVar(&baseDir, "baseDir", "", "Directory to write the tree under (required)")
+	flags.IntVar(&spec.Companies, "companies", 5, "Number of companies")
+	flags.IntVar(&spec.Devices, "devices", 4, "Number of devices per company")
+	flags.IntVar(&spec.Depth, "depth", 5, "Date levels below each device, 1 for years to 5 for minutes")
+	flags.IntVar(&spec.Fanout, "fanout", 3, "Directories per date level")
+	flags.IntVar(&spec.FilesPerDir, "files", 3, "Files per deepest directory")
+	flags.Int64Var(&spec.FileSize, "file-size", 0, "Size of each file in bytes, or the smallest with -max-file-size")
+	flags.Int64Var(&spec.MaxFileSize, "max-file-size", 0, "Largest file size in bytes, sizes are random up to it")
+	flags.Int64Var(&spec.Seed, "seed", 1, "Seed for random file sizes")
+	flags.StringVar(&end, "end", "", "Latest date in the tree, as YYYY-MM-DD (default today)")
+	parseFlags(flags, args)
+	if baseDir == "" {
+		log.Fatal("gen-tree needs -baseDir")
+	}
+	spec.End = time.Now().UTC()
+	if end != "" {
+		t, err := time.Parse("2006-01-02", end)
+		if err != nil {
+			log.Fatal("Bad -end.", err)
+		}
+		spec.End = t.Add(24*time.Hour - time.Second)
+	}
+	stats, err := testtree.Generate(testtree.Disk{}, baseDir, spec)
+	if err != nil {
+		log.Fatal("Could not generate tree.", err)
+	}
+	fmt.Printf("Wrote %d directories and %d files, %d bytes, under %s\n", stats.Dirs, stats.Files, stats.Bytes, baseDir)
+}
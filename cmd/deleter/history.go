@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/notify"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// historyCommand implements "deleter history -history file": list the passes recorded by "run -history", most
+// recent first, e.g. to find when a company's data was last pruned.
+func historyCommand(args []string) {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	var path, since, until string
+	var query pruner.HistoryQuery
+	var asJSON bool
+	flags.StringVar(&path, "history", "", "History file written by run -history (required)")
+	flags.StringVar(&query.Company, "company", "", "Only list passes over this company id, with its stats alone")
+	flags.BoolVar(&query.Removed, "removed", false, "Only list passes that removed something")
+	flags.StringVar(&since, "since", "", "Only list passes started on or after this date (2006-01-02 or RFC 3339)")
+	flags.StringVar(&until, "until", "", "Only list passes started before this date (2006-01-02 or RFC 3339)")
+	flags.IntVar(&query.Limit, "limit", 20, "Most passes to list, 0 for all")
+	flags.BoolVar(&asJSON, "json", false, "Print the full summaries as JSON")
+	parseFlags(flags, args)
+	if path == "" {
+		log.Fatal("history needs -history")
+	}
+	for _, date := range []struct {
+		value string
+		t     *time.Time
+		name  string
+	}{{since, &query.Since, "-since"}, {until, &query.Until, "-until"}} {
+		if date.value == "" {
+			continue
+		}
+		var err error
+		if *date.t, err = parseDate(date.value); err != nil {
+			log.Fatalf("Invalid %s date. %v", date.name, err)
+		}
+	}
+	summaries, err := pruner.ReadHistory(path, query)
+	if err != nil {
+		log.Fatal("Could not read history.", err)
+	}
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(summaries); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "START\tRUN ID\tDURATION\tCOMPLETED\tDELETED\tTRASHED\tFREED\tERRORS")
+	for _, summary := range summaries {
+		totals := summary.Totals()
+		fmt.Fprintf(out, "%s\t%s\t%s\t%d/%d\t%d\t%d\t%s\t%d\n", summary.Start.Format(time.RFC3339), summary.RunID,
+			summary.End.Sub(summary.Start).Round(time.Millisecond), summary.CompletedCount(), len(summary.Companies),
+			totals.DirsDeleted+totals.FilesDeleted, totals.DirsTrashed, notify.FormatBytes(totals.BytesFreed), totals.Errors)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes, as ionice(1) numbers them.
+const (
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+)
+
+// parseIONice parses an -ionice value: "idle", or "best-effort" optionally followed by a level from 0 (highest) to
+// 7 (lowest) as in "best-effort:7".
+func parseIONice(value string) (class int, level int, err error) {
+	name, levelText := value, ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		name, levelText = value[:i], value[i+1:]
+	}
+	switch name {
+	case "idle":
+		if levelText != "" {
+			return 0, 0, fmt.Errorf("the idle class has no levels")
+		}
+		return ioClassIdle, 0, nil
+	case "best-effort":
+		if levelText == "" {
+			return ioClassBestEffort, 4, nil
+		}
+		level, err := strconv.Atoi(levelText)
+		if err != nil || level < 0 || level > 7 {
+			return 0, 0, fmt.Errorf("best-effort level %q is not between 0 and 7", levelText)
+		}
+		return ioClassBestEffort, level, nil
+	}
+	return 0, 0, fmt.Errorf("unknown I/O class %q, want idle or best-effort[:level]", name)
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// setIONice lowers the I/O priority of every thread of the process, as ionice(1) would. Threads started later
+// inherit the priority of the thread that starts them.
+func setIONice(class int, level int) error {
+	tasks, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	prio := uintptr(class<<ioprioClassShift | level)
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func setIONice(class int, level int) error {
+	return errors.New("-ionice is only supported on Linux")
+}
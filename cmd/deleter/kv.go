@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// kvLocation is a config document kept under a key in Consul or etcd, written as consul://host:port/key or
+// etcd://host:port/key. Adding +https to the scheme, as in consul+https://, connects over TLS. Consul requests carry
+// $CONSUL_HTTP_TOKEN if it is set.
+type kvLocation struct {
+	store   string
+	baseURL string
+	key     string
+}
+
+func parseKVLocation(location string) (kvLocation, bool) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return kvLocation{}, false
+	}
+	store, secure := strings.TrimSuffix(u.Scheme, "+https"), strings.HasSuffix(u.Scheme, "+https")
+	if store != "consul" && store != "etcd" {
+		return kvLocation{}, false
+	}
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	return kvLocation{store: store, baseURL: scheme + "://" + u.Host, key: strings.TrimPrefix(u.Path, "/")}, true
+}
+
+// kvClient has no overall timeout since watches block for minutes; requests are bounded by their contexts instead.
+var kvClient = &http.Client{}
+
+// read returns the document and the store's index or revision for it, to pass to wait.
+func (l kvLocation) read(ctx context.Context) ([]byte, uint64, error) {
+	if l.store == "consul" {
+		body, index, err := l.consulGet(ctx, "")
+		if err != nil {
+			return nil, 0, err
+		}
+		defer body.Close()
+		data, err := ioutil.ReadAll(body)
+		// Consul's indexes start at 1; a 0 would make every wait return at once.
+		if index == 0 {
+			index = 1
+		}
+		return data, index, err
+	}
+	var response struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		Kvs []struct {
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := l.etcdPost(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(l.key)}, &response); err != nil {
+		return nil, 0, err
+	}
+	if len(response.Kvs) == 0 {
+		return nil, 0, fmt.Errorf("etcd key %s does not exist", l.key)
+	}
+	revision, _ := strconv.ParseUint(response.Header.Revision, 10, 64)
+	return response.Kvs[0].Value, revision, nil
+}
+
+// wait blocks until the key changes after index, or ctx is done.
+func (l kvLocation) wait(ctx context.Context, index uint64) error {
+	if l.store == "consul" {
+		for {
+			body, newIndex, err := l.consulGet(ctx, fmt.Sprintf("&index=%d&wait=5m", index))
+			if err != nil {
+				return err
+			}
+			body.Close()
+			// An index lower than before means Consul's was reset, e.g. by restoring a snapshot, and the key has to be
+			// read again to know where it stands.
+			if newIndex != index {
+				return nil
+			}
+		}
+	}
+	request := map[string]interface{}{
+		// []byte fields encode as base64, which is how the gateway expects keys.
+		"create_request": map[string]interface{}{"key": []byte(l.key), "start_revision": strconv.FormatUint(index+1, 10)},
+	}
+	body, err := l.etcdStream(ctx, "/v3/watch", request)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	decoder := json.NewDecoder(body)
+	for {
+		var message struct {
+			Result struct {
+				Events []json.RawMessage `json:"events"`
+			} `json:"result"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("etcd watch: %v", err)
+		}
+		if len(message.Result.Events) > 0 {
+			return nil
+		}
+	}
+}
+
+func (l kvLocation) consulGet(ctx context.Context, query string) (io.ReadCloser, uint64, error) {
+	req, err := http.NewRequest("GET", l.baseURL+"/v1/kv/"+l.key+"?raw"+query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	resp, err := kvClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("consul key %s: %s", l.key, resp.Status)
+	}
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return resp.Body, index, nil
+}
+
+func (l kvLocation) etcdPost(ctx context.Context, path string, request interface{}, response interface{}) error {
+	body, err := l.etcdStream(ctx, path, request)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(response)
+}
+
+// etcdStream posts request to etcd's JSON gateway and returns the response body.
+func (l kvLocation) etcdStream(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", l.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := kvClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd %s: %s", path, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// watchConfig reloads the config each time its key changes, until ctx is done. Changes apply from the next pass.
+func watchConfig(ctx context.Context, p *pruner.Pruner, load func() (pruner.Config, error), location kvLocation) {
+	go func() {
+		for ctx.Err() == nil {
+			_, index, err := location.read(ctx)
+			if err == nil {
+				err = location.wait(ctx, index)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Errorln("Could not watch config, retrying.", err)
+				select {
+				case <-time.After(10 * time.Second):
+				case <-ctx.Done():
+				}
+				continue
+			}
+			reloadConfig(p, load)
+		}
+	}()
+}
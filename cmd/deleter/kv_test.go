@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLocation returns the kvLocation of key in store, served by srv.
+func testLocation(t *testing.T, store string, srv *httptest.Server, key string) kvLocation {
+	location, ok := parseKVLocation(store + "://" + strings.TrimPrefix(srv.URL, "http://") + "/" + key)
+	if !ok {
+		t.Fatalf("could not parse a %s location for %s", store, srv.URL)
+	}
+	return location
+}
+
+func TestParseKVLocation(t *testing.T) {
+	for _, test := range []struct {
+		location string
+		want     kvLocation
+		ok       bool
+	}{
+		{"consul://localhost:8500/deleter/config", kvLocation{store: "consul", baseURL: "http://localhost:8500", key: "deleter/config"}, true},
+		{"etcd+https://etcd:2379/deleter", kvLocation{store: "etcd", baseURL: "https://etcd:2379", key: "deleter"}, true},
+		{"https://example.com/config.json", kvLocation{}, false},
+		{"config.json", kvLocation{}, false},
+	} {
+		got, ok := parseKVLocation(test.location)
+		if ok != test.ok || got != test.want {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", test.location, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// consulServer serves the key deleter/config, answering each request with the next of indexes as X-Consul-Index.
+// Blocking queries, which carry an index, are recorded in queries.
+type consulServer struct {
+	mu      sync.Mutex
+	indexes []string
+	queries []string
+}
+
+func (c *consulServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.URL.Path != "/v1/kv/deleter/config" || r.Header.Get("X-Consul-Token") != "secret" {
+		http.NotFound(w, r)
+		return
+	}
+	if index := r.URL.Query().Get("index"); index != "" {
+		c.queries = append(c.queries, index)
+	}
+	if len(c.indexes) == 0 {
+		http.Error(w, "no more answers", http.StatusInternalServerError)
+		return
+	}
+	if c.indexes[0] != "" {
+		w.Header().Set("X-Consul-Index", c.indexes[0])
+	}
+	c.indexes = c.indexes[1:]
+	fmt.Fprint(w, `{"default": {"retentionDays": "30"}}`)
+}
+
+func TestConsulReadAndWait(t *testing.T) {
+	t.Setenv("CONSUL_HTTP_TOKEN", "secret")
+	// The first wait times out with the index unchanged, the second sees it move on.
+	consul := &consulServer{indexes: []string{"7", "7", "9"}}
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+	location := testLocation(t, "consul", srv, "deleter/config")
+	data, index, err := location.read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 7 || !strings.Contains(string(data), "retentionDays") {
+		t.Errorf("read %q at index %d, want the document at index 7", data, index)
+	}
+	if err := location.wait(context.Background(), index); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(consul.queries) != "[7 7]" {
+		t.Errorf("blocking queries were at indexes %v, want [7 7]", consul.queries)
+	}
+}
+
+func TestConsulWaitReturnsWhenTheIndexIsReset(t *testing.T) {
+	t.Setenv("CONSUL_HTTP_TOKEN", "secret")
+	srv := httptest.NewServer(&consulServer{indexes: []string{"3"}})
+	defer srv.Close()
+	if err := testLocation(t, "consul", srv, "deleter/config").wait(context.Background(), 120); err != nil {
+		t.Errorf("wait after the index went back: %v", err)
+	}
+}
+
+func TestConsulReadWithoutAnIndex(t *testing.T) {
+	t.Setenv("CONSUL_HTTP_TOKEN", "secret")
+	srv := httptest.NewServer(&consulServer{indexes: []string{""}})
+	defer srv.Close()
+	_, index, err := testLocation(t, "consul", srv, "deleter/config").read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 1 {
+		t.Errorf("got index %d, want 1 so that waits block", index)
+	}
+}
+
+func TestConsulErrors(t *testing.T) {
+	// Without the token every request is refused.
+	t.Setenv("CONSUL_HTTP_TOKEN", "")
+	srv := httptest.NewServer(&consulServer{indexes: []string{"1", "1"}})
+	defer srv.Close()
+	location := testLocation(t, "consul", srv, "deleter/config")
+	if _, _, err := location.read(context.Background()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("read got %v, want a 404", err)
+	}
+	if err := location.wait(context.Background(), 1); err == nil {
+		t.Error("wait got no error")
+	}
+}
+
+// etcdServer answers range requests for key with value at revision, and watch requests with the messages in
+// watches, one stream per request.
+type etcdServer struct {
+	key, value string
+	revision   int
+	mu         sync.Mutex
+	watches    [][]string
+	starts     []string
+}
+
+func (e *etcdServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var key []byte
+		json.Unmarshal(request["key"], &key)
+		response := map[string]interface{}{"header": map[string]string{"revision": fmt.Sprint(e.revision)}}
+		if string(key) == e.key {
+			response["kvs"] = []map[string][]byte{{"key": key, "value": []byte(e.value)}}
+		}
+		json.NewEncoder(w).Encode(response)
+	case "/v3/watch":
+		var create struct {
+			Key           []byte `json:"key"`
+			StartRevision string `json:"start_revision"`
+		}
+		json.Unmarshal(request["create_request"], &create)
+		e.mu.Lock()
+		e.starts = append(e.starts, create.StartRevision)
+		var messages []string
+		if len(e.watches) > 0 {
+			messages, e.watches = e.watches[0], e.watches[1:]
+		}
+		e.mu.Unlock()
+		for _, message := range messages {
+			fmt.Fprintln(w, message)
+			w.(http.Flusher).Flush()
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestEtcdReadAndWait(t *testing.T) {
+	etcd := &etcdServer{key: "deleter", value: `{"default": {"retentionDays": "30"}}`, revision: 41, watches: [][]string{{
+		`{"result": {"header": {"revision": "41"}, "created": true}}`,
+		`{"result": {"header": {"revision": "42"}, "events": [{"kv": {"key": "ZGVsZXRlcg=="}}]}}`,
+	}}}
+	srv := httptest.NewServer(etcd)
+	defer srv.Close()
+	location := testLocation(t, "etcd", srv, "deleter")
+	data, revision, err := location.read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != etcd.value || revision != 41 {
+		t.Errorf("read %q at revision %d, want %q at 41", data, revision, etcd.value)
+	}
+	if err := location.wait(context.Background(), revision); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(etcd.starts) != "[42]" {
+		t.Errorf("watches started at revisions %v, want [42]", etcd.starts)
+	}
+}
+
+func TestEtcdReadMissingKey(t *testing.T) {
+	srv := httptest.NewServer(&etcdServer{key: "other", revision: 3})
+	defer srv.Close()
+	if _, _, err := testLocation(t, "etcd", srv, "deleter").read(context.Background()); err == nil {
+		t.Error("read of a missing key got no error")
+	}
+}
+
+func TestEtcdWaitStreamClosedPartway(t *testing.T) {
+	srv := httptest.NewServer(&etcdServer{key: "deleter", watches: [][]string{
+		// The watch is created, then the stream ends before any change.
+		{`{"result": {"header": {"revision": "5"}, "created": true}}`},
+		// The stream ends in the middle of a message.
+		{`{"result": {"header": {"revision": "5"}, "events": [`},
+	}})
+	defer srv.Close()
+	location := testLocation(t, "etcd", srv, "deleter")
+	for i := 0; i < 2; i++ {
+		// An error, not a change, so that the config isn't reloaded for nothing.
+		if err := location.wait(context.Background(), 5); err == nil {
+			t.Errorf("watch %d closed without a change, got no error", i)
+		}
+	}
+}
+
+func TestEtcdWaitCancelled(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result": {"created": true}}`)
+		w.(http.Flusher).Flush()
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := testLocation(t, "etcd", srv, "deleter").wait(ctx, 5); err != ctx.Err() {
+		t.Errorf("got %v, want %v", err, ctx.Err())
+	}
+}
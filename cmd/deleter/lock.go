@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("lock is held by another process")
+
+// lockFile takes an exclusive lock on path, creating the file if needed, and writes our pid into it. The lock is
+// released when the returned file is closed or the process exits, so a crashed run never leaves a stale lock behind.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := pruner.LockFile(f); err != nil {
+		f.Close()
+		if err == pruner.ErrFileLocked {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	return f, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/notify"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// watchDisk checks how full each base directory's filesystem is every interval until ctx is done. When usage of one
+// reaches threshold percent it raises an alert and runs an emergency free-space pass towards target. It won't do so
+// for that filesystem again until its usage has dropped back below the threshold.
+func watchDisk(ctx context.Context, p *pruner.Pruner, threshold float64, target pruner.FreeSpaceTarget, interval time.Duration, alert func(notify.DiskUsage, string)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tripped := make(map[string]bool)
+		for {
+			for _, base := range p.Bases() {
+				free, total, err := p.FS.DiskSpace(base)
+				if err != nil {
+					log.Errorln("Could not check free disk space.", err)
+					continue
+				}
+				usage := notify.DiskUsage{Path: base, Free: free, Total: total}
+				switch {
+				case usage.UsedPercent() < threshold:
+					tripped[base] = false
+				case !tripped[base]:
+					tripped[base] = true
+					emergencyPass(ctx, p, usage, threshold, target, alert)
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// emergencyPass alerts that the disk is filling up and frees space towards target.
+func emergencyPass(ctx context.Context, p *pruner.Pruner, usage notify.DiskUsage, threshold float64, target pruner.FreeSpaceTarget, alert func(notify.DiskUsage, string)) {
+	action := fmt.Sprintf("Usage is over the %.1f%% threshold. Starting an emergency pass to free space up to %s, removing the oldest data past each company's minimum retention.", threshold, target)
+	log.WithField("free_bytes", usage.Free).WithField("total_bytes", usage.Total).Errorf("Disk %.1f%% full. %s", usage.UsedPercent(), action)
+	alert(usage, action)
+	summary, err := p.FreeSpace(ctx, target)
+	log.WithField("run_id", summary.RunID).Infoln(summary)
+	if p.AfterPass != nil {
+		p.AfterPass(summary)
+	}
+	if err != nil && err != ctx.Err() {
+		log.Errorln("Emergency pass could not free enough space.", err)
+	}
+}
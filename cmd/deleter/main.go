@@ -0,0 +1,96 @@
+// Command deleter removes company data that has aged past its configured retention.
+//
+// Usage:
+//
+//	deleter <command> [flags]
+//
+// Run "deleter help" for the list of commands. Flags without a command run a pass, as earlier versions did.
+//
+// Exit codes are 0 when everything went well, 1 for fatal errors, including bad flags and an aborted or interrupted
+// pass, and 2 when a pass finished but more companies failed than -error-threshold allows, or a purge finished but
+// left some of the company's data in place.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	// Per-company timezones must work in minimal containers without a zoneinfo database.
+	_ "time/tzdata"
+)
+
+const (
+	exitOK      = 0
+	exitFatal   = 1
+	exitPartial = 2
+	exitLocked  = 3
+)
+
+// command is a deleter subcommand.
+type command struct {
+	summary string
+	run     func(args []string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"run":              {"Prune every company once, or on a schedule with -daemon", runCommand},
+		"validate-config":  {"Check the config for mistakes without touching any data", validateCommand},
+		"explain":          {"Show which config and cutoff each company directory gets", explainCommand},
+		"report":           {"Report what a pass would remove, without removing anything", reportCommand},
+		"diff-policy":      {"Estimate how much more or less a proposed config would remove", diffPolicyCommand},
+		"purge":            {"Delete all, or all dated, data for one company regardless of retention", purgeCommand},
+		"free-space":       {"Remove the oldest data across companies until there is enough free space", freeSpaceCommand},
+		"verify-audit-log": {"Verify the hash chain of an audit log", verifyAuditCommand},
+		"history":          {"List past passes, e.g. to find when a company was last pruned", historyCommand},
+		"bench":            {"Compare how fast each traversal walks a tree, without removing anything", benchCommand},
+		"gen-tree":         {"Write a synthetic company tree, for testing and benchmarks", genTreeCommand},
+		"help":             {"Show this help", func([]string) { usage(os.Stdout) }},
+	}
+}
+
+func main() {
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, known := commands[name]
+	if !known {
+		fmt.Fprintf(os.Stderr, "deleter: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(exitFatal)
+	}
+	cmd.run(args)
+}
+
+// parseFlags parses a command's flags, exiting with exitFatal if they are wrong. The flag package's own
+// ExitOnError would exit with 2, which is reserved for partial failures.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	switch err := flags.Parse(args); err {
+	case nil:
+	case flag.ErrHelp:
+		os.Exit(exitOK)
+	default:
+		os.Exit(exitFatal)
+	}
+}
+
+func usage(out *os.File) {
+	fmt.Fprintln(out, "Usage: deleter <command> [flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-18s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, `Run "deleter <command> -h" for a command's flags.`)
+}
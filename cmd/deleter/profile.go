@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// profiles writes the CPU and heap profiles of a one-shot run for -cpuprofile and -memprofile.
+type profiles struct {
+	cpu     *os.File
+	memPath string
+}
+
+// startProfiles starts CPU profiling to cpuPath, if not empty, and remembers memPath for stop.
+func startProfiles(cpuPath string, memPath string) (*profiles, error) {
+	p := &profiles{memPath: memPath}
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		p.cpu = f
+	}
+	return p, nil
+}
+
+// stop stops CPU profiling and writes the heap profile. The heap profile also holds every allocation sampled since
+// the run started, for go tool pprof -sample_index=alloc_space.
+func (p *profiles) stop() {
+	if p.cpu != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			log.Errorln("Could not write CPU profile.", err)
+		}
+		p.cpu = nil
+	}
+	if p.memPath == "" {
+		return
+	}
+	f, err := os.Create(p.memPath)
+	if err == nil {
+		runtime.GC()
+		err = runtimepprof.WriteHeapProfile(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Errorln("Could not write memory profile.", err)
+	}
+	p.memPath = ""
+}
+
+// servePprof serves the runtime profiles on addr at /debug/pprof/ in the background.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// logProgressOnSignal logs how far the passes under way have got whenever the process receives SIGUSR1, so that a
+// long pass can be checked on without waiting for its summary.
+func logProgressOnSignal(p *pruner.Pruner) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		for range usr1 {
+			logProgress(p.Progress())
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+package main
+
+import "github.com/moriarty-s3a/deleter/pruner"
+
+// logProgressOnSignal does nothing on Windows, which has no SIGUSR1.
+func logProgressOnSignal(p *pruner.Pruner) {}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// purgeCommand implements "deleter purge": delete all, or all date-bounded, data for one company regardless of
+// retention.
+func purgeCommand(args []string) {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	var company, before, auditPath string
+	var dryRun, yes bool
+	flags.StringVar(&company, "company", "", "Company id to purge (required)")
+	flags.StringVar(&before, "before", "", "Only purge data dated before this date (2006-01-02 or RFC 3339), default everything")
+	flags.StringVar(&auditPath, "audit-log", "", "Append a hash-chained record of every removal to this file")
+	flags.BoolVar(&dryRun, "dry-run", false, "Log what would be removed without removing anything")
+	flags.BoolVar(&yes, "yes", false, "Don't ask for confirmation")
+	parseFlags(flags, args)
+	if company == "" {
+		log.Fatal("purge needs -company")
+	}
+	var beforeTime time.Time
+	if before != "" {
+		var err error
+		beforeTime, err = parseDate(before)
+		if err != nil {
+			log.Fatal("Invalid -before date. ", err)
+		}
+	}
+
+	p := common.newPruner()
+	p.DryRun = dryRun
+	if auditPath != "" {
+		var err error
+		p.Audit, err = pruner.OpenAuditLog(auditPath)
+		if err != nil {
+			log.Fatal("Could not open audit log.", err)
+		}
+		defer p.Audit.Close()
+	}
+
+	if !dryRun && !yes && !confirmPurge(company, beforeTime) {
+		log.Fatal("Purge not confirmed, nothing removed.")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	stats, err := p.Purge(ctx, company, beforeTime)
+	log.Infof("Purge of %s: %d directories and %d files removed, %d bytes freed in %d files, %d errors",
+		company, stats.DirsDeleted, stats.FilesDeleted, stats.BytesFreed, stats.FilesRemoved, stats.Errors)
+	if errors.Is(err, pruner.ErrPurgeIncomplete) {
+		// The company asked for its data to be deleted, so whatever is left has to be dealt with by hand.
+		for _, path := range stats.KeptPaths {
+			log.WithField("path", path).Errorln("Purge kept path")
+		}
+		for _, path := range stats.FailedPaths {
+			log.WithField("path", path).Errorln("Purge could not remove path")
+		}
+		log.WithError(err).Errorln("Purge did not remove everything")
+		os.Exit(exitPartial)
+	}
+	if err != nil {
+		log.Fatal("Purge did not finish. ", err)
+	}
+}
+
+// confirmPurge asks the operator to type the company id back before anything is removed.
+func confirmPurge(company string, before time.Time) bool {
+	scope := "ALL data"
+	if !before.IsZero() {
+		scope = "all data dated before " + before.Format(time.RFC3339)
+	}
+	fmt.Fprintf(os.Stderr, "This permanently deletes %s for company %s, bypassing retention and the trash.\n", scope, company)
+	fmt.Fprint(os.Stderr, "Type the company id to confirm: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(answer) == company
+}
+
+// parseDate accepts either a plain date, taken as midnight UTC, or an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
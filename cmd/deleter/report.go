@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// report is anything that can be written as either CSV or JSON.
+type report interface {
+	WriteCSV(w io.Writer) error
+	WriteJSON(w io.Writer) error
+}
+
+// writeReport writes the summary to path, as CSV if the path ends in .csv and JSON otherwise. "{date}" and
+// "{run_id}" in the path are replaced with the pass's start date and run ID, so daily reports can be kept side by side.
+func writeReport(path string, summary pruner.Summary) error {
+	path = strings.NewReplacer(
+		"{date}", summary.Start.UTC().Format("2006-01-02"),
+		"{run_id}", summary.RunID,
+	).Replace(path)
+	return writeReportFile(path, summary)
+}
+
+// writeReportFile writes r to path, as CSV if the path ends in .csv and JSON otherwise.
+func writeReportFile(path string, r report) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial report.
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".csv") {
+		err = r.WriteCSV(file)
+	} else {
+		err = r.WriteJSON(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// reportCommand implements "deleter report": make a dry pass and write its per-company report, to -report or
+// stdout as JSON. With -inventory it reports each company's total size, oldest and newest data, and how much of it is
+// past retention instead.
+func reportCommand(args []string) {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	var reportPath string
+	var inventory bool
+	flags.StringVar(&reportPath, "report", "", "Write the report to this path, CSV if it ends in .csv and JSON otherwise; default stdout")
+	flags.BoolVar(&inventory, "inventory", false, "Report each company's total size, oldest and newest data, and how much is past retention")
+	parseFlags(flags, args)
+	p := common.newPruner()
+	p.DryRun = true
+	if inventory {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		inv, err := p.Inventory(ctx)
+		if err != nil {
+			log.Fatal("Could not take inventory.", err)
+		}
+		if reportPath == "" {
+			err = inv.WriteJSON(os.Stdout)
+		} else {
+			err = writeReportFile(reportPath, inv)
+		}
+		if err != nil {
+			log.Fatal("Could not write report.", err)
+		}
+		return
+	}
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		log.Fatal("Could not open base directory.", err)
+	}
+	if reportPath == "" {
+		err = summary.WriteJSON(os.Stdout)
+	} else {
+		err = writeReport(reportPath, summary)
+	}
+	if err != nil {
+		log.Fatal("Could not write report.", err)
+	}
+}
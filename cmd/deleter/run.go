@@ -0,0 +1,588 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/metrics"
+	"github.com/moriarty-s3a/deleter/notify"
+	"github.com/moriarty-s3a/deleter/pruner"
+	"github.com/moriarty-s3a/deleter/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// runCommand implements "deleter run": prune every company once, or keep pruning on a schedule with -daemon.
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	var schedule, metricsAddr, adminAddr, grpcAddr, pushGateway, reportPath, auditPath, historyPath, asOf, lockPath string
+	var slackURL, emailTo, emailFrom, smtpAddr, lockURL, shardID, ionice, runWindow, checkpointPath, events, otlpEndpoint, statsdAddr, statsdPrefix, sentryDSN, errorWebhook, pagerDutyKey, opsgenieKey string
+	var pprofAddr, cpuProfile, memProfile string
+	var dryRun, daemon, force, confirmAsOf, watchConfigChanges, webhookRemovals, failuresOnly, windowWait, stageRemovals bool
+	var webhookURLs, shardMembers, statsdTags stringList
+	var threshold errorThreshold
+	var alertAfter int
+	var trashGrace, configRefresh, lockTTL, maxRuntime time.Duration
+	var workers, maxDeleteDirs, retries, historyDays int
+	var retryBackoff time.Duration
+	var deletesPerSecond, lowDiskThreshold float64
+	var lowDiskTarget string
+	var lowDiskInterval time.Duration
+	var maxDeleteBytes int64
+	flags.BoolVar(&dryRun, "dry-run", false, "Log what would be removed without removing anything")
+	flags.BoolVar(&daemon, "daemon", false, "Stay resident and prune each company on its schedule")
+	flags.StringVar(&schedule, "schedule", "24h", "Default daemon schedule, a cron expression or duration, for companies without one in config")
+	flags.DurationVar(&configRefresh, "config-db-refresh", 5*time.Minute, "How often the daemon rereads the config when -config-db-driver is set, 0 to only reread on SIGHUP")
+	flags.BoolVar(&watchConfigChanges, "watch-config", true, "In daemon mode, reload the config file whenever it changes")
+	flags.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve /metrics on in daemon mode, such as :9464, empty to disable")
+	flags.StringVar(&adminAddr, "admin-addr", "", "Address to serve the admin API on in daemon mode, empty to disable. Requests must carry $DELETER_ADMIN_TOKEN as a bearer token")
+	flags.StringVar(&grpcAddr, "grpc-addr", "", "Address to serve the gRPC API on in daemon mode, empty to disable. Calls must carry $DELETER_ADMIN_TOKEN as a bearer token")
+	flags.StringVar(&pprofAddr, "pprof-addr", "", "Address to serve the runtime profiles on at /debug/pprof/ in daemon mode, such as localhost:6060, empty to disable")
+	flags.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of a one-shot run to this file")
+	flags.StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file at the end of a one-shot run, with allocations for go tool pprof -sample_index=alloc_space")
+	flags.StringVar(&otlpEndpoint, "otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OpenTelemetry collector to send a trace of each pass to over OTLP/HTTP, e.g. http://localhost:4318, default $OTEL_EXPORTER_OTLP_ENDPOINT. $OTEL_EXPORTER_OTLP_HEADERS, $OTEL_SERVICE_NAME and $TRACEPARENT are honoured")
+	flags.StringVar(&statsdAddr, "statsd", "", "StatsD or DogStatsD host:port, such as the Datadog agent's localhost:8125, to send metrics to as they happen, alongside Prometheus")
+	flags.StringVar(&statsdPrefix, "statsd-prefix", "deleter.", "Prefix of the metric names sent to -statsd")
+	flags.Var(&statsdTags, "statsd-tag", "Tag to add to every metric sent to -statsd, such as env:prod. May be repeated or comma-separated")
+	flags.StringVar(&pushGateway, "pushgateway", "", "Prometheus pushgateway URL to push metrics to after a one-shot run")
+	flags.DurationVar(&trashGrace, "trash-grace", 0, "Move expired directories to the company's .trash and delete them after this long, 0 to delete immediately")
+	flags.BoolVar(&stageRemovals, "stage-removals", false, "Without -trash-grace, rename expired directories into the company's .trash so they vanish at once, and delete them from there in the background, paced by -max-deletes-per-second")
+	flags.IntVar(&workers, "workers", 16, "Maximum number of companies to prune at once, 0 for no limit")
+	flags.Var(&webhookURLs, "webhook", "POST a JSON event to this URL after every pass, signed with $DELETER_WEBHOOK_SECRET if set. May be repeated")
+	flags.BoolVar(&webhookRemovals, "webhook-removals", false, "Also send a webhook event for every directory or file removed or trashed")
+	flags.StringVar(&slackURL, "slack-webhook", os.Getenv("DELETER_SLACK_WEBHOOK"), "Post a summary of every pass to this Slack incoming webhook, default $DELETER_SLACK_WEBHOOK")
+	flags.StringVar(&emailTo, "email-to", "", "Email a summary of every pass to these comma-separated addresses")
+	flags.StringVar(&emailFrom, "email-from", "deleter@localhost", "Sender address for -email-to")
+	flags.StringVar(&smtpAddr, "smtp-addr", "localhost:25", "SMTP server for -email-to; $DELETER_SMTP_USER and $DELETER_SMTP_PASSWORD log in if set")
+	flags.StringVar(&sentryDSN, "sentry-dsn", os.Getenv("SENTRY_DSN"), "Report passes with errors, or that were interrupted or aborted, to this Sentry project, with the summary attached, default $SENTRY_DSN. $SENTRY_ENVIRONMENT sets the environment")
+	flags.StringVar(&errorWebhook, "error-webhook", "", "POST a JSON report of passes with errors, or that were interrupted or aborted, to this error-tracking URL")
+	flags.StringVar(&pagerDutyKey, "pagerduty-routing-key", os.Getenv("PAGERDUTY_ROUTING_KEY"), "In daemon mode, page through this PagerDuty Events API v2 integration when a company fails -alert-after passes in a row, default $PAGERDUTY_ROUTING_KEY")
+	flags.StringVar(&opsgenieKey, "opsgenie-api-key", os.Getenv("OPSGENIE_API_KEY"), "In daemon mode, raise an Opsgenie alert when a company fails -alert-after passes in a row, default $OPSGENIE_API_KEY")
+	flags.IntVar(&alertAfter, "alert-after", 3, "Consecutive failed passes over a company before -pagerduty-routing-key or -opsgenie-api-key pages")
+	flags.BoolVar(&failuresOnly, "notify-failures-only", false, "Only send Slack and email summaries for passes with errors, or that were interrupted or aborted")
+	flags.Var(&threshold, "error-threshold", "Exit with code 2 if more companies than this fail in a one-shot pass: a count, or a percentage with a % suffix")
+	flags.IntVar(&retries, "retries", 3, "Retry a removal that fails with a transient error, such as EBUSY or a stale NFS handle, this many times")
+	flags.DurationVar(&retryBackoff, "retry-backoff", time.Second, "Wait before the first retry of a removal, doubling for each one after")
+	flags.Float64Var(&deletesPerSecond, "max-deletes-per-second", 0, "Remove at most this many paths a second across all companies, so ingestion on the same volume keeps up, 0 for no limit")
+	flags.StringVar(&ionice, "ionice", "", "Run with this I/O scheduling class, idle or best-effort[:level], as ionice(1) would. Linux only")
+	flags.StringVar(&runWindow, "run-window", "", "Only remove data during this daily window in -timezone, e.g. 01:00-05:00, stopping a pass early when it closes")
+	flags.BoolVar(&windowWait, "window-wait", false, "Outside -run-window, wait for it to open instead of exiting. Always on with -daemon")
+	flags.DurationVar(&maxRuntime, "max-runtime", 0, "Stop a pass cleanly once it has run this long, 0 for no limit")
+	flags.StringVar(&checkpointPath, "checkpoint", "", "When a one-shot pass stops early, save how far it got to this file, and carry on from there on the next run")
+	flags.Float64Var(&lowDiskThreshold, "low-disk-threshold", 0, "In daemon mode, raise an alert and start an emergency pass when the base directory's filesystem is this percent full, 0 to disable")
+	flags.StringVar(&lowDiskTarget, "low-disk-target", "", "Free space the emergency pass aims for, e.g. 500GB or 20%, default 5 points below -low-disk-threshold")
+	flags.DurationVar(&lowDiskInterval, "low-disk-interval", time.Minute, "How often to check disk usage for -low-disk-threshold")
+	flags.StringVar(&reportPath, "report", "", "Write a per-company report of each pass to this path, CSV if it ends in .csv and JSON otherwise")
+	flags.StringVar(&auditPath, "audit-log", "", "Append a hash-chained record of every removal to this file")
+	flags.StringVar(&events, "events", "", "Write every decision about a path, scanned, skipped, deleted or failed, to stdout as ndjson, one JSON event per line; logs stay on stderr")
+	flags.StringVar(&historyPath, "history", "", "Append every pass's summary to this file, for the history command and the admin API")
+	flags.IntVar(&historyDays, "history-days", 0, "Drop passes that started more than this many days ago from -history, 0 to keep them all")
+	flags.IntVar(&maxDeleteDirs, "max-delete-dirs", 0, "Abort a pass that would remove more than this many directories, 0 for no limit")
+	flags.Int64Var(&maxDeleteBytes, "max-delete-bytes", 0, "Abort a pass that would free more than this many bytes, 0 for no limit")
+	flags.BoolVar(&force, "force", false, "Ignore -max-delete-dirs and -max-delete-bytes")
+	flags.StringVar(&lockPath, "lockfile", "", "Take an exclusive lock on this file for as long as the run lasts, and exit with code 3 if another run holds it")
+	flags.StringVar(&lockURL, "lock-url", "", "Lock each company in Redis or etcd before pruning it, skipping companies another instance holds: redis://host:port/prefix or etcd://host:port/prefix")
+	flags.DurationVar(&lockTTL, "lock-ttl", 5*time.Minute, "How long a -lock-url lock outlives an instance that stopped refreshing it")
+	flags.Var(&shardMembers, "shard-members", "Split the companies between these instance names by consistent hashing and only prune those belonging to -shard-id. May be repeated or comma-separated")
+	flags.StringVar(&shardID, "shard-id", "", "This instance's name in -shard-members, default the hostname")
+	flags.StringVar(&asOf, "as-of", "", "Pretend it is this time (2006-01-02 or RFC 3339), to see what a future pass would remove. Needs -dry-run or -confirm-as-of")
+	flags.BoolVar(&confirmAsOf, "confirm-as-of", false, "Let -as-of remove data for real. The deletion caps still apply")
+	parseFlags(flags, args)
+
+	if daemon && (cpuProfile != "" || memProfile != "") {
+		log.Fatal("-cpuprofile and -memprofile can't be used with -daemon, use -pprof-addr")
+	}
+	profiling, err := startProfiles(cpuProfile, memProfile)
+	if err != nil {
+		log.Fatal("Could not start profiling.", err)
+	}
+	defer profiling.stop()
+	if lockPath != "" {
+		lock, err := lockFile(lockPath)
+		if err == errLocked {
+			log.Warnf("Another run holds %s, exiting.", lockPath)
+			os.Exit(exitLocked)
+		}
+		if err != nil {
+			log.Fatal("Could not take lock file.", err)
+		}
+		defer lock.Close()
+	}
+	p := common.newPruner()
+	if dryRun {
+		log.Infoln("Dry run, nothing will be removed.")
+	}
+
+	// SIGINT and SIGTERM stop new deletions; whatever is being removed at the time is allowed to finish.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	p.DryRun = dryRun
+	p.TrashGrace = trashGrace
+	p.StageRemovals = stageRemovals
+	p.Workers = workers
+	p.MaxDeletesPerSecond = deletesPerSecond
+	if ionice != "" {
+		class, level, err := parseIONice(ionice)
+		if err == nil {
+			err = setIONice(class, level)
+		}
+		if err != nil {
+			log.Fatal("Could not set -ionice.", err)
+		}
+	}
+	if runWindow != "" {
+		window, err := pruner.ParseRunWindow(runWindow)
+		if err != nil {
+			log.Fatal("Invalid -run-window.", err)
+		}
+		p.Window = window
+		p.WindowWait = windowWait || daemon
+	}
+	p.MaxRuntime = maxRuntime
+	if checkpointPath != "" {
+		if daemon {
+			log.Fatal("-checkpoint can't be used with -daemon")
+		}
+		checkpoint, err := readCheckpoint(checkpointPath)
+		if err != nil {
+			log.Fatal("Could not read checkpoint.", err)
+		}
+		if checkpoint != nil {
+			log.Infof("Resuming pass %s from its checkpoint of %s", checkpoint.RunID, checkpoint.Time.Format(time.RFC3339))
+			p.ResumeFrom = checkpoint
+		}
+	}
+	p.Retries = retries
+	p.RetryBackoff = retryBackoff
+	if lockURL != "" {
+		if lockTTL < 3*time.Second {
+			log.Fatal("-lock-ttl must be at least 3s")
+		}
+		locker, err := newDistLocker(lockURL, lockTTL)
+		if err != nil {
+			log.Fatal("Invalid -lock-url.", err)
+		}
+		p.Locker = locker
+	}
+	if len(shardMembers) > 0 {
+		p.Shard = shardFilter(shardMembers, shardID)
+	}
+	if asOf != "" {
+		asOfTime, err := parseDate(asOf)
+		if err != nil {
+			log.Fatal("Invalid -as-of time. ", err)
+		}
+		if daemon {
+			log.Fatal("-as-of can't be used with -daemon")
+		}
+		if !dryRun && !confirmAsOf {
+			log.Fatal("-as-of removes data for real only with -confirm-as-of; use -dry-run to simulate")
+		}
+		log.Infof("Running as of %s", asOfTime.Format(time.RFC3339))
+		p.Clock = pruner.FixedClock(asOfTime)
+	}
+	p.MaxDeleteDirs = maxDeleteDirs
+	p.MaxDeleteBytes = maxDeleteBytes
+	p.Force = force
+	if auditPath != "" {
+		var err error
+		p.Audit, err = pruner.OpenAuditLog(auditPath)
+		if err != nil {
+			log.Fatal("Could not open audit log.", err)
+		}
+		defer p.Audit.Close()
+	}
+	if historyPath != "" {
+		var err error
+		if p.History, err = pruner.OpenHistory(historyPath); err != nil {
+			log.Fatal("Could not open history.", err)
+		}
+		p.History.KeepDays = historyDays
+		defer p.History.Close()
+	}
+	switch events {
+	case "":
+	case "ndjson":
+		p.OnEvent = pruner.NewEventWriter(os.Stdout).Write
+	default:
+		log.Fatal("Invalid -events, expected ndjson")
+	}
+	var tracer *metrics.OTLP
+	if otlpEndpoint != "" {
+		var err error
+		if tracer, err = newOTLP(otlpEndpoint); err != nil {
+			log.Fatal("Invalid -otlp-endpoint.", err)
+		}
+		defer tracer.Close()
+		p.Tracer = tracer
+	}
+	registry := prometheus.NewRegistry()
+	p.Recorder = metrics.NewPrometheus(registry)
+	if statsdAddr != "" {
+		statsd, err := metrics.NewStatsd(statsdAddr, statsdPrefix, statsdTags.values())
+		if err != nil {
+			log.Fatal("Invalid -statsd.", err)
+		}
+		defer statsd.Close()
+		p.Recorder = pruner.MultiRecorder{p.Recorder, statsd}
+	}
+	reloadOnHangup(p, common.loadConfig)
+	logProgressOnSignal(p)
+	var webhook *notify.Webhook
+	if len(webhookURLs) > 0 {
+		webhook = notify.NewWebhook(webhookURLs, os.Getenv("DELETER_WEBHOOK_SECRET"))
+		defer webhook.Close()
+		if webhookRemovals {
+			p.OnRemove = webhook.Removal
+		}
+	}
+	// exit flushes what the deferred calls would have before exiting with code.
+	exit := func(code int) {
+		if webhook != nil {
+			webhook.Close()
+		}
+		if tracer != nil {
+			tracer.Close()
+		}
+		if p.Audit != nil {
+			p.Audit.Close()
+		}
+		if p.History != nil {
+			p.History.Close()
+		}
+		profiling.stop()
+		os.Exit(code)
+	}
+	summaries := summaryNotifiers(slackURL, emailTo, emailFrom, smtpAddr, failuresOnly)
+	var trackers notify.ErrorTrackers
+	if sentryDSN != "" {
+		trackers = append(trackers, notify.Sentry{DSN: sentryDSN, Environment: os.Getenv("SENTRY_ENVIRONMENT")})
+	}
+	if errorWebhook != "" {
+		trackers = append(trackers, notify.ErrorWebhook{URL: errorWebhook})
+	}
+	alerts := &notify.FailureAlerts{Threshold: alertAfter}
+	if pagerDutyKey != "" {
+		alerts.Pagers = append(alerts.Pagers, notify.PagerDuty{RoutingKey: pagerDutyKey})
+	}
+	if opsgenieKey != "" {
+		alerts.Pagers = append(alerts.Pagers, notify.Opsgenie{APIKey: opsgenieKey})
+	}
+	if len(alerts.Pagers) > 0 && !daemon {
+		// A one-shot run can't see a streak of failures, so there is nothing to page about.
+		log.Warnln("-pagerduty-routing-key and -opsgenie-api-key only page in daemon mode, ignoring them")
+		alerts.Pagers = nil
+	}
+	if len(alerts.Pagers) > 0 && alertAfter < 1 {
+		log.Fatal("-alert-after must be at least 1")
+	}
+	p.AfterPass = func(summary pruner.Summary) {
+		if webhook != nil {
+			webhook.Pass(summary)
+		}
+		summaries.Pass(summary)
+		trackers.Pass(summary)
+		if len(alerts.Pagers) > 0 {
+			alerts.Pass(summary)
+		}
+		if reportPath == "" {
+			return
+		}
+		if err := writeReport(reportPath, summary); err != nil {
+			log.Errorln("Could not write report.", err)
+		}
+	}
+	if daemon {
+		if _, err := pruner.ParseSchedule(schedule); err != nil {
+			log.Fatal("Invalid schedule.", err)
+		}
+		if metricsAddr != "" {
+			metrics.RegisterDiskSpace(registry, p.Bases(), p.FS.DiskSpace)
+			serveMetrics(metricsAddr, registry)
+		}
+		if pprofAddr != "" {
+			servePprof(pprofAddr)
+		}
+		if adminAddr != "" || grpcAddr != "" {
+			token := os.Getenv("DELETER_ADMIN_TOKEN")
+			if token == "" {
+				log.Fatal("-admin-addr and -grpc-addr need DELETER_ADMIN_TOKEN to be set")
+			}
+			if adminAddr != "" {
+				serveAdmin(adminAddr, p, token)
+			}
+			if grpcAddr != "" {
+				serveGRPC(grpcAddr, p, token)
+			}
+		}
+		if common.dbDriver != "" && configRefresh > 0 {
+			refreshConfig(ctx, p, common.loadConfig, configRefresh)
+		}
+		if lowDiskThreshold > 0 {
+			if lowDiskThreshold >= 100 {
+				log.Fatal("-low-disk-threshold must be below 100")
+			}
+			target := pruner.FreeSpaceTarget{Percent: math.Min(100-lowDiskThreshold+5, 99)}
+			if lowDiskTarget != "" {
+				var err error
+				if target, err = pruner.ParseFreeSpaceTarget(lowDiskTarget); err != nil {
+					log.Fatal("Invalid -low-disk-target.", err)
+				}
+			}
+			watchDisk(ctx, p, lowDiskThreshold, target, lowDiskInterval, func(usage notify.DiskUsage, action string) {
+				if webhook != nil {
+					webhook.LowDisk(usage)
+				}
+				summaries.LowDisk(usage, action)
+			})
+		}
+		if kv, ok := parseKVLocation(configLocation(common.configLocation)); ok {
+			watchConfig(ctx, p, common.loadConfig, kv)
+		}
+		if path := configFile(common.configLocation); path != "" && watchConfigChanges {
+			if err := watchConfigFile(ctx, p, common.loadConfig, path); err != nil {
+				log.Errorln("Could not watch config file, reload it with SIGHUP instead.", err)
+			}
+		}
+		err := p.RunScheduled(ctx, schedule)
+		if err == ctx.Err() {
+			log.Infoln("Shut down.")
+			return
+		}
+		log.Fatal(err)
+	}
+	summary, err := p.Run(ctx)
+	switch err.(type) {
+	case *pruner.CapExceededError, *pruner.UnknownCompaniesError:
+		// The pruner has already logged why.
+		p.AfterPass(summary)
+		exit(exitFatal)
+	}
+	if err == pruner.ErrOutsideWindow {
+		log.Infof("Outside the run window %s, nothing to do.", p.Window)
+		exit(exitOK)
+	}
+	if err != nil && err != ctx.Err() {
+		// Not much we can do if we can't read the base directory. Something went very wrong.
+		log.Fatal("Could not open base directory.", err)
+	}
+	log.WithField("run_id", summary.RunID).Infoln(summary)
+	p.AfterPass(summary)
+	if checkpointPath != "" && !dryRun {
+		if err := writeCheckpoint(checkpointPath, summary); err != nil {
+			log.Errorln("Could not write checkpoint.", err)
+		}
+	}
+	if pushGateway != "" {
+		if err := push.New(pushGateway, "deleter").Gatherer(registry).Push(); err != nil {
+			log.Errorln("Could not push metrics.", err)
+		}
+	}
+	if summary.Interrupted {
+		stop()
+		exit(exitFatal)
+	}
+	if summary.WindowClosed || summary.OutOfTime {
+		// The companies left unfinished are picked up by the next run.
+		return
+	}
+	if failed := summary.FailedCount(); threshold.exceeded(failed, len(summary.Companies)) {
+		log.Errorf("%d of %d companies failed, over the -error-threshold of %s", failed, len(summary.Companies), &threshold)
+		exit(exitPartial)
+	}
+}
+
+// errorThreshold is how many companies may fail before a pass counts as a partial failure: a count, or a percentage
+// of the companies in the pass when written with a % suffix.
+type errorThreshold struct {
+	value   float64
+	percent bool
+}
+
+func (t *errorThreshold) String() string {
+	if t.percent {
+		return strconv.FormatFloat(t.value, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(t.value, 'f', -1, 64)
+}
+
+func (t *errorThreshold) Set(s string) error {
+	t.percent = strings.HasSuffix(s, "%")
+	value, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || value < 0 {
+		return fmt.Errorf("expected a count or a percentage, e.g. 3 or 10%%")
+	}
+	t.value = value
+	return nil
+}
+
+// exceeded reports whether failed of total companies is over the threshold.
+func (t *errorThreshold) exceeded(failed int, total int) bool {
+	if t.percent {
+		return total > 0 && float64(failed)*100/float64(total) > t.value
+	}
+	return float64(failed) > t.value
+}
+
+// summaryNotifiers sets up the Slack and email summaries the flags ask for.
+func summaryNotifiers(slackURL, emailTo, emailFrom, smtpAddr string, failuresOnly bool) notify.Summaries {
+	summaries := notify.Summaries{FailuresOnly: failuresOnly}
+	if slackURL != "" {
+		summaries.Notifiers = append(summaries.Notifiers, notify.Slack{WebhookURL: slackURL})
+	}
+	if emailTo != "" {
+		email := notify.Email{Addr: smtpAddr, From: emailFrom, To: strings.Split(emailTo, ",")}
+		if user := os.Getenv("DELETER_SMTP_USER"); user != "" {
+			host, _, _ := net.SplitHostPort(smtpAddr)
+			email.Auth = smtp.PlainAuth("", user, os.Getenv("DELETER_SMTP_PASSWORD"), host)
+		}
+		summaries.Notifiers = append(summaries.Notifiers, email)
+	}
+	return summaries
+}
+
+// reloadOnHangup rereads the config whenever the process receives SIGHUP. The new config applies from the next pass;
+// if it can't be read, the current config stays in effect.
+func reloadOnHangup(p *pruner.Pruner, load func() (pruner.Config, error)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			reloadConfig(p, load)
+		}
+	}()
+}
+
+// logProgress logs how far the passes under way have got, for logProgressOnSignal.
+func logProgress(passes []pruner.PassProgress) {
+	if len(passes) == 0 {
+		log.Infoln("Progress: no pass under way")
+		return
+	}
+	for _, pass := range passes {
+		log.WithFields(log.Fields{
+			"run_id":        pass.RunID,
+			"dry_run":       pass.DryRun,
+			"elapsed":       time.Since(pass.Started).Round(time.Second).String(),
+			"companies":     pass.Companies,
+			"completed":     pass.Completed,
+			"in_flight":     len(pass.InFlight),
+			"dirs_scanned":  pass.Totals.DirsScanned,
+			"dirs_deleted":  pass.Totals.DirsDeleted,
+			"files_deleted": pass.Totals.FilesDeleted,
+			"dirs_trashed":  pass.Totals.DirsTrashed,
+			"bytes_freed":   pass.Totals.BytesFreed,
+			"errors":        pass.Totals.Errors,
+		}).Infof("Progress: %d of %d companies completed, %d in flight", pass.Completed, pass.Companies, len(pass.InFlight))
+		for _, company := range pass.InFlight {
+			companyLog := log.WithFields(log.Fields{
+				"run_id":        pass.RunID,
+				"company_id":    company.Company,
+				"path":          company.Path,
+				"elapsed":       time.Since(company.Started).Round(time.Second).String(),
+				"dirs_scanned":  company.DirsScanned,
+				"dirs_deleted":  company.DirsDeleted,
+				"files_deleted": company.FilesDeleted,
+				"dirs_trashed":  company.DirsTrashed,
+				"bytes_freed":   company.BytesFreed,
+				"errors":        company.Errors,
+			})
+			if company.BaseDir != "" {
+				companyLog = companyLog.WithField("base_dir", company.BaseDir)
+			}
+			companyLog.Infoln("Progress: company in flight")
+		}
+	}
+}
+
+// refreshConfig rereads the config every interval until ctx is done, so that changes to a config database are picked
+// up without a signal.
+func refreshConfig(ctx context.Context, p *pruner.Pruner, load func() (pruner.Config, error), interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				reloadConfig(p, load)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reloadConfig replaces p's config with a freshly loaded one, keeping the current one if it can't be loaded.
+func reloadConfig(p *pruner.Pruner, load func() (pruner.Config, error)) {
+	config, err := load()
+	if err != nil {
+		log.Errorln("Could not reload config, keeping the current one.", err)
+		return
+	}
+	changes := pruner.ConfigChanges(p.Config(), config)
+	p.SetConfig(config)
+	log.WithField("changes", len(changes)).Infoln("Reloaded config.")
+	for _, change := range changes {
+		log.Infof("Config change: %s", change)
+	}
+	log.Debugln("Config= ", config)
+}
+
+// serveGRPC serves the gRPC API on addr in the background.
+func serveGRPC(addr string, p *pruner.Pruner, token string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Could not listen for gRPC.", err)
+	}
+	go func() {
+		log.Fatal(rpc.NewServer(p, token).Serve(listener))
+	}()
+}
+
+// serveMetrics serves the registry on addr at /metrics in the background.
+func serveMetrics(addr string, registry *prometheus.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
+
+// newOTLP returns an OTLP exporter for -otlp-endpoint, configured from the standard OpenTelemetry environment
+// variables.
+func newOTLP(endpoint string) (*metrics.OTLP, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("%q is not an http or https URL", endpoint)
+	}
+	url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if url == "" {
+		url = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	headers := make(map[string]string)
+	for _, header := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if name, value, ok := strings.Cut(header, "="); ok {
+			headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "deleter"
+	}
+	return metrics.NewOTLP(url, service, headers, os.Getenv("TRACEPARENT"))
+}
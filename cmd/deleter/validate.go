@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// validateCommand implements "deleter validate-config": check the config and the base directory without touching
+// any data, printing one line per problem and exiting non-zero if there are any.
+func validateCommand(args []string) {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	var common commonFlags
+	common.register(flags)
+	parseFlags(flags, args)
+	common.setupLogging()
+	data, source, err := readConfigData(common.configLocation)
+	if err != nil {
+		log.Fatal("Could not open config.", err)
+	}
+	problems := 0
+	format := pruner.FormatFromName(source)
+	data, err = pruner.ExpandEnv(data, format, os.LookupEnv)
+	if err == nil {
+		data, err = pruner.ConfigToJSON(data, format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", source, err)
+		os.Exit(exitFatal)
+	}
+	for _, problem := range pruner.ValidateConfig(data) {
+		// Line numbers refer to the JSON translation of YAML and TOML documents, so they would only mislead.
+		if problem.Line > 0 && format == pruner.FormatJSON {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s\n", source, problem.Line, problem.Msg)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", source, problem.Msg)
+		}
+		problems++
+	}
+	config, parseErr := pruner.ParseConfig(data)
+	if parseErr == nil {
+		if err := pruner.ApplyEnvOverrides(&config, os.Environ()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			problems++
+		}
+	}
+	if common.dbDriver != "" {
+		problems += validateDatabase(&common)
+		if companies, err := common.loadDatabase(); err == nil {
+			config = pruner.MergeCompanies(config, companies)
+		}
+	}
+	p := common.basePruner(config)
+	bases := strings.Join(p.Bases(), ", ")
+	readable := true
+	for _, base := range p.Bases() {
+		if _, err := ioutil.ReadDir(base); err != nil {
+			fmt.Fprintf(os.Stderr, "base directory: %v\n", err)
+			problems++
+			readable = false
+		}
+	}
+	if readable && parseErr == nil {
+		// Drift between the config and the directories is worth a warning but isn't invalid.
+		orphans, _ := p.Orphans()
+		for _, id := range orphans.Entries {
+			if pruner.IsPattern(id) {
+				fmt.Fprintf(os.Stderr, "warning: companyId pattern %q matches no directory under %s\n", id, bases)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "warning: company %q has a config entry but no directory under %s\n", id, bases)
+		}
+		for _, dir := range orphans.Dirs {
+			fmt.Fprintf(os.Stderr, "warning: directory %s has no config entry of its own\n", dir)
+		}
+	}
+	if problems > 0 {
+		os.Exit(exitFatal)
+	}
+	fmt.Printf("%s is valid.\n", source)
+}
+
+// validateDatabase checks the company entries in the config database, returning how many problems it found.
+func validateDatabase(common *commonFlags) int {
+	companies, err := common.loadDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	problems := 0
+	for _, entry := range companies {
+		for _, msg := range entry.Problems() {
+			fmt.Fprintf(os.Stderr, "%s: company %q: %s\n", common.dbDriver, entry.Id, msg)
+			problems++
+		}
+	}
+	return problems
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsnotify/fsnotify"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// configFile returns the local file the config is read from, or "" if it comes from somewhere else.
+func configFile(flag string) string {
+	location := configLocation(flag)
+	switch {
+	case location == "" && os.Getenv(configContentEnv) != "":
+		return ""
+	case location == "":
+		return defaultConfigPath
+	case location == "-" || strings.Contains(location, "://"):
+		return ""
+	}
+	return location
+}
+
+// watchConfigFile reloads the config whenever path changes, until ctx is done. The directory is watched rather than
+// the file so that editors that save by renaming, and Kubernetes config maps that swap a symlink, are noticed too.
+func watchConfigFile(ctx context.Context, p *pruner.Pruner, load func() (pruner.Config, error), path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		defer watcher.Close()
+		// Saves often come as several events in a row, so wait for them to settle before reloading.
+		var settle <-chan time.Time
+		for {
+			select {
+			case event := <-watcher.Events:
+				name := filepath.Base(event.Name)
+				if name == filepath.Base(path) || strings.HasPrefix(name, "..") {
+					settle = time.After(500 * time.Millisecond)
+				}
+			case err := <-watcher.Errors:
+				log.Errorln("Error watching config file.", err)
+			case <-settle:
+				settle = nil
+				reloadConfig(p, load)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
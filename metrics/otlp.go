@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// OTLP is a pruner.Tracer that exports spans to an OpenTelemetry collector with OTLP over HTTP, JSON encoded. Ended
+// spans are sent in batches from a background goroutine every few seconds; Close sends what is left.
+type OTLP struct {
+	// URL is the collector's traces endpoint, such as http://localhost:4318/v1/traces.
+	URL     string
+	Headers map[string]string
+	Service string
+	Client  *http.Client
+
+	// parent is the span from the TRACEPARENT the run was started with, if any, that passes are children of.
+	parent spanContext
+
+	mu    sync.Mutex
+	ended []*otlpSpan
+	flush chan struct{}
+	stop  chan struct{}
+	done  sync.WaitGroup
+}
+
+// otlpBatch is how many ended spans are sent at once, and otlpMaxQueued how many are kept while the collector is
+// unreachable before new ones are dropped.
+const (
+	otlpBatch     = 512
+	otlpMaxQueued = 16384
+)
+
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+type spanKey struct{}
+
+// NewOTLP returns a started OTLP exporter sending to url as service. traceparent, if not empty, is a W3C trace
+// context header, such as the TRACEPARENT the run was started with, that passes become part of the trace of.
+func NewOTLP(url string, service string, headers map[string]string, traceparent string) (*OTLP, error) {
+	o := &OTLP{
+		URL:     url,
+		Headers: headers,
+		Service: service,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		flush:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	if traceparent != "" {
+		parent, err := parseTraceparent(traceparent)
+		if err != nil {
+			return nil, err
+		}
+		o.parent = parent
+	}
+	o.done.Add(1)
+	go o.exportAll()
+	return o, nil
+}
+
+// parseTraceparent parses a W3C traceparent header: version-traceid-parentid-flags in hex.
+func parseTraceparent(value string) (spanContext, error) {
+	var parent spanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return parent, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if _, err := hex.Decode(parent.traceID[:], []byte(parts[1])); err != nil {
+		return parent, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if _, err := hex.Decode(parent.spanID[:], []byte(parts[2])); err != nil {
+		return parent, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return parent, nil
+}
+
+// Start starts a span, the child of the one in ctx, or of the traceparent's if there is none.
+func (o *OTLP) Start(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, pruner.Span) {
+	parent, ok := ctx.Value(spanKey{}).(spanContext)
+	if !ok {
+		parent = o.parent
+	}
+	span := &otlpSpan{exporter: o, name: name, start: time.Now(), parentID: parent.spanID, attrs: make(map[string]interface{})}
+	span.context.traceID = parent.traceID
+	if span.context.traceID == ([16]byte{}) {
+		rand.Read(span.context.traceID[:])
+	}
+	rand.Read(span.context.spanID[:])
+	for key, value := range attrs {
+		span.attrs[key] = value
+	}
+	return context.WithValue(ctx, spanKey{}, span.context), span
+}
+
+// Close sends the spans that have ended and stops.
+func (o *OTLP) Close() {
+	close(o.stop)
+	o.done.Wait()
+}
+
+func (o *OTLP) exportAll() {
+	defer o.done.Done()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-o.flush:
+		case <-o.stop:
+			for o.export() {
+			}
+			return
+		}
+		for o.export() {
+		}
+	}
+}
+
+// export sends a batch of ended spans, and reports whether there may be more to send. Spans the collector refuses
+// are put back to try again, unless too many have piled up.
+func (o *OTLP) export() bool {
+	o.mu.Lock()
+	batch := o.ended
+	if len(batch) > otlpBatch {
+		batch = batch[:otlpBatch]
+	}
+	o.ended = o.ended[len(batch):]
+	o.mu.Unlock()
+	if len(batch) == 0 {
+		return false
+	}
+	if err := o.post(batch); err != nil {
+		log.WithField("url", o.URL).WithField("spans", len(batch)).Warnln("Could not export spans.", err)
+		o.mu.Lock()
+		if len(o.ended)+len(batch) <= otlpMaxQueued {
+			o.ended = append(batch, o.ended...)
+		}
+		o.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+func (o *OTLP) post(batch []*otlpSpan) error {
+	spans := make([]map[string]interface{}, len(batch))
+	for i, span := range batch {
+		spans[i] = span.encode()
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": map[string]interface{}{"attributes": encodeAttributes(map[string]interface{}{"service.name": o.Service})},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]string{"name": "github.com/moriarty-s3a/deleter"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", o.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range o.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// otlpSpan is a span started by an OTLP exporter.
+type otlpSpan struct {
+	exporter *OTLP
+	context  spanContext
+	parentID [8]byte
+	name     string
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs map[string]interface{}
+	err   error
+}
+
+func (s *otlpSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+func (s *otlpSpan) End(err error) {
+	s.mu.Lock()
+	s.end, s.err = time.Now(), err
+	s.mu.Unlock()
+	o := s.exporter
+	o.mu.Lock()
+	if len(o.ended) < otlpMaxQueued {
+		o.ended = append(o.ended, s)
+	}
+	full := len(o.ended) >= otlpBatch
+	o.mu.Unlock()
+	if full {
+		select {
+		case o.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// encode returns the span in OTLP's JSON encoding, in which IDs are hex and 64-bit integers are strings.
+func (s *otlpSpan) encode() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span := map[string]interface{}{
+		"traceId":           hex.EncodeToString(s.context.traceID[:]),
+		"spanId":            hex.EncodeToString(s.context.spanID[:]),
+		"name":              s.name,
+		"kind":              1, // SPAN_KIND_INTERNAL
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        encodeAttributes(s.attrs),
+	}
+	if s.parentID != ([8]byte{}) {
+		span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+	}
+	if s.err != nil {
+		span["status"] = map[string]interface{}{"code": 2, "message": s.err.Error()} // STATUS_CODE_ERROR
+	}
+	return span
+}
+
+func encodeAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]interface{}
+		switch value := value.(type) {
+		case string:
+			v = map[string]interface{}{"stringValue": value}
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": v})
+	}
+	return encoded
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	parent, err := parseTraceparent(" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.traceID != [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36} {
+		t.Errorf("got trace id %x", parent.traceID)
+	}
+	if parent.spanID != [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7} {
+		t.Errorf("got span id %x", parent.spanID)
+	}
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+	} {
+		if _, err := parseTraceparent(value); err == nil {
+			t.Errorf("parsed invalid traceparent %q", value)
+		}
+	}
+	if _, err := NewOTLP("http://localhost:4318/v1/traces", "deleter", nil, "garbage"); err == nil {
+		t.Error("NewOTLP accepted an invalid traceparent")
+	}
+}
+
+// otlpRequest is what a collector receives, decoded as far as the tests look at it.
+type otlpRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID           string          `json:"traceId"`
+				SpanID            string          `json:"spanId"`
+				ParentSpanID      string          `json:"parentSpanId"`
+				Name              string          `json:"name"`
+				StartTimeUnixNano string          `json:"startTimeUnixNano"`
+				EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+				Attributes        []otlpAttribute `json:"attributes"`
+				Status            *struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// attributes returns attrs keyed by name, each value as its type and value, such as "intValue 3". Ints are encoded
+// as strings, so they keep all 64 bits.
+func attributes(attrs []otlpAttribute) map[string]string {
+	values := make(map[string]string)
+	for _, attr := range attrs {
+		for kind, value := range attr.Value {
+			values[attr.Key] = fmt.Sprint(kind, " ", value)
+		}
+	}
+	return values
+}
+
+func TestOTLPExport(t *testing.T) {
+	var mu sync.Mutex
+	var requests []otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var request otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	o, err := NewOTLP(srv.URL, "deleter", map[string]string{"Authorization": "Bearer token"}, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, pass := o.Start(context.Background(), "deleter.pass", map[string]interface{}{"deleter.run_id": "run"})
+	_, company := o.Start(ctx, "deleter.company", map[string]interface{}{"deleter.company_id": "acme", "deleter.dry_run": false})
+	company.SetAttribute("deleter.bytes_freed", int64(1<<40))
+	company.SetAttribute("deleter.errors", 2)
+	company.End(errors.New("2 errors"))
+	pass.End(nil)
+	o.Close()
+
+	if len(requests) != 1 || len(requests[0].ResourceSpans) != 1 || len(requests[0].ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("got %+v, want both spans in one request", requests)
+	}
+	resource := requests[0].ResourceSpans[0]
+	if service := attributes(resource.Resource.Attributes)["service.name"]; service != "stringValue deleter" {
+		t.Errorf("got service %q", service)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "deleter.company" || spans[1].Name != "deleter.pass" {
+		t.Fatalf("got spans %+v, want the company's then the pass's, in the order they ended", spans)
+	}
+	companySpan, passSpan := spans[0], spans[1]
+	// Both are in the trace of the traceparent, the pass as the child of its span and the company of the pass.
+	for _, span := range spans {
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || len(span.SpanID) != 16 || span.StartTimeUnixNano == "" || span.EndTimeUnixNano < span.StartTimeUnixNano {
+			t.Errorf("got span %+v", span)
+		}
+	}
+	if passSpan.ParentSpanID != "00f067aa0ba902b7" || companySpan.ParentSpanID != passSpan.SpanID {
+		t.Errorf("pass has parent %s, company %s, want 00f067aa0ba902b7 and %s", passSpan.ParentSpanID, companySpan.ParentSpanID, passSpan.SpanID)
+	}
+	want := map[string]string{
+		"deleter.company_id":  "stringValue acme",
+		"deleter.dry_run":     "boolValue false",
+		"deleter.bytes_freed": "intValue 1099511627776",
+		"deleter.errors":      "intValue 2",
+	}
+	got := attributes(companySpan.Attributes)
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("attribute %s is %q, want %q", key, got[key], value)
+		}
+	}
+	if companySpan.Status == nil || companySpan.Status.Code != 2 || companySpan.Status.Message != "2 errors" {
+		t.Errorf("got company status %+v, want an error", companySpan.Status)
+	}
+	if passSpan.Status != nil {
+		t.Errorf("got pass status %+v, want none", passSpan.Status)
+	}
+}
+
+func TestOTLPKeepsSpansTheCollectorRefused(t *testing.T) {
+	var mu sync.Mutex
+	refuse := true
+	received := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if refuse {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var request otlpRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		received += len(request.ResourceSpans[0].ScopeSpans[0].Spans)
+	}))
+	defer srv.Close()
+	o, err := NewOTLP(srv.URL, "deleter", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := o.Start(context.Background(), "deleter.pass", nil)
+	span.End(nil)
+	if o.export() {
+		t.Error("export reported success to a collector that refused the spans")
+	}
+	mu.Lock()
+	refuse = false
+	mu.Unlock()
+	o.Close()
+	if received != 1 {
+		t.Errorf("collector got %d spans once it was back, want 1", received)
+	}
+}
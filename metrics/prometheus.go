@@ -0,0 +1,115 @@
+// Package metrics exports pruner events to monitoring and tracing systems.
+package metrics
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus is a pruner.Recorder that keeps per-company Prometheus metrics.
+type Prometheus struct {
+	dirsDeleted  *prometheus.CounterVec
+	filesDeleted *prometheus.CounterVec
+	filesRemoved *prometheus.CounterVec
+	bytesFreed   *prometheus.CounterVec
+	errors       *prometheus.CounterVec
+	walkDuration *prometheus.GaugeVec
+	lastSuccess  *prometheus.GaugeVec
+}
+
+// NewPrometheus creates the deleter metrics and registers them with registerer.
+func NewPrometheus(registerer prometheus.Registerer) *Prometheus {
+	labels := []string{"company"}
+	m := &Prometheus{
+		dirsDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deleter_directories_deleted_total",
+			Help: "Directories removed because they were past retention.",
+		}, labels),
+		filesDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deleter_files_deleted_total",
+			Help: "Individual files removed because they were past retention.",
+		}, labels),
+		filesRemoved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deleter_removed_files_total",
+			Help: "Regular files removed, whether inside removed directories or on their own.",
+		}, labels),
+		bytesFreed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deleter_bytes_freed_total",
+			Help: "Bytes of regular files removed.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deleter_errors_total",
+			Help: "Errors while pruning.",
+		}, labels),
+		walkDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "deleter_walk_duration_seconds",
+			Help: "Duration of the most recent pass over the company directory.",
+		}, labels),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "deleter_last_success_timestamp_seconds",
+			Help: "Unix time of the last pass over the company directory that finished without errors.",
+		}, labels),
+	}
+	registerer.MustRegister(m.dirsDeleted, m.filesDeleted, m.filesRemoved, m.bytesFreed, m.errors, m.walkDuration, m.lastSuccess)
+	return m
+}
+
+func (m *Prometheus) DirDeleted(company string, bytes int64, files int) {
+	m.dirsDeleted.WithLabelValues(company).Inc()
+	m.filesRemoved.WithLabelValues(company).Add(float64(files))
+	m.bytesFreed.WithLabelValues(company).Add(float64(bytes))
+}
+
+func (m *Prometheus) FileDeleted(company string, bytes int64) {
+	m.filesDeleted.WithLabelValues(company).Inc()
+	m.filesRemoved.WithLabelValues(company).Inc()
+	m.bytesFreed.WithLabelValues(company).Add(float64(bytes))
+}
+
+func (m *Prometheus) Error(company string) {
+	m.errors.WithLabelValues(company).Inc()
+}
+
+func (m *Prometheus) CompanyDone(company string, duration time.Duration, success bool) {
+	m.walkDuration.WithLabelValues(company).Set(duration.Seconds())
+	if success {
+		m.lastSuccess.WithLabelValues(company).SetToCurrentTime()
+	}
+}
+
+// RegisterDiskSpace registers gauges, labelled with the base directory, for the free and total bytes of the
+// filesystem holding each of paths, read from space at every scrape.
+func RegisterDiskSpace(registerer prometheus.Registerer, paths []string, space func(string) (uint64, uint64, error)) {
+	for _, path := range paths {
+		registerDiskSpace(registerer, path, space)
+	}
+}
+
+func registerDiskSpace(registerer prometheus.Registerer, path string, space func(string) (uint64, uint64, error)) {
+	read := func(total bool) func() float64 {
+		return func() float64 {
+			free, size, err := space(path)
+			if err != nil {
+				return math.NaN()
+			}
+			if total {
+				return float64(size)
+			}
+			return float64(free)
+		}
+	}
+	registerer.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "deleter_disk_free_bytes",
+			Help:        "Bytes available on the filesystem holding the base directory.",
+			ConstLabels: prometheus.Labels{"base_dir": path},
+		}, read(false)),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "deleter_disk_size_bytes",
+			Help:        "Total size of the filesystem holding the base directory.",
+			ConstLabels: prometheus.Labels{"base_dir": path},
+		}, read(true)),
+	)
+}
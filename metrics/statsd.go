@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statsd is a pruner.Recorder that sends metrics over UDP to a StatsD server, such as the Datadog agent, tagged
+// DogStatsD style with the company and, for finished passes, whether they succeeded. Metrics are sent as they happen,
+// so nothing needs to be scraped or pushed at the end of a batch run.
+type Statsd struct {
+	conn   net.Conn
+	prefix string
+	// tags are added to every metric, as "key:value" or bare "value".
+	tags []string
+}
+
+// NewStatsd returns a Statsd sending to addr, a host:port, with metric names starting with prefix.
+func NewStatsd(addr string, prefix string, tags []string) (*Statsd, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Statsd{conn: conn, prefix: prefix, tags: tags}, nil
+}
+
+// Close closes the connection.
+func (s *Statsd) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Statsd) DirDeleted(company string, bytes int64, files int) {
+	tags := s.tagged("company:"+company, "type:directory")
+	s.send(
+		s.metric("removed", "1", "c", tags),
+		s.metric("files_removed", strconv.Itoa(files), "c", tags),
+		s.metric("bytes_freed", strconv.FormatInt(bytes, 10), "c", tags),
+	)
+}
+
+func (s *Statsd) FileDeleted(company string, bytes int64) {
+	tags := s.tagged("company:"+company, "type:file")
+	s.send(
+		s.metric("removed", "1", "c", tags),
+		s.metric("files_removed", "1", "c", tags),
+		s.metric("bytes_freed", strconv.FormatInt(bytes, 10), "c", tags),
+	)
+}
+
+func (s *Statsd) Error(company string) {
+	s.send(s.metric("errors", "1", "c", s.tagged("company:"+company)))
+}
+
+func (s *Statsd) CompanyDone(company string, duration time.Duration, success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	tags := s.tagged("company:"+company, "result:"+result)
+	lines := []string{
+		s.metric("passes", "1", "c", tags),
+		s.metric("pass.duration", strconv.FormatInt(duration.Milliseconds(), 10), "ms", tags),
+	}
+	if success {
+		lines = append(lines, s.metric("last_success", strconv.FormatInt(time.Now().Unix(), 10), "g", s.tagged("company:"+company)))
+	}
+	s.send(lines...)
+}
+
+// tagged returns the DogStatsD tag list of tags and the Statsd's own tags.
+func (s *Statsd) tagged(tags ...string) string {
+	all := make([]string, 0, len(tags)+len(s.tags))
+	for _, tag := range append(tags, s.tags...) {
+		all = append(all, statsdTagReplacer.Replace(tag))
+	}
+	return strings.Join(all, ",")
+}
+
+// statsdTagReplacer replaces the characters that would end a tag or the line it is on.
+var statsdTagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+func (s *Statsd) metric(name string, value string, kind string, tags string) string {
+	line := s.prefix + name + ":" + value + "|" + kind
+	if tags != "" {
+		line += "|#" + tags
+	}
+	return line
+}
+
+// send sends lines in a single datagram. StatsD over UDP is fire and forget, so errors are ignored.
+func (s *Statsd) send(lines ...string) {
+	s.conn.Write([]byte(strings.Join(lines, "\n")))
+}
@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// ErrorTracker reports a failed pass, with its summary attached, to an error-tracking service.
+type ErrorTracker interface {
+	Report(summary pruner.Summary) error
+}
+
+// ErrorTrackers reports every failed pass to each of its ErrorTrackers. A pass counts as failed by Failed, so the
+// errors inside a pass are reported once, together, rather than one at a time.
+type ErrorTrackers []ErrorTracker
+
+// Pass reports the summary of a pass if it failed. It fits Pruner.AfterPass.
+func (t ErrorTrackers) Pass(summary pruner.Summary) {
+	if !Failed(summary) {
+		return
+	}
+	for _, tracker := range t {
+		if err := tracker.Report(summary); err != nil {
+			log.WithField("tracker", fmt.Sprintf("%T", tracker)).WithField("run_id", summary.RunID).Errorln("Could not report failed pass.", err)
+		}
+	}
+}
+
+// Sentry reports failed passes to Sentry as events through its store API. Events of the same kind of failure are
+// fingerprinted alike, so that a pass failing every night shows up as one issue.
+type Sentry struct {
+	// DSN is the project's client key URL, https://<key>@<host>/<project>.
+	DSN         string
+	Environment string
+	Client      *http.Client
+}
+
+// Report sends summary to Sentry as an error event.
+func (s Sentry) Report(summary pruner.Summary) error {
+	endpoint, auth, err := s.parseDSN()
+	if err != nil {
+		return err
+	}
+	subject, body := Describe(summary)
+	id := make([]byte, 16)
+	rand.Read(id)
+	host, _ := os.Hostname()
+	tags := map[string]string{"run_id": summary.RunID, "state": failureState(summary)}
+	if summary.CorrelationID != "" {
+		tags["correlation_id"] = summary.CorrelationID
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   summary.End.UTC().Format(time.RFC3339),
+		"level":       "error",
+		"logger":      "deleter",
+		"platform":    "go",
+		"server_name": host,
+		"environment": s.Environment,
+		"message":     map[string]string{"formatted": subject},
+		"fingerprint": []string{"deleter-pass", failureState(summary)},
+		"tags":        tags,
+		"extra":       map[string]interface{}{"details": body, "summary": summary},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", auth)
+	return send(s.Client, req)
+}
+
+// parseDSN returns the store endpoint and X-Sentry-Auth header of the DSN.
+func (s Sentry) parseDSN() (string, string, error) {
+	dsn, err := url.Parse(s.DSN)
+	if err != nil || dsn.User == nil || dsn.User.Username() == "" || dsn.Host == "" {
+		return "", "", fmt.Errorf("invalid Sentry DSN")
+	}
+	slash := strings.LastIndex(dsn.Path, "/")
+	project := dsn.Path[slash+1:]
+	if project == "" {
+		return "", "", fmt.Errorf("invalid Sentry DSN: no project")
+	}
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/store/", dsn.Scheme, dsn.Host, dsn.Path[:slash], project)
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=deleter, sentry_timestamp=%d, sentry_key=%s", time.Now().Unix(), dsn.User.Username())
+	if secret, ok := dsn.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	return endpoint, auth, nil
+}
+
+// ErrorWebhook reports failed passes by POSTing a JSON ErrorReport to URL, for error trackers other than Sentry.
+type ErrorWebhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// ErrorReport is the body of an ErrorWebhook request: Message and Details are Describe's subject and body.
+type ErrorReport struct {
+	Level         string         `json:"level"`
+	Message       string         `json:"message"`
+	Details       string         `json:"details"`
+	State         string         `json:"state"`
+	Host          string         `json:"host"`
+	RunID         string         `json:"runId"`
+	CorrelationID string         `json:"correlationId,omitempty"`
+	Summary       pruner.Summary `json:"summary"`
+}
+
+// Report POSTs summary to the webhook.
+func (w ErrorWebhook) Report(summary pruner.Summary) error {
+	subject, body := Describe(summary)
+	host, _ := os.Hostname()
+	payload, err := json.Marshal(ErrorReport{
+		Level:         "error",
+		Message:       subject,
+		Details:       body,
+		State:         failureState(summary),
+		Host:          host,
+		RunID:         summary.RunID,
+		CorrelationID: summary.CorrelationID,
+		Summary:       summary,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(w.Client, req)
+}
+
+// failureState names the way a failed pass failed: aborted, interrupted, or errors.
+func failureState(summary pruner.Summary) string {
+	switch {
+	case summary.Aborted:
+		return "aborted"
+	case summary.Interrupted:
+		return "interrupted"
+	}
+	return "errors"
+}
+
+// send sends req with client, or a client with a 30s timeout if that is nil, and fails unless the answer is 2xx.
+func send(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
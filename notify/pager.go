@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// Pager raises and resolves alerts with an on-call service. Key identifies the alert, so that it is raised once
+// and resolved by the same key.
+type Pager interface {
+	Trigger(key string, summary string, details map[string]interface{}) error
+	Resolve(key string) error
+}
+
+// FailureAlerts pages when a company's passes have failed Threshold times in a row, and resolves the alert once
+// one succeeds. A pass over a company fails when it has errors or doesn't complete, such as when the run window
+// closes on it; passes interrupted by shutting down don't count either way.
+type FailureAlerts struct {
+	Pagers    []Pager
+	Threshold int
+
+	mu      sync.Mutex
+	streaks map[string]int
+}
+
+// Pass counts the failures in the summary of a pass and pages or resolves as needed. It fits Pruner.AfterPass.
+func (a *FailureAlerts) Pass(summary pruner.Summary) {
+	if summary.Interrupted {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.streaks == nil {
+		a.streaks = make(map[string]int)
+	}
+	host, _ := os.Hostname()
+	for _, stats := range summary.Companies {
+		if stats.Paused || stats.Unknown || stats.Resumed {
+			continue
+		}
+		company := stats.Company
+		if stats.BaseDir != "" {
+			company = filepath.Join(stats.BaseDir, stats.Company)
+		}
+		key := "deleter/" + host + "/" + company
+		if stats.Errors == 0 && stats.Completed {
+			if a.streaks[company] >= a.Threshold {
+				a.page(func(pager Pager) error { return pager.Resolve(key) }, company)
+			}
+			delete(a.streaks, company)
+			continue
+		}
+		a.streaks[company]++
+		if a.streaks[company] != a.Threshold {
+			continue
+		}
+		message := fmt.Sprintf("deleter: pruning %s on %s has failed %d times in a row", company, host, a.Threshold)
+		details := map[string]interface{}{
+			"company":     stats.Company,
+			"baseDir":     stats.BaseDir,
+			"host":        host,
+			"failures":    a.Threshold,
+			"runId":       summary.RunID,
+			"errors":      stats.Errors,
+			"completed":   stats.Completed,
+			"failedPaths": stats.FailedPaths,
+		}
+		a.page(func(pager Pager) error { return pager.Trigger(key, message, details) }, company)
+	}
+}
+
+func (a *FailureAlerts) page(call func(Pager) error, company string) {
+	for _, pager := range a.Pagers {
+		if err := call(pager); err != nil {
+			log.WithField("pager", fmt.Sprintf("%T", pager)).WithField("company_id", company).Errorln("Could not page.", err)
+		}
+	}
+}
+
+// PagerDuty raises alerts through the PagerDuty Events API v2.
+type PagerDuty struct {
+	RoutingKey string
+	// URL defaults to https://events.pagerduty.com/v2/enqueue.
+	URL    string
+	Client *http.Client
+}
+
+func (p PagerDuty) Trigger(key string, summary string, details map[string]interface{}) error {
+	host, _ := os.Hostname()
+	return p.enqueue(map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         host,
+			"severity":       "error",
+			"component":      details["company"],
+			"class":          "prune failure",
+			"custom_details": details,
+		},
+	})
+}
+
+func (p PagerDuty) Resolve(key string) error {
+	return p.enqueue(map[string]interface{}{"routing_key": p.RoutingKey, "event_action": "resolve", "dedup_key": key})
+}
+
+func (p PagerDuty) enqueue(event map[string]interface{}) error {
+	endpoint := p.URL
+	if endpoint == "" {
+		endpoint = "https://events.pagerduty.com/v2/enqueue"
+	}
+	return postJSON(p.Client, endpoint, nil, event)
+}
+
+// Opsgenie raises alerts through the Opsgenie Alert API.
+type Opsgenie struct {
+	APIKey string
+	// URL defaults to https://api.opsgenie.com, or use https://api.eu.opsgenie.com for the EU instance.
+	URL    string
+	Client *http.Client
+}
+
+func (o Opsgenie) Trigger(key string, summary string, details map[string]interface{}) error {
+	// Opsgenie details are string to string.
+	flat := make(map[string]string, len(details))
+	for name, value := range details {
+		flat[name] = fmt.Sprint(value)
+	}
+	return postJSON(o.Client, o.baseURL()+"/v2/alerts", o.headers(), map[string]interface{}{
+		"message":  summary,
+		"alias":    key,
+		"source":   "deleter",
+		"priority": "P2",
+		"details":  flat,
+	})
+}
+
+func (o Opsgenie) Resolve(key string) error {
+	return postJSON(o.Client, o.baseURL()+"/v2/alerts/"+url.PathEscape(key)+"/close?identifierType=alias", o.headers(), map[string]string{"source": "deleter"})
+}
+
+func (o Opsgenie) baseURL() string {
+	if o.URL != "" {
+		return o.URL
+	}
+	return "https://api.opsgenie.com"
+}
+
+func (o Opsgenie) headers() map[string]string {
+	return map[string]string{"Authorization": "GenieKey " + o.APIKey}
+}
+
+// postJSON POSTs body as JSON to endpoint with headers, failing unless the answer is 2xx.
+func postJSON(client *http.Client, endpoint string, headers map[string]string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return send(client, req)
+}
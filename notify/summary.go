@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// Notifier sends a human-readable summary of a pass somewhere.
+type Notifier interface {
+	Notify(subject string, body string) error
+}
+
+// Summaries sends a summary of every pass to each of its Notifiers. With FailuresOnly, only passes that had errors,
+// were interrupted, or were aborted are sent.
+type Summaries struct {
+	Notifiers    []Notifier
+	FailuresOnly bool
+}
+
+// Pass sends the summary of a pass. It fits Pruner.AfterPass.
+func (s Summaries) Pass(summary pruner.Summary) {
+	if s.FailuresOnly && !Failed(summary) {
+		return
+	}
+	subject, body := Describe(summary)
+	for _, notifier := range s.Notifiers {
+		if err := notifier.Notify(subject, body); err != nil {
+			log.WithField("notifier", fmt.Sprintf("%T", notifier)).Errorln("Could not send pass summary.", err)
+		}
+	}
+}
+
+// LowDisk sends an alert that the disk is filling up, whatever FailuresOnly says.
+func (s Summaries) LowDisk(usage DiskUsage, action string) {
+	host, _ := os.Hostname()
+	subject := fmt.Sprintf("deleter: disk %.1f%% full on %s", usage.UsedPercent(), host)
+	body := fmt.Sprintf("The filesystem holding %s on %s has %s free of %s.\n\n%s\n",
+		usage.Path, host, FormatBytes(int64(usage.Free)), FormatBytes(int64(usage.Total)), action)
+	for _, notifier := range s.Notifiers {
+		if err := notifier.Notify(subject, body); err != nil {
+			log.WithField("notifier", fmt.Sprintf("%T", notifier)).Errorln("Could not send low disk alert.", err)
+		}
+	}
+}
+
+// Failed reports whether a pass had errors, was interrupted, or was aborted.
+func Failed(summary pruner.Summary) bool {
+	return summary.Totals().Errors > 0 || summary.Interrupted || summary.Aborted
+}
+
+// Describe writes a short subject line and a plain-text body summarising a pass.
+func Describe(summary pruner.Summary) (string, string) {
+	totals := summary.Totals()
+	state := "finished"
+	switch {
+	case summary.Aborted && len(summary.UnknownCompanies) > 0:
+		state = "ABORTED by companies without a config entry"
+	case summary.Aborted:
+		state = "ABORTED by deletion cap"
+	case summary.Interrupted:
+		state = "INTERRUPTED"
+	case totals.Errors > 0:
+		state = "finished with errors"
+	}
+	subject := fmt.Sprintf("deleter pass %s: %d companies, %s freed, %d errors",
+		state, len(summary.Companies), FormatBytes(totals.BytesFreed), totals.Errors)
+	var body strings.Builder
+	fmt.Fprintf(&body, "Run %s %s after %s.\n\n", summary.RunID, state, summary.End.Sub(summary.Start).Round(time.Second))
+	fmt.Fprintf(&body, "Companies processed: %d of %d\n", summary.CompletedCount(), len(summary.Companies))
+	fmt.Fprintf(&body, "Directories removed: %d\n", totals.DirsDeleted+totals.DirsTrashed)
+	fmt.Fprintf(&body, "Files removed: %d\n", totals.FilesDeleted)
+	fmt.Fprintf(&body, "Freed: %s in %d files\n", FormatBytes(totals.BytesFreed), totals.FilesRemoved)
+	if len(summary.UnknownCompanies) > 0 {
+		fmt.Fprintf(&body, "No config entry for: %s\n", strings.Join(summary.UnknownCompanies, ", "))
+	}
+	if len(summary.OrphanedEntries) > 0 {
+		fmt.Fprintf(&body, "No directory for config entries: %s\n", strings.Join(summary.OrphanedEntries, ", "))
+	}
+	fmt.Fprintf(&body, "Errors: %d\n", totals.Errors)
+	var failed []string
+	for _, stats := range summary.Companies {
+		if stats.Errors > 0 {
+			failed = append(failed, fmt.Sprintf("  %s: %d errors", stats.Company, stats.Errors))
+		} else if !stats.Completed {
+			failed = append(failed, fmt.Sprintf("  %s: not completed", stats.Company))
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		fmt.Fprintf(&body, "\nCompanies with problems:\n%s\n", strings.Join(failed, "\n"))
+	}
+	return subject, body.String()
+}
+
+// FormatBytes formats a byte count with a binary unit, e.g. 1.5 GiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Slack posts summaries to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func (s Slack) Notify(subject string, body string) error {
+	payload, err := json.Marshal(map[string]string{"text": "*" + subject + "*\n```" + body + "```"})
+	if err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Post(s.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: %s", resp.Status)
+	}
+	return nil
+}
+
+// Email sends summaries through an SMTP server. Auth may be nil for servers that don't need it.
+type Email struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (e Email) Notify(subject string, body string) error {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", e.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return smtp.SendMail(e.Addr, e.Auth, e.From, e.To, message.Bytes())
+}
@@ -0,0 +1,166 @@
+// Package notify tells other systems what the pruner did.
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// Event is the JSON body of a webhook request. Exactly one of Summary, Removal and Disk is set, according to Type.
+type Event struct {
+	Type    string              `json:"type"`
+	Time    time.Time           `json:"time"`
+	Summary *pruner.Summary     `json:"summary,omitempty"`
+	Removal *pruner.AuditRecord `json:"removal,omitempty"`
+	Disk    *DiskUsage          `json:"disk,omitempty"`
+}
+
+const (
+	EventPass    = "pass"
+	EventRemoval = "removal"
+	EventLowDisk = "low_disk"
+)
+
+// DiskUsage describes how full the filesystem holding the base directory is.
+type DiskUsage struct {
+	Path  string `json:"path"`
+	Free  uint64 `json:"freeBytes"`
+	Total uint64 `json:"totalBytes"`
+}
+
+// UsedPercent is how much of the filesystem is in use, from 0 to 100.
+func (d DiskUsage) UsedPercent() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return 100 - float64(d.Free)*100/float64(d.Total)
+}
+
+// Webhook POSTs events to a set of URLs from a background goroutine, retrying failed deliveries with exponential
+// backoff. If Secret is set, every request carries X-Deleter-Timestamp, the Unix time it was signed at, and
+// X-Deleter-Signature, "sha256=" followed by the hex HMAC-SHA256 of the timestamp, a ".", and the body.
+type Webhook struct {
+	URLs   []string
+	Secret string
+	// Attempts is how many times a delivery is tried before it is given up on, and Backoff the wait after the first
+	// failure, doubling after each one.
+	Attempts int
+	Backoff  time.Duration
+	Client   *http.Client
+
+	queue chan Event
+	done  sync.WaitGroup
+}
+
+// NewWebhook returns a started Webhook. Call Close to deliver what is queued before exiting.
+func NewWebhook(urls []string, secret string) *Webhook {
+	w := &Webhook{
+		URLs:     urls,
+		Secret:   secret,
+		Attempts: 5,
+		Backoff:  time.Second,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		queue:    make(chan Event, 1024),
+	}
+	w.done.Add(1)
+	go w.deliverAll()
+	return w
+}
+
+// Pass queues a pass event. It fits Pruner.AfterPass.
+func (w *Webhook) Pass(summary pruner.Summary) {
+	w.queue <- Event{Type: EventPass, Time: summary.End, Summary: &summary}
+}
+
+// Removal queues a removal event. It fits Pruner.OnRemove. When the queue is full it blocks, slowing the pass down
+// rather than losing the event.
+func (w *Webhook) Removal(record pruner.AuditRecord) {
+	w.queue <- Event{Type: EventRemoval, Time: record.Time, Removal: &record}
+}
+
+// LowDisk queues an alert that the disk is filling up.
+func (w *Webhook) LowDisk(usage DiskUsage) {
+	w.queue <- Event{Type: EventLowDisk, Time: time.Now().UTC(), Disk: &usage}
+}
+
+// Close delivers the queued events and stops. Nothing may be queued after Close.
+func (w *Webhook) Close() {
+	close(w.queue)
+	w.done.Wait()
+}
+
+func (w *Webhook) deliverAll() {
+	defer w.done.Done()
+	for event := range w.queue {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Errorln("Could not encode webhook event.", err)
+			continue
+		}
+		for _, url := range w.URLs {
+			if err := w.deliver(url, body); err != nil {
+				log.WithField("url", url).WithField("type", event.Type).Errorln("Giving up on webhook delivery.", err)
+			}
+		}
+	}
+}
+
+// deliver POSTs body to url until it gets a 2xx response or runs out of attempts.
+func (w *Webhook) deliver(url string, body []byte) error {
+	backoff := w.Backoff
+	var err error
+	for attempt := 1; attempt <= w.Attempts; attempt++ {
+		if err = w.post(url, body); err == nil {
+			return nil
+		}
+		if attempt < w.Attempts {
+			log.WithField("url", url).WithField("attempt", attempt).Warnln("Webhook delivery failed, retrying.", err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
+
+func (w *Webhook) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Deleter-Timestamp", timestamp)
+		req.Header.Set("X-Deleter-Signature", "sha256="+Sign(w.Secret, timestamp, body))
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a webhook request, for receivers to compare against
+// X-Deleter-Signature.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package pruner
+
+import (
+	"io/fs"
+	"time"
+)
+
+// recentWrite returns the first file at or below path, and when it was modified, that was modified within the
+// company's skipModifiedWithin, or "" if there is none or no window is set.
+func (c *companyRun) recentWrite(path string, isDir bool) (string, time.Time) {
+	window, err := time.ParseDuration(c.config.SkipModifiedWithin)
+	if err != nil || window <= 0 {
+		return "", time.Time{}
+	}
+	since := c.p.Clock.Now().Add(-window)
+	if !isDir {
+		if info, err := c.fs.Stat(path); err == nil && info.ModTime().After(since) {
+			return path, info.ModTime()
+		}
+		return "", time.Time{}
+	}
+	var found string
+	var modified time.Time
+	c.fs.WalkDir(path, func(path string, f fs.DirEntry, err error) error {
+		if err != nil || f.IsDir() {
+			return nil
+		}
+		if info, err := f.Info(); err == nil && info.ModTime().After(since) {
+			found, modified = path, info.ModTime()
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found, modified
+}
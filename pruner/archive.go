@@ -0,0 +1,35 @@
+package pruner
+
+import (
+	"path"
+	"time"
+
+	"github.com/moriarty-s3a/deleter/archive"
+)
+
+// archive uploads a copy of path to the company's archive backend, under
+// <prefix>/<company>/<path relative to the company directory>.tar.gz.
+func (c *companyRun) archive(localPath string) error {
+	c.mu.Lock()
+	if c.archiver == nil {
+		backend, err := archive.NewBackend(*c.config.Archive)
+		if err != nil {
+			c.mu.Unlock()
+			return err
+		}
+		c.archiver = backend
+	}
+	archiver := c.archiver
+	c.mu.Unlock()
+	key := path.Join(c.config.Archive.Prefix, c.stats.Company, relativePath(c.dir, localPath)) + ".tar.gz"
+	pathLog := c.log.WithField("path", localPath).WithField("archive_key", key)
+	pathLog.Debugln("Archiving")
+	// Elapsed time is measured on the wall clock: Clock is fixed under -as-of.
+	start := time.Now()
+	size, err := archive.Directory(c.ctx, archiver, key, localPath)
+	if err != nil {
+		return err
+	}
+	pathLog.WithField("archive_bytes", size).WithField("duration", time.Since(start).Round(time.Millisecond).String()).Infoln("Archived")
+	return nil
+}
@@ -0,0 +1,168 @@
+package pruner
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// AuditRecord is a single entry in the audit log. Hash covers every other field, including PrevHash, so a record
+// can't be altered, removed, or reordered without breaking the chain from that point on.
+type AuditRecord struct {
+	Seq   int64     `json:"seq"`
+	Time  time.Time `json:"time"`
+	RunID string    `json:"runId"`
+	// CorrelationID is left out when empty, so that records from before it existed still hash the same.
+	CorrelationID string `json:"correlationId,omitempty"`
+	Company       string `json:"companyId"`
+	Action        string `json:"action"`
+	Path          string `json:"path"`
+	Bytes         int64  `json:"bytes"`
+	PrevHash      string `json:"prevHash"`
+	Hash          string `json:"hash,omitempty"`
+}
+
+const (
+	AuditDeleted = "deleted"
+	AuditTrashed = "trashed"
+	// AuditCompressed is a directory replaced by a compressed archive of it under CompressAfter.
+	AuditCompressed = "compressed"
+	// AuditMoved is a directory moved to the company's archiveTo.
+	AuditMoved = "moved"
+)
+
+// ErrFileLocked is returned by LockFile when another open file holds the lock.
+var ErrFileLocked = errors.New("locked by another process")
+
+// AuditLog is an append-only, hash-chained log of everything the pruner disposed of, one JSON record per line.
+type AuditLog struct {
+	mu       sync.Mutex
+	file     *os.File
+	seq      int64
+	lastHash string
+	// size is the length of the log, which a failed write is cut back to so that it doesn't leave half a record
+	// for the next one to be appended to.
+	size int64
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it if needed, and locks it until Close, so that
+// two processes never extend the chain at once. The existing chain is verified first so new records are never
+// appended to a log that has already been tampered with. A last record a crash left half written is cut off.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	if err := LockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit log %s: %v", path, err)
+	}
+	last, complete, err := verifyAuditLog(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > complete {
+		log.WithField("audit_log", path).Warnln("Cutting off a half written last record")
+		if err := file.Truncate(complete); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return &AuditLog{file: file, seq: last.Seq, lastHash: last.Hash, size: complete}, nil
+}
+
+// Append adds a record to the log, filling in its sequence number and hashes, and syncs it to disk.
+func (a *AuditLog) Append(record AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	record.Seq = a.seq + 1
+	record.PrevHash = a.lastHash
+	hash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		a.file.Truncate(a.size)
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	a.size += int64(len(line)) + 1
+	a.seq = record.Seq
+	a.lastHash = record.Hash
+	return nil
+}
+
+// Close closes the underlying file.
+func (a *AuditLog) Close() error {
+	return a.file.Close()
+}
+
+func (r AuditRecord) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAuditLog reads an audit log and checks every record's hash and its link to the previous record. It returns
+// the last record, or a zero record for an empty log. A last line without a newline is one a crash left half
+// written, and is ignored.
+func VerifyAuditLog(r io.Reader) (AuditRecord, error) {
+	last, _, err := verifyAuditLog(r)
+	return last, err
+}
+
+// verifyAuditLog is VerifyAuditLog, also returning the size of the log up to the end of its last complete line.
+func verifyAuditLog(r io.Reader) (last AuditRecord, complete int64, err error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	line := 0
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return last, complete, nil
+		}
+		if err != nil {
+			return last, complete, err
+		}
+		line++
+		var record AuditRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return last, complete, fmt.Errorf("audit log line %d: %v", line, err)
+		}
+		if record.Seq != last.Seq+1 {
+			return last, complete, fmt.Errorf("audit log line %d: sequence %d follows %d", line, record.Seq, last.Seq)
+		}
+		if record.PrevHash != last.Hash {
+			return last, complete, fmt.Errorf("audit log line %d: previous hash does not match record %d", line, last.Seq)
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return last, complete, err
+		}
+		if hash != record.Hash {
+			return last, complete, fmt.Errorf("audit log line %d: hash does not match contents", line)
+		}
+		last = record
+		complete += int64(len(data))
+	}
+}
@@ -0,0 +1,107 @@
+package pruner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendRecords(t *testing.T, path string, count int) {
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	for i := 0; i < count; i++ {
+		if err := audit.Append(AuditRecord{Company: "acme", Action: AuditDeleted, Path: "/data/acme/2020"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func verifyFile(t *testing.T, path string) AuditRecord {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	last, err := VerifyAuditLog(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return last
+}
+
+func TestAuditLogCutsOffHalfWrittenRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	appendRecords(t, path, 2)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte(`{"seq":3,"time":"20`))
+	file.Close()
+	if last := verifyFile(t, path); last.Seq != 2 {
+		t.Errorf("last record is %d, want 2", last.Seq)
+	}
+	appendRecords(t, path, 1)
+	if last := verifyFile(t, path); last.Seq != 3 {
+		t.Errorf("last record is %d after appending, want 3", last.Seq)
+	}
+}
+
+func TestAuditLogIsLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, err := OpenAuditLog(path); err == nil {
+		second.Close()
+		t.Error("opened an audit log another writer has open")
+	}
+	audit.Close()
+	if audit, err = OpenAuditLog(path); err != nil {
+		t.Fatalf("could not open the audit log once its writer closed it: %v", err)
+	}
+	audit.Close()
+}
+
+func TestAuditRecordsUseTheWallClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+	// As under -as-of with -confirm-as-of: the removal is real, only the cutoff is simulated.
+	p := memPruner(fsys, dailyConfig(nil))
+	p.Audit = audit
+	var events []Event
+	p.OnEvent = func(event Event) { events = append(events, event) }
+	start := time.Now()
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit.Close()
+	end := time.Now()
+	ran := func(at time.Time) bool { return !at.Before(start) && !at.After(end) }
+	if last := verifyFile(t, path); last.Seq != 1 || !ran(last.Time) {
+		t.Errorf("audit record %d is stamped %v, want the time it was written", last.Seq, last.Time)
+	}
+	for _, event := range events {
+		if !ran(event.Time) {
+			t.Errorf("%s event for %s is stamped %v, want the time it happened", event.Kind, event.Path, event.Time)
+		}
+	}
+	if !ran(summary.Start) || !ran(summary.End) {
+		t.Errorf("pass ran from %v to %v, want the wall clock", summary.Start, summary.End)
+	}
+	if summary.AsOf == nil || !summary.AsOf.Equal(testNow) {
+		t.Errorf("pass is as of %v, want %v", summary.AsOf, testNow)
+	}
+}
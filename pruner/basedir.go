@@ -0,0 +1,142 @@
+package pruner
+
+import "path/filepath"
+
+// BaseDirConfig is a base directory named in the config, processed in the same passes as the Pruner's own.
+type BaseDirConfig struct {
+	Path string `json:"path"`
+	// Default, if set, replaces the default entry for the company directories under Path that have no entry of
+	// their own. Settings it leaves unset are the default entry's.
+	Default *CompanyConfig `json:"default,omitempty"`
+}
+
+// companyDir is one company directory under one of the base directories.
+type companyDir struct {
+	name string
+	base string
+	// shown is base when there are several base directories, and "" otherwise, so that with a single one a company
+	// is known by its id alone.
+	shown string
+}
+
+func (d companyDir) path() string {
+	return filepath.Join(d.base, d.name)
+}
+
+// key identifies the company directory in checkpoints and schedules, like CompanyStats.key.
+func (d companyDir) key() string {
+	return filepath.Join(d.shown, d.name)
+}
+
+// key is the company id, or with several base directories the company directory's path.
+func (s CompanyStats) key() string {
+	return filepath.Join(s.BaseDir, s.Company)
+}
+
+// Bases returns every base directory passes cover: BaseDir, then BaseDirs, then those in the config.
+func (p *Pruner) Bases() []string {
+	return p.bases(p.Config())
+}
+
+func (p *Pruner) bases(config Config) []string {
+	bases := []string{filepath.Clean(p.BaseDir)}
+	for _, base := range p.BaseDirs {
+		if base = filepath.Clean(base); !containsString(bases, base) {
+			bases = append(bases, base)
+		}
+	}
+	for _, entry := range config.BaseDirs {
+		if base := filepath.Clean(entry.Path); !containsString(bases, base) {
+			bases = append(bases, base)
+		}
+	}
+	return bases
+}
+
+// companyDirs lists the company directories under every base directory, leaving out those outside Shard, Companies
+// or the config's AllowCompanies, and those in ExcludeCompanies. Companies whose entry sets Storage or SFTP are listed
+// under the first base directory whether or not they have a directory there.
+func (p *Pruner) companyDirs() ([]companyDir, error) {
+	config := p.Config()
+	bases := p.bases(config)
+	var companies []companyDir
+	seen := make(map[string]bool)
+	add := func(name string, base string) {
+		if !config.Allowed(name) || (p.Shard != nil && !p.Shard(name)) {
+			return
+		}
+		if (len(p.Companies) == 0 || containsString(p.Companies, name)) && !containsString(p.ExcludeCompanies, name) {
+			dir := companyDir{name: name, base: base}
+			if len(bases) > 1 {
+				dir.shown = base
+			}
+			companies = append(companies, dir)
+			seen[name] = true
+		}
+	}
+	for _, base := range bases {
+		entries, err := p.FS.ReadDir(base)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				add(entry.Name(), base)
+			}
+		}
+	}
+	for _, entry := range config.CompanyConfigs {
+		if entry.remote() && !IsPattern(entry.Id) && !seen[entry.Id] {
+			add(entry.Id, bases[0])
+		}
+	}
+	return companies, nil
+}
+
+// companyNames returns the distinct ids of the company directories.
+func companyNames(dirs []companyDir) []string {
+	var names []string
+	for _, dir := range dirs {
+		if !containsString(names, dir.name) {
+			names = append(names, dir.name)
+		}
+	}
+	return names
+}
+
+// configMaps returns ConfigMap for each base directory, with the default entry replaced by the base directory's own
+// default where it has one.
+func (p *Pruner) configMaps(config Config) map[string]map[string]CompanyConfig {
+	shared := ConfigMap(config)
+	maps := make(map[string]map[string]CompanyConfig)
+	for _, base := range p.bases(config) {
+		maps[base] = shared
+	}
+	for _, entry := range config.BaseDirs {
+		if entry.Default == nil {
+			continue
+		}
+		configMap := make(map[string]CompanyConfig, len(shared))
+		for id, company := range shared {
+			configMap[id] = company
+		}
+		configMap["default"] = inherit(*entry.Default, shared["default"])
+		maps[filepath.Clean(entry.Path)] = configMap
+	}
+	return maps
+}
+
+// baseOf returns the base directory path is below, as Bases has it and made absolute, or "" if it is below none of
+// them. path must be absolute and clean.
+func (p *Pruner) baseOf(path string) (string, string, error) {
+	for _, base := range p.Bases() {
+		abs, err := filepath.Abs(base)
+		if err != nil {
+			return "", "", err
+		}
+		if path != abs && within(abs, path) {
+			return base, abs, nil
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,141 @@
+package pruner
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Traversal is one way of walking a directory tree, compared with the others by Bench.
+type Traversal struct {
+	Name        string
+	Description string
+	// Walk walks the tree at root as filepath.WalkDir does. syscalls, if not nil, is for traversals that can count
+	// the system calls they make to add them to.
+	Walk func(root string, fn fs.WalkDirFunc, syscalls *int64) error
+}
+
+// Traversals are the traversals Bench knows, the one passes use first.
+var Traversals = []Traversal{
+	{
+		Name:        "stream",
+		Description: "os.File.ReadDir in batches of 1024 entries, which passes use",
+		Walk: func(root string, fn fs.WalkDirFunc, _ *int64) error {
+			return OSFileSystem{}.WalkDir(root, fn)
+		},
+	},
+	{
+		Name:        "walkdir",
+		Description: "filepath.WalkDir, which reads each directory whole",
+		Walk: func(root string, fn fs.WalkDirFunc, _ *int64) error {
+			return filepath.WalkDir(root, fn)
+		},
+	},
+	{
+		Name:        "walk",
+		Description: "filepath.Walk, which lstats every entry as it reads it",
+		Walk: func(root string, fn fs.WalkDirFunc, _ *int64) error {
+			return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				var entry fs.DirEntry
+				if info != nil {
+					entry = fs.FileInfoToDirEntry(info)
+				}
+				return fn(path, entry, err)
+			})
+		},
+	},
+	{
+		Name:        "readdir",
+		Description: "os.ReadDir for each directory, as FileSystems without a walk of their own do",
+		Walk: func(root string, fn fs.WalkDirFunc, _ *int64) error {
+			return readDirWalk(OSFileSystem{}, root, fn)
+		},
+	},
+}
+
+// BenchResult is what one walk of a tree with a Traversal took.
+type BenchResult struct {
+	Traversal string
+	Dirs      int64
+	Files     int64
+	Errors    int64
+	Wall      time.Duration
+	// User and System are the CPU time the process spent, which for a walk is mostly System, in system calls.
+	User   time.Duration
+	System time.Duration
+	// Syscalls is how many system calls the walk made, or -1 if the traversal can't count them.
+	Syscalls int64
+	// Allocated and Mallocs are how many bytes and objects the walk allocated, and PeakHeap the most heap in use
+	// at any of the samples taken every 10ms.
+	Allocated uint64
+	Mallocs   uint64
+	PeakHeap  uint64
+}
+
+// Bench walks root with traversal without removing anything. With stat, it also stats every file, as mtime mode
+// does, so the cost of that is included.
+func Bench(root string, traversal Traversal, stat bool) (BenchResult, error) {
+	result := BenchResult{Traversal: traversal.Name, Syscalls: -1}
+	var syscalls int64
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var peak uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > atomic.LoadUint64(&peak) {
+				atomic.StoreUint64(&peak, stats.HeapInuse)
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	userBefore, systemBefore := cpuTimes()
+	start := time.Now()
+
+	err := traversal.Walk(root, func(path string, f fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			result.Errors++
+		case f.IsDir():
+			result.Dirs++
+		default:
+			result.Files++
+			if stat {
+				if _, err := f.Info(); err != nil {
+					result.Errors++
+				}
+			}
+		}
+		return nil
+	}, &syscalls)
+
+	result.Wall = time.Since(start)
+	userAfter, systemAfter := cpuTimes()
+	result.User, result.System = userAfter-userBefore, systemAfter-systemBefore
+	close(done)
+	<-sampled
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	result.Allocated = after.TotalAlloc - before.TotalAlloc
+	result.Mallocs = after.Mallocs - before.Mallocs
+	result.PeakHeap = atomic.LoadUint64(&peak)
+	if syscalls > 0 {
+		result.Syscalls = syscalls
+	}
+	return result, err
+}
@@ -0,0 +1,8 @@
+package pruner
+
+import "time"
+
+// cpuTimes is not supported on Windows, so benchmarks report no CPU time.
+func cpuTimes() (time.Duration, time.Duration) {
+	return 0, 0
+}
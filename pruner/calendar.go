@@ -0,0 +1,148 @@
+package pruner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarRule keeps the days Days picks out for longer than the company's retention, such as month-end days
+// indefinitely or Sundays for a year. Days is a comma-separated list of any of:
+//
+//	monday ... sunday      that day of the week
+//	weekday, weekend       Mondays to Fridays, Saturdays and Sundays
+//	1 ... 31               that day of the month
+//	month-start            the first day of the month
+//	month-end              the last day of the month
+//	business-month-end     the last Monday to Friday of the month
+//	quarter-end, year-end  the last day of March, June, September and December, and of December
+//
+// Retention is in the same format as retentionDays, counted from now. Without one, the days are kept indefinitely.
+type CalendarRule struct {
+	Days      string `json:"days"`
+	Retention string `json:"retention,omitempty"`
+}
+
+// calendarRule is a CalendarRule ready to apply: days that match are kept if they end on or after cutoff, which is
+// zero for rules without a retention.
+type calendarRule struct {
+	match  func(day time.Time) bool
+	cutoff time.Time
+}
+
+// parseDays parses a CalendarRule's Days.
+func parseDays(value string) (func(day time.Time) bool, error) {
+	var matchers []func(time.Time) bool
+	for _, name := range strings.Split(value, ",") {
+		matcher, err := parseDay(strings.ToLower(strings.TrimSpace(name)))
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	return func(day time.Time) bool {
+		for _, matcher := range matchers {
+			if matcher(day) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func parseDay(name string) (func(time.Time) bool, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if name == strings.ToLower(weekday.String()) {
+			weekday := weekday
+			return func(day time.Time) bool { return day.Weekday() == weekday }, nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > 31 {
+			return nil, fmt.Errorf("day of the month %d is out of range", n)
+		}
+		return func(day time.Time) bool { return day.Day() == n }, nil
+	}
+	switch name {
+	case "weekday":
+		return func(day time.Time) bool { return !weekend(day) }, nil
+	case "weekend":
+		return weekend, nil
+	case "month-start":
+		return func(day time.Time) bool { return day.Day() == 1 }, nil
+	case "month-end":
+		return monthEnd, nil
+	case "business-month-end":
+		return func(day time.Time) bool {
+			if weekend(day) {
+				return false
+			}
+			for next := day.AddDate(0, 0, 1); next.Month() == day.Month(); next = next.AddDate(0, 0, 1) {
+				if !weekend(next) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "quarter-end":
+		return func(day time.Time) bool { return monthEnd(day) && day.Month()%3 == 0 }, nil
+	case "year-end":
+		return func(day time.Time) bool { return day.Month() == time.December && day.Day() == 31 }, nil
+	}
+	return nil, fmt.Errorf("unknown day %q", name)
+}
+
+func weekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
+
+func monthEnd(day time.Time) bool {
+	return day.AddDate(0, 0, 1).Day() == 1
+}
+
+// parseCalendarRules prepares the company's calendarRules for the pass.
+func (c *companyRun) parseCalendarRules() error {
+	c.calendar = nil
+	for i, rule := range c.config.CalendarRules {
+		match, err := parseDays(rule.Days)
+		if err != nil {
+			return fmt.Errorf("calendarRules %d: %v", i+1, err)
+		}
+		parsed := calendarRule{match: match}
+		if rule.Retention != "" {
+			retention, err := ParseRetention(rule.Retention)
+			if err != nil {
+				return fmt.Errorf("calendarRules %d: %v", i+1, err)
+			}
+			parsed.cutoff = retention.Cutoff(c.now)
+		}
+		c.calendar = append(c.calendar, parsed)
+	}
+	return nil
+}
+
+// calendarKeeps reports whether a calendar rule keeps a day between start and end, which are in the company's zone,
+// and so whether to keep the expired path covering them. If more than one day is covered and there are dated
+// directories below, it asks for them to be judged instead, to keep only the days the rules pick out.
+func (c *companyRun) calendarKeeps(start time.Time, end time.Time, leaf bool) (keep bool, descend bool) {
+	if len(c.calendar) == 0 {
+		return false, false
+	}
+	loc := c.now.Location()
+	start, end = start.In(loc), end.In(loc)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	single := day.AddDate(0, 0, 1).After(end)
+	for ; !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, rule := range c.calendar {
+			if !rule.match(day) || day.AddDate(0, 0, 1).Add(-time.Nanosecond).Before(rule.cutoff) {
+				continue
+			}
+			if single || leaf {
+				return true, false
+			}
+			return true, true
+		}
+	}
+	return false, false
+}
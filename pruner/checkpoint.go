@@ -0,0 +1,59 @@
+package pruner
+
+import (
+	"strings"
+	"time"
+)
+
+// Checkpoint records how far a pass that stopped early got, so that the next pass can carry on from there instead
+// of starting over.
+type Checkpoint struct {
+	RunID string    `json:"runId"`
+	Time  time.Time `json:"time"`
+	// Done lists the companies the pass finished, by id, or by path when there are several base directories.
+	Done []string `json:"done"`
+	// Progress maps each company the pass started but didn't finish, keyed the same way, to the last directory,
+	// relative to the company directory, that it reached.
+	Progress map[string]string `json:"progress,omitempty"`
+}
+
+// Checkpoint returns where the pass stopped, or nil if it finished every company.
+func (s Summary) Checkpoint() *Checkpoint {
+	checkpoint := &Checkpoint{RunID: s.RunID, Time: s.End, Progress: make(map[string]string)}
+	for _, stats := range s.Companies {
+		switch {
+		case stats.Completed:
+			checkpoint.Done = append(checkpoint.Done, stats.key())
+		case stats.LastPath != "":
+			checkpoint.Progress[stats.key()] = stats.LastPath
+		}
+	}
+	if len(checkpoint.Done) == len(s.Companies) {
+		return nil
+	}
+	return checkpoint
+}
+
+// beforeResume reports whether the directory rel comes before resumeFrom in walk order, and so was dealt with by
+// the pass being resumed. Directories above resumeFrom don't; the walk has to go through them to get back to it.
+func beforeResume(rel string, resumeFrom string) bool {
+	if resumeFrom == "" {
+		return false
+	}
+	parts, resume := strings.Split(rel, "/"), strings.Split(resumeFrom, "/")
+	for i := 0; i < len(parts) && i < len(resume); i++ {
+		if parts[i] != resume[i] {
+			return parts[i] < resume[i]
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package pruner
+
+import (
+	"time"
+)
+
+// Clock supplies the current time to a Pruner.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock backed by time.Now.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is a Clock that always returns the same time, for simulating a pass at another date and for tests.
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// asOf returns now if the Pruner's Clock is a FixedClock, and otherwise nil. Records of what a pass did, such as
+// audit records, are stamped with the time it actually happened; the Clock only decides what has expired.
+func (p *Pruner) asOf(now time.Time) *time.Time {
+	if _, fixed := p.Clock.(FixedClock); fixed {
+		return &now
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package pruner
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFixedClock(t *testing.T) {
+	clock := FixedClock(testNow)
+	if now := clock.Now(); !now.Equal(testNow) {
+		t.Errorf("Now is %s, want %s", now, testNow)
+	}
+	time.Sleep(time.Millisecond)
+	if now := clock.Now(); !now.Equal(testNow) {
+		t.Errorf("Now moved on to %s", now)
+	}
+}
+
+func TestPassRunsAsOfFixedClock(t *testing.T) {
+	for _, test := range []struct {
+		now     time.Time
+		removed bool
+	}{
+		{now: time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), removed: false},
+		{now: time.Date(2020, 2, 15, 0, 0, 0, 0, time.UTC), removed: true},
+	} {
+		fsys := &MemFS{}
+		fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+		p := memPruner(fsys, dailyConfig(nil))
+		p.Clock = FixedClock(test.now)
+		summary, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cutoff, want := summary.Companies[0].Cutoff, test.now.AddDate(0, 0, -30); !cutoff.Equal(want) {
+			t.Errorf("as of %s: cutoff is %s, want %s", test.now, cutoff, want)
+		}
+		_, err = fsys.Stat("/data/acme/2020/01/01")
+		if removed := os.IsNotExist(err); removed != test.removed {
+			t.Errorf("as of %s: removed %v, want %v", test.now, removed, test.removed)
+		}
+	}
+}
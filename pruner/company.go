@@ -0,0 +1,897 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/archive"
+)
+
+// companyRun is the state of a single pass over a single company directory.
+type companyRun struct {
+	p      *Pruner
+	ctx    context.Context
+	dir    string
+	config CompanyConfig
+	runID  string
+	// fs is the Pruner's FS, or for a company with Storage set the object store standing in for its directory.
+	fs FileSystem
+	// now is the time the pass started, which every cutoff is computed from.
+	now       time.Time
+	retention Retention
+	cutoff    time.Time
+	// compressCutoff is the cutoff for config.CompressAfter, zero if it isn't set.
+	compressCutoff time.Time
+	// floored is set when MinKeepDays moved the cutoff back, and minAged when the Pruner's MinAge did.
+	floored bool
+	minAged bool
+	// calendar holds the config's calendarRules.
+	calendar []calendarRule
+	stats    CompanyStats
+	log      log.FieldLogger
+	// dryRun and recorder are normally the Pruner's, but a planning pass runs dry and unrecorded.
+	dryRun   bool
+	recorder Recorder
+	// permanent bypasses the trash, and action replaces AuditDeleted in audit records. Both are for purges.
+	permanent bool
+	action    string
+	// kept holds the relative paths of the newest date directories that MinKeepCount protects.
+	kept map[string]bool
+	// overrides holds the cutoffs set by .retention files, by the path of the directory holding them relative to the
+	// company directory.
+	overrides map[string]time.Time
+	// categories holds the cutoffs of the categories in config.Categories, which are categoryDepth directories below
+	// the company directory.
+	categories    map[string]time.Time
+	categoryDepth int
+	// fileLayout is parsed from config.FileLayout, if set.
+	fileLayout *Layout
+	// root, if set, is the directory removals must stay inside instead of the base directories, for the pass over
+	// an archiveTo directory.
+	root string
+	// minDepth is the shallowest removal allowed, in directories below the company directory. See
+	// CompanyConfig.MinDepth.
+	minDepth int
+	// archiver is created on first use from config.Archive.
+	archiver archive.Backend
+	// retryLater holds the removals that failed with a transient error, for retrySweep.
+	retryLater []pendingRemoval
+	// emptied holds the directories that removals were made from, for removeEmptyDirs.
+	emptied map[string]bool
+	// mu guards stats, retryLater, emptied, archiver and stager while config.Workers removals run at once, and the
+	// counters progress reports and current while the pass is under way. slots and removals bound and track them.
+	mu       sync.Mutex
+	slots    chan struct{}
+	removals sync.WaitGroup
+	// resumeFrom is where the checkpointed pass being resumed stopped in this company, relative to its directory.
+	resumeFrom string
+	// stager is started by the first removal staged under StageRemovals.
+	stager *stager
+	// current is the directory the walk is in, for progress.
+	current string
+	// removeSpans is the span covering the pass's removals. mu guards it.
+	removeSpans removeSpans
+}
+
+// newCompanyRun prepares a pass over one company directory.
+func (p *Pruner) newCompanyRun(ctx context.Context, dir companyDir, config CompanyConfig, runID string, now time.Time, logger log.FieldLogger, dryRun bool, recorder Recorder) *companyRun {
+	logger = logger.WithField("run_id", runID).WithField("company_id", dir.name)
+	if dir.shown != "" {
+		logger = logger.WithField("base_dir", dir.shown)
+	}
+	return &companyRun{
+		p:        p,
+		ctx:      ctx,
+		dir:      dir.path(),
+		config:   config,
+		runID:    runID,
+		fs:       p.companyFS(dir, config),
+		now:      now,
+		stats:    CompanyStats{Company: dir.name, BaseDir: dir.shown},
+		log:      logger,
+		dryRun:   dryRun,
+		recorder: recorder,
+		minDepth: minDepth(config),
+	}
+}
+
+// minDepth returns the shallowest removal the config allows: its MinDepth, or else the depth of the layout's first
+// date field. Nothing shallower than one directory below the company directory is ever allowed.
+func minDepth(config CompanyConfig) int {
+	depth := config.MinDepth
+	if depth == 0 && config.Mode != ModeMtime {
+		if layout, err := config.ParseLayout(); err == nil {
+			depth = layout.FirstDated()
+		}
+	}
+	if depth < 1 {
+		depth = 1
+	}
+	return depth
+}
+
+// guard returns an error unless path is at least minDepth directories inside the company directory and, with
+// symlinks resolved, still inside the base directory. It is the last check before anything is removed, whatever
+// dates the path parsed to.
+func (c *companyRun) guard(path string) error {
+	rel, err := filepath.Rel(c.dir, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("%s is not inside the company directory %s", path, c.dir)
+	}
+	if depth := len(strings.Split(rel, string(os.PathSeparator))); depth < c.minDepth {
+		return fmt.Errorf("%s is %d directories below the company directory, above minDepth %d", path, depth, c.minDepth)
+	}
+	if c.root != "" {
+		return c.p.insideRoot(c.root, path)
+	}
+	return c.inside(path)
+}
+
+// lock takes the company's lock from the Pruner's Locker, if it has one and this isn't a dry run, and reports whether
+// the company may be pruned; a company another instance holds is counted as locked and completed. Until unlock is
+// called, the pass stops as if shut down if the lock is lost, since another instance may already be pruning.
+func (c *companyRun) lock() (unlock func(), ok bool) {
+	if c.p.Locker == nil || c.dryRun {
+		return func() {}, true
+	}
+	lockCtx, release, ok, err := c.p.Locker.TryLock(c.ctx, "company/"+c.stats.Company)
+	if err != nil {
+		c.error(c.dir, "Could not lock company", err)
+		return nil, false
+	}
+	if !ok {
+		c.log.Infoln("Company is locked by another instance, skipping")
+		c.stats.Locked = true
+		c.stats.Completed = true
+		return nil, false
+	}
+	c.ctx = lockCtx
+	return func() {
+		if context.Cause(lockCtx) == ErrLockLost {
+			c.error(c.dir, "Stopped pruning company", ErrLockLost)
+		}
+		release()
+	}, true
+}
+
+// prune runs the pass and returns its stats, once any staged removals have been deleted.
+func (c *companyRun) prune() (stats CompanyStats) {
+	start := time.Now()
+	defer func() {
+		c.recorder.CompanyDone(c.stats.Company, time.Since(start), c.stats.Errors == 0)
+	}()
+	defer func() {
+		c.finishStaging()
+		c.endRemoveSpan()
+		stats = c.stats
+	}()
+	if c.config.OnLegalHold() {
+		c.log.Infoln("Company is under legal hold, skipping")
+		c.skipped(c.dir, "company is under legal hold")
+		c.stats.LegalHold = true
+		c.stats.Completed = true
+		return c.stats
+	}
+	if c.config.Exempt() {
+		c.log.Infoln("Company's retention is never, skipping")
+		c.skipped(c.dir, "company's retention is never")
+		c.stats.Exempt = true
+		c.stats.Completed = true
+		return c.stats
+	}
+	unlock, ok := c.lock()
+	if !ok {
+		return c.stats
+	}
+	defer unlock()
+	if err := c.resolveCutoff(); err != nil {
+		c.configError(err)
+		return c.stats
+	}
+	c.stats.Cutoff = c.cutoff
+	c.log = c.log.WithField("cutoff", c.cutoff.Format(time.RFC3339))
+	// The layout, and what MinKeepCount keeps, are worked out before anything is removed, so that removals earlier
+	// runs left unfinished are judged by them too.
+	var layout Layout
+	if c.config.Mode != ModeMtime {
+		var err error
+		if layout, err = c.config.ParseLayout(); err != nil {
+			c.configError(err)
+			return c.stats
+		}
+		if c.config.FileLayout != "" {
+			fileLayout, err := ParseFileLayout(c.config.FileLayout)
+			if err != nil {
+				c.configError(err)
+				return c.stats
+			}
+			c.fileLayout = &fileLayout
+		}
+		if c.config.MinKeepCount > 0 {
+			c.kept = c.newestLeaves(layout, c.config.MinKeepCount)
+		}
+	}
+	c.finishInterrupted(layout)
+	if c.p.TrashGrace > 0 || c.staging() {
+		// With StageRemovals, this deletes what an earlier pass staged but didn't get to.
+		c.emptyTrash()
+	}
+	if c.config.Mode == ModeMtime {
+		c.pruneByMtime()
+		c.retrySweep()
+		return c.stats
+	}
+	c.pruneByLayout(layout)
+	c.retrySweep()
+	if c.config.RemovesEmptyDirs() {
+		c.removeEmptyDirs(layout)
+	}
+	if c.config.ArchiveTo != "" && c.config.ArchiveRetention != "" && c.stats.Completed {
+		c.pruneTier(layout)
+	}
+	return c.stats
+}
+
+// resolveCutoff works out the company's retention and cutoff, moving now into the company's timezone, and the
+// cutoffs of the sub-tenants with a retention of their own.
+func (c *companyRun) resolveCutoff() error {
+	loc, err := c.location()
+	if err != nil {
+		return err
+	}
+	c.now = c.now.In(loc)
+	c.retention, err = ParseRetention(c.config.Retention)
+	if err != nil {
+		return err
+	}
+	c.cutoff = c.retention.Cutoff(c.now)
+	if err := c.parseCalendarRules(); err != nil {
+		return err
+	}
+	if c.config.CompressAfter != "" {
+		compressAfter, err := ParseRetention(c.config.CompressAfter)
+		if err != nil {
+			return fmt.Errorf("compressAfter: %v", err)
+		}
+		c.compressCutoff = compressAfter.Cutoff(c.now)
+	}
+	var floor time.Time
+	if c.config.MinKeepDays != "" {
+		minKeep, err := ParseRetention(c.config.MinKeepDays)
+		if err != nil {
+			return fmt.Errorf("minKeepDays: %v", err)
+		}
+		if floor = minKeep.Cutoff(c.now); floor.Before(c.cutoff) {
+			c.log.WithField("retention", c.retention.String()).WithField("min_keep", minKeep.String()).Warnln("Retention is shorter than the minimum, keeping the minimum")
+			c.cutoff = floor
+			c.floored = true
+		}
+	}
+	if c.config.HasSubtenants() {
+		for subtenant, value := range c.config.SubtenantRetention {
+			cutoff, err := c.overrideCutoff(value, floor, "subtenant", subtenant)
+			if err != nil {
+				return fmt.Errorf("subtenantRetention %s: %v", subtenant, err)
+			}
+			if c.overrides == nil {
+				c.overrides = make(map[string]time.Time)
+			}
+			c.overrides[subtenant] = cutoff
+		}
+	}
+	if len(c.config.Categories) > 0 {
+		c.categoryDepth = 1
+		if c.config.HasSubtenants() {
+			c.categoryDepth = 2
+		}
+		c.categories = make(map[string]time.Time)
+		for category, value := range c.config.Categories {
+			cutoff, err := c.overrideCutoff(value, floor, "category", category)
+			if err != nil {
+				return fmt.Errorf("categories %s: %v", category, err)
+			}
+			c.categories[category] = cutoff
+		}
+	}
+	c.holdBackToMinAge()
+	return nil
+}
+
+// holdBackToMinAge moves back any cutoff later than the Pruner's MinAge allows.
+func (c *companyRun) holdBackToMinAge() {
+	if c.p.MinAge <= 0 {
+		return
+	}
+	limit := c.now.Add(-c.p.MinAge)
+	if limit.Before(c.cutoff) {
+		c.log.WithField("retention", c.retention.String()).WithField("min_age", c.p.MinAge.String()).Warnln("Retention is shorter than the minimum age, keeping the minimum age")
+		c.cutoff = limit
+		c.minAged = true
+	}
+	for _, cutoffs := range []map[string]time.Time{c.overrides, c.categories} {
+		for name, cutoff := range cutoffs {
+			if limit.Before(cutoff) {
+				cutoffs[name] = limit
+			}
+		}
+	}
+}
+
+// overrideCutoff returns the cutoff for a sub-tenant or category's own retention, value, held back to floor if that
+// is set and later.
+func (c *companyRun) overrideCutoff(value string, floor time.Time, kind string, name string) (time.Time, error) {
+	retention, err := ParseRetention(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cutoff := retention.Cutoff(c.now)
+	if c.config.MinKeepDays != "" && floor.Before(cutoff) {
+		c.log.WithField(kind, name).WithField("retention", retention.String()).Warnln("Retention is shorter than the minimum, keeping the minimum")
+		cutoff = floor
+	}
+	return cutoff, nil
+}
+
+// location returns the zone the company's directory dates are in.
+func (c *companyRun) location() (*time.Location, error) {
+	if c.config.Timezone != "" {
+		return time.LoadLocation(c.config.Timezone)
+	}
+	if c.p.Location != nil {
+		return c.p.Location, nil
+	}
+	return time.UTC, nil
+}
+
+// pruneByLayout removes the directories whose path dates, read according to layout, are before the cutoff.
+func (c *companyRun) pruneByLayout(layout Layout) {
+	err := c.walkDir(c.dir, func(path string, f fs.DirEntry, err error) error {
+		// Stop before starting anything new once we have been told to shut down.
+		if c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		pathLog := c.log.WithField("path", path)
+		pathLog.Debugln("Walk found path")
+		if err != nil {
+			// Ignore errors so that we do as much work as possible.
+			c.error(path, "Error in path", err)
+			return nil
+		}
+		// I assume that any stray files in non-leaf directories should be left alone?
+		if !f.IsDir() {
+			if strings.HasSuffix(f.Name(), compressedSuffix) && c.pruneCompressed(path, layout) {
+				return nil
+			}
+			if c.fileLayout != nil && c.pruneFile(path, f.Name()) {
+				return nil
+			}
+			if layout.DatedBelow(len(relativeParts(c.dir, filepath.Dir(path)))) {
+				c.strayFile(path, f)
+			}
+			return nil
+		}
+		if path == filepath.Join(c.dir, trashDirName) {
+			return filepath.SkipDir
+		}
+		parts := relativeParts(c.dir, path)
+		if beforeResume(strings.Join(parts, "/"), c.resumeFrom) {
+			return filepath.SkipDir
+		}
+		c.walking(path)
+		c.mu.Lock()
+		c.stats.LastPath = strings.Join(parts, "/")
+		c.mu.Unlock()
+		compareDate, dateErr := layout.StrictDate(parts, c.now)
+		if dateErr != nil && c.config.IsStrict() {
+			pathLog.WithError(dateErr).Warnln("Skipping directory that doesn't parse as a date")
+			c.mu.Lock()
+			c.stats.DirsUnparsed++
+			c.mu.Unlock()
+			c.skipped(path, "name doesn't parse as a date")
+			return filepath.SkipDir
+		}
+		rel := relativePath(c.dir, path)
+		if c.readMarkers(path, rel) {
+			pathLog.Infoln("Pinned by a marker file, keeping")
+			c.mu.Lock()
+			c.stats.DirsExcluded++
+			c.mu.Unlock()
+			c.skipped(path, "pinned by a marker file")
+			return filepath.SkipDir
+		}
+		cutoff := c.cutoffFor(rel)
+		pathLog.WithField("date", compareDate.Format(time.RFC3339)).Debugln("Compared directory date")
+		c.scanned(path, compareDate, cutoff)
+		if compareDate.Before(cutoff) {
+			if len(parts) < c.minDepth {
+				pathLog.Debugln("Expired but above minDepth, judging what is inside instead")
+				return nil
+			}
+			if c.excluded(rel) {
+				pathLog.Infoln("Expired but excluded, keeping")
+				c.mu.Lock()
+				c.stats.DirsExcluded++
+				c.mu.Unlock()
+				c.skipped(path, "excluded")
+				return filepath.SkipDir
+			}
+			if c.excludedInside(path, rel) || c.keepsBelow(rel) {
+				// Something inside is protected, so judge the children one at a time instead.
+				return nil
+			}
+			if c.kept[rel] {
+				pathLog.Infoln("Expired but among the newest minKeepCount, keeping")
+				c.skipped(path, "among the newest minKeepCount")
+				return filepath.SkipDir
+			}
+			if mount := c.mountBelow(path); mount != "" {
+				pathLog.WithField("mount", mount).Infoln("Expired but holds another filesystem, removing around it")
+				return nil
+			}
+			// One walk both looks for markers inside and sizes the directory for its removal.
+			scan := c.scanSubtree(path)
+			if scan.marker != "" {
+				pathLog.WithField("marker", scan.marker).Debugln("Expired but holds a marker file, judging what is inside instead")
+				return nil
+			}
+			if layout.DatedBelow(len(parts)) || c.fileLayout != nil {
+				if file, _ := c.recentWrite(path, true); file != "" {
+					pathLog.WithField("file", file).Debugln("Expired but recently written to, judging what is inside instead")
+					return nil
+				}
+				if file, _ := c.openBelow(path, true); file != "" {
+					pathLog.WithField("file", file).Debugln("Expired but holds a file in use, judging what is inside instead")
+					return nil
+				}
+			}
+			if len(c.calendar) > 0 || c.config.Policy != nil {
+				start, _ := layout.StartDate(parts, c.now)
+				leaf := !layout.DatedBelow(len(parts)) && c.fileLayout == nil
+				keep, descend := c.calendarKeeps(start, compareDate, leaf)
+				if keep && !descend {
+					pathLog.Debugln("Expired but kept by a calendar rule")
+					c.skipped(path, "kept by a calendar rule")
+				} else if !keep {
+					keep, descend = c.policyKeeps(path, start, compareDate, leaf)
+				}
+				if descend {
+					return nil
+				} else if keep {
+					return filepath.SkipDir
+				}
+			}
+			c.startRemoval(path, compareDate, scan)
+			// Whether or not the removal worked, everything below is at least as old and has been dealt with.
+			// Descending would only walk into a directory that is gone.
+			return filepath.SkipDir
+		}
+		if compareDate.Before(c.compressCutoff) && c.compressible(path, rel, parts) {
+			c.compress(path, compareDate)
+			return filepath.SkipDir
+		}
+		// Retained. Don't read any further if nothing below can be older than the cutoff, or the compressAfter
+		// cutoff: either the directory starts after it, or the layout has no finer date to look for inside it.
+		if c.compressCutoff.After(cutoff) {
+			cutoff = c.compressCutoff
+		}
+		if start, _ := layout.StartDate(parts, c.now); !start.IsZero() && !start.Before(cutoff) {
+			return filepath.SkipDir
+		}
+		if path != c.dir && !layout.DatedBelow(len(parts)) && c.fileLayout == nil {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	c.removals.Wait()
+	c.finishWalk(err)
+}
+
+// pruneFile removes a file whose name carries a date under fileLayout, if that date is before the cutoff. It reports
+// whether the name matched; files whose names don't are left to strayFile.
+func (c *companyRun) pruneFile(path string, name string) bool {
+	if isMarker(name) {
+		return true
+	}
+	date, err := c.fileLayout.StrictDate([]string{name}, c.now)
+	if err != nil {
+		return false
+	}
+	if !date.Before(c.cutoffFor(relativePath(c.dir, filepath.Dir(path)))) {
+		return true
+	}
+	if c.excluded(relativePath(c.dir, path)) {
+		c.log.WithField("path", path).Infoln("Expired but excluded, keeping")
+		c.mu.Lock()
+		c.stats.DirsExcluded++
+		c.mu.Unlock()
+		c.skipped(path, "excluded")
+		return true
+	}
+	if keep, _ := c.calendarKeeps(date, date, true); keep {
+		c.log.WithField("path", path).Debugln("Expired but kept by a calendar rule")
+		c.skipped(path, "kept by a calendar rule")
+		return true
+	}
+	if keep, _ := c.policyKeeps(path, date, date, true); keep {
+		return true
+	}
+	c.removeExpired(path, false, date)
+	return true
+}
+
+// strayFile applies the company's strayFiles policy to a loose file in a directory that should only hold directories.
+func (c *companyRun) strayFile(path string, f fs.DirEntry) {
+	if c.config.StrayFiles == "" || c.config.StrayFiles == StrayIgnore || isMarker(f.Name()) {
+		return
+	}
+	info, err := f.Info()
+	if err != nil {
+		// Removed since the directory was read.
+		return
+	}
+	pathLog := c.log.WithFields(log.Fields{"path": path, "modified": info.ModTime().Format(time.RFC3339), "bytes": info.Size()})
+	if c.config.StrayFiles == StrayReport {
+		pathLog.Warnln("Stray file outside the date directories")
+		c.mu.Lock()
+		c.stats.StrayFiles++
+		c.mu.Unlock()
+		return
+	}
+	if !info.ModTime().Before(c.cutoffFor(relativePath(c.dir, filepath.Dir(path)))) {
+		return
+	}
+	if c.excluded(relativePath(c.dir, path)) {
+		pathLog.Infoln("Expired but excluded, keeping")
+		c.mu.Lock()
+		c.stats.DirsExcluded++
+		c.mu.Unlock()
+		c.skipped(path, "excluded")
+		return
+	}
+	c.mu.Lock()
+	c.stats.StrayFiles++
+	c.mu.Unlock()
+	c.removeExpired(path, false, info.ModTime())
+}
+
+// startRemoval removes an expired directory found by the walk, which scan is of, in the background if the company
+// has more than one worker. It waits for a free worker first, giving up if the pass is being shut down.
+func (c *companyRun) startRemoval(path string, dataDate time.Time, scan subtree) {
+	if c.config.Workers <= 1 {
+		c.removeScanned(path, true, dataDate, scan)
+		return
+	}
+	if c.slots == nil {
+		c.slots = make(chan struct{}, c.config.Workers)
+	}
+	select {
+	case c.slots <- struct{}{}:
+	case <-c.ctx.Done():
+		return
+	}
+	c.removals.Add(1)
+	go func() {
+		defer c.removals.Done()
+		defer func() { <-c.slots }()
+		c.removeScanned(path, true, dataDate, scan)
+	}()
+}
+
+// finishWalk records how the walk over the company directory ended.
+func (c *companyRun) finishWalk(err error) {
+	if err != nil {
+		if err == c.ctx.Err() {
+			c.log.Infoln("Stopped pruning before finishing")
+			return
+		}
+		c.error(c.dir, "Error walking path", err)
+	}
+	c.stats.Completed = true
+}
+
+// relativePath returns path relative to companyDir, with forward slashes.
+func relativePath(companyDir string, path string) string {
+	rel, err := filepath.Rel(companyDir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// excluded reports whether rel matches one of the company's excludePaths, or is inside a match.
+func (c *companyRun) excluded(rel string) bool {
+	for _, pattern := range c.config.ExcludePaths {
+		if matchGlob(pattern, rel) || matchGlob(pattern+"/**", rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludesBelow reports whether something inside the directory rel may match one of the company's excludePaths.
+func (c *companyRun) excludesBelow(rel string) bool {
+	for _, pattern := range c.config.ExcludePaths {
+		if globMayMatchBelow(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedInside reports whether anything inside the directory at path, rel relative to the company directory,
+// matches one of the company's excludePaths. Unlike excludesBelow it reads the directory, so it is only asked when
+// excludesBelow can't rule a match out.
+func (c *companyRun) excludedInside(path string, rel string) bool {
+	if !c.excludesBelow(rel) {
+		return false
+	}
+	found := false
+	c.fs.WalkDir(path, func(sub string, f fs.DirEntry, err error) error {
+		if err != nil || sub == path {
+			return nil
+		}
+		if c.excluded(relativePath(c.dir, sub)) {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// relativeParts splits path into the directory names below companyDir.
+func relativeParts(companyDir string, path string) []string {
+	rel, err := filepath.Rel(companyDir, path)
+	if err != nil || rel == "." {
+		return nil
+	}
+	return strings.Split(rel, string(os.PathSeparator))
+}
+
+// removeExpired deletes or trashes an expired file or directory and records the outcome. In dry-run mode it only
+// logs what it would have done. dataDate is the date the decision was based on.
+func (c *companyRun) removeExpired(path string, isDir bool, dataDate time.Time) {
+	c.removeScanned(path, isDir, dataDate, c.scanSubtree(path))
+}
+
+// removeScanned is removeExpired for a path scan is of.
+func (c *companyRun) removeScanned(path string, isDir bool, dataDate time.Time, scan subtree) {
+	if err := c.guard(path); err != nil {
+		c.error(path, "Refusing to remove path", err)
+		return
+	}
+	if isDir {
+		if scan.marker != "" {
+			c.log.WithFields(log.Fields{"path": path, "marker": scan.marker}).Warnln("Holds a marker file, keeping it")
+			c.mu.Lock()
+			c.stats.DirsExcluded++
+			c.mu.Unlock()
+			c.skipped(path, "holds a marker file")
+			return
+		}
+		if mount := c.mountBelow(path); mount != "" {
+			c.log.WithFields(log.Fields{"path": path, "mount": mount}).Warnln("Holds another filesystem, keeping it")
+			c.mu.Lock()
+			c.stats.DirsExcluded++
+			c.mu.Unlock()
+			c.skipped(path, "holds another filesystem")
+			return
+		}
+	}
+	if !c.purging() {
+		if file, modified := c.recentWrite(path, isDir); file != "" {
+			c.log.WithFields(log.Fields{"path": path, "file": file, "modified": modified.Format(time.RFC3339)}).Warnln("Expired but recently written to, keeping it")
+			c.mu.Lock()
+			c.stats.ActivePaths = append(c.stats.ActivePaths, path)
+			c.mu.Unlock()
+			c.skipped(path, "recently written to")
+			return
+		}
+	}
+	if file, err := c.openBelow(path, isDir); err != nil {
+		c.error(path, "Error checking for open files, keeping path", err)
+		return
+	} else if file != "" {
+		c.log.WithFields(log.Fields{"path": path, "file": file}).Warnln("Expired but holds a file in use, keeping it")
+		c.mu.Lock()
+		c.stats.OpenPaths = append(c.stats.OpenPaths, path)
+		c.mu.Unlock()
+		c.skipped(path, "holds a file in use")
+		return
+	}
+	size, files := scan.size, scan.files
+	if scan.err != nil {
+		c.log.WithField("path", path).Errorf("Error sizing path : %+v", scan.err)
+	}
+	pathLog := c.log.WithFields(log.Fields{"path": path, "date": dataDate.Format(time.RFC3339), "bytes_freed": size, "files": files})
+	moving := c.config.ArchiveTo != "" && !c.permanent
+	if c.dryRun && moving {
+		pathLog.Infoln("Would move to archiveTo")
+		c.mu.Lock()
+		c.stats.DirsMoved++
+		c.mu.Unlock()
+		c.emit(Event{Kind: EventDeleted, Path: path, Action: AuditMoved, Bytes: size})
+		return
+	}
+	if c.dryRun {
+		pathLog.Infoln("Would remove")
+		c.mu.Lock()
+		c.stats.countRemoved(isDir, size, files)
+		c.mu.Unlock()
+		c.emit(Event{Kind: EventDeleted, Path: path, Action: AuditDeleted, Bytes: size})
+		return
+	}
+	staging := c.staging()
+	if !staging {
+		if err := c.p.pace(c.ctx); err != nil {
+			return
+		}
+	}
+	// Purges and free-space passes remove data because it must go, not because it has aged out, so they don't keep
+	// a copy of it.
+	if c.config.Archive != nil && !c.permanent {
+		if err := c.archive(path); err != nil {
+			c.error(path, "Error archiving path, keeping it", err)
+			return
+		}
+	}
+	trashing := c.p.TrashGrace > 0 && !c.permanent && c.config.Storage == nil
+	action := AuditDeleted
+	switch {
+	case moving:
+		action = AuditMoved
+	case trashing:
+		action = AuditTrashed
+	case c.action != "":
+		action = c.action
+	}
+	if err := c.preDelete(action, path, isDir, size); err != nil {
+		c.error(path, "preDelete hook failed, keeping path", err)
+		return
+	}
+	if moving {
+		target, err := c.moveToTier(path)
+		if err != nil {
+			c.error(path, "Error moving path to archiveTo, keeping it", err)
+			return
+		}
+		pathLog.WithField("target", target).Infoln("Moved to archiveTo")
+		c.mu.Lock()
+		c.stats.DirsMoved++
+		c.markEmptied(path)
+		c.mu.Unlock()
+		c.audit(AuditMoved, path, size)
+		c.postDelete(AuditMoved, path, isDir, size)
+		return
+	}
+	if trashing {
+		pathLog.Debugln("Trashing")
+		if _, err := c.moveToTrash(path); err != nil {
+			c.error(path, "Error trashing path", err)
+			return
+		}
+		c.mu.Lock()
+		c.stats.DirsTrashed++
+		c.markEmptied(path)
+		c.mu.Unlock()
+		c.audit(AuditTrashed, path, size)
+		c.postDelete(AuditTrashed, path, isDir, size)
+		return
+	}
+	if isDir && c.config.Subvolumes != "" && !c.config.remote() {
+		destroyed, err := c.destroySubvolume(path, pathLog)
+		if err != nil {
+			c.error(path, "Error destroying subvolume", err)
+			c.mu.Lock()
+			c.stats.FailedPaths = append(c.stats.FailedPaths, path)
+			c.mu.Unlock()
+			return
+		}
+		if destroyed {
+			c.removed(path, isDir, size, files, pathLog)
+			return
+		}
+	}
+	if staging {
+		pathLog.Debugln("Staging")
+		if err := c.stage(path); err != nil {
+			c.error(path, "Error staging path", err)
+			return
+		}
+		c.removed(path, isDir, size, files, pathLog)
+		return
+	}
+	pathLog.Debugln("Removing")
+	if err := c.removeAll(path); err != nil {
+		if c.ctx.Err() == nil && retryable(err) {
+			pathLog.WithError(err).Warnln("Could not remove, will try again at the end of the pass")
+			c.mu.Lock()
+			c.retryLater = append(c.retryLater, pendingRemoval{path: path, isDir: isDir, size: size, files: files, log: pathLog})
+			c.mu.Unlock()
+			return
+		}
+		c.error(path, "Error removing path", err)
+		c.mu.Lock()
+		c.stats.FailedPaths = append(c.stats.FailedPaths, path)
+		c.mu.Unlock()
+		return
+	}
+	c.removed(path, isDir, size, files, pathLog)
+}
+
+// removed records a successful removal.
+func (c *companyRun) removed(path string, isDir bool, size int64, files int, pathLog log.FieldLogger) {
+	pathLog.Infoln("Removed")
+	action := AuditDeleted
+	if c.action != "" {
+		action = c.action
+	}
+	c.audit(action, path, size)
+	c.postDelete(action, path, isDir, size)
+	c.mu.Lock()
+	c.stats.countRemoved(isDir, size, files)
+	c.markEmptied(path)
+	c.mu.Unlock()
+	if isDir {
+		c.recorder.DirDeleted(c.stats.Company, size, files)
+	} else {
+		c.recorder.FileDeleted(c.stats.Company, size)
+	}
+}
+
+// audit appends a record of a removal to the audit log, if there is one, and passes it to OnRemove.
+func (c *companyRun) audit(action string, path string, size int64) {
+	if action != AuditCompressed {
+		c.emit(Event{Kind: EventDeleted, Path: path, Action: action, Bytes: size})
+	}
+	if c.p.Audit == nil && c.p.OnRemove == nil {
+		return
+	}
+	record := AuditRecord{
+		Time:          time.Now().UTC(),
+		RunID:         c.runID,
+		CorrelationID: c.p.CorrelationID,
+		Company:       c.stats.Company,
+		Action:        action,
+		Path:          path,
+		Bytes:         size,
+	}
+	if c.p.Audit != nil {
+		if err := c.p.Audit.Append(record); err != nil {
+			c.error(path, "Error writing audit record", err)
+		}
+	}
+	if c.p.OnRemove != nil {
+		c.p.OnRemove(record)
+	}
+}
+
+// error logs and counts an error that kept path from being pruned.
+func (c *companyRun) error(path string, msg string, err error) {
+	c.log.WithField("path", path).WithError(err).Errorln(msg)
+	c.mu.Lock()
+	c.stats.Errors++
+	c.mu.Unlock()
+	c.recorder.Error(c.stats.Company)
+	c.emit(Event{Kind: EventFailed, Path: path, Reason: msg, Error: err.Error()})
+}
+
+// configError logs and counts a config problem that keeps the whole company from being pruned.
+func (c *companyRun) configError(err error) {
+	c.log.WithField("company_name", c.config.Name).WithError(err).Errorln("Invalid company config, skipping")
+	c.mu.Lock()
+	c.stats.Errors++
+	c.mu.Unlock()
+	c.recorder.Error(c.stats.Company)
+	c.emit(Event{Kind: EventFailed, Path: c.dir, Reason: "Invalid company config, skipping", Error: err.Error()})
+}
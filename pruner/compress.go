@@ -0,0 +1,112 @@
+package pruner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/archive"
+)
+
+// compressedSuffix is added to the name of a directory replaced by a compressed archive of it.
+const compressedSuffix = ".tar.zst"
+
+// compressible reports whether a directory past the compressAfter cutoff may be compressed whole. Directories that
+// aren't are judged child by child instead, like expired ones that can't be removed whole.
+func (c *companyRun) compressible(path string, rel string, parts []string) bool {
+	if path == c.dir || len(parts) < c.minDepth || c.excluded(rel) || c.excludedInside(path, rel) {
+		return false
+	}
+	return c.mountBelow(path) == "" && c.markerBelow(path) == ""
+}
+
+// compress replaces a directory with a zstd-compressed tar of it, named after it with compressedSuffix. In dry-run
+// mode it only logs what it would have done.
+func (c *companyRun) compress(path string, dataDate time.Time) {
+	if err := c.guard(path); err != nil {
+		c.error(path, "Refusing to compress path", err)
+		return
+	}
+	size, files, sizeErr := c.dirSize(path)
+	if sizeErr != nil {
+		c.log.WithField("path", path).Errorf("Error sizing path : %+v", sizeErr)
+	}
+	pathLog := c.log.WithFields(log.Fields{"path": path, "date": dataDate.Format(time.RFC3339), "bytes": size, "files": files})
+	if c.dryRun {
+		pathLog.Infoln("Would compress")
+		c.mu.Lock()
+		c.stats.DirsCompressed++
+		c.mu.Unlock()
+		return
+	}
+	if err := c.p.pace(c.ctx); err != nil {
+		return
+	}
+	target := path + compressedSuffix
+	if _, err := c.fs.Stat(target); err == nil {
+		c.finishCompress(path, target, size, pathLog)
+		return
+	}
+	compressed, err := archive.CompressInPlace(path, target)
+	if err != nil {
+		c.error(path, "Error compressing path, keeping it", err)
+		return
+	}
+	if err := c.removeAll(path); err != nil {
+		c.error(path, "Error removing compressed path", err)
+		return
+	}
+	pathLog.WithField("compressed_bytes", compressed).Infoln("Compressed")
+	c.audit(AuditCompressed, path, size)
+	c.mu.Lock()
+	c.stats.DirsCompressed++
+	c.mu.Unlock()
+}
+
+// finishCompress removes path, which already has a compressed archive at target, if the archive holds all of it:
+// an earlier pass compressed it and was stopped while removing it. Otherwise path has changed since it was
+// compressed and both are kept, as neither may be replaced by the other without losing files.
+func (c *companyRun) finishCompress(path string, target string, size int64, pathLog log.FieldLogger) {
+	contained, err := archive.ContainsDir(target, path)
+	if err != nil {
+		c.error(path, "Error comparing path with its compressed archive, keeping both", err)
+		return
+	}
+	if !contained {
+		c.error(path, "Path has files its compressed archive doesn't, keeping both", fmt.Errorf("%s differs from %s", path, target))
+		return
+	}
+	if err := c.removeAll(path); err != nil {
+		c.error(path, "Error removing compressed path", err)
+		return
+	}
+	pathLog.Infoln("Already compressed, removed what an earlier pass left of it")
+	c.audit(AuditCompressed, path, size)
+	c.mu.Lock()
+	c.stats.DirsCompressed++
+	c.mu.Unlock()
+}
+
+// pruneCompressed removes an archive written by compress once the directory it replaced has expired. It reports
+// whether path is named like such an archive.
+func (c *companyRun) pruneCompressed(path string, layout Layout) bool {
+	original := strings.TrimSuffix(path, compressedSuffix)
+	date, err := layout.StrictDate(relativeParts(c.dir, original), c.now)
+	if err != nil || original == c.dir {
+		return false
+	}
+	if !date.Before(c.cutoffFor(relativePath(c.dir, filepath.Dir(path)))) {
+		return true
+	}
+	if c.excluded(relativePath(c.dir, original)) || c.excluded(relativePath(c.dir, path)) {
+		c.log.WithField("path", path).Infoln("Expired but excluded, keeping")
+		c.mu.Lock()
+		c.stats.DirsExcluded++
+		c.mu.Unlock()
+		return true
+	}
+	c.removeExpired(path, false, date)
+	return true
+}
@@ -0,0 +1,79 @@
+package pruner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moriarty-s3a/deleter/archive"
+)
+
+// compressPass runs a pass over base, where acme's directories are compressed after 30 days and removed after a
+// year, and returns acme's stats.
+func compressPass(t *testing.T, base string) CompanyStats {
+	t.Helper()
+	p := New(base, dailyConfig(func(c *CompanyConfig) {
+		c.Retention = "365"
+		c.CompressAfter = "30"
+	}))
+	p.Clock = FixedClock(testNow)
+	p.Log = quietLogger()
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary.Companies[0]
+}
+
+func writeTestFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressFinishesAnInterruptedCompress(t *testing.T) {
+	base := t.TempDir()
+	day := filepath.Join(base, "acme", "2020", "12", "01")
+	writeTestFile(t, filepath.Join(day, "a"), "a")
+	writeTestFile(t, filepath.Join(day, "b"), "b")
+	if _, err := archive.CompressInPlace(day, day+compressedSuffix); err != nil {
+		t.Fatal(err)
+	}
+	// The earlier pass was stopped after removing a, while removing the directory.
+	os.Remove(filepath.Join(day, "a"))
+	stats := compressPass(t, base)
+	if stats.Errors != 0 || stats.DirsCompressed != 1 {
+		t.Errorf("got %d errors and %d directories compressed, want 0 and 1", stats.Errors, stats.DirsCompressed)
+	}
+	checkExists(t, OSFileSystem{}, false, day)
+	// The archive still holds both files, and wasn't replaced with one of what was left.
+	writeTestFile(t, filepath.Join(day, "a"), "a")
+	writeTestFile(t, filepath.Join(day, "b"), "b")
+	if contained, err := archive.ContainsDir(day+compressedSuffix, day); err != nil || !contained {
+		t.Errorf("archive doesn't hold the directory as it was compressed: %v, %v", contained, err)
+	}
+}
+
+func TestCompressKeepsADirectoryThatChangedSinceItWasCompressed(t *testing.T) {
+	base := t.TempDir()
+	day := filepath.Join(base, "acme", "2020", "12", "01")
+	writeTestFile(t, filepath.Join(day, "a"), "a")
+	if _, err := archive.CompressInPlace(day, day+compressedSuffix); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(day, "late"), "late")
+	stats := compressPass(t, base)
+	if stats.Errors != 1 {
+		t.Errorf("got %d errors, want 1", stats.Errors)
+	}
+	checkExists(t, OSFileSystem{}, true, filepath.Join(day, "a"), filepath.Join(day, "late"))
+	// The archive still holds a, and wasn't replaced with one of the directory as it is now.
+	if contained, err := archive.ContainsDir(day+compressedSuffix, day); err != nil || contained {
+		t.Errorf("archive holds the changed directory: %v, %v", contained, err)
+	}
+}
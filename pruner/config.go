@@ -0,0 +1,332 @@
+package pruner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/moriarty-s3a/deleter/archive"
+	"github.com/moriarty-s3a/deleter/sftp"
+)
+
+// Config is the retention configuration for every company under the base directory.
+type Config struct {
+	DefaultConfig  CompanyConfig   `json:"default"`
+	CompanyConfigs []CompanyConfig `json:"companies"`
+	// Groups hold settings that company entries naming them inherit. A setting on the company entry overrides the
+	// group's, and the group's overrides the default's.
+	Groups []GroupConfig `json:"groups,omitempty"`
+	// ProtectedPaths are globs, in the same form as excludePaths, of paths that are never removed in any company,
+	// on top of each company's own excludePaths.
+	ProtectedPaths []string `json:"protectedPaths,omitempty"`
+	// AllowCompanies, if not empty, lists the only company directories that are processed. Every other directory
+	// under the base directory is left alone.
+	AllowCompanies []string `json:"allowCompanies,omitempty"`
+	// UnknownCompanies is what happens to company directories without their own entry: UnknownDefault (the
+	// default) prunes them with the default entry, UnknownSkip leaves them alone with a warning, and UnknownFail
+	// refuses to start the pass at all.
+	UnknownCompanies string `json:"unknownCompanies,omitempty"`
+	// BaseDirs are more base directories to process alongside the one the Pruner was given, each optionally with its
+	// own default entry.
+	BaseDirs []BaseDirConfig `json:"baseDirs,omitempty"`
+}
+
+// CompanyConfig is the retention configuration for a single company directory.
+type CompanyConfig struct {
+	// Id is the name of the company directory, or a pattern matching several of them. See IsPattern.
+	Id   string `json:"companyId"`
+	Name string `json:"companyName"`
+	// Group names the entry in the config's groups whose settings this entry inherits.
+	Group string `json:"group,omitempty"`
+	// Retention is a number of days, a number with a "d" or "w" suffix, or a Go duration. See ParseRetention.
+	// "never" exempts the company from pruning, unless it has sub-tenant, category or compression settings of its own.
+	Retention string `json:"retentionDays"`
+	// CompressAfter, in the same format as Retention and shorter than it, is the age at which directories are
+	// replaced by a zstd-compressed tar of themselves, named after them with a ".tar.zst" suffix. The archives are
+	// removed once Retention expires the directory they replaced.
+	CompressAfter string `json:"compressAfter,omitempty"`
+	// MinKeepDays is a floor under Retention, in the same format, so that a mistyped retention can't remove recent
+	// data. Companies without one use the default's.
+	MinKeepDays string `json:"minKeepDays,omitempty"`
+	// MinKeepCount is how many of the newest date directories, per device or other undated branch, are always
+	// kept whatever their age. Companies without one use the default's.
+	MinKeepCount int `json:"minKeepCount,omitempty"`
+	// Timezone is the IANA zone directory dates are written in, e.g. "America/New_York". Companies without one use
+	// the default's, and the Pruner's Location if that is empty too.
+	Timezone string `json:"timezone,omitempty"`
+	// Mode selects how expiry is decided: ModePath (the default) or ModeMtime.
+	Mode string `json:"mode,omitempty"`
+	// Layout is the date layout of the directories below the company directory, or below each sub-tenant directory
+	// if Subtenants is set. See ParseLayout.
+	Layout string `json:"layout,omitempty"`
+	// Subtenants, if set, means the company directory holds one directory per sub-tenant, such as a child
+	// organization, with the layout below each of them. ExcludePaths and MinDepth stay relative to the company
+	// directory. Set to false, it turns off the group's or default's.
+	Subtenants *bool `json:"subtenants,omitempty"`
+	// SubtenantRetention overrides Retention for the sub-tenants it names, in either direction. MinKeepDays still
+	// applies.
+	SubtenantRetention map[string]string `json:"subtenantRetention,omitempty"`
+	// Categories, if set, means there is a directory per category of data, such as logs or exports, below the
+	// company directory, or below each sub-tenant directory, with the layout below each of them. It maps category
+	// names to their retention, which overrides Retention and SubtenantRetention in either direction; categories it
+	// doesn't name get those. MinKeepDays still applies.
+	Categories map[string]string `json:"categories,omitempty"`
+	// FileLayout, if set, is the layout of file names that carry their own date, e.g. "app-{year}{month}{day}.log*",
+	// for logs dumped into flat directories. Matching files anywhere the walk reaches are removed once that date is
+	// before the cutoff; other files are still left alone. See ParseFileLayout.
+	FileLayout string `json:"fileLayout,omitempty"`
+	// StrayFiles is what happens to loose files in directories above the layout's last level, where only directories
+	// are expected: StrayIgnore (the default) leaves them alone, StrayMtime removes them once last modified before
+	// the cutoff, and StrayReport logs and counts them. Files matching FileLayout aren't stray.
+	StrayFiles string `json:"strayFiles,omitempty"`
+	// RemoveEmptyDirs, if set, removes the date directories that the pass left empty, such as a month whose days have
+	// all expired, once the pass is done. Directories that were already empty are left alone. Companies without it
+	// set use the default's.
+	RemoveEmptyDirs *bool `json:"removeEmptyDirs,omitempty"`
+	// Strict, on unless set to false, skips directories whose names don't parse as dates under the layout instead
+	// of treating the unparseable parts as zero. Companies without it set use the default's.
+	Strict *bool `json:"strict,omitempty"`
+	// LegalHold freezes the company: nothing is removed, whatever its age, until the hold is lifted. Set to false, it
+	// lifts the group's or default's.
+	LegalHold *bool `json:"legalHold,omitempty"`
+	// ExcludePaths are globs, relative to the company directory, of paths that are never removed. "**" matches any
+	// number of directories.
+	ExcludePaths []string `json:"excludePaths,omitempty"`
+	// Storage, if set, means the company's data is in an object store bucket rather than on disk: the objects under
+	// Storage's prefix are pruned as though their keys were paths below the company directory, which need not
+	// exist. Only the s3 and gcs types are supported, and removals skip the trash.
+	Storage *archive.Config `json:"storage,omitempty"`
+	// SFTP, if set, means the company's data is on a remote server: the remote directory at SFTP's path is pruned
+	// through an SFTP connection as though it were the company directory, which need not exist locally.
+	SFTP *sftp.Config `json:"sftp,omitempty"`
+	// Archive, if set, uploads a compressed copy of every expired directory before it is removed. Directories are
+	// only removed once the upload has been confirmed. Purges and free-space passes don't archive what they remove.
+	Archive *archive.Config `json:"archive,omitempty"`
+	// ArchiveTo, if set, is a directory, typically on a slower disk, that expired directories are moved to instead of
+	// being removed, keeping their path as <archiveTo>/<company>/<path relative to the company directory>. Moves
+	// across filesystems copy and check the copy before the original is removed.
+	ArchiveTo string `json:"archiveTo,omitempty"`
+	// ArchiveRetention, in the same format as Retention and longer than it, is how long data is kept in ArchiveTo,
+	// judged by the same directory dates. Without one, nothing is ever removed from ArchiveTo.
+	ArchiveRetention string `json:"archiveRetention,omitempty"`
+	// Schedule is a cron expression or Go duration for daemon mode. Companies without one use the default's.
+	Schedule string `json:"schedule,omitempty"`
+	// Workers is how many expired directories of the company are removed at once, for companies too big to prune
+	// one directory at a time. Companies without one use the default's.
+	Workers int `json:"workers,omitempty"`
+	// MinDepth is how many directories below the company directory a removal must be at least. Expired
+	// directories above it are never removed whole; what is inside them is judged instead. Companies without one use
+	// the default's, and the depth of the layout's first date field if that is unset too. The company directory
+	// itself is never removed.
+	MinDepth int `json:"minDepth,omitempty"`
+	// Subvolumes, on Linux, has expired directories that are btrfs subvolumes or ZFS datasets destroyed as such
+	// instead of unlinked file by file: SubvolumesBtrfs and SubvolumesZFS look for one kind, SubvolumesAuto for
+	// either. Directories that turn out not to be one are removed as usual. Companies without one use the default's.
+	// With OneFileSystem, subvolumes and datasets count as other filesystems and are left alone, and datasets can't
+	// be trashed, as their mountpoints can't be renamed.
+	Subvolumes string `json:"subvolumes,omitempty"`
+	// PreDelete, if set, is run before each expired path is removed, trashed or moved to archiveTo, after any
+	// archive upload, and the path is kept, as an error, if it fails. PostDelete is run once the path is gone.
+	// Companies without them use the default's.
+	PreDelete  *Hook `json:"preDelete,omitempty"`
+	PostDelete *Hook `json:"postDelete,omitempty"`
+	// CalendarRules keep the days they pick out for longer than Retention. Path mode only.
+	CalendarRules []CalendarRule `json:"calendarRules,omitempty"`
+	// Policy, if set, can keep paths that retention alone would remove. Companies without one use the default's.
+	Policy *PolicyConfig `json:"policy,omitempty"`
+	// SkipModifiedWithin, a Go duration, keeps an expired path if any file in it was modified that recently, for
+	// late data landing in old date directories. Expired directories with dated directories below are judged by
+	// what is inside them instead, so only the ones written to are kept. Purges ignore it. Companies without one use
+	// the default's.
+	SkipModifiedWithin string `json:"skipModifiedWithin,omitempty"`
+	// OpenFiles, on Linux, keeps an expired path until nothing in it is in use, so that writers don't lose a file
+	// they still have open: OpenFilesProc looks for it in other processes' /proc/*/fd, and OpenFilesFlock for an
+	// exclusive flock on it, for writers on other hosts that follow that convention. Companies without one use the
+	// default's.
+	OpenFiles string `json:"openFiles,omitempty"`
+}
+
+// UnmarshalJSON accepts retentionDays, minKeepDays, compressAfter, archiveRetention and the subtenantRetention and categories values as bare numbers
+// as well as strings, as YAML and TOML configs naturally write them.
+func (c *CompanyConfig) UnmarshalJSON(data []byte) error {
+	type plain CompanyConfig
+	var entry struct {
+		plain
+		Retention          numberOrString            `json:"retentionDays"`
+		MinKeepDays        numberOrString            `json:"minKeepDays,omitempty"`
+		CompressAfter      numberOrString            `json:"compressAfter,omitempty"`
+		ArchiveRetention   numberOrString            `json:"archiveRetention,omitempty"`
+		SubtenantRetention map[string]numberOrString `json:"subtenantRetention,omitempty"`
+		Categories         map[string]numberOrString `json:"categories,omitempty"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	*c = CompanyConfig(entry.plain)
+	c.Retention = string(entry.Retention)
+	c.MinKeepDays = string(entry.MinKeepDays)
+	c.CompressAfter = string(entry.CompressAfter)
+	c.ArchiveRetention = string(entry.ArchiveRetention)
+	c.SubtenantRetention = stringValues(entry.SubtenantRetention)
+	c.Categories = stringValues(entry.Categories)
+	return nil
+}
+
+func stringValues(values map[string]numberOrString) map[string]string {
+	if values == nil {
+		return nil
+	}
+	strs := make(map[string]string, len(values))
+	for key, value := range values {
+		strs[key] = string(value)
+	}
+	return strs
+}
+
+// ParseLayout parses the entry's layout, with undated levels in front of it for the sub-tenant directories if
+// Subtenants is set and the category directories if Categories is.
+func (c CompanyConfig) ParseLayout() (Layout, error) {
+	if !c.HasSubtenants() && len(c.Categories) == 0 {
+		return ParseLayout(c.Layout)
+	}
+	template := c.Layout
+	if template == "" {
+		template = DefaultLayout
+	}
+	if len(c.Categories) > 0 {
+		template = "*/" + template
+	}
+	if c.HasSubtenants() {
+		template = "*/" + template
+	}
+	return ParseLayout(template)
+}
+
+// numberOrString is a string that may be written as a JSON number.
+type numberOrString string
+
+func (s *numberOrString) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil && !strings.HasPrefix(string(data), `"`) {
+		*s = numberOrString(number)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = numberOrString(str)
+	return nil
+}
+
+const (
+	// ModePath decides expiry from the year/month/day/... directories in the path.
+	ModePath = "path"
+	// ModeMtime decides expiry from file modification times, for companies without a date layout.
+	ModeMtime = "mtime"
+)
+
+const (
+	StrayIgnore = "ignore"
+	StrayMtime  = "mtime"
+	StrayReport = "report"
+)
+
+const (
+	SubvolumesAuto  = "auto"
+	SubvolumesBtrfs = "btrfs"
+	SubvolumesZFS   = "zfs"
+)
+
+const (
+	OpenFilesProc  = "proc"
+	OpenFilesFlock = "flock"
+)
+
+const (
+	UnknownDefault = "default"
+	UnknownSkip    = "skip"
+	UnknownFail    = "fail"
+)
+
+// Unknown returns the companies, out of those given, that have no entry of their own or matching a pattern.
+func (c Config) Unknown(companies []string) []string {
+	configMap := ConfigMap(c)
+	var unknown []string
+	for _, company := range companies {
+		if _, known := lookupEntry(configMap, company); !known {
+			unknown = append(unknown, company)
+		}
+	}
+	return unknown
+}
+
+// ParseConfig decodes a JSON config document. Decoding errors say where in the document they are.
+func ParseConfig(data []byte) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		if line, column := jsonPosition(data, err); line > 0 {
+			return Config{}, fmt.Errorf("line %d, column %d: %v", line, column, err)
+		}
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// ConfigMap indexes the company configs by id. The default config is stored under "default". Entries have their
+// group's settings filled in, and ProtectedPaths are added to the excludePaths of every entry.
+func ConfigMap(config Config) map[string]CompanyConfig {
+	configMap := make(map[string]CompanyConfig)
+	groups := groupMap(config.Groups)
+	protect := func(entry CompanyConfig) CompanyConfig {
+		if group, exists := groups[entry.Group]; exists && entry.Group != "" {
+			entry = group.inherit(entry)
+		}
+		if len(config.ProtectedPaths) > 0 {
+			entry.ExcludePaths = append(append([]string(nil), entry.ExcludePaths...), config.ProtectedPaths...)
+		}
+		return entry
+	}
+	configMap["default"] = protect(config.DefaultConfig)
+	for _, entry := range config.CompanyConfigs {
+		configMap[entry.Id] = protect(entry)
+	}
+	return configMap
+}
+
+// Allowed reports whether the company directory is processed under AllowCompanies.
+func (c Config) Allowed(company string) bool {
+	return len(c.AllowCompanies) == 0 || containsString(c.AllowCompanies, company)
+}
+
+// HasSubtenants reports whether Subtenants is on, which it is only if explicitly set.
+func (c CompanyConfig) HasSubtenants() bool {
+	return c.Subtenants != nil && *c.Subtenants
+}
+
+// OnLegalHold reports whether LegalHold is on, which it is only if explicitly set.
+func (c CompanyConfig) OnLegalHold() bool {
+	return c.LegalHold != nil && *c.LegalHold
+}
+
+// RemovesEmptyDirs reports whether RemoveEmptyDirs is on, which it is only if explicitly set.
+func (c CompanyConfig) RemovesEmptyDirs() bool {
+	return c.RemoveEmptyDirs != nil && *c.RemoveEmptyDirs
+}
+
+// remote reports whether the company's data is somewhere other than the Pruner's FS.
+func (c CompanyConfig) remote() bool {
+	return c.Storage != nil || c.SFTP != nil
+}
+
+// Exempt reports whether the company's retention is "never" and nothing inside it has a retention of its own, so
+// that none of its data is ever removed.
+func (c CompanyConfig) Exempt() bool {
+	retention, err := ParseRetention(c.Retention)
+	return err == nil && retention.Never && len(c.SubtenantRetention) == 0 && len(c.Categories) == 0 && c.CompressAfter == ""
+}
+
+// IsStrict reports whether strict date parsing is on, which it is unless explicitly turned off.
+func (c CompanyConfig) IsStrict() bool {
+	return c.Strict == nil || *c.Strict
+}
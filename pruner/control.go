@@ -0,0 +1,90 @@
+package pruner
+
+import (
+	"sort"
+	"time"
+)
+
+// CompanyStatus is what the most recent pass did to a company, and whether it is paused.
+type CompanyStatus struct {
+	Stats CompanyStats `json:"stats"`
+	RunID string       `json:"runId,omitempty"`
+	// Finished is when the pass that produced Stats ended, zero if the company hasn't been pruned yet.
+	Finished time.Time `json:"finished"`
+	Paused   bool      `json:"paused"`
+}
+
+// Pause stops company from being pruned, from the next pass on, until Resume is called.
+func (p *Pruner) Pause(company string) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	if p.paused == nil {
+		p.paused = make(map[string]bool)
+	}
+	p.paused[company] = true
+}
+
+// Resume undoes Pause.
+func (p *Pruner) Resume(company string) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	delete(p.paused, company)
+}
+
+func (p *Pruner) isPaused(company string) bool {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	return p.paused[company]
+}
+
+// Trigger asks RunScheduled to prune the named companies, or every company if none are named, as soon as it can
+// instead of waiting for their schedules. It returns false if too many triggers are already waiting.
+func (p *Pruner) Trigger(companies ...string) bool {
+	select {
+	case p.triggers <- companies:
+		return true
+	default:
+		return false
+	}
+}
+
+// Status returns the latest status of every company that has been pruned or paused, ordered by company.
+func (p *Pruner) Status() []CompanyStatus {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	statuses := make(map[string]CompanyStatus)
+	for company, status := range p.last {
+		statuses[company] = status
+	}
+	for company := range p.paused {
+		found := false
+		for key, status := range statuses {
+			if status.Stats.Company == company {
+				status.Paused = true
+				statuses[key] = status
+				found = true
+			}
+		}
+		if !found {
+			statuses[company] = CompanyStatus{Stats: CompanyStats{Company: company}, Paused: true}
+		}
+	}
+	list := make([]CompanyStatus, 0, len(statuses))
+	for _, status := range statuses {
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Stats.key() < list[j].Stats.key() })
+	return list
+}
+
+// recordStatus remembers the outcome of a pass for Status.
+func (p *Pruner) recordStatus(summary Summary) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	if p.last == nil {
+		p.last = make(map[string]CompanyStatus)
+	}
+	for _, stats := range summary.Companies {
+		p.last[stats.key()] = CompanyStatus{Stats: stats, RunID: summary.RunID, Finished: summary.End, Paused: p.paused[stats.Company]}
+	}
+}
@@ -0,0 +1,141 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ConfigChanges describes, one line per entry, how the entries in next differ from those in prev: added and removed
+// companies, retention changes, and entries whose other settings changed. Changes to the protected paths or allowed
+// companies come first.
+func ConfigChanges(prev Config, next Config) []string {
+	var changes []string
+	if !reflect.DeepEqual(prev.ProtectedPaths, next.ProtectedPaths) {
+		changes = append(changes, fmt.Sprintf("protectedPaths: %v -> %v", prev.ProtectedPaths, next.ProtectedPaths))
+	}
+	if !reflect.DeepEqual(prev.AllowCompanies, next.AllowCompanies) {
+		changes = append(changes, fmt.Sprintf("allowCompanies: %v -> %v", prev.AllowCompanies, next.AllowCompanies))
+	}
+	describe := func(name string, before CompanyConfig, after CompanyConfig) {
+		switch {
+		case before.Retention != after.Retention:
+			changes = append(changes, fmt.Sprintf("%s: retention %s -> %s", name, before.Retention, after.Retention))
+		case !reflect.DeepEqual(before, after):
+			changes = append(changes, fmt.Sprintf("%s: settings changed, retention still %s", name, after.Retention))
+		}
+	}
+	describe("default", prev.DefaultConfig, next.DefaultConfig)
+	prevGroups, nextGroups := groupMap(prev.Groups), groupMap(next.Groups)
+	for _, name := range groupNames(prevGroups, nextGroups) {
+		before, hadGroup := prevGroups[name]
+		after, hasGroup := nextGroups[name]
+		switch {
+		case !hadGroup:
+			changes = append(changes, fmt.Sprintf("group %s: added", name))
+		case !hasGroup:
+			changes = append(changes, fmt.Sprintf("group %s: removed", name))
+		default:
+			describe("group "+name, before.CompanyConfig, after.CompanyConfig)
+		}
+	}
+	if !reflect.DeepEqual(prev.BaseDirs, next.BaseDirs) {
+		changes = append(changes, fmt.Sprintf("baseDirs: %d -> %d entries", len(prev.BaseDirs), len(next.BaseDirs)))
+	}
+	// Compare the entries as written, without the protected paths added to them.
+	before, after := ConfigMap(Config{CompanyConfigs: prev.CompanyConfigs}), ConfigMap(Config{CompanyConfigs: next.CompanyConfigs})
+	var ids []string
+	for id := range before {
+		ids = append(ids, id)
+	}
+	for id := range after {
+		if _, exists := before[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if id == "default" {
+			continue
+		}
+		name := "company " + id
+		prevEntry, hadEntry := before[id]
+		nextEntry, hasEntry := after[id]
+		switch {
+		case !hadEntry:
+			changes = append(changes, fmt.Sprintf("%s: added, retention %s", name, nextEntry.Retention))
+		case !hasEntry:
+			changes = append(changes, fmt.Sprintf("%s: removed, now uses the default retention %s", name, next.DefaultConfig.Retention))
+		default:
+			describe(name, prevEntry, nextEntry)
+		}
+	}
+	return changes
+}
+
+// PolicyImpact compares what a pass run now would remove from one company directory under the current config and
+// under a proposed one.
+type PolicyImpact struct {
+	Company string `json:"companyId"`
+	// BaseDir is the base directory holding the company directory, when there are several.
+	BaseDir string `json:"baseDir,omitempty"`
+	// Current and Proposed are the retention each config gives the company, and the dry-run stats under it.
+	CurrentRetention  string       `json:"currentRetention"`
+	ProposedRetention string       `json:"proposedRetention"`
+	Current           CompanyStats `json:"current"`
+	Proposed          CompanyStats `json:"proposed"`
+}
+
+// Changed reports whether the proposed config would remove a different amount of data than the current one.
+func (i PolicyImpact) Changed() bool {
+	return i.Current.DirsDeleted != i.Proposed.DirsDeleted || i.Current.FilesDeleted != i.Proposed.FilesDeleted ||
+		i.Current.BytesFreed != i.Proposed.BytesFreed
+}
+
+// PolicyImpact makes two dry passes over every company directory, one with the current config and one with next,
+// both as of the same moment, and returns what each would remove.
+func (p *Pruner) PolicyImpact(ctx context.Context, next Config) ([]PolicyImpact, error) {
+	companies, err := p.companyDirs()
+	if err != nil {
+		return nil, err
+	}
+	current, proposed := p.configMaps(p.Config()), p.configMaps(next)
+	now := p.Clock.Now()
+	quiet := log.New()
+	quiet.Out = ioutil.Discard
+	var impacts []PolicyImpact
+	for _, company := range companies {
+		if ctx.Err() != nil {
+			return impacts, ctx.Err()
+		}
+		before, after := companyConfig(current[company.base], company.name), companyConfig(proposed[company.base], company.name)
+		impacts = append(impacts, PolicyImpact{
+			Company:           company.name,
+			BaseDir:           company.shown,
+			CurrentRetention:  before.Retention,
+			ProposedRetention: after.Retention,
+			Current:           p.newCompanyRun(ctx, company, before, "", now, quiet, true, NopRecorder{}).prune(),
+			Proposed:          p.newCompanyRun(ctx, company, after, "", now, quiet, true, NopRecorder{}).prune(),
+		})
+	}
+	return impacts, nil
+}
+
+// groupNames returns the names of the groups in either map, sorted.
+func groupNames(a map[string]GroupConfig, b map[string]GroupConfig) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, exists := a[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package pruner
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// markEmptied records that something was removed from path's directory. The caller holds mu.
+func (c *companyRun) markEmptied(path string) {
+	if c.emptied == nil {
+		c.emptied = make(map[string]bool)
+	}
+	c.emptied[filepath.Dir(path)] = true
+}
+
+// removeEmptyDirs removes the date directories that the pass's removals left empty, and then their parents if that
+// leaves them empty too. Only directories at a dated level of the layout, and no shallower than minDepth, are
+// removed.
+func (c *companyRun) removeEmptyDirs(layout Layout) {
+	if c.dryRun || !c.stats.Completed {
+		return
+	}
+	dirs := make([]string, 0, len(c.emptied))
+	for dir := range c.emptied {
+		dirs = append(dirs, dir)
+	}
+	// Deepest first, so a parent is only considered after its children have had their chance to go.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		depth := len(relativeParts(c.dir, dir))
+		if dir == c.dir || depth > layout.Depth() || !layout.levels[depth-1].dated() || c.guard(dir) != nil {
+			continue
+		}
+		if c.excluded(relativePath(c.dir, dir)) {
+			continue
+		}
+		if empty, err := dirEmpty(c.fs, dir); err != nil || !empty {
+			continue
+		}
+		if err := c.p.pace(c.ctx); err != nil {
+			return
+		}
+		if err := c.fs.RemoveAll(dir); err != nil {
+			c.error(dir, "Error removing empty directory", err)
+			continue
+		}
+		c.log.WithField("path", dir).Infoln("Removed empty directory")
+		if parent := filepath.Dir(dir); !c.emptied[parent] {
+			c.emptied[parent] = true
+			dirs = append(dirs, parent)
+			sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+		}
+	}
+}
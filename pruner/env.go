@@ -0,0 +1,185 @@
+package pruner
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var interpolationPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-fallback} in a config document with the values lookup returns. In JSON
+// documents the values are escaped for use inside strings. Only the braced form is recognised, so a bare $ is left
+// alone. It is an error for a variable without a fallback to be unset.
+func ExpandEnv(data []byte, format string, lookup func(string) (string, bool)) ([]byte, error) {
+	var missing []string
+	expanded := interpolationPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := interpolationPattern.FindSubmatch(match)
+		value, set := lookup(string(groups[1]))
+		if !set {
+			if groups[2] == nil {
+				missing = append(missing, string(groups[1]))
+				return match
+			}
+			value = string(groups[2][2:])
+		}
+		if format == FormatJSON {
+			quoted, _ := json.Marshal(value)
+			return quoted[1 : len(quoted)-1]
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("config refers to unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// Environment variable prefixes for config overrides. DELETER_DEFAULT_<FIELD> overrides a field of the default entry
+// and DELETER_COMPANY_<ID>_<FIELD> a field of a company's entry. Field names are the CompanyConfig field names in
+// upper snake case, e.g. RETENTION or MIN_KEEP_DAYS, and fields of archive as ARCHIVE_BUCKET and so on. In ids,
+// anything other than a letter or digit is written as _.
+const (
+	defaultOverridePrefix = "DELETER_DEFAULT_"
+	companyOverridePrefix = "DELETER_COMPANY_"
+)
+
+// ApplyEnvOverrides sets config fields from the DELETER_DEFAULT_ and DELETER_COMPANY_ variables in environ, which
+// is in the form os.Environ returns. Lists are comma-separated. It is an error for a variable to name a company or
+// field that doesn't exist, or to set an invalid value.
+func ApplyEnvOverrides(config *Config, environ []string) error {
+	sort.Strings(environ)
+	var problems []string
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		var entry *CompanyConfig
+		var field string
+		switch {
+		case strings.HasPrefix(name, defaultOverridePrefix):
+			entry, field = &config.DefaultConfig, strings.TrimPrefix(name, defaultOverridePrefix)
+		case strings.HasPrefix(name, companyOverridePrefix):
+			entry, field = config.overrideTarget(strings.TrimPrefix(name, companyOverridePrefix))
+			if entry == nil {
+				problems = append(problems, fmt.Sprintf("%s: no company in the config matches", name))
+				continue
+			}
+		default:
+			continue
+		}
+		before := make(map[string]bool)
+		for _, msg := range entry.Problems() {
+			before[msg] = true
+		}
+		if err := setField(reflect.ValueOf(entry).Elem(), field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		for _, msg := range entry.Problems() {
+			if !before[msg] {
+				problems = append(problems, fmt.Sprintf("%s: %s", name, msg))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config overrides: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// overrideTarget finds the company entry a DELETER_COMPANY_ variable name, without the prefix, refers to, and returns
+// it with the rest of the name. The longest matching id wins, since ids may themselves contain underscores.
+func (c *Config) overrideTarget(name string) (*CompanyConfig, string) {
+	var target *CompanyConfig
+	var field string
+	for i := range c.CompanyConfigs {
+		prefix := envName(c.CompanyConfigs[i].Id) + "_"
+		if strings.HasPrefix(name, prefix) && (target == nil || len(prefix) > len(name)-len(field)) {
+			target, field = &c.CompanyConfigs[i], strings.TrimPrefix(name, prefix)
+		}
+	}
+	return target, field
+}
+
+// setField sets the field of the struct v whose upper snake case name is field from value.
+func setField(v reflect.Value, field string, value string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := envName(t.Field(i).Name)
+		if field == name {
+			return setValue(v.Field(i), value)
+		}
+		if strings.HasPrefix(field, name+"_") && t.Field(i).Type.Kind() == reflect.Ptr && t.Field(i).Type.Elem().Kind() == reflect.Struct {
+			if v.Field(i).IsNil() {
+				v.Field(i).Set(reflect.New(t.Field(i).Type.Elem()))
+			}
+			return setField(v.Field(i).Elem(), strings.TrimPrefix(field, name+"_"), value)
+		}
+	}
+	return fmt.Errorf("unknown field %s", field)
+}
+
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		v.SetBool(b)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("field can't be set from the environment")
+		}
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		if items.Len() == 0 {
+			items = reflect.Zero(v.Type())
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("field can't be set from the environment")
+	}
+	return nil
+}
+
+// envName turns a Go field name or company id into the form used in variable names: MinKeepDays becomes
+// MIN_KEEP_DAYS and acme-corp becomes ACME_CORP.
+func envName(s string) string {
+	var name strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])):
+			name.WriteRune('_')
+			name.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			name.WriteRune(unicode.ToUpper(r))
+		default:
+			name.WriteRune('_')
+		}
+	}
+	return name.String()
+}
@@ -0,0 +1,77 @@
+package pruner
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSetValueEveryField sets every field of Config, and of the structs it holds, from the environment, to check
+// that setValue either sets it or says it can't, and never panics.
+func TestSetValueEveryField(t *testing.T) {
+	seen := make(map[reflect.Type]bool)
+	var walk func(path string, typ reflect.Type)
+	walk = func(path string, typ reflect.Type) {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+			if typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Struct {
+				break
+			}
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			for _, value := range []string{"x", "1", "a, b", ""} {
+				t.Run(path+"."+field.Name+"="+value, func(t *testing.T) {
+					v := reflect.New(field.Type).Elem()
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("setValue panicked: %v", r)
+						}
+					}()
+					setValue(v, value)
+				})
+			}
+			walk(path+"."+field.Name, field.Type)
+		}
+	}
+	walk("Config", reflect.TypeOf(Config{}))
+	if !seen[reflect.TypeOf(CompanyConfig{})] {
+		t.Error("the walk never reached CompanyConfig")
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme-corp", Retention: "30"}}}
+	err := ApplyEnvOverrides(&config, []string{
+		"DELETER_DEFAULT_RETENTION=10",
+		"DELETER_COMPANY_ACME_CORP_MIN_KEEP_DAYS=3",
+		"DELETER_COMPANY_ACME_CORP_EXCLUDE_PATHS=tmp, cache",
+		"PATH=/bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DefaultConfig.Retention != "10" {
+		t.Errorf("default retention is %q, want 10", config.DefaultConfig.Retention)
+	}
+	company := config.CompanyConfigs[0]
+	if company.MinKeepDays != "3" {
+		t.Errorf("min keep days is %q, want 3", company.MinKeepDays)
+	}
+	if !reflect.DeepEqual(company.ExcludePaths, []string{"tmp", "cache"}) {
+		t.Errorf("exclude paths are %q, want [tmp cache]", company.ExcludePaths)
+	}
+}
+
+func TestApplyEnvOverridesRejectsStructLists(t *testing.T) {
+	var config Config
+	if err := ApplyEnvOverrides(&config, []string{"DELETER_DEFAULT_CALENDAR_RULES=x"}); err == nil {
+		t.Error("setting a list of rules from a string was not rejected")
+	}
+}
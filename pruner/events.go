@@ -0,0 +1,89 @@
+package pruner
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event is a decision a pass made about a path, for programs following a pass as it goes. Kind is one of the Event*
+// kinds.
+type Event struct {
+	Time          time.Time `json:"time"`
+	RunID         string    `json:"runId"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Company       string    `json:"companyId"`
+	Kind          string    `json:"event"`
+	Path          string    `json:"path"`
+	// Date and Cutoff are set on scanned directories: the date their name gives and the cutoff it was compared with.
+	Date    *time.Time `json:"date,omitempty"`
+	Cutoff  *time.Time `json:"cutoff,omitempty"`
+	Expired bool       `json:"expired,omitempty"`
+	// Action is what was, or in a dry run would have been, done to a deleted path: an Audit* action.
+	Action string `json:"action,omitempty"`
+	// Reason says why a path was skipped or failed, and Error is the error behind a failure.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+}
+
+const (
+	// EventScanned is a directory the walk compared with the cutoff.
+	EventScanned = "scanned"
+	// EventSkipped is an expired path kept, or a directory passed over, and why.
+	EventSkipped = "skipped"
+	// EventDeleted is a path removed, trashed or moved, or in a dry run one that would have been.
+	EventDeleted = "deleted"
+	// EventFailed is a path an error kept from being pruned.
+	EventFailed = "failed"
+)
+
+// EventWriter writes events as newline-delimited JSON. It is safe for concurrent use.
+type EventWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewEventWriter returns an EventWriter writing to w.
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{encoder: json.NewEncoder(w)}
+}
+
+// Write writes event as a line of JSON.
+func (w *EventWriter) Write(event Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.encoder.Encode(event)
+}
+
+// emit fills in what every event of the company's pass shares and passes event to the Pruner's OnEvent, if set.
+func (c *companyRun) emit(event Event) {
+	if c.p.OnEvent == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.RunID = c.runID
+	event.CorrelationID = c.p.CorrelationID
+	event.Company = c.stats.Company
+	event.DryRun = c.dryRun
+	c.p.OnEvent(event)
+}
+
+// scanned emits an EventScanned for the directory at path.
+func (c *companyRun) scanned(path string, date time.Time, cutoff time.Time) {
+	if c.p.OnEvent != nil {
+		c.emit(Event{Kind: EventScanned, Path: path, Date: &date, Cutoff: &cutoff, Expired: date.Before(cutoff)})
+	}
+}
+
+// skipped emits an EventSkipped for path, and for a purge records it in KeptPaths.
+func (c *companyRun) skipped(path string, reason string) {
+	if c.purging() {
+		c.mu.Lock()
+		c.stats.KeptPaths = append(c.stats.KeptPaths, path)
+		c.mu.Unlock()
+	}
+	c.emit(Event{Kind: EventSkipped, Path: path, Reason: reason})
+}
@@ -0,0 +1,183 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Explanation describes how a pass would treat a company directory.
+type Explanation struct {
+	Company string
+	// BaseDir is the base directory holding the company directory, when there are several.
+	BaseDir string
+	// Explicit is set when the company has its own config entry rather than the default.
+	Explicit  bool
+	Config    CompanyConfig
+	Retention Retention
+	// Cutoff is the time before which data is removed. Floored is set when MinKeepDays moved it back, and MinAged
+	// when the Pruner's MinAge did.
+	Cutoff  time.Time
+	Floored bool
+	MinAged bool
+	// Err is set when the company's config keeps it from being pruned.
+	Err error
+}
+
+// Explain works out, without touching any data, which config each company directory gets and what its cutoff is.
+func (p *Pruner) Explain() ([]Explanation, error) {
+	companies, err := p.companyDirs()
+	if err != nil {
+		return nil, err
+	}
+	configMaps := p.configMaps(p.Config())
+	now := p.Clock.Now()
+	quiet := log.New()
+	quiet.Out = ioutil.Discard
+	explanations := make([]Explanation, 0, len(companies))
+	for _, company := range companies {
+		configMap := configMaps[company.base]
+		_, explicit := lookupEntry(configMap, company.name)
+		run := p.newCompanyRun(context.Background(), company, companyConfig(configMap, company.name), "", now, quiet, true, NopRecorder{})
+		explanation := Explanation{Company: company.name, BaseDir: company.shown, Explicit: explicit, Config: run.config}
+		if err := run.resolveCutoff(); err != nil {
+			explanation.Err = err
+		} else if _, err := run.config.ParseLayout(); err != nil && run.config.Mode != ModeMtime {
+			explanation.Err = err
+		}
+		explanation.Retention = run.retention
+		explanation.Cutoff = run.cutoff
+		explanation.Floored = run.floored
+		explanation.MinAged = run.minAged
+		explanations = append(explanations, explanation)
+	}
+	return explanations, nil
+}
+
+// PathExplanation describes how a pass would treat one path below one of the base directories.
+type PathExplanation struct {
+	Path    string
+	Company string
+	// Date is the date the decision is based on: the end of the interval the path's name covers, or its
+	// modification time in mtime mode. It is zero when there is none.
+	Date   time.Time
+	Cutoff time.Time
+	Remove bool
+	// Reason says why the path is or isn't removed.
+	Reason string
+}
+
+// ExplainPath works out, without touching any data, whether a pass would remove path and why.
+func (p *Pruner) ExplainPath(path string) (PathExplanation, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return PathExplanation{}, err
+	}
+	configured, base, err := p.baseOf(abs)
+	if err != nil {
+		return PathExplanation{}, err
+	}
+	if base == "" {
+		return PathExplanation{}, fmt.Errorf("%s is not inside a company directory under %s", path, strings.Join(p.Bases(), ", "))
+	}
+	parts := relativeParts(base, abs)
+	company := parts[0]
+	quiet := log.New()
+	quiet.Out = ioutil.Discard
+	configMap := p.configMaps(p.Config())[configured]
+	run := p.newCompanyRun(context.Background(), companyDir{name: company, base: base}, companyConfig(configMap, company), "", p.Clock.Now(), quiet, true, NopRecorder{})
+	explanation := PathExplanation{Path: abs, Company: company}
+	rel := strings.Join(parts[1:], "/")
+	if run.config.OnLegalHold() {
+		explanation.Reason = "company is under legal hold"
+		return explanation, nil
+	}
+	if run.config.Exempt() {
+		explanation.Reason = "company's retention is never"
+		return explanation, nil
+	}
+	if err := run.resolveCutoff(); err != nil {
+		return explanation, fmt.Errorf("company %s: %v", company, err)
+	}
+	if len(parts) > 1 && parts[1] == trashDirName {
+		explanation.Cutoff = run.cutoff
+		explanation.Reason = "in the trash, removed once the trash grace period is over"
+		return explanation, nil
+	}
+	for i := 1; i <= len(parts); i++ {
+		dir := filepath.Join(append([]string{base}, parts[:i]...)...)
+		if info, err := run.fs.Stat(dir); err != nil || !info.IsDir() {
+			break
+		}
+		if run.readMarkers(dir, relativePath(run.dir, dir)) {
+			explanation.Cutoff = run.cutoff
+			explanation.Reason = "pinned by a marker file in " + dir
+			return explanation, nil
+		}
+	}
+	run.cutoff = run.cutoffFor(rel)
+	explanation.Cutoff = run.cutoff
+	if run.config.Mode == ModeMtime {
+		info, err := run.fs.Stat(abs)
+		if err != nil {
+			return explanation, err
+		}
+		explanation.Date = info.ModTime()
+		switch {
+		case info.IsDir():
+			explanation.Reason = "directory in mtime mode, removed only once a pass has emptied it"
+		case rel != "" && run.excluded(rel):
+			explanation.Reason = "matches excludePaths or protectedPaths"
+		case explanation.Date.Before(run.cutoff):
+			explanation.Remove = true
+			explanation.Reason = "modified before the cutoff"
+		default:
+			explanation.Reason = "modified after the cutoff"
+		}
+		return explanation, nil
+	}
+	layout, err := run.config.ParseLayout()
+	if err != nil {
+		return explanation, fmt.Errorf("company %s: %v", company, err)
+	}
+	date, dateErr := layout.StrictDate(parts[1:], run.now)
+	explanation.Date = date
+	switch {
+	case rel == "":
+		explanation.Reason = "company directory, never removed"
+	case dateErr != nil && run.config.IsStrict():
+		explanation.Date = time.Time{}
+		explanation.Reason = fmt.Sprintf("skipped, name doesn't parse as a date: %v", dateErr)
+	case !date.Before(run.cutoff):
+		explanation.Reason = "not expired"
+	case len(parts)-1 < run.minDepth:
+		explanation.Remove = true
+		explanation.Reason = fmt.Sprintf("expired, but above minDepth %d so only what is inside is removed", run.minDepth)
+	case run.excluded(rel):
+		explanation.Reason = "expired but matches excludePaths or protectedPaths"
+	default:
+		if run.config.MinKeepCount > 0 {
+			run.kept = run.newestLeaves(layout, run.config.MinKeepCount)
+		}
+		keptAbove := false
+		for i := 2; i <= len(parts); i++ {
+			keptAbove = keptAbove || run.kept[strings.Join(parts[1:i], "/")]
+		}
+		switch {
+		case keptAbove:
+			explanation.Reason = "expired but among the newest minKeepCount"
+		case run.excludedInside(abs, rel) || run.keepsBelow(rel):
+			explanation.Remove = true
+			explanation.Reason = "expired, but only the parts not protected by excludePaths or minKeepCount are removed"
+		default:
+			explanation.Remove = true
+			explanation.Reason = "expired"
+		}
+	}
+	return explanation, nil
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package pruner
+
+import (
+	"os"
+	"syscall"
+)
+
+// LockFile takes an exclusive flock on f, which lasts until f is closed or the process exits. It returns
+// ErrFileLocked if another open file holds one.
+func LockFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return ErrFileLocked
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package pruner
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// LockFile takes an exclusive LockFileEx lock on f, which lasts until f is closed or the process exits. It returns
+// ErrFileLocked if another open file holds one. Windows locks are mandatory, so the lock is on the last byte a file
+// can have rather than on its contents, which stay readable by everyone else.
+func LockFile(f *os.File) error {
+	var overlapped syscall.Overlapped
+	overlapped.Offset = 0xFFFFFFFF
+	overlapped.OffsetHigh = 0x7FFFFFFF
+	ok, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if ok == 0 {
+		if err == errorLockViolation {
+			return ErrFileLocked
+		}
+		return err
+	}
+	return nil
+}
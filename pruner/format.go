@@ -0,0 +1,63 @@
+package pruner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config document formats. YAML and TOML use the same field names as JSON.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FormatFromName picks a config format from the extension of a file name or URL, defaulting to JSON.
+func FormatFromName(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		name = u.Path
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// ParseConfigFormat decodes a config document in the given format.
+func ParseConfigFormat(data []byte, format string) (Config, error) {
+	data, err := ConfigToJSON(data, format)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// ConfigToJSON translates a YAML or TOML config document to JSON. JSON documents are returned unchanged.
+func ConfigToJSON(data []byte, format string) ([]byte, error) {
+	var document map[string]interface{}
+	switch format {
+	case FormatJSON:
+		return data, nil
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+	return json.Marshal(document)
+}
@@ -0,0 +1,262 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditReclaimed marks records written by FreeSpace.
+const AuditReclaimed = "reclaimed"
+
+// FreeSpaceTarget is how much free space FreeSpace makes: a number of bytes, or a percentage of the filesystem.
+type FreeSpaceTarget struct {
+	Bytes   uint64
+	Percent float64
+}
+
+// ParseFreeSpaceTarget parses a target such as 500GB, 1.5TiB or 10%. K, M, G and T are powers of 1000 and KiB,
+// MiB, GiB and TiB powers of 1024; a bare number is bytes.
+func ParseFreeSpaceTarget(spec string) (FreeSpaceTarget, error) {
+	if strings.HasSuffix(spec, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || percent <= 0 || percent >= 100 {
+			return FreeSpaceTarget{}, fmt.Errorf("free space target [%s] is not a percentage between 0 and 100", spec)
+		}
+		return FreeSpaceTarget{Percent: percent}, nil
+	}
+	bytes, err := ParseSize(spec)
+	if err != nil {
+		return FreeSpaceTarget{}, fmt.Errorf("free space target [%s] is not a size such as 500GB or a percentage", spec)
+	}
+	return FreeSpaceTarget{Bytes: bytes}, nil
+}
+
+// ParseSize parses a size such as 500GB or 1.5TiB. K, M, G and T are powers of 1000 and KiB, MiB, GiB and TiB
+// powers of 1024; a bare number is bytes.
+func ParseSize(spec string) (uint64, error) {
+	number := strings.TrimRight(spec, "KMGTiB")
+	unit := strings.ToUpper(spec[len(number):])
+	multipliers := map[string]float64{
+		"": 1, "B": 1,
+		"K": 1e3, "KB": 1e3, "M": 1e6, "MB": 1e6, "G": 1e9, "GB": 1e9, "T": 1e12, "TB": 1e12,
+		"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40,
+	}
+	multiplier, known := multipliers[unit]
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if !known || err != nil || value <= 0 {
+		return 0, fmt.Errorf("[%s] is not a size such as 500GB", spec)
+	}
+	return uint64(value * multiplier), nil
+}
+
+func (t FreeSpaceTarget) String() string {
+	if t.Percent > 0 {
+		return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatUint(t.Bytes, 10) + " bytes"
+}
+
+// met reports whether free bytes out of total reach the target.
+func (t FreeSpaceTarget) met(free uint64, total uint64) bool {
+	if t.Percent > 0 {
+		return float64(free) >= float64(total)*t.Percent/100
+	}
+	return free >= t.Bytes
+}
+
+// ErrTargetNotMet is returned by FreeSpace when everything it was allowed to remove is gone and there still isn't
+// enough free space.
+var ErrTargetNotMet = fmt.Errorf("ran out of data that may be removed before reaching the free space target")
+
+// reclaimable is a date directory FreeSpace may remove.
+type reclaimable struct {
+	run  *companyRun
+	path string
+	date time.Time
+}
+
+// FreeSpace removes date directories oldest first, across every company, until the filesystem holding each base
+// directory has target free, regardless of retention. It keeps to each company's minKeepDays and minKeepCount, excludePaths, marker
+// files and legal hold, skips paused companies and those in mtime mode, and bypasses the trash. Like a pass, it skips
+// companies another instance holds the Locker's lock on. A dry run counts the bytes it would free as freed.
+func (p *Pruner) FreeSpace(ctx context.Context, target FreeSpaceTarget) (Summary, error) {
+	p.passMu.Lock()
+	defer p.passMu.Unlock()
+	companies, err := p.companyDirs()
+	if err != nil {
+		return Summary{}, err
+	}
+	config := p.Config()
+	configMaps := p.configMaps(config)
+	now := p.Clock.Now()
+	summary := Summary{RunID: newRunID(), CorrelationID: p.CorrelationID, Start: time.Now(), AsOf: p.asOf(now)}
+	runs := make([]*companyRun, 0, len(companies))
+	candidates := make(map[string][]reclaimable)
+	// Companies stay locked from finding what may be removed until the removals are done, as for a pass.
+	var unlocks []func()
+	unlockAll := func() {
+		for _, unlock := range unlocks {
+			unlock()
+		}
+		unlocks = nil
+	}
+	defer func() {
+		unlockAll()
+		for _, run := range runs {
+			run.endRemoveSpan()
+		}
+	}()
+	for _, company := range companies {
+		run := p.newCompanyRun(ctx, company, companyConfig(configMaps[company.base], company.name), summary.RunID, now, p.Log, p.DryRun, p.Recorder)
+		run.permanent = true
+		run.action = AuditReclaimed
+		runs = append(runs, run)
+		unlock, ok := run.lock()
+		if !ok {
+			continue
+		}
+		unlocks = append(unlocks, unlock)
+		found, err := run.reclaimable()
+		if err != nil {
+			run.configError(err)
+			continue
+		}
+		candidates[company.base] = append(candidates[company.base], found...)
+	}
+
+	// Each base directory may be on its own filesystem, so each frees space from its own data.
+	planned := make(map[string]uint64)
+	for _, base := range p.bases(config) {
+		found := candidates[base]
+		sort.SliceStable(found, func(i, j int) bool { return found[i].date.Before(found[j].date) })
+		p.Log.WithField("run_id", summary.RunID).WithField("base_dir", base).Infof("Found %d directories that may be removed to reach %s free", len(found), target)
+		for _, candidate := range found {
+			free, total, err := p.FS.DiskSpace(base)
+			if err != nil {
+				return summary, err
+			}
+			if target.met(free+planned[base], total) || ctx.Err() != nil {
+				break
+			}
+			if candidate.run.ctx.Err() != nil {
+				// The company's lock was lost.
+				continue
+			}
+			before := candidate.run.stats.BytesFreed
+			candidate.run.removeExpired(candidate.path, true, candidate.date)
+			if p.DryRun {
+				planned[base] += uint64(candidate.run.stats.BytesFreed - before)
+			}
+		}
+	}
+	unlockAll()
+	for _, run := range runs {
+		run.stats.Completed = ctx.Err() == nil && run.stats.Errors == 0
+		summary.Companies = append(summary.Companies, run.stats)
+	}
+	summary.End = time.Now()
+	summary.Interrupted = ctx.Err() != nil
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+	for _, base := range p.bases(config) {
+		free, total, err := p.FS.DiskSpace(base)
+		if err != nil {
+			return summary, err
+		}
+		if !target.met(free+planned[base], total) {
+			return summary, ErrTargetNotMet
+		}
+	}
+	return summary, nil
+}
+
+// reclaimable lists the company's date directories, at the finest dated level of its layout, that are older than
+// its minKeepDays and not protected by minKeepCount, excludePaths or marker files.
+func (c *companyRun) reclaimable() ([]reclaimable, error) {
+	if c.config.OnLegalHold() || c.p.isPaused(c.stats.Company) {
+		c.log.Infoln("Company is under legal hold or paused, skipping")
+		return nil, nil
+	}
+	if c.config.Exempt() {
+		c.log.Infoln("Company's retention is never, skipping")
+		return nil, nil
+	}
+	if c.config.Mode == ModeMtime {
+		c.log.Infoln("Company is in mtime mode, skipping")
+		return nil, nil
+	}
+	if c.config.remote() {
+		// Nothing removed from object storage or another server frees space on our disks.
+		return nil, nil
+	}
+	loc, err := c.location()
+	if err != nil {
+		return nil, err
+	}
+	c.now = c.now.In(loc)
+	c.cutoff = c.now
+	if c.config.MinKeepDays != "" {
+		minKeep, err := ParseRetention(c.config.MinKeepDays)
+		if err != nil {
+			return nil, fmt.Errorf("minKeepDays: %v", err)
+		}
+		c.cutoff = minKeep.Cutoff(c.now)
+	}
+	c.holdBackToMinAge()
+	c.stats.Cutoff = c.cutoff
+	layout, err := c.config.ParseLayout()
+	if err != nil {
+		return nil, err
+	}
+	if c.config.MinKeepCount > 0 {
+		c.kept = c.newestLeaves(layout, c.config.MinKeepCount)
+	}
+	var found []reclaimable
+	err = c.walkDir(c.dir, func(path string, f fs.DirEntry, err error) error {
+		if c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		if err != nil {
+			c.error(path, "Error in path", err)
+			return nil
+		}
+		if !f.IsDir() || path == c.dir {
+			return nil
+		}
+		if path == filepath.Join(c.dir, trashDirName) {
+			return filepath.SkipDir
+		}
+		c.stats.DirsScanned++
+		parts := relativeParts(c.dir, path)
+		rel := strings.Join(parts, "/")
+		date, dateErr := layout.StrictDate(parts, c.now)
+		if dateErr != nil && c.config.IsStrict() {
+			c.stats.DirsUnparsed++
+			return filepath.SkipDir
+		}
+		if start, _ := layout.StartDate(parts, c.now); !start.IsZero() && !start.Before(c.cutoff) {
+			return filepath.SkipDir
+		}
+		if c.excluded(rel) || c.readMarkers(path, rel) {
+			return filepath.SkipDir
+		}
+		if layout.DatedBelow(len(parts)) {
+			return nil
+		}
+		if len(parts) >= c.minDepth && date.Before(c.cutoffFor(rel)) && !c.kept[rel] && !c.keepsBelow(rel) && !c.excludedInside(path, rel) {
+			found = append(found, reclaimable{run: c, path: path, date: date})
+		}
+		return filepath.SkipDir
+	})
+	if err != nil && err != c.ctx.Err() {
+		c.error(c.dir, "Error walking path", err)
+	}
+	return found, nil
+}
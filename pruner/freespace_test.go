@@ -0,0 +1,170 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// usageFS is a MemFS whose disk is total bytes, of which the files use what they hold.
+type usageFS struct {
+	*MemFS
+	total uint64
+}
+
+func (u usageFS) DiskSpace(name string) (uint64, uint64, error) {
+	var used uint64
+	u.WalkDir("/", func(path string, f fs.DirEntry, err error) error {
+		if err == nil && f.Type().IsRegular() {
+			if info, err := f.Info(); err == nil {
+				used += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return u.total - used, u.total, nil
+}
+
+// reclaimTree writes a file of 100 bytes in each of days, given as company/year/month/day, to a disk of 1000 bytes.
+func reclaimTree(days ...string) usageFS {
+	fsys := usageFS{MemFS: &MemFS{}, total: 1000}
+	for _, day := range days {
+		fsys.WriteFile("/data/"+day+"/data", make([]byte, 100), testNow)
+	}
+	return fsys
+}
+
+// reclaimConfig has a daily layout and a retention of 30 days for each of companies.
+func reclaimConfig(companies ...string) Config {
+	var config Config
+	for _, company := range companies {
+		config.CompanyConfigs = append(config.CompanyConfigs, CompanyConfig{Id: company, Retention: "30", Layout: "{year}/{month}/{day}"})
+	}
+	return config
+}
+
+// reclaimed runs FreeSpace and returns the paths it removed, relative to the base directory and sorted, the stats
+// of each company and its error.
+func reclaimed(t *testing.T, p *Pruner, target FreeSpaceTarget) ([]string, map[string]CompanyStats, error) {
+	var mu sync.Mutex
+	var removed []string
+	p.OnRemove = func(record AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, filepath.ToSlash(relativePath("/data", record.Path)))
+	}
+	summary, err := p.FreeSpace(context.Background(), target)
+	sort.Strings(removed)
+	stats := make(map[string]CompanyStats)
+	for _, company := range summary.Companies {
+		stats[company.Company] = company
+	}
+	return removed, stats, err
+}
+
+func TestFreeSpaceRemovesTheOldestFirstAcrossCompanies(t *testing.T) {
+	fsys := reclaimTree("acme/2020/01/01", "acme/2020/03/01", "acme/2020/12/31", "beta/2020/02/01", "beta/2020/04/01")
+	// 500 bytes are free, and 700 are wanted: the two oldest days go, whichever company they belong to.
+	removed, _, err := reclaimed(t, memPruner(fsys, reclaimConfig("acme", "beta")), FreeSpaceTarget{Bytes: 700})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[acme/2020/01/01 beta/2020/02/01]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+	// A percentage of the disk works the same way.
+	removed, _, err = reclaimed(t, memPruner(fsys, reclaimConfig("acme", "beta")), FreeSpaceTarget{Percent: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[acme/2020/03/01]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+}
+
+func TestFreeSpaceKeepsWhatIsProtected(t *testing.T) {
+	fsys := reclaimTree(
+		"acme/2020/01/01", "acme/2020/02/15", "acme/2020/03/01", "acme/2020/12/31",
+		"beta/2020/02/01", "beta/2020/03/01", "beta/2020/04/01",
+		"held/2019/01/01",
+	)
+	fsys.WriteFile("/data/acme/2020/03/01/"+keepMarker, nil, testNow)
+	fsys.WriteFile("/data/beta/2020/03/01/"+retentionMarker, []byte("never"), testNow)
+	config := reclaimConfig("acme", "beta", "held")
+	config.CompanyConfigs[0].ExcludePaths = []string{"2020/01"}
+	config.CompanyConfigs[0].MinKeepDays = "30"
+	config.CompanyConfigs[1].MinKeepCount = 1
+	hold := true
+	config.CompanyConfigs[2].LegalHold = &hold
+	removed, stats, err := reclaimed(t, memPruner(fsys, config), FreeSpaceTarget{Percent: 99})
+	if err != ErrTargetNotMet {
+		t.Errorf("got %v, want ErrTargetNotMet", err)
+	}
+	if want := "[acme/2020/02/15 beta/2020/02/01]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+	for company, stats := range stats {
+		if stats.Errors != 0 {
+			t.Errorf("%s: got %d errors", company, stats.Errors)
+		}
+	}
+	checkExists(t, fsys, true, "/data/acme/2020/01/01/data", "/data/acme/2020/03/01/data", "/data/acme/2020/12/31/data",
+		"/data/beta/2020/03/01/data", "/data/beta/2020/04/01/data", "/data/held/2019/01/01/data")
+}
+
+// heldLocker is a Locker on which another instance holds the locks of held, and which counts the locks it gives
+// out and gets back.
+type heldLocker struct {
+	held     []string
+	mu       sync.Mutex
+	locked   []string
+	unlocked int
+}
+
+func (h *heldLocker) TryLock(ctx context.Context, key string) (context.Context, func(), bool, error) {
+	for _, company := range h.held {
+		if key == "company/"+company {
+			return nil, nil, false, nil
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.locked = append(h.locked, key)
+	return ctx, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.unlocked++
+	}, true, nil
+}
+
+func TestFreeSpaceLocksEachCompany(t *testing.T) {
+	fsys := reclaimTree("acme/2020/01/01", "beta/2019/01/01")
+	locker := &heldLocker{held: []string{"beta"}}
+	p := memPruner(fsys, reclaimConfig("acme", "beta"))
+	p.Locker = locker
+	removed, stats, err := reclaimed(t, p, FreeSpaceTarget{Percent: 99})
+	if err != ErrTargetNotMet {
+		t.Errorf("got %v, want ErrTargetNotMet", err)
+	}
+	// beta's data is older, but another instance is pruning it.
+	if want := "[acme/2020/01/01]"; fmt.Sprint(removed) != want || !stats["beta"].Locked {
+		t.Errorf("removed %q with beta locked %v, want %s and beta locked", removed, stats["beta"].Locked, want)
+	}
+	if strings.Join(locker.locked, " ") != "company/acme" || locker.unlocked != 1 {
+		t.Errorf("locked %q and unlocked %d, want acme locked and unlocked", locker.locked, locker.unlocked)
+	}
+
+	// Nothing more is removed from a company whose lock is lost.
+	p = memPruner(fsys, reclaimConfig("acme", "beta"))
+	p.Locker = lostLocker{}
+	removed, stats, _ = reclaimed(t, p, FreeSpaceTarget{Percent: 99})
+	if len(removed) != 0 || stats["beta"].Errors != 1 {
+		t.Errorf("removed %q with %d errors, want nothing and an error for beta", removed, stats["beta"].Errors)
+	}
+	checkExists(t, fsys, true, "/data/beta/2019/01/01/data")
+}
@@ -0,0 +1,81 @@
+package pruner
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem is the set of filesystem operations a Pruner needs. ReadDir and WalkDir don't stat the entries they
+// return, which matters on trees of millions of files; callers that need sizes or times ask the DirEntry for them.
+type FileSystem interface {
+	ReadDir(dirname string) ([]os.DirEntry, error)
+	WalkDir(root string, fn fs.WalkDirFunc) error
+	Stat(path string) (os.FileInfo, error)
+	ReadFile(path string) ([]byte, error)
+	EvalSymlinks(path string) (string, error)
+	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+	MkdirAll(path string, perm os.FileMode) error
+	// DiskSpace returns the bytes available to us and the total size of the filesystem holding path.
+	DiskSpace(path string) (free uint64, total uint64, err error)
+}
+
+// OSFileSystem is a FileSystem backed by the local disk. WalkDir reads directories Batch entries at a time, or
+// defaultWalkBatch if it is zero; see walkStreamed.
+type OSFileSystem struct {
+	Batch int
+}
+
+func (OSFileSystem) ReadDir(dirname string) ([]os.DirEntry, error) {
+	return os.ReadDir(dirname)
+}
+
+func (o OSFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	return walkStreamed(o, root, info, err, fn)
+}
+
+func (o OSFileSystem) openDir(name string) (dirStream, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return osDirStream{f: f, batch: o.batch()}, nil
+}
+
+func (o OSFileSystem) batch() int {
+	if o.Batch > 0 {
+		return o.Batch
+	}
+	return defaultWalkBatch
+}
+
+func (o OSFileSystem) withBatch(entries int) FileSystem {
+	o.Batch = entries
+	return o
+}
+
+func (OSFileSystem) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (OSFileSystem) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (OSFileSystem) EvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
+func (OSFileSystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package pruner
+
+import (
+	"os"
+	"syscall"
+)
+
+func (OSFileSystem) DiskSpace(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
+
+// device returns the ID of the filesystem info was read from, on disk or in a MemFS.
+func device(info os.FileInfo) (uint64, bool) {
+	if dev, ok := info.Sys().(memDevice); ok {
+		return uint64(dev), true
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
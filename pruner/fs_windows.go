@@ -0,0 +1,19 @@
+package pruner
+
+import (
+	"errors"
+	"os"
+)
+
+func (OSFileSystem) DiskSpace(path string) (uint64, uint64, error) {
+	return 0, 0, errors.New("disk space is not supported on Windows")
+}
+
+// device is not supported on Windows, so directories on disk are never taken to be on another filesystem. MemFS
+// mounts still are.
+func device(info os.FileInfo) (uint64, bool) {
+	if dev, ok := info.Sys().(memDevice); ok {
+		return uint64(dev), true
+	}
+	return 0, false
+}
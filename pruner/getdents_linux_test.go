@@ -0,0 +1,34 @@
+//go:build linux
+
+package pruner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetdentsRemoveAll(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "tree")
+	for i := 0; i < 300; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", i%3), fmt.Sprintf("sub%d", i%7))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Small batches, so that directories are emptied over several reads.
+	fsys := GetdentsFileSystem{OSFileSystem: OSFileSystem{Batch: 8}}
+	if err := fsys.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(root); !os.IsNotExist(err) {
+		t.Errorf("%s is still there: %v", root, err)
+	}
+	if err := fsys.RemoveAll(root); err != nil {
+		t.Errorf("removing a path that doesn't exist: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package pruner
+
+import "errors"
+
+// NewFastFileSystem is only supported on Linux.
+func NewFastFileSystem() (FileSystem, error) {
+	return nil, errors.New("the getdents64 fast path is only supported on Linux")
+}
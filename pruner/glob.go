@@ -0,0 +1,52 @@
+package pruner
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether a slash-separated relative path matches pattern. Each pattern element is matched with
+// path.Match against one path element, except "**", which matches any number of elements, including none.
+func matchGlob(pattern string, rel string) bool {
+	return matchElements(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchElements(pattern []string, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchElements(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// globMayMatchBelow reports whether pattern could match something inside the directory rel, so that removing rel
+// wholesale might remove something the pattern protects.
+func globMayMatchBelow(pattern string, rel string) bool {
+	elements := strings.Split(pattern, "/")
+	for _, part := range strings.Split(rel, "/") {
+		if len(elements) == 0 {
+			return false
+		}
+		if elements[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(elements[0], part); !ok {
+			return false
+		}
+		elements = elements[1:]
+	}
+	return len(elements) > 0
+}
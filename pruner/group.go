@@ -0,0 +1,69 @@
+package pruner
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// GroupConfig holds settings shared by every company entry that names the group. Any field of a company entry may
+// be set on a group.
+type GroupConfig struct {
+	Name string `json:"name"`
+	CompanyConfig
+}
+
+// UnmarshalJSON reads the group's name and settings from the same object.
+func (g *GroupConfig) UnmarshalJSON(data []byte) error {
+	var named struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	if err := g.CompanyConfig.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	g.Name = named.Name
+	return nil
+}
+
+// inherit fills in the fields entry leaves unset from the group's settings.
+func (g GroupConfig) inherit(entry CompanyConfig) CompanyConfig {
+	return inherit(entry, g.CompanyConfig)
+}
+
+// inherit fills in the fields entry leaves unset from from, other than its id, name and group, and storage and sftp,
+// which say where the company's own data is. from's excludePaths are added to the entry's own rather than replaced
+// by them. Booleans are pointers so that an entry can set false over from's true.
+func inherit(entry CompanyConfig, from CompanyConfig) CompanyConfig {
+	merged := reflect.ValueOf(&entry).Elem()
+	source := reflect.ValueOf(from)
+	for i := 0; i < merged.NumField(); i++ {
+		switch merged.Type().Field(i).Name {
+		case "Id", "Name", "Group", "Storage", "SFTP":
+			continue
+		case "ExcludePaths":
+			excludes := append([]string(nil), from.ExcludePaths...)
+			for _, pattern := range entry.ExcludePaths {
+				if !containsString(excludes, pattern) {
+					excludes = append(excludes, pattern)
+				}
+			}
+			entry.ExcludePaths = excludes
+			continue
+		}
+		if field := merged.Field(i); field.IsZero() {
+			field.Set(source.Field(i))
+		}
+	}
+	return entry
+}
+
+// groupMap indexes the groups by name.
+func groupMap(groups []GroupConfig) map[string]GroupConfig {
+	byName := make(map[string]GroupConfig, len(groups))
+	for _, group := range groups {
+		byName[group.Name] = group
+	}
+	return byName
+}
@@ -0,0 +1,85 @@
+package pruner
+
+import (
+	"fmt"
+	"testing"
+)
+
+// inheritanceConfig has a default, a group of cameras overriding some of it and companies in and out of the group.
+const inheritanceConfig = `{
+	"default": {
+		"retentionDays": 30,
+		"layout": "{year}/{month}/{day}",
+		"excludePaths": ["exports/**"],
+		"strayFiles": "report",
+		"compressAfter": 7,
+		"archive": {"type": "s3", "bucket": "archive"},
+		"calendarRules": [{"days": "month-end"}],
+		"storage": {"type": "s3", "bucket": "data", "prefix": "default"}
+	},
+	"groups": [{
+		"name": "cameras",
+		"retentionDays": 60,
+		"layout": "{device}/{year}/{month}/{day}",
+		"mode": "path",
+		"excludePaths": ["*/2020/**", "exports/**"],
+		"subtenants": true,
+		"legalHold": true
+	}],
+	"protectedPaths": ["audit/**"],
+	"companies": [
+		{"companyId": "plain"},
+		{"companyId": "camera", "group": "cameras"},
+		{"companyId": "released", "group": "cameras", "legalHold": false, "subtenants": false, "layout": "{year}/{month}", "excludePaths": ["keep/**"]},
+		{"companyId": "own", "retentionDays": 10, "strayFiles": "ignore", "excludePaths": ["exports/**"]}
+	]
+}`
+
+func TestCompanyConfigInheritance(t *testing.T) {
+	config, err := ParseConfig([]byte(inheritanceConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMap := ConfigMap(config)
+	for _, test := range []struct {
+		company      string
+		retention    string
+		layout       string
+		excludes     string
+		strayFiles   string
+		compress     string
+		legalHold    bool
+		subtenants   bool
+		archive      bool
+		calendar     int
+		defaultEntry bool
+	}{
+		// Everything comes from the default.
+		{company: "plain", retention: "30", layout: "{year}/{month}/{day}", excludes: "[exports/** audit/**]", strayFiles: "report", compress: "7", archive: true, calendar: 1},
+		// The group's settings win over the default's, and its excludePaths are added to the default's.
+		{company: "camera", retention: "60", layout: "{device}/{year}/{month}/{day}", excludes: "[exports/** audit/** */2020/**]", strayFiles: "report", compress: "7", legalHold: true, subtenants: true, archive: true, calendar: 1},
+		// The company's win over the group's, false included.
+		{company: "released", retention: "60", layout: "{year}/{month}", excludes: "[exports/** audit/** */2020/** keep/**]", strayFiles: "report", compress: "7", archive: true, calendar: 1},
+		{company: "own", retention: "10", layout: "{year}/{month}/{day}", excludes: "[exports/** audit/**]", strayFiles: "ignore", compress: "7", archive: true, calendar: 1},
+		// Companies without an entry get the default as it is, where its data is included.
+		{company: "other", retention: "30", layout: "{year}/{month}/{day}", excludes: "[exports/** audit/**]", strayFiles: "report", compress: "7", archive: true, calendar: 1, defaultEntry: true},
+	} {
+		got := companyConfig(configMap, test.company)
+		if got.Retention != test.retention || got.Layout != test.layout || got.StrayFiles != test.strayFiles || got.CompressAfter != test.compress {
+			t.Errorf("%s: got retention %q, layout %q, strayFiles %q and compressAfter %q, want %q, %q, %q and %q", test.company, got.Retention, got.Layout, got.StrayFiles, got.CompressAfter, test.retention, test.layout, test.strayFiles, test.compress)
+		}
+		if excludes := fmt.Sprint(got.ExcludePaths); excludes != test.excludes {
+			t.Errorf("%s: got excludePaths %s, want %s", test.company, excludes, test.excludes)
+		}
+		if got.OnLegalHold() != test.legalHold || got.HasSubtenants() != test.subtenants {
+			t.Errorf("%s: got legalHold %v and subtenants %v, want %v and %v", test.company, got.OnLegalHold(), got.HasSubtenants(), test.legalHold, test.subtenants)
+		}
+		if (got.Archive != nil) != test.archive || len(got.CalendarRules) != test.calendar {
+			t.Errorf("%s: got archive %+v and calendarRules %v", test.company, got.Archive, got.CalendarRules)
+		}
+		// Where a company's data is can't be shared, so an entry never takes it from the default.
+		if (got.Storage != nil) != test.defaultEntry {
+			t.Errorf("%s: got storage %+v", test.company, got.Storage)
+		}
+	}
+}
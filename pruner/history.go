@@ -0,0 +1,264 @@
+package pruner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// History is a persistent record of every pass, one JSON summary per line, for answering when a company's data was
+// last pruned long after the logs are gone.
+type History struct {
+	// KeepDays, if positive, is how many days of passes the history keeps: each Append drops the passes that
+	// started longer ago than that, so that the file doesn't grow forever.
+	KeepDays int
+
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// size is the length of the history, which a failed write is cut back to so that it doesn't leave half a line
+	// for the next one to be appended to.
+	size int64
+}
+
+// HistoryQuery narrows down the passes History.Query returns. Zero fields match everything.
+type HistoryQuery struct {
+	// Company, if set, only matches passes over the company, by id, and leaves out the other companies' stats.
+	Company string
+	// Removed only matches passes that removed, trashed or moved something, of Company's if that is set.
+	Removed bool
+	Since   time.Time
+	Until   time.Time
+	// Limit, if positive, is how many of the most recent matching passes to return.
+	Limit int
+}
+
+// OpenHistory opens the history at path for appending, creating it if needed, and locks it until Close, so that
+// two processes never append to it at once. A last line a crash left half written is cut off.
+func OpenHistory(path string) (*History, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	if err := LockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("history %s: %v", path, err)
+	}
+	size, complete, err := completeLines(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if size > complete {
+		log.WithField("history", path).Warnln("Cutting off a half written last line")
+		if err := file.Truncate(complete); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return &History{path: path, file: file, size: complete}, nil
+}
+
+// completeLines returns the length of r and the length of its complete lines, those ending in a newline.
+func completeLines(r io.Reader) (size int64, complete int64, err error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadSlice('\n')
+		size += int64(len(line))
+		if err == nil {
+			complete = size
+		} else if err == io.EOF {
+			return size, complete, nil
+		} else if err != bufio.ErrBufferFull {
+			return 0, 0, err
+		}
+	}
+}
+
+// Append adds a pass's summary to the history and syncs it to disk.
+func (h *History) Append(summary Summary) error {
+	line, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		h.file.Truncate(h.size)
+		return err
+	}
+	if err := h.file.Sync(); err != nil {
+		return err
+	}
+	h.size += int64(len(line)) + 1
+	if h.KeepDays > 0 {
+		if err := h.compact(time.Now().AddDate(0, 0, -h.KeepDays)); err != nil {
+			return fmt.Errorf("compacting history: %v", err)
+		}
+	}
+	return nil
+}
+
+// Compact drops the passes that started before before.
+func (h *History) Compact(before time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.compact(before)
+}
+
+// compact rewrites the history without the passes that started before before, if there are any. The rest is written
+// to a new file, locked before it replaces the history, so that readers see either the old or the new history in
+// full and no other writer gets in between.
+func (h *History) compact(before time.Time) error {
+	file, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var kept [][]byte
+	dropped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var summary struct {
+			Start time.Time `json:"start"`
+		}
+		// A line that can't be read is kept for someone to look at.
+		if json.Unmarshal(scanner.Bytes(), &summary) == nil && summary.Start.Before(before) {
+			dropped++
+			continue
+		}
+		kept = append(kept, append(append([]byte(nil), scanner.Bytes()...), '\n'))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if dropped == 0 {
+		return nil
+	}
+
+	compacted, err := os.OpenFile(h.path+".compact", os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	size, err := writeCompacted(compacted, kept)
+	if err == nil {
+		err = os.Rename(compacted.Name(), h.path)
+	}
+	if err != nil {
+		compacted.Close()
+		os.Remove(compacted.Name())
+		return err
+	}
+	h.file.Close()
+	h.file, h.size = compacted, size
+	return nil
+}
+
+// writeCompacted locks file and writes lines to it, returning their length once they are synced to disk.
+func writeCompacted(file *os.File, lines [][]byte) (int64, error) {
+	if err := LockFile(file); err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, line := range lines {
+		n, err := file.Write(line)
+		if err != nil {
+			return 0, err
+		}
+		size += int64(n)
+	}
+	return size, file.Sync()
+}
+
+// Close closes the underlying file, releasing the lock.
+func (h *History) Close() error {
+	return h.file.Close()
+}
+
+// Query returns the passes matching query, most recent first.
+func (h *History) Query(query HistoryQuery) ([]Summary, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return ReadHistory(h.path, query)
+}
+
+// ReadHistory returns the passes in the history at path matching query, most recent first. It doesn't take the
+// lock, so it can be read while a run appends to it.
+func ReadHistory(path string, query HistoryQuery) ([]Summary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var matches []Summary
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var summary Summary
+		if err := json.Unmarshal(scanner.Bytes(), &summary); err != nil {
+			// A run may be half way through appending the last line. Anything else is damage.
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("history line %d: %v", line, err)
+		}
+		if summary, ok := query.match(summary); ok {
+			matches = append(matches, summary)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	return matches, nil
+}
+
+// match reports whether summary matches the query, returning it with only Company's stats if that is set.
+func (q HistoryQuery) match(summary Summary) (Summary, bool) {
+	if (!q.Since.IsZero() && summary.Start.Before(q.Since)) || (!q.Until.IsZero() && !summary.Start.Before(q.Until)) {
+		return summary, false
+	}
+	if q.Company != "" {
+		var companies []CompanyStats
+		for _, stats := range summary.Companies {
+			if stats.Company == q.Company {
+				companies = append(companies, stats)
+			}
+		}
+		if len(companies) == 0 {
+			return summary, false
+		}
+		summary.Companies = companies
+	}
+	if q.Removed {
+		totals := summary.Totals()
+		if totals.DirsDeleted+totals.FilesDeleted+totals.DirsTrashed+totals.DirsMoved == 0 {
+			return summary, false
+		}
+	}
+	return summary, true
+}
+
+// recordHistory appends the summary of a pass that may have removed something to the History, if there is one.
+func (p *Pruner) recordHistory(summary Summary) {
+	if p.History == nil || p.DryRun {
+		return
+	}
+	if err := p.History.Append(summary); err != nil {
+		p.Log.WithField("run_id", summary.RunID).Errorln("Could not record pass in history.", err)
+	}
+}
@@ -0,0 +1,154 @@
+package pruner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// appendPasses opens the history at path and appends a pass for each of runIDs, started as many days after testNow
+// as the id is long.
+func appendPasses(t *testing.T, path string, runIDs ...string) {
+	t.Helper()
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	for _, runID := range runIDs {
+		start := testNow.AddDate(0, 0, len(runID))
+		summary := Summary{RunID: runID, Start: start, End: start.Add(time.Minute), Companies: []CompanyStats{{Company: "acme", DirsDeleted: 1}}}
+		if err := history.Append(summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runIDs returns the run ids of the passes in the history at path, most recent first.
+func runIDs(t *testing.T, path string) []string {
+	t.Helper()
+	summaries, err := ReadHistory(path, HistoryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, summary := range summaries {
+		ids = append(ids, summary.RunID)
+	}
+	return ids
+}
+
+func TestHistoryCutsOffHalfWrittenLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	appendPasses(t, path, "a", "bb")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte(`{"runId":"ccc","start":"20`))
+	file.Close()
+	// A reader skips the half written line, which may still be being written.
+	if ids := runIDs(t, path); len(ids) != 2 {
+		t.Errorf("got passes %q, want bb and a", ids)
+	}
+	// The next writer cuts it off rather than appending to it.
+	appendPasses(t, path, "dddd")
+	if ids := runIDs(t, path); len(ids) != 3 || ids[0] != "dddd" || ids[1] != "bb" || ids[2] != "a" {
+		t.Errorf("got passes %q, want dddd, bb and a", ids)
+	}
+}
+
+func TestHistoryIsLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, err := OpenHistory(path); err == nil {
+		second.Close()
+		t.Error("opened a history another writer has open")
+	}
+	// Readers don't need the lock.
+	if _, err := ReadHistory(path, HistoryQuery{}); err != nil {
+		t.Errorf("could not read a history another writer has open: %v", err)
+	}
+	history.Close()
+	if history, err = OpenHistory(path); err != nil {
+		t.Fatalf("could not open the history once its writer closed it: %v", err)
+	}
+	history.Close()
+}
+
+func TestHistoryQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	appendPasses(t, path, "a", "bb", "ccc")
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	summaries, err := history.Query(HistoryQuery{Company: "acme", Since: testNow.AddDate(0, 0, 2), Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].RunID != "ccc" {
+		t.Errorf("got %+v, want the pass ccc alone", summaries)
+	}
+	if summaries, _ := history.Query(HistoryQuery{Company: "other"}); len(summaries) != 0 {
+		t.Errorf("got %+v for a company that was never pruned", summaries)
+	}
+}
+
+func TestHistoryCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	appendPasses(t, path, "a", "bb", "ccc")
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	if err := history.Compact(testNow.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if ids := runIDs(t, path); len(ids) != 2 || ids[0] != "ccc" || ids[1] != "bb" {
+		t.Errorf("got passes %q, want ccc and bb", ids)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("the compacted history was left behind: %v", err)
+	}
+	// The compacted history is still locked and appended to.
+	if second, err := OpenHistory(path); err == nil {
+		second.Close()
+		t.Error("opened a compacted history another writer has open")
+	}
+	summary := Summary{RunID: "dddd", Start: testNow.AddDate(0, 0, 4)}
+	if err := history.Append(summary); err != nil {
+		t.Fatal(err)
+	}
+	if ids := runIDs(t, path); len(ids) != 3 || ids[0] != "dddd" {
+		t.Errorf("got passes %q, want dddd, ccc and bb", ids)
+	}
+}
+
+func TestHistoryKeepDays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	history.KeepDays = 30
+	for _, summary := range []Summary{
+		{RunID: "old", Start: time.Now().AddDate(0, 0, -31)},
+		{RunID: "recent", Start: time.Now().AddDate(0, 0, -29)},
+		{RunID: "now", Start: time.Now()},
+	} {
+		if err := history.Append(summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := runIDs(t, path); len(ids) != 2 || ids[0] != "now" || ids[1] != "recent" {
+		t.Errorf("got passes %q, want now and recent", ids)
+	}
+}
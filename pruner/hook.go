@@ -0,0 +1,135 @@
+package pruner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hook is an external command or URL told about an expired path before or after it is disposed of. Exactly one of
+// Command and URL is set. Commands get the HookEvent as JSON on stdin and its fields in DELETER_* environment
+// variables; URLs get it POSTed as JSON, with Headers, which may use ${VAR} to keep secrets out of the config.
+type Hook struct {
+	Command []string          `json:"command,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is a Go duration, default 30s, after which the hook is given up on and counts as failed.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// HookEvent is what a Hook is told. Action is what is about to be, or has been, done to the path: an Audit* action.
+type HookEvent struct {
+	Hook          string    `json:"hook"`
+	Time          time.Time `json:"time"`
+	RunID         string    `json:"runId"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Company       string    `json:"companyId"`
+	Action        string    `json:"action"`
+	Path          string    `json:"path"`
+	IsDir         bool      `json:"isDir"`
+	Bytes         int64     `json:"bytes"`
+}
+
+const (
+	HookPreDelete  = "preDelete"
+	HookPostDelete = "postDelete"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// Check reports what is wrong with the hook, if anything.
+func (h *Hook) Check() error {
+	if (len(h.Command) == 0) == (h.URL == "") {
+		return fmt.Errorf("exactly one of command and url must be set")
+	}
+	if h.URL != "" && !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("url %q is not http or https", h.URL)
+	}
+	if h.Timeout != "" {
+		if timeout, err := time.ParseDuration(h.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("bad timeout %q", h.Timeout)
+		}
+	}
+	return nil
+}
+
+// run calls the hook with event, failing if a command exits non-zero or a URL answers other than 2xx.
+func (h *Hook) run(ctx context.Context, event HookEvent) error {
+	timeout := defaultHookTimeout
+	if parsed, err := time.ParseDuration(h.Timeout); err == nil && parsed > 0 {
+		timeout = parsed
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if h.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range h.Headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s answered %s", h.URL, resp.Status)
+		}
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"DELETER_HOOK="+event.Hook,
+		"DELETER_RUN_ID="+event.RunID,
+		"DELETER_CORRELATION_ID="+event.CorrelationID,
+		"DELETER_COMPANY_ID="+event.Company,
+		"DELETER_ACTION="+event.Action,
+		"DELETER_PATH="+event.Path,
+		"DELETER_IS_DIR="+strconv.FormatBool(event.IsDir),
+		"DELETER_BYTES="+strconv.FormatInt(event.Bytes, 10),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", h.Command[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hookEvent returns what the company's hooks are told about path.
+func (c *companyRun) hookEvent(hook string, action string, path string, isDir bool, size int64) HookEvent {
+	return HookEvent{Hook: hook, Time: time.Now().UTC(), RunID: c.runID, CorrelationID: c.p.CorrelationID, Company: c.stats.Company, Action: action, Path: path, IsDir: isDir, Bytes: size}
+}
+
+// preDelete runs the company's preDelete hook, if it has one, for a path about to be disposed of with action.
+func (c *companyRun) preDelete(action string, path string, isDir bool, size int64) error {
+	if c.config.PreDelete == nil {
+		return nil
+	}
+	return c.config.PreDelete.run(c.ctx, c.hookEvent(HookPreDelete, action, path, isDir, size))
+}
+
+// postDelete runs the company's postDelete hook, if it has one, for a path that has been disposed of with action.
+// The path is gone whatever the hook says, so failures are only logged. The hook is run even if the pass is being
+// shut down, since the removal has already happened; its own timeout still bounds it.
+func (c *companyRun) postDelete(action string, path string, isDir bool, size int64) {
+	if c.config.PostDelete == nil {
+		return
+	}
+	if err := c.config.PostDelete.run(context.Background(), c.hookEvent(HookPostDelete, action, path, isDir, size)); err != nil {
+		c.log.WithField("path", path).WithError(err).Warnln("postDelete hook failed")
+	}
+}
@@ -0,0 +1,26 @@
+package pruner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPostDeleteRunsAfterShutdown(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer srv.Close()
+	config := dailyConfig(func(c *CompanyConfig) { c.PostDelete = &Hook{URL: srv.URL} })
+	p := memPruner(&MemFS{}, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	run := p.newCompanyRun(ctx, companyDir{name: "acme", base: "/data"}, config.CompanyConfigs[0], "test", testNow, p.Log, false, p.Recorder)
+	run.postDelete(AuditDeleted, "/data/acme/2020/01/01", true, 4)
+	if calls != 1 {
+		t.Errorf("postDelete hook called %d times once the pass was cancelled, want 1", calls)
+	}
+}
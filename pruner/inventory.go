@@ -0,0 +1,176 @@
+package pruner
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Inventory describes how much data every company holds and how old it is.
+type Inventory struct {
+	Time      time.Time          `json:"time"`
+	Companies []CompanyInventory `json:"companies"`
+}
+
+// CompanyInventory describes the data in one company directory, not counting its trash.
+type CompanyInventory struct {
+	Company string `json:"companyId"`
+	// BaseDir is the base directory holding the company directory, when there are several.
+	BaseDir string `json:"baseDir,omitempty"`
+	// Oldest and Newest are the date directories, relative to the company directory, holding the oldest and newest
+	// data, and OldestDate and NewestDate the dates they end at. In mtime mode they are files and their modification
+	// times instead.
+	Oldest     string    `json:"oldest,omitempty"`
+	OldestDate time.Time `json:"oldestDate,omitempty"`
+	Newest     string    `json:"newest,omitempty"`
+	NewestDate time.Time `json:"newestDate,omitempty"`
+	TotalBytes int64     `json:"totalBytes"`
+	TotalFiles int       `json:"totalFiles"`
+	// Cutoff, ExpiredBytes and ExpiredFiles are what a pass would remove now.
+	Cutoff       time.Time `json:"cutoff,omitempty"`
+	ExpiredBytes int64     `json:"expiredBytes"`
+	ExpiredFiles int       `json:"expiredFiles"`
+	// Err is set when the company's data couldn't be read or its config is invalid.
+	Err string `json:"error,omitempty"`
+}
+
+// Inventory scans every company directory, without removing anything, for its total size, its oldest and newest
+// data, and how much of it is past retention.
+func (p *Pruner) Inventory(ctx context.Context) (Inventory, error) {
+	companies, err := p.companyDirs()
+	if err != nil {
+		return Inventory{}, err
+	}
+	configMaps := p.configMaps(p.Config())
+	now := p.Clock.Now()
+	quiet := log.New()
+	quiet.Out = ioutil.Discard
+	inventory := Inventory{Time: now}
+	for _, company := range companies {
+		if ctx.Err() != nil {
+			return inventory, ctx.Err()
+		}
+		config := companyConfig(configMaps[company.base], company.name)
+		run := p.newCompanyRun(ctx, company, config, "", now, quiet, true, NopRecorder{})
+		item := run.inventory()
+		// What a pass would remove is whatever a dry pass counts, with every protection applied.
+		stats := p.newCompanyRun(ctx, company, config, "", now, quiet, true, NopRecorder{}).prune()
+		item.Cutoff = stats.Cutoff
+		item.ExpiredBytes = stats.BytesFreed
+		item.ExpiredFiles = stats.FilesRemoved
+		if item.Err == "" && stats.Errors > 0 && !config.OnLegalHold() {
+			item.Err = "errors while checking retention, see a dry run"
+		}
+		inventory.Companies = append(inventory.Companies, item)
+	}
+	return inventory, nil
+}
+
+// inventory walks the whole company directory, apart from the trash.
+func (c *companyRun) inventory() CompanyInventory {
+	item := CompanyInventory{Company: c.stats.Company, BaseDir: c.stats.BaseDir}
+	loc, err := c.location()
+	if err != nil {
+		item.Err = err.Error()
+		return item
+	}
+	c.now = c.now.In(loc)
+	var layout Layout
+	if c.config.Mode != ModeMtime {
+		if layout, err = c.config.ParseLayout(); err != nil {
+			item.Err = err.Error()
+			return item
+		}
+	}
+	see := func(rel string, date time.Time) {
+		if item.Oldest == "" || date.Before(item.OldestDate) {
+			item.Oldest, item.OldestDate = rel, date
+		}
+		if item.Newest == "" || date.After(item.NewestDate) {
+			item.Newest, item.NewestDate = rel, date
+		}
+	}
+	err = c.walkDir(c.dir, func(path string, f fs.DirEntry, err error) error {
+		if c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if path == filepath.Join(c.dir, trashDirName) {
+			return filepath.SkipDir
+		}
+		parts := relativeParts(c.dir, path)
+		if f.IsDir() {
+			if c.config.Mode == ModeMtime || path == c.dir || layout.DatedBelow(len(parts)) {
+				return nil
+			}
+			if date, err := layout.StrictDate(parts, c.now); err == nil || !c.config.IsStrict() {
+				see(strings.Join(parts, "/"), date)
+			}
+			return nil
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			item.TotalBytes += info.Size()
+			item.TotalFiles++
+			if c.config.Mode == ModeMtime {
+				see(strings.Join(parts, "/"), info.ModTime())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		item.Err = err.Error()
+	}
+	return item
+}
+
+// WriteJSON writes the inventory as an indented JSON document.
+func (inv Inventory) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(inv)
+}
+
+// WriteCSV writes the inventory as CSV with a header row and one row per company.
+func (inv Inventory) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"company_id", "oldest", "oldest_date", "newest", "newest_date", "total_bytes", "total_files", "cutoff", "expired_bytes", "expired_files", "error", "base_dir"})
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	for _, item := range inv.Companies {
+		out.Write([]string{
+			item.Company,
+			item.Oldest,
+			date(item.OldestDate),
+			item.Newest,
+			date(item.NewestDate),
+			strconv.FormatInt(item.TotalBytes, 10),
+			strconv.Itoa(item.TotalFiles),
+			date(item.Cutoff),
+			strconv.FormatInt(item.ExpiredBytes, 10),
+			strconv.Itoa(item.ExpiredFiles),
+			item.Err,
+			item.BaseDir,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
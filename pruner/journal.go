@@ -0,0 +1,283 @@
+package pruner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// JournalEntry is a line of the deletion journal: Op is JournalBegin, written before a removal starts, JournalDone,
+// written once it has finished, or JournalFailed, written if it gave up.
+type JournalEntry struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	RunID   string    `json:"runId"`
+	Company string    `json:"companyId"`
+	Path    string    `json:"path"`
+}
+
+const (
+	JournalBegin = "begin"
+	JournalDone  = "done"
+	// JournalFailed closes a removal that failed, or that a later run chose not to finish. What is left of the path
+	// is then judged by the walk like anything else.
+	JournalFailed = "failed"
+)
+
+// Journal is a crash-safe record of the removals in progress, one JSON entry per line, synced to disk before each
+// removal starts and after it finishes. A removal with a begin entry and no done entry was interrupted, and may have
+// left a partly deleted directory behind, which the next run finishes removing if it still would have removed it.
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	pending []JournalEntry
+}
+
+// OpenJournal opens the journal at path, creating it if needed, and reads the removals that earlier runs left
+// unfinished. The journal is compacted to just those, so it only grows with the removals of runs in progress.
+func OpenJournal(path string) (*Journal, error) {
+	pending, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	// Rewrite to a temporary file first so a crash never loses the unfinished entries.
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range pending {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, 2); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Journal{file: file, pending: pending}, nil
+}
+
+// readJournal returns the begin entries of the journal at path that have no done entry after them, in the order
+// they were written.
+func readJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var pending []JournalEntry
+	open := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A crash can leave the last line half written. Anything else is damage.
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("journal line %d: %v", line, err)
+		}
+		switch entry.Op {
+		case JournalBegin:
+			if _, exists := open[entry.Path]; !exists {
+				open[entry.Path] = len(pending)
+				pending = append(pending, entry)
+			}
+		case JournalDone, JournalFailed:
+			if i, exists := open[entry.Path]; exists {
+				pending[i].Path = ""
+				delete(open, entry.Path)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	unfinished := pending[:0]
+	for _, entry := range pending {
+		if entry.Path != "" {
+			unfinished = append(unfinished, entry)
+		}
+	}
+	return unfinished, nil
+}
+
+// Begin records that the removal of path is about to start.
+func (j *Journal) Begin(runID string, company string, path string) error {
+	return j.write(JournalEntry{Time: time.Now().UTC(), Op: JournalBegin, RunID: runID, Company: company, Path: path})
+}
+
+// Done records that the removal of path has finished, and that whatever earlier run left it unfinished has been
+// made up for.
+func (j *Journal) Done(runID string, company string, path string) error {
+	return j.write(JournalEntry{Time: time.Now().UTC(), Op: JournalDone, RunID: runID, Company: company, Path: path})
+}
+
+// Failed records that the removal of path gave up, or won't be finished, leaving path to be judged afresh.
+func (j *Journal) Failed(runID string, company string, path string) error {
+	return j.write(JournalEntry{Time: time.Now().UTC(), Op: JournalFailed, RunID: runID, Company: company, Path: path})
+}
+
+func (j *Journal) write(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Unfinished returns the removals that earlier runs left unfinished inside dir and haven't been finished since
+// the journal was opened, in the order they were started.
+func (j *Journal) Unfinished(dir string) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var inside []JournalEntry
+	for _, entry := range j.pending {
+		if rel, err := filepath.Rel(dir, entry.Path); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			inside = append(inside, entry)
+		}
+	}
+	return inside
+}
+
+// finished drops path from the removals left unfinished by earlier runs.
+func (j *Journal) finished(path string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, entry := range j.pending {
+		if entry.Path == path {
+			j.pending = append(j.pending[:i], j.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close closes the underlying file.
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// finishInterrupted finishes, before the walk, the removals inside the company directory that an earlier run
+// started and never finished, in the order it started them. Each is judged again first, since the config, markers
+// or clock may have changed since, and those the walk would now keep are closed in the journal and left to it. In
+// dry-run mode it only reports them.
+func (c *companyRun) finishInterrupted(layout Layout) {
+	if c.p.Journal == nil {
+		return
+	}
+	for _, entry := range c.p.Journal.Unfinished(c.dir) {
+		if c.ctx.Err() != nil {
+			return
+		}
+		pathLog := c.log.WithFields(log.Fields{"path": entry.Path, "interrupted_run_id": entry.RunID})
+		c.stats.InterruptedPaths = append(c.stats.InterruptedPaths, entry.Path)
+		if c.dryRun {
+			pathLog.Warnln("Would finish removal an earlier run left partly done")
+			continue
+		}
+		info, err := c.fs.Stat(entry.Path)
+		if os.IsNotExist(err) {
+			pathLog.Infoln("Removal an earlier run left unfinished is already done")
+			c.p.Journal.finished(entry.Path)
+			if err := c.p.Journal.Done(c.runID, c.stats.Company, entry.Path); err != nil {
+				c.error(entry.Path, "Error writing journal", err)
+			}
+			continue
+		}
+		if err := c.guard(entry.Path); err != nil {
+			c.error(entry.Path, "Refusing to finish interrupted removal", err)
+			continue
+		}
+		if reason := c.keepsInterrupted(entry.Path, info, layout); reason != "" {
+			pathLog.WithField("reason", reason).Warnln("Not finishing removal an earlier run left partly done, leaving it to the walk")
+			c.p.Journal.finished(entry.Path)
+			if err := c.p.Journal.Failed(c.runID, c.stats.Company, entry.Path); err != nil {
+				c.error(entry.Path, "Error writing journal", err)
+			}
+			continue
+		}
+		size, files, _ := c.dirSize(entry.Path)
+		pathLog.Warnln("Finishing removal an earlier run left partly done")
+		if err := c.removeAll(entry.Path); err != nil {
+			c.error(entry.Path, "Error finishing interrupted removal", err)
+			c.stats.FailedPaths = append(c.stats.FailedPaths, entry.Path)
+			continue
+		}
+		c.removed(entry.Path, info == nil || info.IsDir(), size, files, pathLog)
+	}
+}
+
+// keepsInterrupted returns why path, whose removal an earlier run left unfinished and whose Stat gave info, would
+// no longer be removed, or "" if it still would be.
+func (c *companyRun) keepsInterrupted(path string, info os.FileInfo, layout Layout) string {
+	rel := relativePath(c.dir, path)
+	if c.excluded(rel) || c.excludedInside(path, rel) {
+		return "excluded"
+	}
+	// Markers are read from the company directory down, as the walk reads them, so that a .retention file above
+	// path sets its cutoff.
+	parts := relativeParts(c.dir, path)
+	for i := range parts {
+		dir := filepath.Join(append([]string{c.dir}, parts[:i+1]...)...)
+		if (i < len(parts)-1 || info.IsDir()) && c.readMarkers(dir, relativePath(c.dir, dir)) {
+			return "pinned by a marker file"
+		}
+	}
+	if info.IsDir() && c.markerBelow(path) != "" {
+		return "holds a marker file"
+	}
+	if c.kept[rel] || c.keepsBelow(rel) {
+		return "among the newest minKeepCount"
+	}
+	cutoff := c.cutoffFor(rel)
+	if _, err := c.fs.Stat(path + compressedSuffix); err == nil && c.compressCutoff.After(cutoff) {
+		// It was being removed once compress had archived it.
+		cutoff = c.compressCutoff
+	}
+	date := info.ModTime()
+	if c.config.Mode != ModeMtime {
+		var err error
+		if date, err = layout.StrictDate(parts, c.now); err != nil && c.config.IsStrict() {
+			return "name doesn't parse as a date"
+		}
+	}
+	if !date.Before(cutoff) {
+		return "not expired"
+	}
+	return ""
+}
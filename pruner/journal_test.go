@@ -0,0 +1,108 @@
+package pruner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// failingFS is a MemFS that can't remove anything.
+type failingFS struct {
+	*MemFS
+}
+
+func (failingFS) RemoveAll(name string) error {
+	return &os.PathError{Op: "unlinkat", Path: name, Err: syscall.EPERM}
+}
+
+func TestJournalRecordsFailedRemovals(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	journal, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := memPruner(failingFS{fsys}, dailyConfig(nil))
+	p.Journal = journal
+	summary, err := p.Run(context.Background())
+	journal.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Companies) != 1 || len(summary.Companies[0].FailedPaths) != 1 {
+		t.Errorf("got %+v, want one failed path", summary.Companies)
+	}
+	pending, err := readJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("failed removals left open in the journal: %+v", pending)
+	}
+}
+
+func TestFinishInterruptedRejudges(t *testing.T) {
+	const expired = "/data/acme/2020/01/01"
+	for _, test := range []struct {
+		name    string
+		path    string
+		files   []string
+		edit    func(*CompanyConfig)
+		removed bool
+	}{
+		{name: "still expired", path: expired, removed: true},
+		{name: "already gone", path: "/data/acme/2020/01/02", removed: true},
+		{name: "not expired", path: "/data/acme/2020/12/31"},
+		{name: "keep marker", path: expired, files: []string{expired + "/" + keepMarker}},
+		{name: "keep marker below", path: expired, files: []string{expired + "/sub/" + keepMarker}},
+		{name: "retention marker above", path: expired, files: []string{"/data/acme/2020/" + retentionMarker}},
+		{name: "excluded", path: expired, edit: func(c *CompanyConfig) { c.ExcludePaths = []string{"2020/01"} }},
+		{name: "min keep count", path: expired, edit: func(c *CompanyConfig) { c.MinKeepCount = 3 }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := &MemFS{}
+			fsys.WriteFile(expired+"/data", []byte("data"), testNow)
+			fsys.WriteFile("/data/acme/2020/12/31/data", []byte("data"), testNow)
+			for _, file := range test.files {
+				fsys.WriteFile(file, []byte("3650"), testNow)
+			}
+			journalPath := filepath.Join(t.TempDir(), "journal")
+			journal, err := OpenJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			journal.Begin("interrupted", "acme", test.path)
+			journal.Close()
+			if journal, err = OpenJournal(journalPath); err != nil {
+				t.Fatal(err)
+			}
+			p := memPruner(fsys, dailyConfig(test.edit))
+			p.Journal = journal
+			// Only finish what was interrupted, without the walk that would otherwise judge the path again.
+			run := p.newCompanyRun(context.Background(), companyDir{name: "acme", base: "/data"}, p.Config().CompanyConfigs[0], "test", testNow, p.Log, false, p.Recorder)
+			if err := run.resolveCutoff(); err != nil {
+				t.Fatal(err)
+			}
+			layout, _ := run.config.ParseLayout()
+			if run.config.MinKeepCount > 0 {
+				run.kept = run.newestLeaves(layout, run.config.MinKeepCount)
+			}
+			run.finishInterrupted(layout)
+			journal.Close()
+			_, err = fsys.Stat(test.path)
+			if removed := os.IsNotExist(err); removed != test.removed {
+				t.Errorf("removed %v, want %v", removed, test.removed)
+			}
+			pending, err := readJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pending) != 0 {
+				t.Errorf("the interrupted removal was left open in the journal: %+v", pending)
+			}
+		})
+	}
+}
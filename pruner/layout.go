@@ -0,0 +1,376 @@
+package pruner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLayout is the layout used for companies that don't declare one: a device directory followed by one
+// directory per date component.
+const DefaultLayout = "*/{year}/{month}/{day}/{hour}/{minute}"
+
+// layoutPresets are layouts that may be named in a template in place of the elements they stand for.
+var layoutPresets = map[string]string{
+	// Hive-style partitions, as Spark and other Hadoop tools write them.
+	"hive": "year={year}/month={month}/day={day}/hour={hour}",
+}
+
+// dateUnit identifies one component of a directory date, from coarsest to finest.
+type dateUnit int
+
+const (
+	unitNone dateUnit = iota
+	unitYear
+	unitMonth
+	unitDay
+	unitHour
+	unitMinute
+	// unitEpoch and unitEpochMillis are a whole Unix timestamp, in seconds or milliseconds, and can't be combined with
+	// the other units.
+	unitEpoch
+	unitEpochMillis
+	// unitWeek is an ISO 8601 week number, which only combines with unitYear, read as the ISO week-numbering year.
+	unitWeek
+)
+
+var layoutTokens = map[string]dateUnit{
+	"year":    unitYear,
+	"month":   unitMonth,
+	"day":     unitDay,
+	"hour":    unitHour,
+	"minute":  unitMinute,
+	"epoch":   unitEpoch,
+	"epochms": unitEpochMillis,
+	"week":    unitWeek,
+}
+
+var tokenPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// unitPatterns match each field. Years are four digits and the smaller fields at most two, so that fields written
+// next to each other, as in "{year}{month}{day}", can be told apart.
+var unitPatterns = map[dateUnit]string{
+	unitYear:        `(\d{4})`,
+	unitMonth:       `(\d{1,2})`,
+	unitDay:         `(\d{1,2})`,
+	unitHour:        `(\d{1,2})`,
+	unitMinute:      `(\d{1,2})`,
+	unitEpoch:       `(\d+)`,
+	unitEpochMillis: `(\d+)`,
+	unitWeek:        `(\d{1,2})`,
+}
+
+// quoteLiteral quotes the text between an element's fields, except that "*" matches any text.
+func quoteLiteral(text string) string {
+	return strings.ReplaceAll(regexp.QuoteMeta(text), `\*`, `.*`)
+}
+
+// Layout describes how dates are encoded in the directories below a company directory. It is written as a
+// slash-separated template with one element per directory level, e.g. "{year}/{month}/{day}",
+// "{year}-{month}-{day}/{hour}" or, for Hive partitions, "year={year}/month={month}". An element of "*" matches any
+// directory name and carries no date, and an element of "hive" stands for
+// "year={year}/month={month}/day={day}/hour={hour}". A date may instead be a single Unix timestamp, "{epoch}" in
+// seconds or "{epochms}" in milliseconds, in which case a directory covers just that moment, or an ISO 8601 week,
+// "{year}/{week}", in which case it covers Monday to Sunday. An element without
+// any fields may also be a Go reference-time layout, e.g. "2006-01-02" or "20060102T15". Within an element with
+// fields, "*" matches any text, e.g. "{year}{month}{day}-*".
+type Layout struct {
+	levels []layoutLevel
+}
+
+type layoutLevel struct {
+	pattern *regexp.Regexp
+	// goLayout is the Go reference-time layout the level's names are parsed with, instead of pattern.
+	goLayout string
+	units    []dateUnit
+}
+
+// dated reports whether the level carries a date field.
+func (l layoutLevel) dated() bool {
+	return l.pattern != nil || l.goLayout != ""
+}
+
+// goLayoutUnits returns the date units a Go reference-time layout writes, from coarsest to finest.
+func goLayoutUnits(layout string) []dateUnit {
+	reference := time.Date(2001, 2, 3, 4, 5, 0, 0, time.UTC)
+	steps := []struct {
+		unit dateUnit
+		next time.Time
+	}{
+		{unitYear, reference.AddDate(1, 0, 0)},
+		{unitMonth, reference.AddDate(0, 1, 0)},
+		{unitDay, reference.AddDate(0, 0, 1)},
+		{unitHour, reference.Add(time.Hour)},
+		{unitMinute, reference.Add(time.Minute)},
+	}
+	var units []dateUnit
+	for _, step := range steps {
+		if reference.Format(layout) != step.next.Format(layout) {
+			units = append(units, step.unit)
+		}
+	}
+	return units
+}
+
+// ParseLayout parses a layout template. An empty template is DefaultLayout.
+func ParseLayout(template string) (Layout, error) {
+	if template == "" {
+		template = DefaultLayout
+	}
+	var layout Layout
+	seen := make(map[dateUnit]bool)
+	var elements []string
+	for _, element := range strings.Split(template, "/") {
+		if preset, ok := layoutPresets[element]; ok {
+			elements = append(elements, strings.Split(preset, "/")...)
+			continue
+		}
+		elements = append(elements, element)
+	}
+	for _, element := range elements {
+		if element == "*" {
+			layout.levels = append(layout.levels, layoutLevel{})
+			continue
+		}
+		var level layoutLevel
+		if !tokenPattern.MatchString(element) {
+			if units := goLayoutUnits(element); len(units) > 0 {
+				for _, unit := range units {
+					if seen[unit] {
+						return Layout{}, fmt.Errorf("layout [%s]: element %q repeats a field", template, element)
+					}
+					seen[unit] = true
+				}
+				layout.levels = append(layout.levels, layoutLevel{goLayout: element, units: units})
+				continue
+			}
+		}
+		var pattern strings.Builder
+		pattern.WriteString("^")
+		last := 0
+		for _, match := range tokenPattern.FindAllStringSubmatchIndex(element, -1) {
+			unit, known := layoutTokens[element[match[2]:match[3]]]
+			if !known {
+				return Layout{}, fmt.Errorf("layout [%s]: unknown field %s", template, element[match[0]:match[1]])
+			}
+			if seen[unit] {
+				return Layout{}, fmt.Errorf("layout [%s]: field %s appears more than once", template, element[match[0]:match[1]])
+			}
+			seen[unit] = true
+			pattern.WriteString(quoteLiteral(element[last:match[0]]))
+			pattern.WriteString(unitPatterns[unit])
+			level.units = append(level.units, unit)
+			last = match[1]
+		}
+		if len(level.units) == 0 {
+			return Layout{}, fmt.Errorf("layout [%s]: element %q has no date field", template, element)
+		}
+		pattern.WriteString(quoteLiteral(element[last:]))
+		pattern.WriteString("$")
+		level.pattern = regexp.MustCompile(pattern.String())
+		layout.levels = append(layout.levels, level)
+	}
+	if (seen[unitEpoch] || seen[unitEpochMillis]) && len(seen) > 1 {
+		return Layout{}, fmt.Errorf("layout [%s]: {epoch} and {epochms} can't be combined with other fields", template)
+	}
+	if seen[unitWeek] && (seen[unitMonth] || seen[unitDay] || seen[unitHour] || seen[unitMinute]) {
+		return Layout{}, fmt.Errorf("layout [%s]: {week} can only be combined with {year}", template)
+	}
+	return layout, nil
+}
+
+// CompareDate returns the last moment covered by a directory, given the names of the directories between the
+// company directory and it. If the directory structure is incomplete, we build as much as we can and choose the last
+// second of that interval. This avoids having to individually delete multiple directories that would have all
+// expired. Directories that don't carry a date yet compare as now. Directory dates are read in now's location.
+// Components that don't parse count as zero; use StrictDate to reject them instead.
+func (l Layout) CompareDate(relParts []string, now time.Time) time.Time {
+	_, date, _ := l.span(relParts, now)
+	return date
+}
+
+// StrictDate is CompareDate, except that it returns an error if a date component doesn't match the layout or is out
+// of range, e.g. month 13.
+func (l Layout) StrictDate(relParts []string, now time.Time) (time.Time, error) {
+	_, date, err := l.span(relParts, now)
+	return date, err
+}
+
+// StartDate is StrictDate for the first moment a directory covers rather than the last. It is zero for directories
+// that don't carry a date yet.
+func (l Layout) StartDate(relParts []string, now time.Time) (time.Time, error) {
+	start, _, err := l.span(relParts, now)
+	return start, err
+}
+
+// DatedBelow reports whether any level deeper than depth directories below the company directory carries a date
+// field. If none does, everything inside a directory at that depth has the directory's date.
+func (l Layout) DatedBelow(depth int) bool {
+	for i := depth; i < len(l.levels); i++ {
+		if l.levels[i].dated() {
+			return true
+		}
+	}
+	return false
+}
+
+// Depth is the number of directory levels in the layout.
+func (l Layout) Depth() int {
+	return len(l.levels)
+}
+
+// FirstDated is how many directories below the company directory the first level carrying a date field is.
+func (l Layout) FirstDated() int {
+	for i, level := range l.levels {
+		if level.dated() {
+			return i + 1
+		}
+	}
+	return len(l.levels)
+}
+
+var unitRanges = map[dateUnit][2]int{
+	unitMonth:  {1, 12},
+	unitDay:    {1, 31},
+	unitHour:   {0, 23},
+	unitMinute: {0, 59},
+	unitWeek:   {1, 53},
+}
+
+// span returns the first and last moments covered by a directory.
+func (l Layout) span(relParts []string, now time.Time) (time.Time, time.Time, error) {
+	fields := map[dateUnit]int{unitMonth: 1, unitDay: 1}
+	finest := unitNone
+	var epoch time.Time
+	var err error
+	for i, part := range relParts {
+		if i >= len(l.levels) {
+			break
+		}
+		level := l.levels[i]
+		if level.goLayout != "" {
+			date, parseErr := time.ParseInLocation(level.goLayout, part, now.Location())
+			if parseErr != nil && err == nil {
+				err = fmt.Errorf("directory %q does not match the layout", part)
+			}
+			values := map[dateUnit]int{unitYear: date.Year(), unitMonth: int(date.Month()), unitDay: date.Day(), unitHour: date.Hour(), unitMinute: date.Minute()}
+			for _, unit := range level.units {
+				fields[unit] = 0
+				if parseErr == nil {
+					fields[unit] = values[unit]
+				}
+				if unit > finest {
+					finest = unit
+				}
+			}
+			continue
+		}
+		if level.pattern == nil {
+			continue
+		}
+		match := level.pattern.FindStringSubmatch(part)
+		if match == nil && err == nil {
+			err = fmt.Errorf("directory %q does not match the layout", part)
+		}
+		for j, unit := range level.units {
+			if unit == unitEpoch || unit == unitEpochMillis {
+				if match != nil {
+					value, _ := strconv.ParseInt(match[j+1], 10, 64)
+					if unit == unitEpochMillis {
+						epoch = time.UnixMilli(value).In(now.Location())
+					} else {
+						epoch = time.Unix(value, 0).In(now.Location())
+					}
+				}
+				finest = unit
+				continue
+			}
+			value := 0
+			if match != nil {
+				value, _ = strconv.Atoi(match[j+1])
+			}
+			if bounds, bounded := unitRanges[unit]; bounded && match != nil && err == nil && (value < bounds[0] || value > bounds[1]) {
+				err = fmt.Errorf("directory %q has %d out of range", part, value)
+			}
+			fields[unit] = value
+			if unit > finest {
+				finest = unit
+			}
+		}
+	}
+	if finest == unitNone {
+		return time.Time{}, now, err
+	}
+	if finest == unitEpoch || finest == unitEpochMillis {
+		return epoch, epoch, err
+	}
+	if finest == unitWeek {
+		return isoWeek(fields[unitYear], fields[unitWeek], now.Location(), err)
+	}
+	if finest == unitYear && l.weekly() {
+		// Above a week, the year is the ISO week-numbering year, which runs from the start of its week 1 to the
+		// start of the next year's, and so can end a few days into the next calendar year.
+		start, _, err := isoWeek(fields[unitYear], 1, now.Location(), err)
+		next, _, _ := isoWeek(fields[unitYear]+1, 1, now.Location(), nil)
+		return start, next.Add(-1 * time.Second), err
+	}
+	start := time.Date(fields[unitYear], time.Month(fields[unitMonth]), fields[unitDay], fields[unitHour], fields[unitMinute], 0, 0, now.Location())
+	if err == nil && start.Day() != fields[unitDay] {
+		err = fmt.Errorf("day %d does not exist in %d-%02d", fields[unitDay], fields[unitYear], fields[unitMonth])
+	}
+	var end time.Time
+	switch finest {
+	case unitYear:
+		end = start.AddDate(1, 0, 0)
+	case unitMonth:
+		end = start.AddDate(0, 1, 0)
+	case unitDay:
+		end = start.AddDate(0, 0, 1)
+	case unitHour:
+		end = start.Add(time.Hour)
+	default:
+		end = start.Add(time.Minute)
+	}
+	return start, end.Add(-1 * time.Second), err
+}
+
+// weekly reports whether the layout has a week level.
+func (l Layout) weekly() bool {
+	for _, level := range l.levels {
+		for _, unit := range level.units {
+			if unit == unitWeek {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isoWeek returns the first and last moments of an ISO 8601 week.
+func isoWeek(year, week int, loc *time.Location, err error) (time.Time, time.Time, error) {
+	// January 4th is always in week 1.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	start := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+7*(week-1))
+	if y, w := start.ISOWeek(); err == nil && (y != year || w != week) {
+		err = fmt.Errorf("week %d does not exist in %d", week, year)
+	}
+	return start, start.AddDate(0, 0, 7).Add(-1 * time.Second), err
+}
+
+// ParseFileLayout parses the template for file names carrying their own date, e.g. "app-{year}{month}{day}.log*". It
+// is a single layout element.
+func ParseFileLayout(template string) (Layout, error) {
+	if strings.Contains(template, "/") {
+		return Layout{}, fmt.Errorf("file layout [%s]: must be a single file name", template)
+	}
+	layout, err := ParseLayout(template)
+	if err != nil {
+		return Layout{}, err
+	}
+	if layout.Depth() != 1 || !layout.levels[0].dated() {
+		return Layout{}, fmt.Errorf("file layout [%s]: has no date field", template)
+	}
+	return layout, nil
+}
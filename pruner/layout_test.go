@@ -0,0 +1,111 @@
+package pruner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeeklyLayoutSpans(t *testing.T) {
+	layout, err := ParseLayout("{year}/{week}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path       string
+		start, end string
+	}{
+		// 2020 has 53 ISO weeks, the last of which ends on 2021-01-03.
+		{path: "2020", start: "2019-12-30T00:00:00Z", end: "2021-01-03T23:59:59Z"},
+		{path: "2020/53", start: "2020-12-28T00:00:00Z", end: "2021-01-03T23:59:59Z"},
+		{path: "2020/01", start: "2019-12-30T00:00:00Z", end: "2020-01-05T23:59:59Z"},
+		{path: "2021", start: "2021-01-04T00:00:00Z", end: "2022-01-02T23:59:59Z"},
+	} {
+		start, end, err := layout.span(strings.Split(test.path, "/"), testNow)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		if got := start.Format(time.RFC3339); got != test.start {
+			t.Errorf("%s starts at %s, want %s", test.path, got, test.start)
+		}
+		if got := end.Format(time.RFC3339); got != test.end {
+			t.Errorf("%s ends at %s, want %s", test.path, got, test.end)
+		}
+	}
+}
+
+func TestDirectoryDatesAreReadInTheCompanyTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err)
+	}
+	// With a retention of 36h the cutoff is 2020-12-30 12:00 UTC: 07:00 in New York and 21:00 in Tokyo.
+	for _, test := range []struct {
+		timezone string
+		location *time.Location
+		want     string
+	}{
+		{"", nil, "[2020/12/29 2020/12/30/06 2020/12/30/07 2020/12/30/11]"},
+		{"America/New_York", nil, "[2020/12/29 2020/12/30/06]"},
+		{"", tokyo, "[2020/12/29 2020/12/30/06 2020/12/30/07 2020/12/30/11 2020/12/30/12 2020/12/30/20]"},
+		// The company's own timezone wins over the Pruner's.
+		{"America/New_York", tokyo, "[2020/12/29 2020/12/30/06]"},
+		{"UTC", tokyo, "[2020/12/29 2020/12/30/06 2020/12/30/07 2020/12/30/11]"},
+	} {
+		fsys := &MemFS{}
+		for _, dir := range []string{"2020/12/29/23", "2020/12/30/06", "2020/12/30/07", "2020/12/30/11", "2020/12/30/12", "2020/12/30/20", "2020/12/30/21", "2020/12/31/05"} {
+			fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+		}
+		p := memPruner(fsys, dailyConfig(func(company *CompanyConfig) {
+			company.Retention = "36h"
+			company.Layout = "{year}/{month}/{day}/{hour}"
+			company.Timezone = test.timezone
+		}))
+		p.Location = test.location
+		removed, _ := removedPaths(t, p)
+		if fmt.Sprint(removed) != test.want {
+			t.Errorf("timezone %q, location %v: removed %q, want %s", test.timezone, test.location, removed, test.want)
+		}
+	}
+}
+
+func TestUnknownTimezoneSkipsTheCompany(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2019/01/01/data", []byte("data"), testNow)
+	removed, stats := removedPaths(t, memPruner(fsys, dailyConfig(func(company *CompanyConfig) { company.Timezone = "Mars/Olympus_Mons" })))
+	if len(removed) != 0 || stats.Errors != 1 {
+		t.Errorf("removed %q with %d errors, want nothing and an error", removed, stats.Errors)
+	}
+}
+
+func TestStrictDate(t *testing.T) {
+	layout, err := ParseLayout("{year}/{month}/{day}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{"2020", "2020-12-31"},
+		{"2020/02", "2020-02-29"},
+		{"2020/02/29", "2020-02-29"},
+		{"2021/02/29", ""},
+		{"2020/13", ""},
+		{"2020/00", ""},
+		{"2020/12/32", ""},
+		{"2020/12/backup", ""},
+		{"backup/12/01", ""},
+	} {
+		date, err := layout.StrictDate(strings.Split(test.path, "/"), testNow)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.path, date)
+			}
+		} else if err != nil || date.Format("2006-01-02") != test.want {
+			t.Errorf("%s: got %v, %v, want %s", test.path, date, err, test.want)
+		}
+	}
+}
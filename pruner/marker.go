@@ -0,0 +1,142 @@
+package pruner
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// keepMarker is a file that keeps the directory holding it, and everything inside, whatever its age.
+	keepMarker = ".keep"
+	// retentionMarker is a file holding a retention, in the config's format, for the directory holding it and
+	// everything inside. It can only keep data longer than the config does.
+	retentionMarker = ".retention"
+)
+
+// isMarker reports whether name is one of the marker files, which are never removed on their own.
+func isMarker(name string) bool {
+	return name == keepMarker || name == retentionMarker
+}
+
+// readMarkers checks the directory at rel, relative to the company directory, for marker files. It reports whether
+// the directory is to be kept, and records the cutoff of a .retention file for cutoffFor. Purges ignore markers.
+func (c *companyRun) readMarkers(dir string, rel string) bool {
+	if c.purging() {
+		return false
+	}
+	if _, err := c.fs.Stat(filepath.Join(dir, keepMarker)); err == nil {
+		return true
+	}
+	data, err := c.fs.ReadFile(filepath.Join(dir, retentionMarker))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.error(dir, "Error reading "+retentionMarker+", keeping the directory", err)
+			return true
+		}
+		return false
+	}
+	pathLog := c.log.WithField("path", dir)
+	retention, err := ParseRetention(string(data))
+	if err != nil {
+		// Keep the data rather than guess what the file meant.
+		c.error(dir, "Invalid "+retentionMarker+", keeping the directory", err)
+		return true
+	}
+	if retention.Never {
+		// Like a .keep file.
+		return true
+	}
+	cutoff := retention.Cutoff(c.now)
+	if !cutoff.Before(c.cutoffFor(rel)) {
+		pathLog.WithField("retention", strings.TrimSpace(string(data))).Warnln("Ignoring " + retentionMarker + " shorter than the config's retention")
+		return false
+	}
+	pathLog.WithField("retention", strings.TrimSpace(string(data))).Debugln("Retention overridden by " + retentionMarker)
+	c.mu.Lock()
+	if c.overrides == nil {
+		c.overrides = make(map[string]time.Time)
+	}
+	c.overrides[rel] = cutoff
+	c.mu.Unlock()
+	return false
+}
+
+// cutoffFor returns the cutoff for the path rel, relative to the company directory: that of the closest .retention
+// file, category or sub-tenant with a retention of its own found at or above it, or the company's.
+func (c *companyRun) cutoffFor(rel string) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.overrides) == 0 && len(c.categories) == 0 {
+		return c.cutoff
+	}
+	for dir := rel; ; dir = path.Dir(dir) {
+		if cutoff, ok := c.overrides[dir]; ok {
+			return cutoff
+		}
+		if c.categories != nil && strings.Count(dir, "/") == c.categoryDepth-1 {
+			if cutoff, ok := c.categories[path.Base(dir)]; ok {
+				return cutoff
+			}
+		}
+		if dir == "." || dir == "/" || dir == "" {
+			return c.cutoff
+		}
+	}
+}
+
+// subtree is what a single walk of a path about to be removed found in it.
+type subtree struct {
+	size  int64
+	files int
+	// marker is the first marker file found below the path, which stops the walk, or "" if there is none.
+	marker string
+	err    error
+}
+
+// scanSubtree walks path once, sizing it as dirSize does and looking for marker files below it as markerBelow does,
+// unless the pass is a purge, so that a directory about to be removed isn't walked once for each. It carries on past errors, keeping the first,
+// so that a marker isn't missed for want of looking.
+func (c *companyRun) scanSubtree(path string) subtree {
+	var scan subtree
+	c.fs.WalkDir(path, func(sub string, f fs.DirEntry, err error) error {
+		if err == nil && f.Type().IsRegular() {
+			var info os.FileInfo
+			if info, err = f.Info(); err == nil {
+				scan.size += info.Size()
+				scan.files++
+			}
+		}
+		if err != nil {
+			if scan.err == nil {
+				scan.err = err
+			}
+			return nil
+		}
+		if sub != path && !f.IsDir() && isMarker(f.Name()) && !c.purging() {
+			scan.marker = sub
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return scan
+}
+
+// markerBelow returns the first marker file found below the directory path, or "" if there is none.
+func (c *companyRun) markerBelow(dir string) string {
+	var marker string
+	c.fs.WalkDir(dir, func(path string, f fs.DirEntry, err error) error {
+		if err != nil || f.IsDir() {
+			return nil
+		}
+		if isMarker(f.Name()) {
+			marker = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return marker
+}
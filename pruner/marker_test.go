@@ -0,0 +1,55 @@
+package pruner
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+)
+
+// countingFS is a MemFS that counts the walks from each directory.
+type countingFS struct {
+	*MemFS
+	mu    sync.Mutex
+	walks map[string]int
+}
+
+func (c *countingFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	c.mu.Lock()
+	c.walks[root]++
+	c.mu.Unlock()
+	return c.MemFS.WalkDir(root, fn)
+}
+
+func TestExpiredDirectoryIsWalkedOnce(t *testing.T) {
+	fsys := &countingFS{MemFS: &MemFS{}, walks: make(map[string]int)}
+	fsys.WriteFile("/data/acme/2020/01/01/a/data", []byte("data"), testNow)
+	fsys.WriteFile("/data/acme/2020/01/01/b/data", []byte("data"), testNow)
+	summary, err := memPruner(fsys, dailyConfig(nil)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes := summary.Totals().BytesFreed; bytes != 8 {
+		t.Errorf("freed %d bytes, want 8", bytes)
+	}
+	if walks := fsys.walks["/data/acme/2020/01"]; walks != 1 {
+		t.Errorf("the expired directory was walked %d times, want 1", walks)
+	}
+}
+
+func TestMarkerBelowKeepsOnlyItsDirectory(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+	fsys.WriteFile("/data/acme/2020/01/02/"+keepMarker, nil, testNow)
+	fsys.WriteFile("/data/acme/2020/01/02/data", []byte("data"), testNow)
+	if _, err := memPruner(fsys, dailyConfig(nil)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat("/data/acme/2020/01/01"); !os.IsNotExist(err) {
+		t.Errorf("unmarked directory was kept: %v", err)
+	}
+	if _, err := fsys.Stat("/data/acme/2020/01/02/data"); err != nil {
+		t.Errorf("marked directory was removed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package pruner
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IsPattern reports whether a companyId is a pattern for several company directories rather than a single id: a
+// glob using *, ? or [...], or a regular expression written between slashes, e.g. "/^acme-(us|eu)-[0-9]+$/".
+func IsPattern(id string) bool {
+	return isRegexp(id) || strings.ContainsAny(id, "*?[")
+}
+
+func isRegexp(id string) bool {
+	return len(id) > 2 && strings.HasPrefix(id, "/") && strings.HasSuffix(id, "/")
+}
+
+// checkPattern returns an error if the companyId pattern is malformed.
+func checkPattern(id string) error {
+	if isRegexp(id) {
+		_, err := regexp.Compile(id[1 : len(id)-1])
+		return err
+	}
+	_, err := path.Match(id, "")
+	return err
+}
+
+// matchCompany reports whether the companyId pattern matches the company directory name. Regular expressions are
+// unanchored unless they anchor themselves.
+func matchCompany(pattern string, company string) bool {
+	if isRegexp(pattern) {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err == nil && re.MatchString(company)
+	}
+	ok, _ := path.Match(pattern, company)
+	return ok
+}
+
+// lookupEntry returns the config entry for a company directory: the entry with its id, or else the entry with the
+// longest pattern that matches it. Ties go to the pattern that sorts first.
+func lookupEntry(configMap map[string]CompanyConfig, company string) (CompanyConfig, bool) {
+	if entry, exists := configMap[company]; exists && company != "default" {
+		return entry, true
+	}
+	best := ""
+	for id := range configMap {
+		if !IsPattern(id) || !matchCompany(id, company) {
+			continue
+		}
+		if best == "" || len(id) > len(best) || (len(id) == len(best) && id < best) {
+			best = id
+		}
+	}
+	if best == "" {
+		return CompanyConfig{}, false
+	}
+	return configMap[best], true
+}
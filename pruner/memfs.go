@@ -0,0 +1,347 @@
+package pruner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is a FileSystem held in memory, for running passes over a tree built up with MkdirAll, WriteFile, Symlink
+// and Chtimes without touching a disk. Symlinks are followed as the os package follows them, and Mount makes a
+// directory another filesystem. compressAfter, and archiveTo moves across filesystems, still read and write the disk
+// directly and so need an OSFileSystem.
+//
+// The zero value is an empty filesystem with only the root directory. Paths are cleaned before use.
+type MemFS struct {
+	// Free and Total are what DiskSpace reports for every path.
+	Free  uint64
+	Total uint64
+
+	mu    sync.Mutex
+	nodes map[string]*memNode
+	// mounts holds the device of each directory Mount was called on.
+	mounts map[string]uint64
+}
+
+// memDevice is what the Sys method of a MemFS FileInfo returns: the device of the filesystem it is on.
+type memDevice uint64
+
+type memNode struct {
+	dir bool
+	// link is what a symlink points to, and "" for anything else.
+	link     string
+	data     []byte
+	modified time.Time
+}
+
+// node returns the node at the cleaned path name, creating the root on first use. Callers hold mu.
+func (m *MemFS) node(name string) (*memNode, bool) {
+	if m.nodes == nil {
+		m.nodes = map[string]*memNode{string(filepath.Separator): {dir: true}}
+	}
+	node, ok := m.nodes[name]
+	return node, ok
+}
+
+// resolve returns the cleaned path name with the symlinks among its directories, and with follow its last element
+// too, replaced by what they point to. Callers hold mu.
+func (m *MemFS) resolve(name string, follow bool) (string, error) {
+	sep := string(filepath.Separator)
+	resolved := sep
+	rest := strings.Split(name, sep)
+	for links := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+		if part == "" {
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		node, ok := m.node(next)
+		if !ok || node.link == "" || (len(rest) == 0 && !follow) {
+			resolved = next
+			continue
+		}
+		if links++; links > 255 {
+			return "", &fs.PathError{Op: "lstat", Path: name, Err: fmt.Errorf("too many levels of symbolic links")}
+		}
+		target := node.link
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+		rest = append(strings.Split(filepath.Clean(target), sep), rest...)
+		resolved = sep
+	}
+	return resolved, nil
+}
+
+// deviceOf returns the device of the filesystem the resolved path name is on. Callers hold mu.
+func (m *MemFS) deviceOf(name string) uint64 {
+	var device uint64
+	mount := ""
+	for dir, dev := range m.mounts {
+		if within(dir, name) && len(dir) > len(mount) {
+			device, mount = dev, dir
+		}
+	}
+	return device
+}
+
+// children returns the names of the entries of the directory dir, sorted. Callers hold mu.
+func (m *MemFS) children(dir string) []string {
+	prefix := dir
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	var names []string
+	for name := range m.nodes {
+		if name != dir && strings.HasPrefix(name, prefix) && !strings.ContainsRune(name[len(prefix):], filepath.Separator) {
+			names = append(names, name[len(prefix):])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteFile creates or replaces the file name, and any directories it needs, modified at modified.
+func (m *MemFS) WriteFile(name string, data []byte, modified time.Time) error {
+	name = filepath.Clean(name)
+	if err := m.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.resolve(name, true)
+	if err != nil {
+		return err
+	}
+	if node, ok := m.node(name); ok && node.dir {
+		return &fs.PathError{Op: "write", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	m.nodes[name] = &memNode{data: append([]byte(nil), data...), modified: modified}
+	return nil
+}
+
+// Symlink creates newname, and any directories it needs, as a symlink to oldname, which is taken to be relative to
+// newname's directory unless it is absolute.
+func (m *MemFS) Symlink(oldname, newname string) error {
+	newname = filepath.Clean(newname)
+	if err := m.MkdirAll(filepath.Dir(newname), 0755); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	newname, err := m.resolve(newname, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.node(newname); ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	m.nodes[newname] = &memNode{link: oldname}
+	return nil
+}
+
+// Mount makes the directory dir, and everything below it, another filesystem, as a volume mounted there would be.
+func (m *MemFS) Mount(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, err := m.resolve(filepath.Clean(dir), true)
+	if err != nil {
+		return err
+	}
+	if node, ok := m.node(dir); !ok || !node.dir {
+		return &fs.PathError{Op: "mount", Path: dir, Err: fmt.Errorf("not a directory")}
+	}
+	if m.mounts == nil {
+		m.mounts = make(map[string]uint64)
+	}
+	m.mounts[dir] = uint64(len(m.mounts) + 1)
+	return nil
+}
+
+// Chtimes sets when name was last modified.
+func (m *MemFS) Chtimes(name string, modified time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.resolve(filepath.Clean(name), true)
+	if err != nil {
+		return err
+	}
+	node, ok := m.node(name)
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	node.modified = modified
+	return nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirname, err := m.resolve(filepath.Clean(name), true)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := m.node(dirname)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: dirname, Err: fs.ErrNotExist}
+	}
+	if !node.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: dirname, Err: fmt.Errorf("not a directory")}
+	}
+	var entries []os.DirEntry
+	for _, name := range m.children(dirname) {
+		child := m.nodes[filepath.Join(dirname, name)]
+		entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: name, node: *child, device: m.deviceOf(filepath.Join(dirname, name))}))
+	}
+	return entries, nil
+}
+
+func (m *MemFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return readDirWalk(m, root, fn)
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, err := m.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := m.node(resolved)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{name: filepath.Base(name), node: *node, device: m.deviceOf(resolved)}, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.resolve(filepath.Clean(name), true)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := m.node(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.dir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+func (m *MemFS) EvalSymlinks(name string) (string, error) {
+	if _, err := m.Stat(name); err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resolve(filepath.Clean(name), true)
+}
+
+// RemoveAll removes name and everything below it. A symlink is removed, not what it points to.
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.resolve(filepath.Clean(name), false)
+	if err != nil {
+		return err
+	}
+	for path := range m.nodes {
+		if path != string(filepath.Separator) && within(name, path) {
+			delete(m.nodes, path)
+		}
+	}
+	return nil
+}
+
+// Rename moves oldpath, and everything below it, to newpath, which must not exist.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, err := m.resolve(filepath.Clean(oldpath), false)
+	if err != nil {
+		return err
+	}
+	newpath, err = m.resolve(filepath.Clean(newpath), false)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.node(oldpath); !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if parent, ok := m.node(filepath.Dir(newpath)); !ok || !parent.dir {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if _, ok := m.node(newpath); ok || within(oldpath, newpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	for path, node := range m.nodes {
+		if within(oldpath, path) {
+			delete(m.nodes, path)
+			m.nodes[newpath+strings.TrimPrefix(path, oldpath)] = node
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.resolve(filepath.Clean(name), true)
+	if err != nil {
+		return err
+	}
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if node, ok := m.node(dir); ok {
+			if !node.dir {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: fmt.Errorf("not a directory")}
+			}
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if _, ok := m.nodes[dir]; ok {
+			return nil
+		}
+		m.nodes[dir] = &memNode{dir: true}
+	}
+}
+
+func (m *MemFS) DiskSpace(name string) (uint64, uint64, error) {
+	return m.Free, m.Total, nil
+}
+
+// memInfo is the os.FileInfo of a MemFS node.
+type memInfo struct {
+	name   string
+	node   memNode
+	device uint64
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) ModTime() time.Time { return i.node.modified }
+func (i memInfo) IsDir() bool        { return i.node.dir }
+func (i memInfo) Sys() interface{}   { return memDevice(i.device) }
+
+func (i memInfo) Mode() os.FileMode {
+	if i.node.link != "" {
+		return os.ModeSymlink | 0777
+	}
+	if i.node.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
@@ -0,0 +1,149 @@
+package pruner
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// testNow is the time the Pruners under test run at. With a retention of 30 days, the cutoff is 2020-12-02.
+var testNow = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// memPruner returns a Pruner over fsys with its base directory at /data, running at testNow.
+func memPruner(fsys FileSystem, config Config) *Pruner {
+	p := New("/data", config)
+	p.FS = fsys
+	p.Clock = FixedClock(testNow)
+	p.Log = quietLogger()
+	return p
+}
+
+// quietLogger is a logger for Pruners under test, which log a lot that the tests don't look at.
+func quietLogger() *log.Logger {
+	logger := log.New()
+	logger.Out = io.Discard
+	return logger
+}
+
+// dailyConfig is the config of a company acme with a day directory layout and a retention of 30 days.
+func dailyConfig(edit func(*CompanyConfig)) Config {
+	company := CompanyConfig{Id: "acme", Retention: "30", Layout: "{year}/{month}/{day}"}
+	if edit != nil {
+		edit(&company)
+	}
+	return Config{CompanyConfigs: []CompanyConfig{company}}
+}
+
+// checkExists fails t for each of paths in fsys whose existence isn't want.
+func checkExists(t *testing.T, fsys FileSystem, want bool, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		_, err := fsys.Stat(path)
+		if exists := !os.IsNotExist(err); exists != want {
+			t.Errorf("%s exists: %v, want %v", path, exists, want)
+		}
+	}
+}
+
+func TestMemFSLayoutPruning(t *testing.T) {
+	fsys := &MemFS{}
+	for _, file := range []string{
+		"/data/acme/2020/10/01/data",
+		"/data/acme/2020/11/30/data",
+		"/data/acme/2020/12/01/data",
+		"/data/acme/2020/12/05/data",
+		"/data/acme/misc/notes",
+	} {
+		fsys.WriteFile(file, []byte("data"), testNow)
+	}
+	fsys.WriteFile("/data/acme/2020/10/"+retentionMarker, []byte("3650"), testNow)
+	summary, err := memPruner(fsys, dailyConfig(nil)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExists(t, fsys, false, "/data/acme/2020/11", "/data/acme/2020/12/01")
+	checkExists(t, fsys, true, "/data/acme/2020/10/01/data", "/data/acme/2020/12/05/data", "/data/acme/misc/notes")
+	stats := summary.Companies[0]
+	if stats.DirsDeleted != 2 || stats.BytesFreed != 8 || stats.DirsUnparsed != 1 {
+		t.Errorf("deleted %d directories and %d bytes, with %d unparsed, want 2, 8 and 1", stats.DirsDeleted, stats.BytesFreed, stats.DirsUnparsed)
+	}
+}
+
+func TestMemFSMtimeMode(t *testing.T) {
+	fsys := &MemFS{}
+	old, recent := testNow.AddDate(0, -6, 0), testNow.AddDate(0, 0, -1)
+	fsys.WriteFile("/data/acme/a/old", []byte("data"), old)
+	fsys.WriteFile("/data/acme/a/recent", []byte("data"), recent)
+	fsys.WriteFile("/data/acme/b/c/old", []byte("data"), old)
+	fsys.MkdirAll("/data/acme/empty", 0755)
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30", Mode: ModeMtime}}}
+	summary, err := memPruner(fsys, config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b is emptied by the pass and goes; the directory that was empty already stays for the data it is waiting for.
+	checkExists(t, fsys, false, "/data/acme/a/old", "/data/acme/b")
+	checkExists(t, fsys, true, "/data/acme/a/recent", "/data/acme/empty")
+	if files := summary.Companies[0].FilesDeleted; files != 2 {
+		t.Errorf("deleted %d files, want 2", files)
+	}
+}
+
+func TestMemFSTrash(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+	p := memPruner(fsys, dailyConfig(nil))
+	p.TrashGrace = 7 * 24 * time.Hour
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trashed := summary.Companies[0].DirsTrashed; trashed != 1 {
+		t.Errorf("trashed %d directories, want 1", trashed)
+	}
+	trashed := "/data/acme/" + trashDirName + "/" + testNow.Format(trashTimeLayout)
+	checkExists(t, fsys, false, "/data/acme/2020/01")
+	checkExists(t, fsys, true, trashed+"/2020/01/01/data")
+
+	// Within the grace period the trash is left alone, and after it, emptied.
+	p.Clock = FixedClock(testNow.Add(p.TrashGrace - time.Hour))
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(t, fsys, true, trashed)
+	p.Clock = FixedClock(testNow.Add(p.TrashGrace))
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkExists(t, fsys, false, trashed)
+}
+
+func TestMemFSSymlinks(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/outside/2020/11/01/data", []byte("data"), testNow)
+	fsys.Symlink("../../outside/2020", "/data/acme/2020")
+	fsys.Symlink("/data/loop", "/data/loop")
+	if resolved, err := fsys.EvalSymlinks("/data/acme/2020/11/01"); err != nil || resolved != "/outside/2020/11/01" {
+		t.Errorf("got %q, %v, want /outside/2020/11/01", resolved, err)
+	}
+	if info, err := fsys.Stat("/data/acme/2020"); err != nil || !info.IsDir() {
+		t.Errorf("stat of a link to a directory got %v, %v", info, err)
+	}
+	entries, err := fsys.ReadDir("/data/acme")
+	if err != nil || len(entries) != 1 || entries[0].Type() != os.ModeSymlink {
+		t.Errorf("got entries %v, %v, want the link", entries, err)
+	}
+	if _, err := fsys.Stat("/data/loop"); err == nil {
+		t.Error("stat of a link to itself worked")
+	}
+	// Removing the link, or anything through it, doesn't remove more than it would on a disk.
+	fsys.RemoveAll("/data/acme/2020/11/01")
+	checkExists(t, fsys, false, "/outside/2020/11/01")
+	fsys.RemoveAll("/data/acme/2020")
+	checkExists(t, fsys, false, "/data/acme/2020")
+	checkExists(t, fsys, true, "/outside/2020/11")
+}
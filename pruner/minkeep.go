@@ -0,0 +1,86 @@
+package pruner
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// newestLeaves finds the count newest date directories at the deepest level of the layout, separately for every
+// branch of the undated ("*") levels. Only the newest branches of each date level are read.
+func (c *companyRun) newestLeaves(layout Layout, count int) map[string]bool {
+	kept := make(map[string]bool)
+	var scan func(parts []string, remaining *int)
+	scan = func(parts []string, remaining *int) {
+		if *remaining <= 0 || c.ctx.Err() != nil {
+			return
+		}
+		depth := len(parts)
+		if depth == len(layout.levels) {
+			kept[strings.Join(parts, "/")] = true
+			*remaining--
+			return
+		}
+		children := c.childDirs(parts)
+		if !layout.levels[depth].dated() {
+			// Every undated branch is its own series with its own count.
+			for _, child := range children {
+				if depth == 0 && child == trashDirName {
+					continue
+				}
+				fresh := count
+				scan(append(parts[:depth:depth], child), &fresh)
+			}
+			return
+		}
+		if len(children) == 0 && depth > 0 {
+			// A date directory with nothing below it still holds the newest data of its branch.
+			kept[strings.Join(parts, "/")] = true
+			*remaining--
+			return
+		}
+		dates := make(map[string]time.Time, len(children))
+		for _, child := range children {
+			dates[child] = layout.CompareDate(append(parts[:depth:depth], child), c.now)
+		}
+		sort.Slice(children, func(i, j int) bool { return dates[children[i]].After(dates[children[j]]) })
+		for _, child := range children {
+			if *remaining <= 0 {
+				return
+			}
+			scan(append(parts[:depth:depth], child), remaining)
+		}
+	}
+	total := count
+	scan(nil, &total)
+	return kept
+}
+
+// childDirs lists the names of the directories inside the directory at parts below the company directory.
+func (c *companyRun) childDirs(parts []string) []string {
+	dir := filepath.Join(append([]string{c.dir}, parts...)...)
+	entries, err := c.fs.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if c.p.isDir(dir, entry) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs
+}
+
+// keepsBelow reports whether one of the directories kept by MinKeepCount is inside the directory rel.
+func (c *companyRun) keepsBelow(rel string) bool {
+	prefix := path.Clean(rel) + "/"
+	for kept := range c.kept {
+		if strings.HasPrefix(kept, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package pruner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// lateDecember has days at the end of 2020 for a company acme, the last one the day before testNow.
+func lateDecember() *MemFS {
+	fsys := &MemFS{}
+	for _, day := range []string{"20", "24", "26", "31"} {
+		fsys.WriteFile("/data/acme/2020/12/"+day+"/data", []byte("data"), testNow)
+	}
+	return fsys
+}
+
+func TestMinKeepDaysFloorsRetention(t *testing.T) {
+	for _, test := range []struct {
+		retention string
+		minKeep   string
+		want      string
+	}{
+		// A retention of 0 days, a typo, removes everything without a floor.
+		{"0", "", "[2020]"},
+		{"0", "7", "[2020/12/20 2020/12/24]"},
+		{"6h", "1w", "[2020/12/20 2020/12/24]"},
+		// The floor never shortens a longer retention.
+		{"10", "7", "[2020/12/20]"},
+	} {
+		config := dailyConfig(func(company *CompanyConfig) {
+			company.Retention = test.retention
+			company.MinKeepDays = test.minKeep
+		})
+		removed, _ := removedPaths(t, memPruner(lateDecember(), config))
+		if fmt.Sprint(removed) != test.want {
+			t.Errorf("retention %s, minKeepDays %q: removed %q, want %s", test.retention, test.minKeep, removed, test.want)
+		}
+	}
+}
+
+func TestMinKeepDaysFloorsSubtenantRetention(t *testing.T) {
+	fsys := &MemFS{}
+	for _, dir := range []string{"north/2020/12/20", "north/2020/12/28", "south/2020/12/20", "south/2020/12/28"} {
+		fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+	}
+	subtenants := true
+	config := dailyConfig(func(company *CompanyConfig) {
+		company.Subtenants = &subtenants
+		company.MinKeepDays = "7"
+		company.SubtenantRetention = map[string]string{"north": "1"}
+	})
+	removed, _ := removedPaths(t, memPruner(fsys, config))
+	if want := "[north/2020/12/20]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+}
+
+func TestMinKeepCountKeepsTheNewest(t *testing.T) {
+	config := dailyConfig(func(company *CompanyConfig) {
+		company.Retention = "0"
+		company.MinKeepCount = 2
+	})
+	removed, stats := removedPaths(t, memPruner(lateDecember(), config))
+	if want := "[2020/12/20 2020/12/24]"; fmt.Sprint(removed) != want || stats.Errors != 0 {
+		t.Errorf("removed %q with %d errors, want %s and none", removed, stats.Errors, want)
+	}
+}
+
+// TestMinKeepCountSurvivesAClockInTheFuture runs a pass with a clock years ahead, when all data looks expired, and
+// expects the newest directory of each device to be kept all the same.
+func TestMinKeepCountSurvivesAClockInTheFuture(t *testing.T) {
+	fsys := &MemFS{}
+	for _, dir := range []string{"a/2020/11/01", "a/2020/12/20", "b/2019/01/01", "b/2019/01/02"} {
+		fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+	}
+	config := dailyConfig(func(company *CompanyConfig) {
+		company.Layout = "*/{year}/{month}/{day}"
+		company.MinKeepCount = 1
+	})
+	p := memPruner(fsys, config)
+	p.Clock = FixedClock(testNow.Add(10 * 365 * 24 * time.Hour))
+	removed, _ := removedPaths(t, p)
+	if want := "[a/2020/11 b/2019/01/01]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+	checkExists(t, fsys, true, "/data/acme/a/2020/12/20/data", "/data/acme/b/2019/01/02/data")
+}
@@ -0,0 +1,97 @@
+package pruner
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// pruneByMtime removes every file last modified before the cutoff. Directories are only removed once this pass has
+// emptied them, so empty directories created ahead of time for new data are left alone.
+func (c *companyRun) pruneByMtime() {
+	emptied := make(map[string]bool)
+	err := c.walkDir(c.dir, func(path string, f fs.DirEntry, err error) error {
+		if c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		if err != nil {
+			c.error(path, "Error in path", err)
+			return nil
+		}
+		if f.IsDir() {
+			if path == filepath.Join(c.dir, trashDirName) {
+				return filepath.SkipDir
+			}
+			if path != c.dir && c.excluded(relativePath(c.dir, path)) {
+				return filepath.SkipDir
+			}
+			c.walking(path)
+			if c.readMarkers(path, relativePath(c.dir, path)) {
+				c.log.WithField("path", path).Infoln("Pinned by a marker file, keeping")
+				c.mu.Lock()
+				c.stats.DirsExcluded++
+				c.mu.Unlock()
+				c.skipped(path, "pinned by a marker file")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isMarker(f.Name()) {
+			return nil
+		}
+		info, err := f.Info()
+		if err != nil {
+			// Removed since the directory was read.
+			return nil
+		}
+		if info.ModTime().Before(c.cutoffFor(relativePath(c.dir, filepath.Dir(path)))) {
+			if c.excluded(relativePath(c.dir, path)) {
+				c.mu.Lock()
+				c.stats.DirsExcluded++
+				c.mu.Unlock()
+				c.skipped(path, "excluded")
+				return nil
+			}
+			before := c.stats.FilesDeleted + c.stats.DirsTrashed
+			c.removeExpired(path, false, info.ModTime())
+			if c.stats.FilesDeleted+c.stats.DirsTrashed > before {
+				emptied[filepath.Dir(path)] = true
+			}
+		}
+		return nil
+	})
+	c.finishWalk(err)
+	if c.dryRun || !c.stats.Completed {
+		return
+	}
+	// Only the directories files were removed from can have been emptied, and then their parents, so the walk
+	// doesn't have to remember every directory it went through. Deepest first, so a parent is only considered after
+	// its children have had their chance to go.
+	dirs := make([]string, 0, len(emptied))
+	for dir := range emptied {
+		dirs = append(dirs, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		if c.guard(dir) != nil {
+			continue
+		}
+		if empty, err := dirEmpty(c.fs, dir); err != nil || !empty {
+			continue
+		}
+		if err := c.p.pace(c.ctx); err != nil {
+			return
+		}
+		if err := c.fs.RemoveAll(dir); err != nil {
+			c.error(dir, "Error removing empty directory", err)
+			continue
+		}
+		if parent := filepath.Dir(dir); !emptied[parent] {
+			emptied[parent] = true
+			dirs = append(dirs, parent)
+			sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+		}
+	}
+}
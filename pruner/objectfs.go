@@ -0,0 +1,310 @@
+package pruner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moriarty-s3a/deleter/archive"
+)
+
+// companyFS returns the FileSystem a company's directory is read and pruned through.
+func (p *Pruner) companyFS(dir companyDir, config CompanyConfig) FileSystem {
+	if config.SFTP != nil {
+		return sftpFS{client: p.sftpClient(*config.SFTP), root: dir.path(), base: path.Clean(config.SFTP.Path)}
+	}
+	if config.Storage == nil {
+		return p.localFS()
+	}
+	store, err := archive.NewStore(*config.Storage)
+	if err != nil {
+		return failedFS{err}
+	}
+	prefix := strings.Trim(config.Storage.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return objectFS{store: store, root: dir.path(), prefix: prefix}
+}
+
+// The companyRun methods below are the Pruner's, for companies on the Pruner's FS, and otherwise work through fs
+// alone: neither object stores nor remote servers are followed into through symlinks or checked for mount points.
+
+func (c *companyRun) walkDir(root string, fn fs.WalkDirFunc) error {
+	if !c.config.remote() {
+		return c.p.walkDir(root, fn)
+	}
+	return c.fs.WalkDir(root, fn)
+}
+
+func (c *companyRun) dirSize(path string) (int64, int, error) {
+	return dirSize(c.fs, path)
+}
+
+func (c *companyRun) mountBelow(path string) string {
+	if !c.config.remote() {
+		return c.p.mountBelow(path)
+	}
+	return ""
+}
+
+func (c *companyRun) inside(path string) error {
+	if !c.config.remote() {
+		return c.p.inside(path)
+	}
+	if !within(c.dir, path) {
+		return fmt.Errorf("%s is outside %s", path, c.dir)
+	}
+	return nil
+}
+
+// objectFS is a FileSystem over the objects in a Store whose keys start with prefix, with root standing for prefix.
+// Directories are the key prefixes ending in a slash. Every call lists the objects it needs afresh.
+type objectFS struct {
+	store  archive.Store
+	root   string
+	prefix string
+}
+
+var errNotSupported = errors.New("not supported by object storage")
+
+// key returns the key, or for a directory the key prefix without its trailing slash, that path stands for.
+func (o objectFS) key(name string) (string, error) {
+	rel, err := filepath.Rel(o.root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%s is outside %s", name, o.root)
+	}
+	if rel == "." {
+		return strings.TrimSuffix(o.prefix, "/"), nil
+	}
+	return o.prefix + filepath.ToSlash(rel), nil
+}
+
+// objectNode is a file or directory in the tree built from a listing.
+type objectNode struct {
+	name     string
+	dir      bool
+	size     int64
+	modified time.Time
+	children map[string]*objectNode
+}
+
+// tree lists everything below name and builds it into a tree. The root of the tree is name itself, which must be a
+// directory; a missing one is fs.ErrNotExist unless it is the root of the store.
+func (o objectFS) tree(name string) (*objectNode, error) {
+	key, err := o.key(name)
+	if err != nil {
+		return nil, err
+	}
+	prefix := key + "/"
+	if key == "" {
+		prefix = ""
+	}
+	root := &objectNode{name: filepath.Base(name), dir: true, children: make(map[string]*objectNode)}
+	found := false
+	err = o.store.List(context.Background(), prefix, func(object archive.Object) error {
+		found = true
+		parts := strings.Split(strings.TrimPrefix(object.Key, prefix), "/")
+		node := root
+		for i, part := range parts {
+			if part == "" {
+				// A "directory" placeholder object, or an empty segment.
+				continue
+			}
+			child, exists := node.children[part]
+			if !exists {
+				child = &objectNode{name: part, dir: i < len(parts)-1, children: make(map[string]*objectNode)}
+				node.children[part] = child
+			}
+			if i < len(parts)-1 {
+				child.dir = true
+			} else if !child.dir {
+				child.size, child.modified = object.Size, object.Modified
+			}
+			node = child
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found && name != o.root {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return root, nil
+}
+
+func (n *objectNode) sorted() []*objectNode {
+	children := make([]*objectNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	return children
+}
+
+func (o objectFS) ReadDir(dirname string) ([]os.DirEntry, error) {
+	root, err := o.tree(dirname)
+	if err != nil {
+		return nil, err
+	}
+	var entries []os.DirEntry
+	for _, child := range root.sorted() {
+		entries = append(entries, fs.FileInfoToDirEntry(objectInfo{child}))
+	}
+	return entries, nil
+}
+
+func (o objectFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	tree, err := o.tree(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	err = o.walk(root, tree, fn)
+	if err == filepath.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (o objectFS) walk(name string, node *objectNode, fn fs.WalkDirFunc) error {
+	if err := fn(name, fs.FileInfoToDirEntry(objectInfo{node}), nil); err != nil || !node.dir {
+		return err
+	}
+	for _, child := range node.sorted() {
+		if err := o.walk(filepath.Join(name, child.name), child, fn); err != nil {
+			if err == filepath.SkipDir && !child.dir {
+				// Skip the rest of the directory, as fs.WalkDir does.
+				return nil
+			}
+			if err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o objectFS) Stat(name string) (os.FileInfo, error) {
+	key, err := o.key(name)
+	if err != nil {
+		return nil, err
+	}
+	if name == o.root {
+		return objectInfo{&objectNode{name: filepath.Base(name), dir: true}}, nil
+	}
+	var info os.FileInfo
+	err = o.store.List(context.Background(), key, func(object archive.Object) error {
+		switch {
+		case object.Key == key:
+			info = objectInfo{&objectNode{name: path.Base(key), size: object.Size, modified: object.Modified}}
+		case strings.HasPrefix(object.Key, key+"/"):
+			info = objectInfo{&objectNode{name: path.Base(key), dir: true}}
+		default:
+			return nil
+		}
+		return fs.SkipAll
+	})
+	if err != nil && err != fs.SkipAll {
+		return nil, err
+	}
+	if info == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+func (o objectFS) ReadFile(name string) ([]byte, error) {
+	key, err := o.key(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := o.Stat(name); err != nil {
+		return nil, err
+	}
+	return o.store.Get(context.Background(), key)
+}
+
+func (o objectFS) EvalSymlinks(name string) (string, error) {
+	return name, nil
+}
+
+// RemoveAll deletes the object name stands for and every object below it, one at a time.
+func (o objectFS) RemoveAll(name string) error {
+	key, err := o.key(name)
+	if err != nil {
+		return err
+	}
+	if key == strings.TrimSuffix(o.prefix, "/") {
+		return fmt.Errorf("refusing to remove everything under %s", o.root)
+	}
+	var keys []string
+	err = o.store.List(context.Background(), key, func(object archive.Object) error {
+		if object.Key == key || strings.HasPrefix(object.Key, key+"/") {
+			keys = append(keys, object.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := o.store.Delete(context.Background(), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o objectFS) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errNotSupported}
+}
+
+// MkdirAll does nothing, since key prefixes don't need creating.
+func (o objectFS) MkdirAll(name string, perm os.FileMode) error {
+	return nil
+}
+
+func (o objectFS) DiskSpace(name string) (uint64, uint64, error) {
+	return 0, 0, errNotSupported
+}
+
+// objectInfo is the os.FileInfo of an objectNode.
+type objectInfo struct {
+	node *objectNode
+}
+
+func (i objectInfo) Name() string       { return i.node.name }
+func (i objectInfo) Size() int64        { return i.node.size }
+func (i objectInfo) ModTime() time.Time { return i.node.modified }
+func (i objectInfo) IsDir() bool        { return i.node.dir }
+func (i objectInfo) Sys() interface{}   { return nil }
+
+func (i objectInfo) Mode() os.FileMode {
+	if i.node.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// failedFS is the FileSystem of a company whose storage couldn't be set up. Every call fails with why.
+type failedFS struct {
+	err error
+}
+
+func (f failedFS) ReadDir(string) ([]os.DirEntry, error)        { return nil, f.err }
+func (f failedFS) WalkDir(root string, fn fs.WalkDirFunc) error { return fn(root, nil, f.err) }
+func (f failedFS) Stat(string) (os.FileInfo, error)             { return nil, f.err }
+func (f failedFS) ReadFile(string) ([]byte, error)              { return nil, f.err }
+func (f failedFS) EvalSymlinks(string) (string, error)          { return "", f.err }
+func (f failedFS) RemoveAll(string) error                       { return f.err }
+func (f failedFS) Rename(string, string) error                  { return f.err }
+func (f failedFS) MkdirAll(string, os.FileMode) error           { return f.err }
+func (f failedFS) DiskSpace(string) (uint64, uint64, error)     { return 0, 0, f.err }
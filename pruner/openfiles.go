@@ -0,0 +1,40 @@
+package pruner
+
+import (
+	"io/fs"
+)
+
+// openBelow returns the first file at or below path that the company's openFiles check finds in use, or "" if there
+// is none or no check is set.
+func (c *companyRun) openBelow(path string, isDir bool) (string, error) {
+	switch c.config.OpenFiles {
+	case OpenFilesProc:
+		return procOpenBelow(path)
+	case OpenFilesFlock:
+		if !isDir {
+			if locked, err := flocked(path); err != nil || !locked {
+				return "", err
+			}
+			return path, nil
+		}
+		var found string
+		var lockErr error
+		c.fs.WalkDir(path, func(path string, f fs.DirEntry, err error) error {
+			if err != nil || !f.Type().IsRegular() {
+				return nil
+			}
+			locked, err := flocked(path)
+			if err != nil {
+				lockErr = err
+				return fs.SkipAll
+			}
+			if locked {
+				found = path
+				return fs.SkipAll
+			}
+			return nil
+		})
+		return found, lockErr
+	}
+	return "", nil
+}
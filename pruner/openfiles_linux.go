@@ -0,0 +1,68 @@
+//go:build linux
+
+package pruner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// procOpenBelow returns the first file at or below path that another process has open, going by /proc/*/fd. Only
+// the processes whose fds this one may read are seen, which without root means those of the same user.
+func procOpenBelow(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return "", err
+	}
+	self := strconv.Itoa(os.Getpid())
+	prefix := path + string(os.PathSeparator)
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil || proc.Name() == self {
+			continue
+		}
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// Gone since, or not ours to look at.
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && (target == path || strings.HasPrefix(target, prefix)) {
+				return target, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// flocked reports whether another process holds an exclusive flock on the file at path, as writers following the
+// flock convention do for as long as they write.
+func flocked(path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
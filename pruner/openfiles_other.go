@@ -0,0 +1,15 @@
+//go:build !linux
+
+package pruner
+
+import "errors"
+
+var errOpenFilesUnsupported = errors.New("openFiles is only supported on Linux")
+
+func procOpenBelow(path string) (string, error) {
+	return "", errOpenFilesUnsupported
+}
+
+func flocked(path string) (bool, error) {
+	return false, errOpenFilesUnsupported
+}
@@ -0,0 +1,54 @@
+package pruner
+
+import "sort"
+
+// Orphans lists where the config and the company directories under the base directories don't line up.
+type Orphans struct {
+	// Entries are the config entries with no company directory, e.g. for offboarded companies or mistyped ids.
+	// Entries with Storage or SFTP set don't need one.
+	Entries []string `json:"entries,omitempty"`
+	// Dirs are the company directories with no config entry of their own.
+	Dirs []string `json:"dirs,omitempty"`
+}
+
+// Orphans compares the config's entries with every company directory under every base directory, whatever the
+// shard, allowlist and company filters.
+func (p *Pruner) Orphans() (Orphans, error) {
+	config := p.Config()
+	dirs := make(map[string]bool)
+	var names []string
+	for _, base := range p.bases(config) {
+		entries, err := p.FS.ReadDir(base)
+		if err != nil {
+			return Orphans{}, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && !dirs[entry.Name()] {
+				dirs[entry.Name()] = true
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	orphans := Orphans{Dirs: config.Unknown(names)}
+	for _, entry := range config.CompanyConfigs {
+		if !dirs[entry.Id] && !matchesAny(entry.Id, names) && !entry.remote() {
+			orphans.Entries = append(orphans.Entries, entry.Id)
+		}
+	}
+	sort.Strings(orphans.Entries)
+	return orphans, nil
+}
+
+// matchesAny reports whether id is a pattern matching one of the company directories.
+func matchesAny(id string, companies []string) bool {
+	if !IsPattern(id) {
+		return false
+	}
+	for _, company := range companies {
+		if matchCompany(id, company) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package pruner
+
+import (
+	"context"
+	"time"
+)
+
+// pace waits until the next deletion is allowed under MaxDeletesPerSecond, which every company shares. It returns
+// ctx's error if ctx is done first. Pacing is in real time, whatever the Clock says.
+func (p *Pruner) pace(ctx context.Context) error {
+	if p.MaxDeletesPerSecond <= 0 {
+		return nil
+	}
+	p.paceMu.Lock()
+	now := time.Now()
+	if p.nextDelete.Before(now) {
+		p.nextDelete = now
+	}
+	wait := p.nextDelete.Sub(now)
+	p.nextDelete = p.nextDelete.Add(time.Duration(float64(time.Second) / p.MaxDeletesPerSecond))
+	p.paceMu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
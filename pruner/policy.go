@@ -0,0 +1,150 @@
+package pruner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PolicyConfig points at an Open Policy Agent decision that can keep paths the company's retention would remove,
+// for rules a day count can't express, such as keeping month-end days for seven years. The walk asks it about each
+// expired directory, and each expired file under fileLayout, before removing it. Path mode only.
+type PolicyConfig struct {
+	// URL is the decision's OPA data API URL, such as http://localhost:8181/v1/data/deleter/decision. It is POSTed
+	// {"input": PolicyInput} and must answer with a result that is either a boolean, true to keep the path, or an
+	// object with booleans "keep" or "descend" and an optional "reason" to log. Descend has the walk judge what is
+	// inside the directory instead, so a policy can say to look inside an expired month for the days to keep. An
+	// undefined or false result removes the path.
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout is a Go duration, default 10s. A path whose decision fails or times out is kept, as an error.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// PolicyInput is what a policy decides on.
+type PolicyInput struct {
+	Company string `json:"companyId"`
+	// Path is relative to the company directory, with forward slashes.
+	Path string `json:"path"`
+	// Start and End are the first and last moments the path's date covers, End being the date retention compares.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Leaf is set when nothing below the path is dated, so there is nothing to descend to.
+	Leaf   bool          `json:"leaf"`
+	Bytes  int64         `json:"bytes"`
+	Files  int           `json:"files"`
+	Now    time.Time     `json:"now"`
+	Cutoff time.Time     `json:"cutoff"`
+	Config CompanyConfig `json:"config"`
+}
+
+const defaultPolicyTimeout = 10 * time.Second
+
+// Check reports what is wrong with the policy config, if anything.
+func (p *PolicyConfig) Check() error {
+	if !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+		return fmt.Errorf("url %q is not http or https", p.URL)
+	}
+	if p.Timeout != "" {
+		if timeout, err := time.ParseDuration(p.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("bad timeout %q", p.Timeout)
+		}
+	}
+	return nil
+}
+
+// policyDecision is a policy's answer about a path.
+type policyDecision struct {
+	Keep    bool   `json:"keep"`
+	Descend bool   `json:"descend"`
+	Reason  string `json:"reason"`
+}
+
+// decide asks the policy about the path input describes.
+func (p *PolicyConfig) decide(ctx context.Context, input PolicyInput) (policyDecision, error) {
+	timeout := defaultPolicyTimeout
+	if parsed, err := time.ParseDuration(p.Timeout); err == nil && parsed > 0 {
+		timeout = parsed
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var decision policyDecision
+	body, err := json.Marshal(map[string]PolicyInput{"input": input})
+	if err != nil {
+		return decision, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewReader(body))
+	if err != nil {
+		return decision, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range p.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return decision, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decision, fmt.Errorf("%s answered %s", p.URL, resp.Status)
+	}
+	var answer struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return decision, fmt.Errorf("decoding policy answer: %v", err)
+	}
+	if len(answer.Result) == 0 || string(answer.Result) == "null" {
+		return decision, nil
+	}
+	if err := json.Unmarshal(answer.Result, &decision.Keep); err == nil {
+		return decision, nil
+	}
+	if err := json.Unmarshal(answer.Result, &decision); err != nil {
+		return decision, fmt.Errorf("policy result %s is neither a boolean nor an object", answer.Result)
+	}
+	return decision, nil
+}
+
+// policyKeeps asks the company's policy, if it has one, about the expired path, which covers start to end, and
+// reports whether to keep it and whether to judge what is inside it instead. A decision that fails is counted as an
+// error and keeps the path.
+func (c *companyRun) policyKeeps(path string, start time.Time, end time.Time, leaf bool) (keep bool, descend bool) {
+	if c.config.Policy == nil || c.purging() {
+		return false, false
+	}
+	rel := relativePath(c.dir, path)
+	input := PolicyInput{Company: c.stats.Company, Path: rel, Start: start, End: end, Leaf: leaf, Now: c.now, Cutoff: c.cutoffFor(rel), Config: c.config}
+	input.Bytes, input.Files, _ = c.dirSize(path)
+	decision, err := c.config.Policy.decide(c.ctx, input)
+	if err != nil {
+		c.error(path, "Error asking policy, keeping path", err)
+		return true, false
+	}
+	pathLog := c.log.WithField("path", path)
+	if decision.Reason != "" {
+		pathLog = pathLog.WithField("reason", decision.Reason)
+	}
+	switch {
+	case decision.Keep:
+		pathLog.Infoln("Expired but kept by policy")
+		c.mu.Lock()
+		c.stats.DirsExcluded++
+		c.mu.Unlock()
+		reason := "kept by policy"
+		if decision.Reason != "" {
+			reason += ": " + decision.Reason
+		}
+		c.skipped(path, reason)
+		return true, false
+	case decision.Descend && !leaf:
+		pathLog.Debugln("Expired but policy asked to judge what is inside instead")
+		return true, true
+	}
+	return false, false
+}
@@ -0,0 +1,175 @@
+package pruner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// policyServer is an OPA stand-in answering each decision with the result answer returns for its input, as JSON,
+// or with no result if answer returns "".
+func policyServer(t *testing.T, answer func(input PolicyInput) string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input PolicyInput `json:"input"`
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer policy" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if result := answer(request.Input); result != "" {
+			fmt.Fprintf(w, `{"result": %s}`, result)
+		} else {
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPolicyDecisions(t *testing.T) {
+	for _, test := range []struct {
+		result string
+		want   policyDecision
+	}{
+		{`true`, policyDecision{Keep: true}},
+		{`false`, policyDecision{}},
+		// An undefined decision removes the path.
+		{``, policyDecision{}},
+		{`null`, policyDecision{}},
+		{`{"keep": true, "reason": "month end"}`, policyDecision{Keep: true, Reason: "month end"}},
+		{`{"descend": true}`, policyDecision{Descend: true}},
+		{`{"keep": false, "descend": false, "reason": "expired"}`, policyDecision{Reason: "expired"}},
+	} {
+		srv := policyServer(t, func(input PolicyInput) string {
+			if input.Company != "acme" || input.Path != "2020/10" {
+				return `"unexpected input"`
+			}
+			return test.result
+		})
+		policy := &PolicyConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer policy"}}
+		decision, err := policy.decide(context.Background(), PolicyInput{Company: "acme", Path: "2020/10"})
+		if err != nil || decision != test.want {
+			t.Errorf("result %s: got %+v, %v, want %+v", test.result, decision, err, test.want)
+		}
+	}
+}
+
+func TestPolicyDecisionErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler http.HandlerFunc
+		err     string
+	}{
+		{"status", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusInternalServerError)
+		}, "500 Internal Server Error"},
+		{"not json", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "keep")
+		}, "decoding policy answer"},
+		{"other result", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"result": "keep"}`)
+		}, "neither a boolean nor an object"},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			fmt.Fprint(w, `{"result": false}`)
+		}, "context deadline exceeded"},
+	} {
+		srv := httptest.NewServer(test.handler)
+		policy := &PolicyConfig{URL: srv.URL, Timeout: "50ms"}
+		_, err := policy.decide(context.Background(), PolicyInput{Company: "acme", Path: "2020/10"})
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+// policyTree has expired months, one of them with a month-end day, and a retained one.
+func policyTree() *MemFS {
+	fsys := &MemFS{}
+	for _, dir := range []string{"2020/08/15", "2020/09/01", "2020/10/01", "2020/10/31", "2020/11/15", "2020/12/20"} {
+		fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+	}
+	return fsys
+}
+
+func TestPolicyKeepsAndDescends(t *testing.T) {
+	var asked []string
+	srv := policyServer(t, func(input PolicyInput) string {
+		asked = append(asked, input.Path)
+		switch input.Path {
+		case "2020/09":
+			return `true`
+		case "2020/10":
+			// Keep what is left of the month end, and only that, from an expired month.
+			if input.Leaf || input.Cutoff != testNow.AddDate(0, 0, -30) {
+				return `"unexpected input"`
+			}
+			return `{"descend": true}`
+		case "2020/10/31":
+			if !input.Leaf || !input.End.Equal(time.Date(2020, 10, 31, 23, 59, 59, 0, time.UTC)) {
+				return `"unexpected input"`
+			}
+			return `{"keep": true, "reason": "month end"}`
+		case "2020/11":
+			return `{"keep": false}`
+		}
+		return ""
+	})
+	p := memPruner(policyTree(), dailyConfig(func(company *CompanyConfig) {
+		company.Policy = &PolicyConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer policy"}}
+	}))
+	removed, stats := removedPaths(t, p)
+	if want := "[2020/08 2020/10/01 2020/11]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+	if stats.DirsExcluded != 2 || stats.Errors != 0 {
+		t.Errorf("got %d kept by policy and %d errors, want 2 and none", stats.DirsExcluded, stats.Errors)
+	}
+	// Only expired paths are asked about, and the retained month isn't.
+	if want := "[2020/08 2020/09 2020/10 2020/10/01 2020/10/31 2020/11]"; fmt.Sprint(asked) != want {
+		t.Errorf("asked about %q, want %s", asked, want)
+	}
+	checkExists(t, p.FS, true, "/data/acme/2020/09/01", "/data/acme/2020/10/31", "/data/acme/2020/12/20")
+}
+
+// TestPolicyFailsClosed expects a policy that can't be asked, or doesn't answer in time, to keep everything.
+func TestPolicyFailsClosed(t *testing.T) {
+	for _, handler := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			fmt.Fprint(w, `{"result": false}`)
+		},
+	} {
+		srv := httptest.NewServer(handler)
+		fsys := policyTree()
+		p := memPruner(fsys, dailyConfig(func(company *CompanyConfig) {
+			company.Policy = &PolicyConfig{URL: srv.URL, Timeout: "50ms"}
+		}))
+		removed, stats := removedPaths(t, p)
+		srv.Close()
+		if len(removed) != 0 || stats.Errors != 4 {
+			t.Errorf("removed %q with %d errors, want nothing and an error for each expired month", removed, stats.Errors)
+		}
+		checkExists(t, fsys, true, "/data/acme/2020/08/15", "/data/acme/2020/09/01", "/data/acme/2020/10/01", "/data/acme/2020/11/15")
+	}
+}
@@ -0,0 +1,129 @@
+package pruner
+
+import (
+	"sort"
+	"time"
+)
+
+// PassProgress is how far a pass still under way has got. Started times are by the wall clock, not the Pruner's
+// Clock, so that how long things have been going is real even when Clock is not.
+type PassProgress struct {
+	RunID   string
+	Started time.Time
+	// DryRun is set for dry runs, including the planning pass that checks the caps before the real one.
+	DryRun bool
+	// Companies is how many companies the pass is over, and Completed how many of them it has finished or skipped.
+	Companies int
+	Completed int
+	// Totals adds up what the finished companies and those in flight have done so far.
+	Totals   CompanyStats
+	InFlight []CompanyProgress
+}
+
+// CompanyProgress is how far a pass has got with a company it is pruning.
+type CompanyProgress struct {
+	Company string
+	BaseDir string
+	Started time.Time
+	// Path is the directory the walk is in, or last reached.
+	Path         string
+	DirsScanned  int
+	DirsDeleted  int
+	FilesDeleted int
+	DirsTrashed  int
+	BytesFreed   int64
+	Errors       int
+}
+
+// passProgress tracks a pass for Progress. It is guarded by the Pruner's stateMu.
+type passProgress struct {
+	runID     string
+	started   time.Time
+	dryRun    bool
+	companies int
+	completed int
+	// finished adds up the stats of the companies that are done.
+	finished CompanyStats
+	running  map[*companyRun]time.Time
+}
+
+// Progress returns how far each pass under way has got, oldest first.
+func (p *Pruner) Progress() []PassProgress {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	list := make([]PassProgress, 0, len(p.passes))
+	for pass := range p.passes {
+		progress := PassProgress{RunID: pass.runID, Started: pass.started, DryRun: pass.dryRun, Companies: pass.companies, Completed: pass.completed, Totals: pass.finished}
+		for run, started := range pass.running {
+			company := run.progress()
+			company.Started = started
+			progress.InFlight = append(progress.InFlight, company)
+			progress.Totals.add(CompanyStats{DirsScanned: company.DirsScanned, DirsDeleted: company.DirsDeleted, FilesDeleted: company.FilesDeleted, DirsTrashed: company.DirsTrashed, BytesFreed: company.BytesFreed, Errors: company.Errors})
+		}
+		sort.Slice(progress.InFlight, func(i, j int) bool { return progress.InFlight[i].Started.Before(progress.InFlight[j].Started) })
+		list = append(list, progress)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// startProgress starts tracking a pass over companies companies.
+func (p *Pruner) startProgress(runID string, dryRun bool, companies int) *passProgress {
+	pass := &passProgress{runID: runID, started: time.Now(), dryRun: dryRun, companies: companies, running: make(map[*companyRun]time.Time)}
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	if p.passes == nil {
+		p.passes = make(map[*passProgress]bool)
+	}
+	p.passes[pass] = true
+	return pass
+}
+
+// endProgress stops tracking a pass.
+func (p *Pruner) endProgress(pass *passProgress) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	delete(p.passes, pass)
+}
+
+// companyStarted records that the pass has started on run.
+func (p *Pruner) companyStarted(pass *passProgress, run *companyRun) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	pass.running[run] = time.Now()
+}
+
+// companyDone records that the pass is done with a company, with the stats it ended with. run is nil for companies
+// the pass skipped.
+func (p *Pruner) companyDone(pass *passProgress, run *companyRun, stats CompanyStats) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	delete(pass.running, run)
+	pass.completed++
+	pass.finished.add(stats)
+}
+
+// progress returns how far the company's pass has got. The walk keeps the counters it reports up to date under mu.
+func (c *companyRun) progress() CompanyProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CompanyProgress{
+		Company:      c.stats.Company,
+		BaseDir:      c.stats.BaseDir,
+		Path:         c.current,
+		DirsScanned:  c.stats.DirsScanned,
+		DirsDeleted:  c.stats.DirsDeleted,
+		FilesDeleted: c.stats.FilesDeleted,
+		DirsTrashed:  c.stats.DirsTrashed,
+		BytesFreed:   c.stats.BytesFreed,
+		Errors:       c.stats.Errors,
+	}
+}
+
+// walking counts the directory path, which the walk has just reached.
+func (c *companyRun) walking(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.DirsScanned++
+	c.current = path
+}
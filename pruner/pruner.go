@@ -0,0 +1,423 @@
+// Package pruner removes date-structured company data that has aged past its configured retention.
+//
+// Data is expected to be laid out as <baseDir>/<companyId>/<layout>, where the layout is configurable per company and
+// defaults to <device>/<year>/<month>/<day>/<hour>/<minute>.
+package pruner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/moriarty-s3a/deleter/sftp"
+)
+
+// Pruner walks every company directory under BaseDir and removes the directories past retention.
+type Pruner struct {
+	BaseDir string
+	// BaseDirs are more base directories whose company directories are processed in the same passes as BaseDir's,
+	// sharing Workers and MaxDeletesPerSecond. The config's baseDirs are added to them.
+	BaseDirs []string
+	DryRun   bool
+	// TrashGrace enables the trash stage when positive: expired directories are moved into the company's .trash
+	// directory and only deleted once they have been there for TrashGrace.
+	TrashGrace time.Duration
+	// StageRemovals, when TrashGrace is zero, moves expired directories into the company's .trash as trashing does,
+	// so that they leave the live tree at once, and deletes them from there in the background, paced by
+	// MaxDeletesPerSecond, while the pass goes on. Renames aren't paced. A company's pass finishes once everything it
+	// staged is deleted.
+	StageRemovals bool
+	// Location is the zone directory dates are read in for companies whose config has no timezone. Nil means UTC.
+	Location *time.Location
+	// AfterPass, if set, is called with the summary of every pass RunScheduled makes.
+	AfterPass func(Summary)
+	// MaxDeleteDirs and MaxDeleteBytes cap what a single pass may remove, counting files removed individually as
+	// directories. Zero means no cap. Force ignores the caps.
+	MaxDeleteDirs  int
+	MaxDeleteBytes int64
+	Force          bool
+	// Workers bounds how many companies are pruned at once. Zero or less means no limit.
+	Workers int
+	// Retries is how many more times a removal that failed with a transient error is tried straight away, waiting
+	// RetryBackoff before the first retry and twice as long before each one after. Removals that still fail are
+	// tried once more at the end of the company's pass.
+	Retries      int
+	RetryBackoff time.Duration
+	// Shard, if set, limits passes to the companies it returns true for, so that several instances can split BaseDir
+	// between them. Locker, if set, is locked for each company before it is pruned for real; a company whose lock
+	// another instance holds is skipped.
+	Shard  func(company string) bool
+	Locker Locker
+	// Companies, if not empty, limits passes to the named company directories, and ExcludeCompanies leaves the
+	// named ones out.
+	Companies        []string
+	ExcludeCompanies []string
+	// MaxDeletesPerSecond spreads removals out so that they don't starve other writers to the same volume. Every
+	// path trashed or removed counts as one, across all companies. Zero means no limit.
+	MaxDeletesPerSecond float64
+	// Window, if set, restricts removals to a daily time range in Location. A pass asked for outside it waits for
+	// it to open if WindowWait is set and is refused otherwise, and a pass still going when it closes stops early.
+	// Dry runs ignore it.
+	Window     *RunWindow
+	WindowWait bool
+	// MaxRuntime, if positive, stops a pass early once it has been running this long.
+	MaxRuntime time.Duration
+	// MinAge, if positive, keeps anything younger than it whatever a company's retention says, as a guard against
+	// clock skew and bad configs. It doesn't apply to purges.
+	MinAge time.Duration
+	// ResumeFrom, if set, makes the next pass skip what the pass that left the checkpoint already did.
+	ResumeFrom *Checkpoint
+	// FollowSymlinks makes passes walk into symlinked directories that lead somewhere inside a base directory,
+	// judging what is inside them as if it were under the link. An expired link is removed, not what it points to.
+	// Whether or not it is set, nothing that resolves outside the base directories is ever removed.
+	FollowSymlinks bool
+	// OneFileSystem keeps passes on the filesystem each company directory is on: directories on another one, such
+	// as volumes mounted into a company's tree, are neither walked nor removed, and an expired directory with one
+	// inside is only removed around it.
+	OneFileSystem bool
+	// MaxMemory, if positive, is roughly how many bytes passes should make do with. Walks read directories in
+	// batches sized to fit it, see walkBatch; it is advisory, not a hard limit.
+	MaxMemory int64
+
+	Clock    Clock
+	FS       FileSystem
+	Log      log.FieldLogger
+	Recorder Recorder
+	// Tracer, if set, gets a span for every pass, every company in it, and every company's removals.
+	Tracer Tracer
+	// Audit, if set, gets a record of every directory or file removed or trashed.
+	Audit *AuditLog
+	// History, if set, gets the summary of every pass that isn't a dry run.
+	History *History
+	// Journal, if set, records each removal before it starts and after it finishes, and passes first finish the
+	// removals inside their companies that earlier runs left unfinished.
+	Journal *Journal
+	// OnRemove, if set, is called with the same record, minus the sequence number and hashes, whether or not there
+	// is an audit log.
+	OnRemove func(AuditRecord)
+	// OnEvent, if set, is called with every decision passes make about a path. It must be safe for concurrent use.
+	OnEvent func(Event)
+	// CorrelationID, if set, is an ID from whatever started the run, such as a job scheduler, carried on every
+	// summary, event, audit record and hook call alongside the run ID. Log lines get it from the logger, if at all.
+	CorrelationID string
+
+	configMu sync.RWMutex
+	config   Config
+	passMu   sync.Mutex
+	// paceMu guards nextDelete, the earliest time pace lets the next removal start.
+	paceMu     sync.Mutex
+	nextDelete time.Time
+
+	// stateMu guards the pause flags, the latest status of each company, and the passes under way.
+	stateMu  sync.Mutex
+	paused   map[string]bool
+	last     map[string]CompanyStatus
+	passes   map[*passProgress]bool
+	triggers chan []string
+
+	// sftpMu guards sftpClients, the connections to the servers of companies with SFTP set.
+	sftpMu      sync.Mutex
+	sftpClients map[string]*sftp.Client
+}
+
+// New returns a Pruner for baseDir that uses the system clock, the local disk, and the standard logger.
+func New(baseDir string, config Config) *Pruner {
+	return &Pruner{
+		BaseDir:  baseDir,
+		config:   config,
+		Clock:    SystemClock{},
+		FS:       OSFileSystem{},
+		Log:      log.StandardLogger(),
+		Recorder: NopRecorder{},
+		triggers: make(chan []string, 16),
+	}
+}
+
+// Config returns the configuration the next pass will use.
+func (p *Pruner) Config() Config {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+	return p.config
+}
+
+// SetConfig replaces the configuration. A pass already in progress keeps the config it started with.
+func (p *Pruner) SetConfig(config Config) {
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+	p.config = config
+}
+
+// Run prunes every company directory once and returns when all of them are done. If ctx is cancelled, no new
+// deletions are started, deletions already in progress are allowed to finish, and the returned Summary is marked
+// as interrupted along with ctx's error.
+func (p *Pruner) Run(ctx context.Context) (Summary, error) {
+	companies, err := p.companyDirs()
+	if err != nil {
+		return Summary{}, err
+	}
+	return p.runCompanies(ctx, companies, p.Recorder)
+}
+
+// RunCompanies prunes the named company directories once, or all of them if none are named, like Run, sending events
+// to recorder as well as to p.Recorder. A company with directories under several base directories has all of them
+// pruned. Names that aren't company directories under any base directory are an error.
+func (p *Pruner) RunCompanies(ctx context.Context, companies []string, recorder Recorder) (Summary, error) {
+	existing, err := p.companyDirs()
+	if err != nil {
+		return Summary{}, err
+	}
+	if len(companies) == 0 {
+		return p.runCompanies(ctx, existing, MultiRecorder{p.Recorder, recorder})
+	}
+	names := companyNames(existing)
+	for _, company := range companies {
+		if !containsString(names, company) {
+			return Summary{}, fmt.Errorf("no company directory %s under %s", company, strings.Join(p.Bases(), ", "))
+		}
+	}
+	var selected []companyDir
+	for _, dir := range existing {
+		if containsString(companies, dir.name) {
+			selected = append(selected, dir)
+		}
+	}
+	return p.runCompanies(ctx, selected, MultiRecorder{p.Recorder, recorder})
+}
+
+// companyConfig returns the config entry for a company directory, by id or pattern, falling back to the default.
+// Whatever the entry, and its group, leave unset is taken from the default.
+func companyConfig(configMap map[string]CompanyConfig, company string) CompanyConfig {
+	defaults := configMap["default"]
+	config, exists := lookupEntry(configMap, company)
+	if !exists {
+		return defaults
+	}
+	return inherit(config, defaults)
+}
+
+// runCompanies prunes the named company directories concurrently and waits for them to finish. If a deletion cap is
+// set, a dry planning pass runs first and nothing is removed if the plan exceeds the cap. Passes never overlap; a
+// pass waits for the one in progress to finish, and for the run window if there is one.
+func (p *Pruner) runCompanies(ctx context.Context, companies []companyDir, recorder Recorder) (Summary, error) {
+	p.passMu.Lock()
+	defer p.passMu.Unlock()
+	outer, windowCtx := ctx, ctx
+	if p.Window != nil && !p.DryRun {
+		var cancel context.CancelFunc
+		var err error
+		windowCtx, cancel, err = p.enterWindow(ctx)
+		if err != nil {
+			now := time.Now()
+			return Summary{Start: now, End: now, Interrupted: err == ctx.Err()}, err
+		}
+		defer cancel()
+	}
+	ctx = windowCtx
+	if p.MaxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.MaxRuntime)
+		defer cancel()
+	}
+	if config := p.Config(); config.UnknownCompanies == UnknownFail {
+		if unknown := config.Unknown(companyNames(companies)); len(unknown) > 0 {
+			err := &UnknownCompaniesError{Companies: unknown}
+			p.Log.Errorln(err)
+			now := time.Now()
+			return Summary{Start: now, End: now, Aborted: true, UnknownCompanies: unknown}, err
+		}
+	}
+	resume := p.ResumeFrom
+	p.ResumeFrom = nil
+	if !p.DryRun && !p.Force && (p.MaxDeleteDirs > 0 || p.MaxDeleteBytes > 0) {
+		quiet := log.New()
+		quiet.Out = ioutil.Discard
+		plan := p.pass(ctx, companies, true, quiet, NopRecorder{}, resume)
+		if plan.Interrupted {
+			p.stoppedEarly(&plan, outer, windowCtx)
+			return plan, outer.Err()
+		}
+		totals := plan.Totals()
+		dirs := totals.DirsDeleted + totals.FilesDeleted
+		if (p.MaxDeleteDirs > 0 && dirs > p.MaxDeleteDirs) || (p.MaxDeleteBytes > 0 && totals.BytesFreed > p.MaxDeleteBytes) {
+			err := &CapExceededError{Dirs: dirs, Bytes: totals.BytesFreed, MaxDirs: p.MaxDeleteDirs, MaxBytes: p.MaxDeleteBytes}
+			p.Log.WithField("run_id", plan.RunID).Errorln(err)
+			plan.Aborted = true
+			return plan, err
+		}
+	}
+	summary := p.pass(ctx, companies, p.DryRun, p.Log, recorder, resume)
+	if summary.Interrupted {
+		p.stoppedEarly(&summary, outer, windowCtx)
+	}
+	p.recordStatus(summary)
+	p.recordHistory(summary)
+	return summary, outer.Err()
+}
+
+// stoppedEarly works out why an interrupted pass stopped: ctx itself, the run window closing, or MaxRuntime running
+// out.
+func (p *Pruner) stoppedEarly(summary *Summary, ctx context.Context, windowCtx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	summary.Interrupted = false
+	passLog := p.Log.WithField("run_id", summary.RunID)
+	if windowCtx.Err() != nil {
+		summary.WindowClosed = true
+		passLog.Warnf("Run window %s closed before the pass finished", p.Window)
+		return
+	}
+	summary.OutOfTime = true
+	passLog.Warnf("Pass ran out of its %s budget before finishing", p.MaxRuntime)
+}
+
+// CapExceededError is returned when a pass would delete more than MaxDeleteDirs or MaxDeleteBytes.
+type CapExceededError struct {
+	Dirs     int
+	Bytes    int64
+	MaxDirs  int
+	MaxBytes int64
+}
+
+func (e *CapExceededError) Error() string {
+	return fmt.Sprintf("pass would delete %d paths and %d bytes, over the cap of %d paths or %d bytes; nothing was removed (use -force to override)",
+		e.Dirs, e.Bytes, e.MaxDirs, e.MaxBytes)
+}
+
+// UnknownCompaniesError is returned when unknownCompanies is "fail" and there are company directories without a
+// config entry.
+type UnknownCompaniesError struct {
+	Companies []string
+}
+
+func (e *UnknownCompaniesError) Error() string {
+	return fmt.Sprintf("no config entry for companies %s and unknownCompanies is %q; nothing was removed", strings.Join(e.Companies, ", "), UnknownFail)
+}
+
+// pass prunes the named company directories concurrently and waits for them to finish, skipping what resume says
+// was already done.
+func (p *Pruner) pass(ctx context.Context, companies []companyDir, dryRun bool, logger log.FieldLogger, recorder Recorder, resume *Checkpoint) Summary {
+	config := p.Config()
+	configMaps := p.configMaps(config)
+	currTime := p.Clock.Now()
+	summary := Summary{RunID: newRunID(), CorrelationID: p.CorrelationID, Start: time.Now(), AsOf: p.asOf(currTime), Companies: make([]CompanyStats, len(companies))}
+	logger = logger.WithField("run_id", summary.RunID)
+	ctx, passSpan := p.tracer().Start(ctx, "deleter.pass", map[string]interface{}{
+		"deleter.run_id":    summary.RunID,
+		"deleter.dry_run":   dryRun,
+		"deleter.companies": len(companies),
+	})
+	if resume != nil {
+		summary.ResumedFrom = resume.RunID
+	}
+	summary.UnknownCompanies = config.Unknown(companyNames(companies))
+	if orphans, err := p.Orphans(); err == nil {
+		summary.OrphanedEntries = orphans.Entries
+	}
+	progress := p.startProgress(summary.RunID, dryRun, len(companies))
+	defer p.endProgress(progress)
+	var wg sync.WaitGroup
+	var slots chan struct{}
+	if p.Workers > 0 {
+		slots = make(chan struct{}, p.Workers)
+	}
+	for i, dir := range companies {
+		company := dir.name
+		summary.Companies[i].Company = company
+		summary.Companies[i].BaseDir = dir.shown
+		if p.isPaused(company) {
+			logger.WithField("company_id", company).Infoln("Company is paused, skipping")
+			summary.Companies[i].Paused = true
+			summary.Companies[i].Completed = true
+			p.companyDone(progress, nil, summary.Companies[i])
+			continue
+		}
+		if config.UnknownCompanies == UnknownSkip && containsString(summary.UnknownCompanies, company) {
+			logger.WithField("company_id", company).Warnln("Company has no config entry, skipping")
+			summary.Companies[i].Unknown = true
+			summary.Companies[i].Completed = true
+			p.companyDone(progress, nil, summary.Companies[i])
+			continue
+		}
+		if resume != nil && containsString(resume.Done, dir.key()) {
+			logger.WithField("company_id", company).Infof("Company was finished by pass %s, skipping", resume.RunID)
+			summary.Companies[i].Resumed = true
+			summary.Companies[i].Completed = true
+			p.companyDone(progress, nil, summary.Companies[i])
+			continue
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+		companyCtx, span := p.tracer().Start(ctx, "deleter.company", map[string]interface{}{"deleter.company_id": company, "deleter.base_dir": dir.base})
+		run := p.newCompanyRun(companyCtx, dir, companyConfig(configMaps[dir.base], company), summary.RunID, currTime, logger, dryRun, recorder)
+		if resume != nil {
+			run.resumeFrom = resume.Progress[dir.key()]
+		}
+		run.log.Debugln("Config = ", run.config)
+		p.companyStarted(progress, run)
+		wg.Add(1)
+		go func(stats *CompanyStats) {
+			defer wg.Done()
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			*stats = run.prune()
+			p.companyDone(progress, run, *stats)
+			endSpan(span, *stats)
+		}(&summary.Companies[i])
+	}
+	wg.Wait()
+	summary.End = time.Now()
+	summary.Interrupted = ctx.Err() != nil
+	endSpan(passSpan, summary.Totals())
+	return summary
+}
+
+// dirSize returns the total size in bytes and the number of the regular files below path.
+func (p *Pruner) dirSize(path string) (int64, int, error) {
+	return dirSize(p.localFS(), path)
+}
+
+func dirSize(fsys FileSystem, path string) (int64, int, error) {
+	var size int64
+	var files int
+	err := fsys.WalkDir(path, func(_ string, f fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.Type().IsRegular() {
+			info, err := f.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// newRunID returns a random identifier for a pass, to tie together its log lines.
+func newRunID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
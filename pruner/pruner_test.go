@@ -0,0 +1,364 @@
+package pruner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// deepTree writes a tree in DefaultLayout for a company acme with a device cam: every month of 2019 and 2020, three
+// days in each, two hours in each day and two minutes in each hour, with a file in each minute.
+func deepTree() *MemFS {
+	fsys := &MemFS{}
+	for year := 2019; year <= 2020; year++ {
+		for month := 1; month <= 12; month++ {
+			for day := 1; day <= 3; day++ {
+				for hour := 0; hour < 2; hour++ {
+					for minute := 0; minute < 60; minute += 30 {
+						dir := fmt.Sprintf("/data/acme/cam/%d/%02d/%02d/%02d/%02d", year, month, day, hour, minute)
+						fsys.WriteFile(dir+"/data", []byte("data"), testNow)
+					}
+				}
+			}
+		}
+	}
+	return fsys
+}
+
+// removedPaths runs a pass and returns the paths it removed, relative to the company directory, and its stats.
+func removedPaths(t *testing.T, p *Pruner) ([]string, CompanyStats) {
+	var mu sync.Mutex
+	var removed []string
+	p.OnRemove = func(record AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, filepath.ToSlash(relativePath("/data/acme", record.Path)))
+	}
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(removed)
+	return removed, summary.Companies[0]
+}
+
+func TestWalkRemovesOnlyTheTopmostExpiredDirectories(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30"}}}
+	removed, stats := removedPaths(t, memPruner(deepTree(), config))
+	want := []string{"cam/2019"}
+	for month := 1; month <= 11; month++ {
+		want = append(want, fmt.Sprintf("cam/2020/%02d", month))
+	}
+	want = append(want, "cam/2020/12/01")
+	if fmt.Sprint(removed) != fmt.Sprint(want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	// The company and device directories, both years, the months of 2020 and the days of December. Nothing
+	// inside a removed directory is walked into.
+	if stats.DirsScanned != 19 {
+		t.Errorf("scanned %d directories, want 19", stats.DirsScanned)
+	}
+	if stats.Errors != 0 {
+		t.Errorf("got %d errors", stats.Errors)
+	}
+}
+
+func TestWalkSkipsTheSameDirectoriesInDryRun(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30"}}}
+	p := memPruner(deepTree(), config)
+	p.DryRun = true
+	_, dry := removedPaths(t, p)
+	_, real := removedPaths(t, memPruner(deepTree(), config))
+	if dry.DirsScanned != real.DirsScanned || dry.DirsDeleted != real.DirsDeleted {
+		t.Errorf("dry run scanned %d and would remove %d directories, the real pass scanned %d and removed %d", dry.DirsScanned, dry.DirsDeleted, real.DirsScanned, real.DirsDeleted)
+	}
+}
+
+// unreadableFS is a MemFS in which one directory can't be read.
+type unreadableFS struct {
+	*MemFS
+	dir string
+}
+
+func (u unreadableFS) ReadDir(name string) ([]os.DirEntry, error) {
+	if name == u.dir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("permission denied")}
+	}
+	return u.MemFS.ReadDir(name)
+}
+
+func (u unreadableFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return readDirWalk(u, root, fn)
+}
+
+func TestWalkCountsErrorsAndCarriesOn(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30"}}}
+	fsys := unreadableFS{MemFS: deepTree(), dir: "/data/acme/cam/2020/12"}
+	removed, stats := removedPaths(t, memPruner(fsys, config))
+	if stats.Errors != 1 {
+		t.Errorf("got %d errors, want 1", stats.Errors)
+	}
+	want := []string{"cam/2019"}
+	for month := 1; month <= 11; month++ {
+		want = append(want, fmt.Sprintf("cam/2020/%02d", month))
+	}
+	if fmt.Sprint(removed) != fmt.Sprint(want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	// What is in the directory that couldn't be read is kept, expired or not.
+	checkExists(t, fsys, true, "/data/acme/cam/2020/12/01/00/00/data", "/data/acme/cam/2020/12/02/00/00/data")
+}
+
+// busyTree is deepTree with a month pinned by a .keep file, a stray file and a directory that isn't a date.
+func busyTree() *MemFS {
+	fsys := deepTree()
+	fsys.WriteFile("/data/acme/cam/2020/03/"+keepMarker, nil, testNow)
+	fsys.WriteFile("/data/acme/cam/notes.txt", []byte("notes"), testNow)
+	fsys.WriteFile("/data/acme/cam/misc/data", []byte("data"), testNow)
+	return fsys
+}
+
+func TestWalkWithWorkersMatchesOneWorker(t *testing.T) {
+	config := func(workers int) Config {
+		return Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30", Workers: workers, ExcludePaths: []string{"cam/2020/05"}, StrayFiles: StrayReport}}}
+	}
+	removed, stats := removedPaths(t, memPruner(busyTree(), config(1)))
+	want := []string{"cam/2019", "cam/2020/01", "cam/2020/02", "cam/2020/04"}
+	for month := 6; month <= 11; month++ {
+		want = append(want, fmt.Sprintf("cam/2020/%02d", month))
+	}
+	want = append(want, "cam/2020/12/01")
+	if fmt.Sprint(removed) != fmt.Sprint(want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	if stats.DirsExcluded != 2 || stats.DirsUnparsed != 1 || stats.StrayFiles != 1 {
+		t.Errorf("got %d excluded, %d unparsed and %d stray, want 2, 1 and 1", stats.DirsExcluded, stats.DirsUnparsed, stats.StrayFiles)
+	}
+	// The walk counts what it keeps while workers count what they remove, which -race checks is done under mu.
+	for _, workers := range []int{2, 8} {
+		removedByWorkers, statsByWorkers := removedPaths(t, memPruner(busyTree(), config(workers)))
+		if fmt.Sprint(removedByWorkers) != fmt.Sprint(removed) {
+			t.Errorf("%d workers removed %q, one removed %q", workers, removedByWorkers, removed)
+		}
+		if fmt.Sprint(statsByWorkers) != fmt.Sprint(stats) {
+			t.Errorf("%d workers ended with %+v, one with %+v", workers, statsByWorkers, stats)
+		}
+	}
+}
+
+// lostLocker is a Locker whose locks are lost as soon as they are taken.
+type lostLocker struct{}
+
+func (lostLocker) TryLock(ctx context.Context, key string) (context.Context, func(), bool, error) {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	cancel(ErrLockLost)
+	return lockCtx, func() {}, true, nil
+}
+
+func TestWalkStopsWhenTheCompanyLockIsLost(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30"}}}
+	p := memPruner(deepTree(), config)
+	p.Locker = lostLocker{}
+	removed, stats := removedPaths(t, p)
+	if len(removed) != 0 {
+		t.Errorf("removed %q after losing the lock", removed)
+	}
+	if stats.Completed || stats.Errors != 1 {
+		t.Errorf("got completed %v with %d errors, want an incomplete pass with 1 error", stats.Completed, stats.Errors)
+	}
+}
+
+// recordingTracer is a Tracer that records the spans that ended, by name, with their attributes.
+type recordingTracer struct {
+	mu    sync.Mutex
+	ended []recordedSpan
+}
+
+type recordedSpan struct {
+	tracer *recordingTracer
+	name   string
+	attrs  map[string]interface{}
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, Span) {
+	span := &recordedSpan{tracer: r, name: name, attrs: make(map[string]interface{})}
+	for key, value := range attrs {
+		span.attrs[key] = value
+	}
+	return ctx, span
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordedSpan) End(error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.ended = append(s.tracer.ended, *s)
+}
+
+func TestWalkTracesRemovalsInOneSpan(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30"}}}
+	p := memPruner(deepTree(), config)
+	tracer := &recordingTracer{}
+	p.Tracer = tracer
+	removed, _ := removedPaths(t, p)
+	var names []string
+	for _, span := range tracer.ended {
+		names = append(names, span.name)
+	}
+	if fmt.Sprint(names) != "[deleter.remove deleter.company deleter.pass]" {
+		t.Fatalf("got spans %q, want one for the removals, the company and the pass", names)
+	}
+	if attrs := tracer.ended[0].attrs; attrs["deleter.removals"] != len(removed) || attrs["deleter.removal_failures"] != 0 {
+		t.Errorf("got removal span attributes %v, want %d removals", attrs, len(removed))
+	}
+}
+
+func TestWalkNeverRemovesAboveMinDepth(t *testing.T) {
+	tree := func() *MemFS {
+		fsys := &MemFS{}
+		for _, dir := range []string{"2019/12/30", "2019/12/31", "2020/11/15", "2020/12/20"} {
+			fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+		}
+		return fsys
+	}
+	removed, _ := removedPaths(t, memPruner(tree(), dailyConfig(nil)))
+	if want := "[2019 2020/11]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+	// Expired years and months are judged by what is inside them instead.
+	fsys := tree()
+	removed, stats := removedPaths(t, memPruner(fsys, dailyConfig(func(company *CompanyConfig) { company.MinDepth = 3 })))
+	if want := "[2019/12/30 2019/12/31 2020/11/15]"; fmt.Sprint(removed) != want || stats.Errors != 0 {
+		t.Errorf("removed %q with %d errors, want %s and none", removed, stats.Errors, want)
+	}
+	checkExists(t, fsys, true, "/data/acme/2019/12", "/data/acme/2020/11", "/data/acme/2020/12/20/data")
+}
+
+func TestGuardRefusesPathsOutsideTheCompany(t *testing.T) {
+	fsys := deepTree()
+	fsys.WriteFile("/data/other/cam/2019/01/01/data", []byte("data"), testNow)
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30", MinDepth: 2}}}
+	p := memPruner(fsys, config)
+	run := p.newCompanyRun(context.Background(), companyDir{name: "acme", base: "/data"}, config.CompanyConfigs[0], "test", testNow, p.Log, false, p.Recorder)
+	for _, path := range []string{
+		"/data/acme",
+		"/data",
+		"/",
+		"/data/acme/..",
+		"/data/acme/cam/..",
+		"/data/acme/cam/2019/../../../other/cam",
+		"/data/acme/../other/cam/2019",
+		"/data/acmeco/cam/2019",
+		// Above minDepth.
+		"/data/acme/cam",
+		"/data/acme/cam/2019/..",
+	} {
+		if err := run.guard(path); err == nil {
+			t.Errorf("guard allowed removing %s", path)
+		}
+		run.removeExpired(path, true, testNow.AddDate(-10, 0, 0))
+	}
+	if run.stats.Errors != 10 || run.stats.DirsDeleted != 0 {
+		t.Errorf("got %d errors and %d removals, want every removal refused", run.stats.Errors, run.stats.DirsDeleted)
+	}
+	checkExists(t, fsys, true, "/data/acme/cam/2019/01/01/00/00/data", "/data/other/cam/2019/01/01/data")
+	if err := run.guard("/data/acme/cam/2019"); err != nil {
+		t.Errorf("guard refused removing /data/acme/cam/2019: %v", err)
+	}
+}
+
+func TestWalkStaysOnOneFileSystem(t *testing.T) {
+	tree := func() *MemFS {
+		fsys := &MemFS{}
+		for _, dir := range []string{"2019/01/01", "2020/10/01", "2020/10/02", "2020/12/20"} {
+			fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+		}
+		// A volume mounted over an expired year, and one inside an expired month.
+		fsys.Mount("/data/acme/2019")
+		fsys.Mount("/data/acme/2020/10/02")
+		return fsys
+	}
+	removed, _ := removedPaths(t, memPruner(tree(), dailyConfig(nil)))
+	if want := "[2019 2020/10]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+	// The month is removed around the volume inside it.
+	fsys := tree()
+	p := memPruner(fsys, dailyConfig(nil))
+	p.OneFileSystem = true
+	removed, stats := removedPaths(t, p)
+	if want := "[2020/10/01]"; fmt.Sprint(removed) != want || stats.Errors != 0 {
+		t.Errorf("removed %q with %d errors, want %s and none", removed, stats.Errors, want)
+	}
+	checkExists(t, fsys, true, "/data/acme/2019/01/01/data", "/data/acme/2020/10/02/data")
+}
+
+func TestDeletionCapsAbortThePassBeforeRemovingAnything(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30"}}}
+	// deepTree's expired data is 13 directories holding 280 files of 4 bytes.
+	for _, test := range []struct {
+		maxDirs  int
+		maxBytes int64
+		force    bool
+		dryRun   bool
+		exceeded bool
+	}{
+		{maxDirs: 12, exceeded: true},
+		{maxDirs: 13},
+		{maxBytes: 1119, exceeded: true},
+		{maxBytes: 1120},
+		{maxDirs: 13, maxBytes: 1000, exceeded: true},
+		{maxDirs: 1, maxBytes: 1, force: true},
+		// A dry run removes nothing anyway, and shows what the pass would remove.
+		{maxDirs: 1, dryRun: true},
+	} {
+		fsys := deepTree()
+		p := memPruner(fsys, config)
+		p.MaxDeleteDirs, p.MaxDeleteBytes, p.Force, p.DryRun = test.maxDirs, test.maxBytes, test.force, test.dryRun
+		summary, err := p.Run(context.Background())
+		var capErr *CapExceededError
+		if exceeded := errors.As(err, &capErr); exceeded != test.exceeded || (err != nil && !exceeded) {
+			t.Errorf("%+v: got %v", test, err)
+			continue
+		}
+		if test.exceeded {
+			if capErr.Dirs != 13 || capErr.Bytes != 1120 || !summary.Aborted {
+				t.Errorf("%+v: got %+v and aborted %v, want 13 paths and 1120 bytes, aborted", test, capErr, summary.Aborted)
+			}
+		} else if totals := summary.Totals(); totals.DirsDeleted != 13 || totals.BytesFreed != 1120 {
+			t.Errorf("%+v: removed %d directories and %d bytes, want 13 and 1120", test, totals.DirsDeleted, totals.BytesFreed)
+		}
+		checkExists(t, fsys, test.exceeded || test.dryRun, "/data/acme/cam/2019", "/data/acme/cam/2020/12/01")
+	}
+}
+
+func TestWalkSkipsDirectoriesThatAreNotDates(t *testing.T) {
+	tree := func() *MemFS {
+		fsys := &MemFS{}
+		for _, dir := range []string{"2020/12/01", "2020/12/05", "2020/12/32", "2020/12/backup", "backup/2019/01"} {
+			fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+		}
+		return fsys
+	}
+	fsys := tree()
+	removed, stats := removedPaths(t, memPruner(fsys, dailyConfig(nil)))
+	if want := "[2020/12/01]"; fmt.Sprint(removed) != want || stats.DirsUnparsed != 3 {
+		t.Errorf("removed %q with %d unparsed, want %s and 3", removed, stats.DirsUnparsed, want)
+	}
+	checkExists(t, fsys, true, "/data/acme/2020/12/32", "/data/acme/2020/12/backup", "/data/acme/backup/2019/01")
+	// Without strict parsing, what doesn't parse counts as zero: backup is year 0, and day backup the last of November.
+	strict := false
+	removed, stats = removedPaths(t, memPruner(tree(), dailyConfig(func(company *CompanyConfig) { company.Strict = &strict })))
+	if want := "[2020/12/01 2020/12/backup backup]"; fmt.Sprint(removed) != want || stats.DirsUnparsed != 0 {
+		t.Errorf("not strict: removed %q with %d unparsed, want %s and none", removed, stats.DirsUnparsed, want)
+	}
+}
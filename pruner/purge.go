@@ -0,0 +1,136 @@
+package pruner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// AuditPurged marks records written by Purge.
+const AuditPurged = "purged"
+
+// ErrPurgeIncomplete is returned by Purge when it finished but left some of the data it was to delete in place. The
+// stats' KeptPaths and FailedPaths say what.
+var ErrPurgeIncomplete = errors.New("purge left data in place")
+
+// Purge permanently deletes a company's data regardless of its retention, bypassing the trash. If before is zero
+// everything in the company directory goes, the directory itself is kept. Otherwise only data dated before it
+// goes, with dates read the same way a normal pass reads them; the company's trash is left for its grace period.
+// Only legal hold stops a purge: marker files and policies are ignored. What excludePaths or protectedPaths keep,
+// or a removal fails on, makes it return ErrPurgeIncomplete. A company with directories under several base
+// directories is purged from each of them, and the stats are their totals.
+func (p *Pruner) Purge(ctx context.Context, company string, before time.Time) (CompanyStats, error) {
+	if company == "" || company == "." || company == ".." || filepath.Base(company) != company {
+		return CompanyStats{}, fmt.Errorf("invalid company id %q", company)
+	}
+	config := p.Config()
+	configMaps := p.configMaps(config)
+	bases := p.bases(config)
+	var dirs []companyDir
+	for _, base := range bases {
+		if info, err := p.FS.Stat(filepath.Join(base, company)); err == nil && info.IsDir() {
+			dirs = append(dirs, companyDir{name: company, base: base})
+		}
+	}
+	if len(dirs) == 0 {
+		// Let the purge of BaseDir report why there is nothing there.
+		dirs = append(dirs, companyDir{name: company, base: bases[0]})
+	}
+	runID := newRunID()
+	totals := CompanyStats{Company: company, Completed: true}
+	for _, dir := range dirs {
+		if len(bases) > 1 {
+			dir.shown = dir.base
+		}
+		stats, err := p.purge(ctx, dir, companyConfig(configMaps[dir.base], company), runID, before)
+		totals.add(stats)
+		totals.Cutoff = stats.Cutoff
+		totals.FailedPaths = append(totals.FailedPaths, stats.FailedPaths...)
+		totals.KeptPaths = append(totals.KeptPaths, stats.KeptPaths...)
+		totals.Completed = totals.Completed && stats.Completed
+		if err != nil {
+			return totals, err
+		}
+	}
+	if len(totals.KeptPaths) > 0 || totals.Errors > 0 {
+		return totals, fmt.Errorf("%w: %d paths kept, %d errors", ErrPurgeIncomplete, len(totals.KeptPaths), totals.Errors)
+	}
+	return totals, nil
+}
+
+// purgeAll removes everything inside dir but what the company's excludePaths keep, going into the directories
+// holding an excluded path to remove what is around it.
+func (c *companyRun) purgeAll(dir string) error {
+	entries, err := c.fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		path := filepath.Join(dir, entry.Name())
+		rel := relativePath(c.dir, path)
+		switch {
+		case c.excluded(rel):
+			c.log.WithField("path", path).Warnln("Path is excluded, keeping it")
+			c.stats.DirsExcluded++
+			c.skipped(path, "excluded")
+		case entry.IsDir() && c.excludedInside(path, rel):
+			if err := c.purgeAll(path); err != nil {
+				c.error(path, "Error reading directory", err)
+			}
+		default:
+			c.removeExpired(path, entry.IsDir(), c.now)
+		}
+	}
+	return nil
+}
+
+// purging reports whether the pass is a purge.
+func (c *companyRun) purging() bool {
+	return c.action == AuditPurged
+}
+
+// purge is Purge for one company directory.
+func (p *Pruner) purge(ctx context.Context, dir companyDir, config CompanyConfig, runID string, before time.Time) (CompanyStats, error) {
+	company := dir.name
+	run := p.newCompanyRun(ctx, dir, config, runID, p.Clock.Now(), p.Log, p.DryRun, p.Recorder)
+	run.permanent = true
+	run.action = AuditPurged
+	defer run.endRemoveSpan()
+	if run.config.OnLegalHold() {
+		return run.stats, fmt.Errorf("company %s is under legal hold", company)
+	}
+	loc, err := run.location()
+	if err != nil {
+		return run.stats, err
+	}
+	run.now = run.now.In(loc)
+	if before.IsZero() {
+		run.log.Warnln("Purging all company data")
+		// Everything goes, so there are no directories above the data to protect, only the company directory.
+		run.minDepth = 1
+		if err := run.purgeAll(run.dir); err != nil {
+			return run.stats, err
+		}
+		run.stats.Completed = true
+		return run.stats, nil
+	}
+	run.cutoff = before
+	run.stats.Cutoff = before
+	run.log = run.log.WithField("cutoff", before.Format(time.RFC3339))
+	run.log.Warnln("Purging company data")
+	if run.config.Mode == ModeMtime {
+		run.pruneByMtime()
+	} else {
+		layout, err := run.config.ParseLayout()
+		if err != nil {
+			return run.stats, err
+		}
+		run.pruneByLayout(layout)
+	}
+	return run.stats, ctx.Err()
+}
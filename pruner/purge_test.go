@@ -0,0 +1,133 @@
+package pruner
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moriarty-s3a/deleter/archive"
+)
+
+// archiveServer is an S3 endpoint that accepts every upload and counts them.
+func archiveServer(t *testing.T, uploads *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := md5.Sum(body)
+		atomic.AddInt32(uploads, 1)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	return srv
+}
+
+// archivedPruner returns a Pruner over a company acme, archived to endpoint, with one expired day of data.
+func archivedPruner(t *testing.T, endpoint string) (*Pruner, string) {
+	base := t.TempDir()
+	expired := filepath.Join(base, "acme", "2020", "01", "01")
+	if err := os.MkdirAll(expired, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(expired, "data"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{CompanyConfigs: []CompanyConfig{{
+		Id:        "acme",
+		Retention: "30",
+		Layout:    "{year}/{month}/{day}",
+		Archive:   &archive.Config{Type: "s3", Bucket: "bucket", Endpoint: endpoint},
+	}}}
+	p := New(base, config)
+	p.Clock = FixedClock(testNow)
+	p.Log = quietLogger()
+	return p, expired
+}
+
+func TestRunArchives(t *testing.T) {
+	var uploads int32
+	srv := archiveServer(t, &uploads)
+	p, expired := archivedPruner(t, srv.URL)
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if uploads != 1 {
+		t.Errorf("got %d uploads, want 1", uploads)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed: %v", expired, err)
+	}
+}
+
+func TestPurgeDoesNotArchive(t *testing.T) {
+	var uploads int32
+	srv := archiveServer(t, &uploads)
+	p, expired := archivedPruner(t, srv.URL)
+	if _, err := p.Purge(context.Background(), "acme", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if uploads != 0 {
+		t.Errorf("purge made %d uploads, want none", uploads)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("%s was not purged: %v", expired, err)
+	}
+}
+
+func TestPurgeIgnoresMarkers(t *testing.T) {
+	for _, before := range []time.Time{{}, testNow} {
+		fsys := &MemFS{}
+		fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+		fsys.WriteFile("/data/acme/2020/01/01/"+keepMarker, nil, testNow)
+		fsys.WriteFile("/data/acme/2020/02/01/data", []byte("data"), testNow)
+		fsys.WriteFile("/data/acme/2020/02/"+retentionMarker, []byte("never"), testNow)
+		stats, err := memPruner(fsys, dailyConfig(nil)).Purge(context.Background(), "acme", before)
+		if err != nil {
+			t.Errorf("purge before %v: %v", before, err)
+		}
+		// A company's right to have its data deleted outweighs whoever pinned it; only legal hold doesn't.
+		checkExists(t, fsys, false, "/data/acme/2020/01/01", "/data/acme/2020/02/01")
+		if len(stats.KeptPaths) != 0 {
+			t.Errorf("purge before %v kept %q", before, stats.KeptPaths)
+		}
+	}
+}
+
+func TestPurgeReportsWhatItKeeps(t *testing.T) {
+	for _, before := range []time.Time{{}, testNow} {
+		fsys := &MemFS{}
+		fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+		fsys.WriteFile("/data/acme/2020/02/01/data", []byte("data"), testNow)
+		config := dailyConfig(func(company *CompanyConfig) { company.ExcludePaths = []string{"2020/02/**"} })
+		stats, err := memPruner(fsys, config).Purge(context.Background(), "acme", before)
+		if !errors.Is(err, ErrPurgeIncomplete) {
+			t.Errorf("purge before %v got %v, want %v", before, err, ErrPurgeIncomplete)
+		}
+		checkExists(t, fsys, false, "/data/acme/2020/01/01")
+		checkExists(t, fsys, true, "/data/acme/2020/02/01/data")
+		if len(stats.KeptPaths) != 1 || !strings.HasPrefix(stats.KeptPaths[0], "/data/acme/2020") {
+			t.Errorf("purge before %v kept %q, want the excluded directory", before, stats.KeptPaths)
+		}
+	}
+}
+
+func TestPurgeRefusesLegalHold(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2020/01/01/data", []byte("data"), testNow)
+	held := true
+	config := dailyConfig(func(company *CompanyConfig) { company.LegalHold = &held })
+	if _, err := memPruner(fsys, config).Purge(context.Background(), "acme", time.Time{}); err == nil {
+		t.Error("purge of a company under legal hold got no error")
+	}
+	checkExists(t, fsys, true, "/data/acme/2020/01/01/data")
+}
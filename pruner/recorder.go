@@ -0,0 +1,53 @@
+package pruner
+
+import (
+	"time"
+)
+
+// Recorder receives pruning events, typically to export them as metrics. Implementations must be safe for
+// concurrent use, since companies are pruned in parallel.
+type Recorder interface {
+	// DirDeleted is called after a directory and everything below it, files regular files in all, has been removed.
+	DirDeleted(company string, bytes int64, files int)
+	// FileDeleted is called after a single file has been removed.
+	FileDeleted(company string, bytes int64)
+	// Error is called for every error that stopped a directory or company from being pruned.
+	Error(company string)
+	// CompanyDone is called when a pass over a company finishes. success is false if any error was recorded.
+	CompanyDone(company string, duration time.Duration, success bool)
+}
+
+// NopRecorder is a Recorder that discards everything.
+type NopRecorder struct{}
+
+func (NopRecorder) DirDeleted(string, int64, int)           {}
+func (NopRecorder) FileDeleted(string, int64)               {}
+func (NopRecorder) Error(string)                            {}
+func (NopRecorder) CompanyDone(string, time.Duration, bool) {}
+
+// MultiRecorder passes every event to each of its Recorders in turn.
+type MultiRecorder []Recorder
+
+func (m MultiRecorder) DirDeleted(company string, bytes int64, files int) {
+	for _, r := range m {
+		r.DirDeleted(company, bytes, files)
+	}
+}
+
+func (m MultiRecorder) FileDeleted(company string, bytes int64) {
+	for _, r := range m {
+		r.FileDeleted(company, bytes)
+	}
+}
+
+func (m MultiRecorder) Error(company string) {
+	for _, r := range m {
+		r.Error(company)
+	}
+}
+
+func (m MultiRecorder) CompanyDone(company string, duration time.Duration, success bool) {
+	for _, r := range m {
+		r.CompanyDone(company, duration, success)
+	}
+}
@@ -0,0 +1,48 @@
+package pruner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteJSON writes the summary as an indented JSON document.
+func (s Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WriteCSV writes the summary as CSV with a header row and one row per company.
+func (s Summary) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"run_id", "company_id", "cutoff", "dirs_scanned", "dirs_deleted", "files_deleted", "dirs_trashed", "bytes_freed", "errors", "dirs_excluded", "dirs_unparsed", "legal_hold", "completed", "files_removed", "base_dir", "correlation_id"})
+	for _, stats := range s.Companies {
+		cutoff := ""
+		if !stats.Cutoff.IsZero() {
+			cutoff = stats.Cutoff.Format(time.RFC3339)
+		}
+		out.Write([]string{
+			s.RunID,
+			stats.Company,
+			cutoff,
+			strconv.Itoa(stats.DirsScanned),
+			strconv.Itoa(stats.DirsDeleted),
+			strconv.Itoa(stats.FilesDeleted),
+			strconv.Itoa(stats.DirsTrashed),
+			strconv.FormatInt(stats.BytesFreed, 10),
+			strconv.Itoa(stats.Errors),
+			strconv.Itoa(stats.DirsExcluded),
+			strconv.Itoa(stats.DirsUnparsed),
+			strconv.FormatBool(stats.LegalHold),
+			strconv.FormatBool(stats.Completed),
+			strconv.Itoa(stats.FilesRemoved),
+			stats.BaseDir,
+			s.CorrelationID,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
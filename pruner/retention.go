@@ -0,0 +1,80 @@
+package pruner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention is how long a company's data is kept. Whole days and weeks are applied as calendar days so that
+// retention doesn't drift across DST changes; anything finer is applied as an exact duration. BusinessDays count
+// only Mondays to Fridays. Never keeps data forever.
+type Retention struct {
+	Days         int
+	BusinessDays int
+	Duration     time.Duration
+	Never        bool
+}
+
+// ParseRetention parses a retention value. A bare number is a count of days, as in the original config format.
+// A number with a "d" or "w" suffix is a count of days or weeks, one with "bd" a count of business days, and
+// anything else is parsed as a Go duration ("36h"). "never" or "infinite" keeps data forever.
+func ParseRetention(value string) (Retention, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "never") || strings.EqualFold(value, "infinite") {
+		return Retention{Never: true}, nil
+	}
+	if days, err := strconv.Atoi(value); err == nil {
+		return checkRetention(value, Retention{Days: days})
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(value, "bd")); err == nil && strings.HasSuffix(value, "bd") {
+		return checkRetention(value, Retention{BusinessDays: n})
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") {
+		return checkRetention(value, Retention{Days: n})
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(value, "w")); err == nil && strings.HasSuffix(value, "w") {
+		return checkRetention(value, Retention{Days: 7 * n})
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return Retention{}, fmt.Errorf("retention [%s] is not a number of days, weeks, or a duration", value)
+	}
+	return checkRetention(value, Retention{Duration: duration})
+}
+
+func checkRetention(value string, retention Retention) (Retention, error) {
+	if retention.Days < 0 || retention.BusinessDays < 0 || retention.Duration < 0 {
+		return Retention{}, fmt.Errorf("retention [%s] is negative", value)
+	}
+	return retention, nil
+}
+
+// Cutoff returns the time before which data has expired, the zero time if it never does.
+func (r Retention) Cutoff(now time.Time) time.Time {
+	if r.Never {
+		return time.Time{}
+	}
+	cutoff := now.AddDate(0, 0, -r.Days).Add(-r.Duration)
+	for n := r.BusinessDays; n > 0; {
+		cutoff = cutoff.AddDate(0, 0, -1)
+		if !weekend(cutoff) {
+			n--
+		}
+	}
+	return cutoff
+}
+
+func (r Retention) String() string {
+	if r.Never {
+		return "never"
+	}
+	if r.Duration != 0 {
+		return r.Duration.String()
+	}
+	if r.BusinessDays != 0 {
+		return fmt.Sprintf("%dbd", r.BusinessDays)
+	}
+	return fmt.Sprintf("%dd", r.Days)
+}
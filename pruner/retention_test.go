@@ -0,0 +1,100 @@
+package pruner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  Retention
+	}{
+		{"30", Retention{Days: 30}},
+		{" 30d ", Retention{Days: 30}},
+		{"2w", Retention{Days: 14}},
+		{"10bd", Retention{BusinessDays: 10}},
+		{"36h", Retention{Duration: 36 * time.Hour}},
+		{"90m", Retention{Duration: 90 * time.Minute}},
+		{"0", Retention{}},
+		{"never", Retention{Never: true}},
+		{"Infinite", Retention{Never: true}},
+	} {
+		if got, err := ParseRetention(test.value); err != nil || got != test.want {
+			t.Errorf("%q: got %+v, %v, want %+v", test.value, got, err, test.want)
+		}
+	}
+	for _, value := range []string{"", "-1", "-2w", "-36h", "30x", "d", "soon"} {
+		if got, err := ParseRetention(value); err == nil {
+			t.Errorf("%q: got %+v, want an error", value, got)
+		}
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	// testNow is a Friday.
+	for _, test := range []struct {
+		value string
+		want  time.Time
+	}{
+		{"30", time.Date(2020, 12, 2, 0, 0, 0, 0, time.UTC)},
+		{"1w", time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC)},
+		{"36h", time.Date(2020, 12, 30, 12, 0, 0, 0, time.UTC)},
+		{"5bd", time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC)},
+		{"never", time.Time{}},
+	} {
+		retention, _ := ParseRetention(test.value)
+		if got := retention.Cutoff(testNow); !got.Equal(test.want) {
+			t.Errorf("%s: got cutoff %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestSubDayRetention(t *testing.T) {
+	fsys := &MemFS{}
+	for _, dir := range []string{"2020/12/29/23", "2020/12/30/10", "2020/12/30/12", "2020/12/31/05"} {
+		fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+	}
+	config := dailyConfig(func(company *CompanyConfig) {
+		company.Retention = "36h"
+		company.Layout = "{year}/{month}/{day}/{hour}"
+	})
+	removed, _ := removedPaths(t, memPruner(fsys, config))
+	if want := "[2020/12/29 2020/12/30/10]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %q, want %s", removed, want)
+	}
+}
+
+// TestRetentionMarkerOnlyExtends expects a .retention file to keep its directory longer than the config does, and
+// to be ignored where it would keep it for less.
+func TestRetentionMarkerOnlyExtends(t *testing.T) {
+	fsys := &MemFS{}
+	for _, dir := range []string{"2020/08/15", "2020/09/15", "2020/10/01", "2020/10/15", "2020/11/15", "2020/12/01", "2020/12/20"} {
+		fsys.WriteFile("/data/acme/"+dir+"/data", []byte("data"), testNow)
+	}
+	for dir, retention := range map[string]string{
+		"2020/09": "1w",
+		"2020/10": "90",
+		"2020/11": "never",
+		"2020/12": "1d",
+	} {
+		fsys.WriteFile("/data/acme/"+dir+"/"+retentionMarker, []byte(retention+"\n"), testNow)
+	}
+	removed, stats := removedPaths(t, memPruner(fsys, dailyConfig(nil)))
+	// The month holding an ignored marker is judged by its days, as the marker file itself is never removed.
+	if want := "[2020/08 2020/09/15 2020/10/01 2020/12/01]"; fmt.Sprint(removed) != want || stats.Errors != 0 {
+		t.Errorf("removed %q with %d errors, want %s and none", removed, stats.Errors, want)
+	}
+	checkExists(t, fsys, true, "/data/acme/2020/10/15/data", "/data/acme/2020/11/15/data", "/data/acme/2020/12/20/data")
+}
+
+func TestInvalidRetentionMarkerKeepsItsDirectory(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/data/acme/2020/09/15/data", []byte("data"), testNow)
+	fsys.WriteFile("/data/acme/2020/09/"+retentionMarker, []byte("a while"), testNow)
+	removed, stats := removedPaths(t, memPruner(fsys, dailyConfig(nil)))
+	if len(removed) != 0 || stats.Errors != 1 {
+		t.Errorf("removed %q with %d errors, want nothing and an error", removed, stats.Errors)
+	}
+}
@@ -0,0 +1,142 @@
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// pendingRemoval is a removal to try again in the retry sweep.
+type pendingRemoval struct {
+	path  string
+	isDir bool
+	size  int64
+	files int
+	log   log.FieldLogger
+}
+
+// removeAll removes path, retrying transient failures up to Retries times with exponential backoff from
+// RetryBackoff. It gives up early if the pass is being shut down.
+func (c *companyRun) removeAll(path string) (err error) {
+	retries := 0
+	c.startRemoveSpan()
+	defer func() { c.countRemoval(retries, err) }()
+	if c.p.Journal != nil {
+		if err := c.p.Journal.Begin(c.runID, c.stats.Company, path); err != nil {
+			return fmt.Errorf("writing journal: %v", err)
+		}
+	}
+	if c.p.Journal != nil {
+		defer func() {
+			if err != nil {
+				// Close the removal, so the next run judges what is left of path afresh rather than finishing it.
+				if err := c.p.Journal.Failed(c.runID, c.stats.Company, path); err != nil {
+					c.log.WithField("path", path).WithError(err).Warnln("Could not record failed removal in the journal")
+				}
+			}
+		}()
+	}
+	backoff := c.p.RetryBackoff
+	err = c.fs.RemoveAll(path)
+	for attempt := 1; err != nil && attempt <= c.p.Retries && retryable(err); attempt++ {
+		c.log.WithField("path", path).WithField("attempt", attempt).WithError(err).Debugln("Removal failed, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return err
+		}
+		backoff *= 2
+		retries = attempt
+		err = c.fs.RemoveAll(path)
+	}
+	if err == nil && c.p.Journal != nil {
+		c.p.Journal.finished(path)
+		if err := c.p.Journal.Done(c.runID, c.stats.Company, path); err != nil {
+			c.log.WithField("path", path).WithError(err).Warnln("Removed, but could not record it in the journal")
+		}
+	}
+	return err
+}
+
+// removeSpans holds the span covering a company's removals and what has been counted in it. Paths are counted
+// rather than given a span each, which on a big company would be more than the exporter's queue holds.
+type removeSpans struct {
+	span     Span
+	removals int
+	retries  int
+	failures int
+}
+
+// startRemoveSpan starts the span covering the company's removals on the first of them, so that a company with
+// nothing to remove has none.
+func (c *companyRun) startRemoveSpan() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.removeSpans.span == nil {
+		_, c.removeSpans.span = c.p.tracer().Start(c.ctx, "deleter.remove", map[string]interface{}{"deleter.company_id": c.stats.Company})
+	}
+}
+
+// countRemoval counts a removal that took retries retries and ended with err in the span covering the removals.
+func (c *companyRun) countRemoval(retries int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeSpans.removals++
+	c.removeSpans.retries += retries
+	if err != nil {
+		c.removeSpans.failures++
+	}
+}
+
+// endRemoveSpan ends the span covering the company's removals, if there were any, with how many there were, as
+// failed if any of them failed.
+func (c *companyRun) endRemoveSpan() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spans := c.removeSpans
+	if spans.span == nil {
+		return
+	}
+	spans.span.SetAttribute("deleter.removals", spans.removals)
+	spans.span.SetAttribute("deleter.removal_retries", spans.retries)
+	spans.span.SetAttribute("deleter.removal_failures", spans.failures)
+	var err error
+	if spans.failures > 0 {
+		err = fmt.Errorf("%d of %d removals failed", spans.failures, spans.removals)
+	}
+	spans.span.End(err)
+	c.removeSpans = removeSpans{}
+}
+
+// retrySweep makes a last attempt at the removals that failed during the pass, after everything else is done, and
+// counts the ones that still fail as errors.
+func (c *companyRun) retrySweep() {
+	pending := c.retryLater
+	c.retryLater = nil
+	for _, removal := range pending {
+		err := c.ctx.Err()
+		if err == nil {
+			err = c.removeAll(removal.path)
+		}
+		if err != nil {
+			c.error(removal.path, "Error removing path, giving up", err)
+			c.stats.FailedPaths = append(c.stats.FailedPaths, removal.path)
+			continue
+		}
+		c.removed(removal.path, removal.isDir, removal.size, removal.files, removal.log)
+	}
+}
+
+// retryable reports whether a removal error may go away by itself: a busy file, or a stale or timed out network
+// filesystem. NFS clients also report a directory as not empty while files in it are still being released.
+func retryable(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EBUSY, syscall.EAGAIN, syscall.EINTR, syscall.EIO, syscall.ESTALE, syscall.ETIMEDOUT, syscall.ENOTEMPTY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
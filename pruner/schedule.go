@@ -0,0 +1,112 @@
+package pruner
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxScheduleSleep bounds how long RunScheduled sleeps, so new company directories and config reloads are noticed
+// even when the next scheduled pass is far away.
+const maxScheduleSleep = time.Minute
+
+// ParseSchedule parses a schedule given either as a Go duration ("6h") or a standard five field cron expression.
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	if interval, err := time.ParseDuration(spec); err == nil {
+		return cron.Every(interval), nil
+	}
+	return cron.ParseStandard(spec)
+}
+
+type companySchedule struct {
+	spec string
+	next time.Time
+}
+
+// RunScheduled prunes each company on its own schedule until ctx is cancelled. Companies whose config has no schedule
+// use the default config's schedule, and defaultSchedule if that is empty too. Every company is pruned once at startup,
+// and again whenever Trigger asks for it.
+func (p *Pruner) RunScheduled(ctx context.Context, defaultSchedule string) error {
+	schedules := make(map[string]*companySchedule)
+	triggered := make(map[string]bool)
+	triggerAll := false
+	for {
+		companies, err := p.companyDirs()
+		if err != nil {
+			p.Log.Errorln("Could not read base directory, will retry.", err)
+		}
+		configMaps := p.configMaps(p.Config())
+		now := p.Clock.Now()
+		wake := now.Add(maxScheduleSleep)
+		var due []companyDir
+		for _, company := range companies {
+			spec := scheduleSpec(configMaps[company.base], company.name, defaultSchedule)
+			schedule, err := ParseSchedule(spec)
+			if err != nil {
+				p.Log.Errorf("Invalid schedule [%s] for company %s, skipping : %+v", spec, company.key(), err)
+				continue
+			}
+			entry, exists := schedules[company.key()]
+			if !exists {
+				entry = &companySchedule{spec: spec, next: now}
+				schedules[company.key()] = entry
+			} else if entry.spec != spec {
+				entry.spec = spec
+				entry.next = schedule.Next(now)
+			}
+			if !entry.next.After(now) || triggerAll || triggered[company.name] || triggered[company.key()] {
+				due = append(due, company)
+				entry.next = schedule.Next(now)
+			}
+			if entry.next.Before(wake) {
+				wake = entry.next
+			}
+		}
+		triggered, triggerAll = make(map[string]bool), false
+		if len(due) > 0 {
+			p.Log.Infof("Running scheduled pass for %d companies", len(due))
+			summary, err := p.runCompanies(ctx, due, p.Recorder)
+			p.Log.WithField("run_id", summary.RunID).Infoln(summary)
+			if p.AfterPass != nil {
+				p.AfterPass(summary)
+			}
+			if err != nil && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if summary.WindowClosed {
+				// Finish the rest as soon as the window opens again.
+				for _, stats := range summary.Companies {
+					if !stats.Completed {
+						triggered[stats.key()] = true
+					}
+				}
+			}
+			continue
+		}
+		timer := time.NewTimer(wake.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		case companies := <-p.triggers:
+			timer.Stop()
+			p.Log.WithField("companies", companies).Infoln("Pass triggered")
+			triggerAll = len(companies) == 0
+			for _, company := range companies {
+				triggered[company] = true
+			}
+		}
+	}
+}
+
+func scheduleSpec(configMap map[string]CompanyConfig, company string, defaultSchedule string) string {
+	if spec := companyConfig(configMap, company).Schedule; spec != "" {
+		return spec
+	}
+	if spec := configMap["default"].Schedule; spec != "" {
+		return spec
+	}
+	return defaultSchedule
+}
@@ -0,0 +1,170 @@
+package pruner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/moriarty-s3a/deleter/sftp"
+)
+
+// sftpClient returns the Pruner's client for config's server, sharing one connection between the companies and
+// passes that use the same login.
+func (p *Pruner) sftpClient(config sftp.Config) *sftp.Client {
+	p.sftpMu.Lock()
+	defer p.sftpMu.Unlock()
+	key := config.Connection()
+	client, ok := p.sftpClients[key]
+	if !ok {
+		if p.sftpClients == nil {
+			p.sftpClients = make(map[string]*sftp.Client)
+		}
+		client = sftp.NewClient(config)
+		p.sftpClients[key] = client
+	}
+	return client
+}
+
+// sftpFS is a FileSystem over the remote directory base, with root standing for it. Symlinks are never followed.
+type sftpFS struct {
+	client *sftp.Client
+	root   string
+	base   string
+}
+
+// remote returns the remote path name stands for.
+func (s sftpFS) remote(name string) (string, error) {
+	rel, err := filepath.Rel(s.root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%s is outside %s", name, s.root)
+	}
+	return path.Join(s.base, filepath.ToSlash(rel)), nil
+}
+
+func (s sftpFS) ReadDir(dirname string) ([]os.DirEntry, error) {
+	remote, err := s.remote(dirname)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := s.client.ReadDir(remote)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]os.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+func (s sftpFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return readDirWalk(s, root, fn)
+}
+
+// Stat doesn't follow symlinks, so that walks never leave the tree.
+func (s sftpFS) Stat(name string) (os.FileInfo, error) {
+	remote, err := s.remote(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Lstat(remote)
+}
+
+func (s sftpFS) ReadFile(name string) ([]byte, error) {
+	remote, err := s.remote(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.ReadFile(remote)
+}
+
+func (s sftpFS) EvalSymlinks(name string) (string, error) {
+	return name, nil
+}
+
+// RemoveAll removes name and everything below it, one remote request per file or directory.
+func (s sftpFS) RemoveAll(name string) error {
+	remote, err := s.remote(name)
+	if err != nil {
+		return err
+	}
+	if remote == s.base {
+		return fmt.Errorf("refusing to remove everything under %s", s.root)
+	}
+	err = s.removeAll(remote)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s sftpFS) removeAll(remote string) error {
+	info, err := s.client.Lstat(remote)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return s.client.Remove(remote)
+	}
+	infos, err := s.client.ReadDir(remote)
+	if err != nil {
+		return err
+	}
+	for _, child := range infos {
+		if err := s.removeAll(path.Join(remote, child.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.client.RemoveDir(remote)
+}
+
+func (s sftpFS) Rename(oldpath, newpath string) error {
+	oldRemote, err := s.remote(oldpath)
+	if err != nil {
+		return err
+	}
+	newRemote, err := s.remote(newpath)
+	if err != nil {
+		return err
+	}
+	return s.client.Rename(oldRemote, newRemote)
+}
+
+// MkdirAll creates name and any parents it needs. perm is left to the server's umask.
+func (s sftpFS) MkdirAll(name string, perm os.FileMode) error {
+	remote, err := s.remote(name)
+	if err != nil {
+		return err
+	}
+	return s.mkdirAll(remote)
+}
+
+func (s sftpFS) mkdirAll(remote string) error {
+	info, err := s.client.Lstat(remote)
+	if err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: remote, Err: fmt.Errorf("not a directory")}
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if parent := path.Dir(remote); parent != remote {
+		if err := s.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	return s.client.Mkdir(remote)
+}
+
+func (s sftpFS) DiskSpace(name string) (uint64, uint64, error) {
+	remote, err := s.remote(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.client.StatVFS(remote)
+}
@@ -0,0 +1,59 @@
+package pruner
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// ringReplicas is how many points each member gets on a Ring, to spread companies evenly.
+const ringReplicas = 100
+
+// Ring assigns companies to the members of a group of instances by consistent hashing, so that adding or removing an
+// instance only moves the companies it gains or loses.
+type Ring struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewRing returns a Ring over members.
+func NewRing(members []string) *Ring {
+	r := &Ring{owners: make(map[uint32]string)}
+	for _, member := range members {
+		for i := 0; i < ringReplicas; i++ {
+			point := crc32.ChecksumIEEE([]byte(member + "#" + strconv.Itoa(i)))
+			if _, taken := r.owners[point]; !taken {
+				r.points = append(r.points, point)
+				r.owners[point] = member
+			}
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Owner returns the member key belongs to, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// ErrLockLost is the cause of a lock's context being cancelled when the lock is lost before it was unlocked.
+var ErrLockLost = errors.New("lock was lost")
+
+// Locker takes locks that keep instances sharing BaseDir from pruning the same company at once.
+type Locker interface {
+	// TryLock takes the lock named key unless someone else holds it, in which case ok is false. The lock is held
+	// until unlock is called. lockCtx is derived from ctx and is cancelled with ErrLockLost as its cause if the
+	// lock is lost before then, so that the work it guards can stop before another instance takes it over.
+	TryLock(ctx context.Context, key string) (lockCtx context.Context, unlock func(), ok bool, err error)
+}
@@ -0,0 +1,88 @@
+package pruner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// LoadSQLCompanies runs query against db and returns one company config per row. Columns are matched to config
+// fields by name, ignoring case and underscores, so company_id and companyId both fill Id. NULL columns leave their
+// field unset and excludePaths is comma-separated. A row whose id is "default" is the default entry.
+func LoadSQLCompanies(ctx context.Context, db *sql.DB, query string) ([]CompanyConfig, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var companies []CompanyConfig
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		var entry CompanyConfig
+		for i, column := range columns {
+			if !values[i].Valid {
+				continue
+			}
+			if err := setColumn(&entry, column, values[i].String); err != nil {
+				return nil, fmt.Errorf("row %d: column %s: %v", len(companies)+1, column, err)
+			}
+		}
+		if entry.Id == "" {
+			return nil, fmt.Errorf("row %d: no companyId", len(companies)+1)
+		}
+		companies = append(companies, entry)
+	}
+	return companies, rows.Err()
+}
+
+// MergeCompanies returns config with entries added or, where the ids match, replaced. An entry with the id "default"
+// replaces the default entry.
+func MergeCompanies(config Config, entries []CompanyConfig) Config {
+	merged := config
+	merged.CompanyConfigs = nil
+	index := make(map[string]int)
+	for _, entry := range config.CompanyConfigs {
+		index[entry.Id] = len(merged.CompanyConfigs)
+		merged.CompanyConfigs = append(merged.CompanyConfigs, entry)
+	}
+	for _, entry := range entries {
+		if entry.Id == "default" {
+			merged.DefaultConfig = entry
+			continue
+		}
+		if i, exists := index[entry.Id]; exists {
+			merged.CompanyConfigs[i] = entry
+			continue
+		}
+		index[entry.Id] = len(merged.CompanyConfigs)
+		merged.CompanyConfigs = append(merged.CompanyConfigs, entry)
+	}
+	return merged
+}
+
+// setColumn sets the field of entry whose JSON name matches column.
+func setColumn(entry *CompanyConfig, column string, value string) error {
+	normalize := func(name string) string { return strings.ToLower(strings.Replace(name, "_", "", -1)) }
+	v := reflect.ValueOf(entry).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && normalize(name) == normalize(column) {
+			return setValue(v.Field(i), value)
+		}
+	}
+	return fmt.Errorf("no config field of that name")
+}
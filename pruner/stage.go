@@ -0,0 +1,91 @@
+package pruner
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// stager deletes, in the background, the paths StageRemovals renamed into a company's trash.
+type stager struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []string
+	closed bool
+	done   chan struct{}
+}
+
+// staging reports whether expired paths are staged for background deletion rather than removed in place.
+func (c *companyRun) staging() bool {
+	return c.p.StageRemovals && c.p.TrashGrace <= 0 && !c.permanent && !c.dryRun && c.config.Storage == nil
+}
+
+// stage renames path into the company's trash, where walks don't look, and queues it for the background deleter,
+// starting that on first use.
+func (c *companyRun) stage(path string) error {
+	target, err := c.moveToTrash(path)
+	if err != nil {
+		return err
+	}
+	// Workers stage at once, so the first of them to get here starts the one stager finishStaging waits for.
+	c.mu.Lock()
+	if c.stager == nil {
+		c.stager = &stager{done: make(chan struct{})}
+		c.stager.cond = sync.NewCond(&c.stager.mu)
+		go c.drainStaged(c.stager)
+	}
+	s := c.stager
+	c.mu.Unlock()
+	s.mu.Lock()
+	s.queue = append(s.queue, target)
+	s.mu.Unlock()
+	s.cond.Signal()
+	return nil
+}
+
+// drainStaged deletes staged paths in the order they were staged, paced by MaxDeletesPerSecond, until finishStaging
+// is called and the queue is empty. Once the pass is stopped it leaves what is still queued in the trash, for the
+// next pass to delete.
+func (c *companyRun) drainStaged(s *stager) {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		path := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		if c.ctx.Err() != nil || c.p.pace(c.ctx) != nil {
+			continue
+		}
+		if err := c.fs.RemoveAll(path); err != nil {
+			c.error(path, "Error deleting staged path, leaving it in the trash", err)
+		}
+	}
+}
+
+// finishStaging waits for everything staged to be deleted, then removes the pass's directory in the trash if all
+// that is left in it is the empty directories staged paths were moved out of.
+func (c *companyRun) finishStaging() {
+	c.mu.Lock()
+	s := c.stager
+	c.mu.Unlock()
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+	<-s.done
+	stamped := filepath.Join(c.dir, trashDirName, c.now.UTC().Format(trashTimeLayout))
+	if _, files, err := c.dirSize(stamped); err == nil && files == 0 {
+		if err := c.fs.RemoveAll(stamped); err != nil {
+			c.log.WithField("path", stamped).WithError(err).Warnln("Could not remove emptied staging directory")
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package pruner
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStagedRemovalsWithWorkers(t *testing.T) {
+	config := Config{CompanyConfigs: []CompanyConfig{{Id: "acme", Retention: "30", Workers: 8}}}
+	fsys := deepTree()
+	p := memPruner(fsys, config)
+	p.StageRemovals = true
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := summary.Companies[0]
+	if stats.DirsDeleted != 13 || stats.Errors != 0 {
+		t.Errorf("removed %d directories with %d errors, want 13 and none", stats.DirsDeleted, stats.Errors)
+	}
+	// Run only returns once everything staged is deleted, by the one stager the workers share.
+	checkExists(t, fsys, false, "/data/acme/cam/2019", "/data/acme/cam/2020/11", "/data/acme/cam/2020/12/01", "/data/acme/"+trashDirName+"/"+testNow.Format(trashTimeLayout))
+	checkExists(t, fsys, true, "/data/acme/cam/2020/12/02", "/data/acme/cam/2020/12/03")
+}
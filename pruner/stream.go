@@ -0,0 +1,207 @@
+package pruner
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// defaultWalkBatch is how many directory entries a walk holds at once for each directory it is reading, unless
+// MaxMemory says otherwise. It is what os.RemoveAll reads at a time.
+const defaultWalkBatch = 1024
+
+// walkBatch sizes the batches from MaxMemory: a quarter of it is shared between Workers walks, each of them reading
+// walkDepthEstimate directories at once, for entries of about walkEntryCost bytes. The rest is left for the
+// runtime and everything else a pass keeps.
+const (
+	walkDepthEstimate = 8
+	walkEntryCost     = 512
+	minWalkBatch      = 64
+	maxWalkBatch      = 65536
+)
+
+// dirStream reads a directory a batch at a time. next returns io.EOF, and no entries, once there are no more.
+type dirStream interface {
+	next() ([]os.DirEntry, error)
+	close()
+}
+
+// dirStreamer is a FileSystem whose directories can be read a batch at a time, so that walking a directory of
+// millions of files doesn't mean holding all of them.
+type dirStreamer interface {
+	openDir(name string) (dirStream, error)
+}
+
+// batchedFileSystem is a FileSystem whose batch size can be set.
+type batchedFileSystem interface {
+	withBatch(entries int) FileSystem
+}
+
+// walkBatch returns how many directory entries each directory being read may hold at once.
+func (p *Pruner) walkBatch() int {
+	if p.MaxMemory <= 0 {
+		return defaultWalkBatch
+	}
+	walks := int64(p.Workers)
+	if walks <= 0 {
+		walks = 16
+	}
+	batch := p.MaxMemory / 4 / (walks * walkDepthEstimate * walkEntryCost)
+	if batch < minWalkBatch {
+		return minWalkBatch
+	}
+	if batch > maxWalkBatch {
+		return maxWalkBatch
+	}
+	return int(batch)
+}
+
+// localFS returns FS, reading directories in batches of walkBatch if it can.
+func (p *Pruner) localFS() FileSystem {
+	if batched, ok := p.FS.(batchedFileSystem); ok {
+		return batched.withBatch(p.walkBatch())
+	}
+	return p.FS
+}
+
+// osDirStream reads a directory with os.File.ReadDir.
+type osDirStream struct {
+	f     *os.File
+	batch int
+}
+
+func (s osDirStream) next() ([]os.DirEntry, error) {
+	return s.f.ReadDir(s.batch)
+}
+
+func (s osDirStream) close() {
+	s.f.Close()
+}
+
+// walkStreamed walks the tree at root, whose Lstat gave info and err, as filepath.WalkDir does, except that a
+// directory with more entries than fit in a batch is walked a batch at a time: the files of each batch in lexical
+// order as it is read, then the directories of all of them in lexical order. Only the directories are held on to, so
+// memory stays bounded however many files a directory has. Smaller directories are walked in lexical order.
+func walkStreamed(fsys dirStreamer, root string, info fs.FileInfo, err error, fn fs.WalkDirFunc) error {
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = streamEntries(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func streamEntries(fsys dirStreamer, name string, entry fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, entry, nil); err != nil || !entry.IsDir() {
+		return err
+	}
+	walk := func(path string, child fs.DirEntry) error { return streamEntries(fsys, path, child, fn) }
+	stream, err := fsys.openDir(name)
+	if err != nil {
+		return walkError(name, entry, fn, err)
+	}
+	first, err := stream.next()
+	var more []os.DirEntry
+	if err == nil {
+		more, err = stream.next()
+	}
+	if len(more) == 0 {
+		// It all fit in one batch, so the whole directory can be walked in order, as filepath.WalkDir would.
+		stream.close()
+		if err != nil && err != io.EOF {
+			if err := walkError(name, entry, fn, err); err != nil {
+				return err
+			}
+		}
+		sortEntries(first)
+		return walkChildren(name, first, walk)
+	}
+	var dirs []os.DirEntry
+	for batch := first; len(batch) > 0; {
+		sortEntries(batch)
+		for _, child := range batch {
+			if child.IsDir() {
+				dirs = append(dirs, child)
+				continue
+			}
+			if err := walk(filepath.Join(name, child.Name()), child); err != nil {
+				stream.close()
+				if err == filepath.SkipDir {
+					// Skip the rest of the directory.
+					return nil
+				}
+				return err
+			}
+		}
+		if more != nil {
+			batch, more = more, nil
+			continue
+		}
+		if batch, err = stream.next(); err != nil && err != io.EOF {
+			stream.close()
+			if err := walkError(name, entry, fn, err); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	stream.close()
+	sortEntries(dirs)
+	return walkChildren(name, dirs, walk)
+}
+
+// walkError gives fn a second call for the directory name, with the error reading it, as filepath.WalkDir does.
+func walkError(name string, entry fs.DirEntry, fn fs.WalkDirFunc, err error) error {
+	if err := fn(name, entry, err); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// walkChildren walks each of the entries of the directory name with walk, handling SkipDir as filepath.WalkDir does.
+func walkChildren(name string, entries []os.DirEntry, walk func(path string, entry fs.DirEntry) error) error {
+	for _, child := range entries {
+		if err := walk(filepath.Join(name, child.Name()), child); err != nil {
+			if err == filepath.SkipDir {
+				if !child.IsDir() {
+					// Skip the rest of the directory.
+					return nil
+				}
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func sortEntries(entries []os.DirEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+}
+
+// dirEmpty reports whether the directory dir has nothing in it, reading no more of it than it has to.
+func dirEmpty(fsys FileSystem, dir string) (bool, error) {
+	streamer, ok := fsys.(dirStreamer)
+	if !ok {
+		entries, err := fsys.ReadDir(dir)
+		return len(entries) == 0, err
+	}
+	stream, err := streamer.openDir(dir)
+	if err != nil {
+		return false, err
+	}
+	defer stream.close()
+	entries, err := stream.next()
+	if err == io.EOF {
+		return true, nil
+	}
+	return len(entries) == 0, err
+}
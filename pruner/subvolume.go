@@ -0,0 +1,34 @@
+package pruner
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// destroySubvolume destroys path with the btrfs or zfs tool if it is a subvolume or dataset of the kind the company's
+// subvolumes setting looks for, and reports whether it was one.
+func (c *companyRun) destroySubvolume(path string, pathLog log.FieldLogger) (bool, error) {
+	kind, name, err := subvolumeAt(path)
+	if err != nil {
+		return false, err
+	}
+	if kind == "" || (c.config.Subvolumes != SubvolumesAuto && c.config.Subvolumes != kind) {
+		return false, nil
+	}
+	var args []string
+	switch kind {
+	case SubvolumesBtrfs:
+		args = []string{"btrfs", "subvolume", "delete", path}
+	case SubvolumesZFS:
+		// -r takes the dataset's snapshots, and any datasets nested in it, with it.
+		args = []string{"zfs", "destroy", "-r", name}
+	}
+	pathLog.WithField(kind, name).Debugln("Destroying subvolume")
+	if output, err := exec.CommandContext(c.ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("%s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return true, nil
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package pruner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The statfs f_type of btrfs and ZFS, and the inode number of the root directory of every btrfs subvolume.
+const (
+	btrfsMagic       = 0x9123683e
+	zfsMagic         = 0x2fc12fc1
+	btrfsSubvolInode = 256
+)
+
+// subvolumeAt returns SubvolumesBtrfs and path if path is the root of a btrfs subvolume, SubvolumesZFS and the
+// dataset's name if path is where a ZFS dataset is mounted, or "" if it is neither.
+func subvolumeAt(path string) (string, string, error) {
+	var fsStat syscall.Statfs_t
+	if err := syscall.Statfs(path, &fsStat); err != nil {
+		return "", "", err
+	}
+	switch uint32(fsStat.Type) {
+	case btrfsMagic:
+		var stat syscall.Stat_t
+		if err := syscall.Lstat(path, &stat); err != nil {
+			return "", "", err
+		}
+		if stat.Ino == btrfsSubvolInode {
+			return SubvolumesBtrfs, path, nil
+		}
+	case zfsMagic:
+		dataset, err := mountSource(path, "zfs")
+		if err != nil || dataset == "" {
+			return "", "", err
+		}
+		return SubvolumesZFS, dataset, nil
+	}
+	return "", "", nil
+}
+
+// mountSource returns the source, for ZFS the dataset, of the filesystem of type fsType mounted at path, or "" if
+// none is.
+func mountSource(path string, fsType string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	source := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - type source superoptions
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || unescapeMount(fields[4]) != path {
+			continue
+		}
+		for i := 5; i+2 < len(fields); i++ {
+			if fields[i] == "-" {
+				// Later lines are mounts made over earlier ones at the same point.
+				source = ""
+				if fields[i+1] == fsType {
+					source = unescapeMount(fields[i+2])
+				}
+				break
+			}
+		}
+	}
+	return source, scanner.Err()
+}
+
+// unescapeMount undoes the octal escapes of spaces, tabs, newlines and backslashes in mountinfo fields.
+func unescapeMount(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package pruner
+
+// subvolumeAt finds no subvolumes outside Linux.
+func subvolumeAt(path string) (string, string, error) {
+	return "", "", nil
+}
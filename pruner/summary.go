@@ -0,0 +1,235 @@
+package pruner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Summary describes a single pass over the company directories.
+type Summary struct {
+	RunID string `json:"runId"`
+	// CorrelationID is the Pruner's CorrelationID at the time of the pass.
+	CorrelationID string `json:"correlationId,omitempty"`
+	// Start and End are when the pass ran, whatever its Clock said.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// AsOf is the date the pass's cutoffs were computed from, if its Clock was a FixedClock, as it is under -as-of.
+	AsOf        *time.Time `json:"asOf,omitempty"`
+	Interrupted bool       `json:"interrupted"`
+	// Aborted is set when a deletion cap stopped the pass before anything was removed, in which case the stats are
+	// the plan, or when unknownCompanies is "fail" and there were UnknownCompanies.
+	Aborted bool `json:"aborted,omitempty"`
+	// WindowClosed is set when the run window closed before the pass finished.
+	WindowClosed bool `json:"windowClosed,omitempty"`
+	// OutOfTime is set when the pass stopped early because MaxRuntime ran out.
+	OutOfTime bool `json:"outOfTime,omitempty"`
+	// ResumedFrom is the run ID of the pass whose checkpoint this one carried on from.
+	ResumedFrom string `json:"resumedFrom,omitempty"`
+	// UnknownCompanies are the company directories the pass found without a config entry of their own.
+	UnknownCompanies []string `json:"unknownCompanies,omitempty"`
+	// OrphanedEntries are the config entries with no company directory under the base directory.
+	OrphanedEntries []string       `json:"orphanedEntries,omitempty"`
+	Companies       []CompanyStats `json:"companies"`
+}
+
+// CompanyStats describes what a pass did to a single company directory.
+type CompanyStats struct {
+	Company string `json:"companyId"`
+	// BaseDir is the base directory holding the company directory, when there are several.
+	BaseDir     string    `json:"baseDir,omitempty"`
+	Cutoff      time.Time `json:"cutoff"`
+	DirsScanned int       `json:"dirsScanned"`
+	DirsDeleted int       `json:"dirsDeleted"`
+	// FilesDeleted counts individual files removed, as opposed to whole directories.
+	FilesDeleted int `json:"filesDeleted"`
+	// DirsTrashed counts directories moved into the company's trash rather than deleted.
+	DirsTrashed int   `json:"dirsTrashed"`
+	BytesFreed  int64 `json:"bytesFreed"`
+	// FilesRemoved counts the regular files removed, inside removed directories or on their own.
+	FilesRemoved int `json:"filesRemoved"`
+	Errors       int `json:"errors"`
+	// Unknown is set for a company left alone because it has no config entry and unknownCompanies is "skip".
+	Unknown bool `json:"unknown,omitempty"`
+	// FailedPaths are the paths that expired but couldn't be removed.
+	FailedPaths []string `json:"failedPaths,omitempty"`
+	// InterruptedPaths are the paths the Journal showed an earlier run had started removing and not finished, which
+	// the pass finished, or in dry-run mode would have.
+	InterruptedPaths []string `json:"interruptedPaths,omitempty"`
+	// ActivePaths are the expired paths kept because a file in them was modified within skipModifiedWithin.
+	ActivePaths []string `json:"activePaths,omitempty"`
+	// OpenPaths are the expired paths kept because the openFiles check found a file in them in use.
+	OpenPaths []string `json:"openPaths,omitempty"`
+	// KeptPaths are the paths a purge left in place, for whatever reason, such as excludePaths.
+	KeptPaths []string `json:"keptPaths,omitempty"`
+	// DirsExcluded counts expired paths kept because of the company's excludePaths or the protectedPaths, marker files
+	// or other filesystems mounted inside them.
+	DirsExcluded int `json:"dirsExcluded"`
+	// DirsUnparsed counts directories skipped by strict parsing because their names aren't dates.
+	DirsUnparsed int `json:"dirsUnparsed"`
+	// DirsMoved counts expired directories moved to the company's archiveTo rather than removed.
+	DirsMoved int `json:"dirsMoved,omitempty"`
+	// DirsCompressed counts directories replaced by a compressed archive under the company's compressAfter.
+	DirsCompressed int `json:"dirsCompressed,omitempty"`
+	// StrayFiles counts the loose files reported or, if expired, removed under the company's strayFiles policy.
+	StrayFiles int `json:"strayFiles,omitempty"`
+	// LegalHold is set when the company was skipped because it is under legal hold.
+	LegalHold bool `json:"legalHold"`
+	// Exempt is set when the company was skipped because its retention is never.
+	Exempt bool `json:"exempt,omitempty"`
+	// Paused is set when the company was skipped because it was paused through Pause.
+	Paused bool `json:"paused,omitempty"`
+	// Locked is set when the company was skipped because another instance held its lock.
+	Locked bool `json:"locked,omitempty"`
+	// Resumed is set when the company was skipped because the pass being resumed had already finished it.
+	Resumed bool `json:"resumed,omitempty"`
+	// LastPath is the last directory, relative to the company directory, that the pass reached.
+	LastPath string `json:"lastPath,omitempty"`
+	// Completed is false if the pass was interrupted before finishing the company, or never started it.
+	Completed bool `json:"completed"`
+}
+
+// Totals adds up the counters of every company.
+func (s Summary) Totals() CompanyStats {
+	var totals CompanyStats
+	for _, stats := range s.Companies {
+		totals.add(stats)
+	}
+	return totals
+}
+
+// add adds the counters of other to stats.
+func (stats *CompanyStats) add(other CompanyStats) {
+	stats.DirsScanned += other.DirsScanned
+	stats.DirsDeleted += other.DirsDeleted
+	stats.FilesDeleted += other.FilesDeleted
+	stats.DirsTrashed += other.DirsTrashed
+	stats.BytesFreed += other.BytesFreed
+	stats.FilesRemoved += other.FilesRemoved
+	stats.Errors += other.Errors
+	stats.DirsExcluded += other.DirsExcluded
+	stats.DirsUnparsed += other.DirsUnparsed
+	stats.StrayFiles += other.StrayFiles
+	stats.DirsCompressed += other.DirsCompressed
+	stats.DirsMoved += other.DirsMoved
+}
+
+// CompletedCount is the number of companies the pass finished.
+func (s Summary) CompletedCount() int {
+	count := 0
+	for _, stats := range s.Companies {
+		if stats.Completed {
+			count++
+		}
+	}
+	return count
+}
+
+// FailedCount is the number of companies that had errors or weren't finished.
+func (s Summary) FailedCount() int {
+	count := 0
+	for _, stats := range s.Companies {
+		if stats.Errors > 0 || !stats.Completed {
+			count++
+		}
+	}
+	return count
+}
+
+func (s Summary) String() string {
+	totals := s.Totals()
+	state := "finished"
+	if s.Interrupted {
+		state = "interrupted"
+	}
+	if s.Aborted {
+		state = "aborted by deletion cap"
+		if len(s.UnknownCompanies) > 0 {
+			state = "aborted by companies without a config entry"
+		}
+	}
+	if s.WindowClosed {
+		state = "stopped by the run window closing"
+	}
+	if s.OutOfTime {
+		state = "out of time"
+	}
+	line := fmt.Sprintf("Pass %s after %s: %d of %d companies completed, %d directories scanned, %d deleted, %d files deleted, %d trashed, %d bytes freed in %d files, %d unparseable, %d errors",
+		state, s.End.Sub(s.Start), s.CompletedCount(), len(s.Companies), totals.DirsScanned, totals.DirsDeleted, totals.FilesDeleted, totals.DirsTrashed, totals.BytesFreed, totals.FilesRemoved, totals.DirsUnparsed, totals.Errors)
+	if totals.DirsMoved > 0 {
+		line += fmt.Sprintf(", %d moved to archiveTo", totals.DirsMoved)
+	}
+	if totals.DirsCompressed > 0 {
+		line += fmt.Sprintf(", %d compressed", totals.DirsCompressed)
+	}
+	if totals.StrayFiles > 0 {
+		line += fmt.Sprintf(", %d stray files", totals.StrayFiles)
+	}
+	if interrupted := s.interruptedPaths(); len(interrupted) > 0 {
+		line += fmt.Sprintf(", %d removals left partly done by an earlier run", len(interrupted))
+	}
+	if active := s.activePaths(); len(active) > 0 {
+		line += fmt.Sprintf(", %d kept for recent writes", len(active))
+	}
+	if open := s.openPaths(); len(open) > 0 {
+		line += fmt.Sprintf(", %d kept for open files", len(open))
+	}
+	if exempt := s.exemptCount(); exempt > 0 {
+		line += fmt.Sprintf(", %d exempt", exempt)
+	}
+	if len(s.UnknownCompanies) > 0 {
+		line += fmt.Sprintf(", no config entry for %s", strings.Join(s.UnknownCompanies, ", "))
+	}
+	if len(s.OrphanedEntries) > 0 {
+		line += fmt.Sprintf(", no directory for %s", strings.Join(s.OrphanedEntries, ", "))
+	}
+	return line
+}
+
+// exemptCount is the number of companies skipped because their retention is never.
+func (s Summary) exemptCount() int {
+	count := 0
+	for _, stats := range s.Companies {
+		if stats.Exempt {
+			count++
+		}
+	}
+	return count
+}
+
+// interruptedPaths collects every company's InterruptedPaths.
+func (s Summary) interruptedPaths() []string {
+	var paths []string
+	for _, stats := range s.Companies {
+		paths = append(paths, stats.InterruptedPaths...)
+	}
+	return paths
+}
+
+// activePaths collects every company's ActivePaths.
+func (s Summary) activePaths() []string {
+	var paths []string
+	for _, stats := range s.Companies {
+		paths = append(paths, stats.ActivePaths...)
+	}
+	return paths
+}
+
+// openPaths collects every company's OpenPaths.
+func (s Summary) openPaths() []string {
+	var paths []string
+	for _, stats := range s.Companies {
+		paths = append(paths, stats.OpenPaths...)
+	}
+	return paths
+}
+
+func (stats *CompanyStats) countRemoved(isDir bool, size int64, files int) {
+	if isDir {
+		stats.DirsDeleted++
+	} else {
+		stats.FilesDeleted++
+	}
+	stats.BytesFreed += size
+	stats.FilesRemoved += files
+}
@@ -0,0 +1,103 @@
+package pruner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inside returns an error unless removing path would remove something inside one of the base directories once
+// symlinks are resolved. Only the directories leading to path are resolved: removing a symlink removes the link, not
+// what it points to.
+func (p *Pruner) inside(path string) error {
+	bases, err := p.resolvedBases()
+	if err != nil {
+		return err
+	}
+	parent, err := p.FS.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	resolved := filepath.Join(parent, filepath.Base(path))
+	if !withinAny(bases, resolved) {
+		return fmt.Errorf("%s resolves to %s, outside %s", path, resolved, strings.Join(p.Bases(), ", "))
+	}
+	return nil
+}
+
+// insideRoot is inside for a single directory other than the base directories.
+func (p *Pruner) insideRoot(root string, path string) error {
+	resolvedRoot, err := p.FS.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	parent, err := p.FS.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	resolved := filepath.Join(parent, filepath.Base(path))
+	if !within(resolvedRoot, resolved) {
+		return fmt.Errorf("%s resolves to %s, outside %s", path, resolved, root)
+	}
+	return nil
+}
+
+// resolvedBases returns the base directories with symlinks resolved.
+func (p *Pruner) resolvedBases() ([]string, error) {
+	var resolved []string
+	for _, base := range p.Bases() {
+		dir, err := p.FS.EvalSymlinks(base)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, dir)
+	}
+	return resolved, nil
+}
+
+// withinAny reports whether path is one of dirs or below one of them.
+func withinAny(dirs []string, path string) bool {
+	for _, dir := range dirs {
+		if within(dir, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or below it. Both must be clean.
+func within(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// followLink returns what the symlink at path points to, if FollowSymlinks is set and it is a directory inside one
+// of the base directories, and "" otherwise.
+func (p *Pruner) followLink(path string) string {
+	if !p.FollowSymlinks {
+		return ""
+	}
+	bases, err := p.resolvedBases()
+	if err != nil {
+		return ""
+	}
+	target, err := p.FS.EvalSymlinks(path)
+	if err != nil || !withinAny(bases, target) {
+		p.Log.WithField("path", path).Warnln("Not following symlink that leads outside the base directory")
+		return ""
+	}
+	if info, err := p.FS.Stat(target); err != nil || !info.IsDir() {
+		return ""
+	}
+	return target
+}
+
+// isDir reports whether the entry in dir is a directory, or with FollowSymlinks a symlink walkDir would follow.
+func (p *Pruner) isDir(dir string, entry os.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	return entry.Type()&fs.ModeSymlink != 0 && p.followLink(filepath.Join(dir, entry.Name())) != ""
+}
@@ -0,0 +1,80 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// escapingTree has a company acme with an expired day of its own, and symlinks in place of an expired month and an
+// expired year that lead out of the base directory.
+func escapingTree() *MemFS {
+	fsys := &MemFS{}
+	for _, file := range []string{
+		"/data/acme/2020/10/01/data",
+		"/data/acme/2020/12/20/data",
+		"/outside/2020/11/01/data",
+		"/outside/old/2019/01/01/data",
+	} {
+		fsys.WriteFile(file, []byte("data"), testNow)
+	}
+	fsys.Symlink("/outside/2020/11", "/data/acme/2020/11")
+	fsys.Symlink("../../outside/old/2019", "/data/acme/2019")
+	return fsys
+}
+
+func TestWalkDoesNotFollowSymlinksOutOfTheBase(t *testing.T) {
+	for _, follow := range []bool{false, true} {
+		fsys := escapingTree()
+		p := memPruner(fsys, dailyConfig(nil))
+		p.FollowSymlinks = follow
+		removed, stats := removedPaths(t, p)
+		if want := "[2020/10]"; fmt.Sprint(removed) != want || stats.Errors != 0 {
+			t.Errorf("followSymlinks %v: removed %q with %d errors, want %s and none", follow, removed, stats.Errors, want)
+		}
+		checkExists(t, fsys, true, "/outside/2020/11/01/data", "/outside/old/2019/01/01/data", "/data/acme/2020/11", "/data/acme/2019")
+	}
+}
+
+// TestRemovalsResolvingOutOfTheBaseAreRefused swaps a directory for a symlink out of the base directory between
+// the walk finding what is below it and removing it, and expects the removal to be refused.
+func TestRemovalsResolvingOutOfTheBaseAreRefused(t *testing.T) {
+	fsys := escapingTree()
+	fsys.WriteFile("/outside/2020/10/01/data", []byte("data"), testNow)
+	config := dailyConfig(nil)
+	p := memPruner(fsys, config)
+	run := p.newCompanyRun(context.Background(), companyDir{name: "acme", base: "/data"}, config.CompanyConfigs[0], "test", testNow, p.Log, false, p.Recorder)
+	fsys.RemoveAll("/data/acme/2020/10")
+	fsys.Symlink("/outside/2020/10", "/data/acme/2020/10")
+	for _, path := range []string{"/data/acme/2020/10/01", "/data/acme/2019/01", "/data/acme/2020/11/01"} {
+		run.removeExpired(path, true, testNow.AddDate(-1, 0, 0))
+	}
+	if run.stats.Errors != 3 || run.stats.DirsDeleted != 0 {
+		t.Errorf("got %d errors and %d removals, want every removal refused", run.stats.Errors, run.stats.DirsDeleted)
+	}
+	// Removing a symlink only removes the link.
+	run.removeExpired("/data/acme/2020/10", true, testNow.AddDate(-1, 0, 0))
+	checkExists(t, fsys, false, "/data/acme/2020/10")
+	checkExists(t, fsys, true, "/outside/2020/10/01/data", "/outside/2020/11/01/data", "/outside/old/2019/01/01/data")
+}
+
+// TestCompanyDirectoryLinkedOutOfTheBase expects a company directory that is a symlink out of the base directory not
+// to be pruned at all.
+func TestCompanyDirectoryLinkedOutOfTheBase(t *testing.T) {
+	fsys := &MemFS{}
+	fsys.WriteFile("/outside/acme/2020/10/01/data", []byte("data"), testNow)
+	fsys.WriteFile("/outside/acme/2019/01/01/data", []byte("data"), testNow)
+	fsys.Symlink("/outside/acme", "/data/acme")
+	for _, follow := range []bool{false, true} {
+		p := memPruner(fsys, dailyConfig(nil))
+		p.FollowSymlinks = follow
+		summary, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if totals := summary.Totals(); totals.DirsDeleted != 0 {
+			t.Errorf("followSymlinks %v: removed %d directories", follow, totals.DirsDeleted)
+		}
+		checkExists(t, fsys, true, "/outside/acme/2020/10/01/data", "/outside/acme/2019/01/01/data")
+	}
+}
@@ -0,0 +1,177 @@
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// moveToTier moves an expired path to the company's archiveTo, keeping its position relative to the company
+// directory, and returns where it went. Whatever is already there from earlier passes is merged with, not replaced.
+func (c *companyRun) moveToTier(path string) (string, error) {
+	rel, err := filepath.Rel(c.dir, path)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(c.config.ArchiveTo, c.stats.Company, rel)
+	return target, c.moveTree(path, target)
+}
+
+// moveTree moves src to dst, merging directories that exist on both sides.
+func (c *companyRun) moveTree(src string, dst string) error {
+	if c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	existing, err := c.fs.Stat(dst)
+	if err == nil {
+		info, err := c.fs.Stat(src)
+		if err != nil {
+			return err
+		}
+		if !existing.IsDir() || !info.IsDir() {
+			return fmt.Errorf("%s already exists", dst)
+		}
+		entries, err := c.fs.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := c.moveTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return c.fs.RemoveAll(src)
+	}
+	if err := c.fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := c.fs.Rename(src, dst); !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	// Another filesystem: copy under a temporary name, check the copy, and only then remove the original.
+	partial := dst + ".partial"
+	if err := c.fs.RemoveAll(partial); err != nil {
+		return err
+	}
+	if err := copyTree(src, partial); err != nil {
+		c.fs.RemoveAll(partial)
+		return err
+	}
+	srcSize, srcFiles, err := c.dirSize(src)
+	if err != nil {
+		return err
+	}
+	dstSize, dstFiles, err := c.dirSize(partial)
+	if err != nil {
+		return err
+	}
+	if srcSize != dstSize || srcFiles != dstFiles {
+		c.fs.RemoveAll(partial)
+		return fmt.Errorf("copy of %s has %d bytes in %d files, expected %d bytes in %d files", src, dstSize, dstFiles, srcSize, srcFiles)
+	}
+	if err := c.fs.Rename(partial, dst); err != nil {
+		return err
+	}
+	return c.removeAll(src)
+}
+
+// copyTree copies the tree rooted at src to dst, keeping modes and modification times. Symlinks are copied as links.
+// Every file is synced before copyTree returns.
+func copyTree(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, f fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := f.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			// Sockets, devices and the like have nothing to copy.
+			return nil
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+func copyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// pruneTier removes what has been in the company's archiveTo for longer than its archiveRetention, with the same
+// layout and rules as the company directory itself.
+func (c *companyRun) pruneTier(layout Layout) {
+	dir := companyDir{name: c.stats.Company, base: c.config.ArchiveTo}
+	if _, err := c.fs.Stat(dir.path()); err != nil {
+		// Nothing has been moved there yet.
+		return
+	}
+	config := c.config
+	config.Retention = config.ArchiveRetention
+	config.ArchiveTo, config.ArchiveRetention, config.CompressAfter = "", "", ""
+	// The data was archived, if it needed to be, before it was moved.
+	config.Archive = nil
+	config.MinKeepCount = 0
+	// Sub-tenant and category retentions are for the company directory; archiveRetention applies to all of it.
+	config.SubtenantRetention, config.Categories = nil, nil
+	tier := c.p.newCompanyRun(c.ctx, dir, config, c.runID, c.now, c.log.WithField("archive_to", c.config.ArchiveTo), c.dryRun, c.recorder)
+	tier.root = c.config.ArchiveTo
+	tier.permanent = true
+	tier.minDepth = c.minDepth
+	defer tier.endRemoveSpan()
+	if err := tier.resolveCutoff(); err != nil {
+		c.configError(fmt.Errorf("archiveRetention: %v", err))
+		return
+	}
+	tier.log = tier.log.WithField("cutoff", tier.cutoff.Format(time.RFC3339))
+	tier.pruneByLayout(layout)
+	tier.retrySweep()
+	if config.RemovesEmptyDirs() {
+		tier.removeEmptyDirs(layout)
+	}
+	c.stats.add(tier.stats)
+	if !tier.stats.Completed {
+		c.stats.Completed = false
+	}
+}
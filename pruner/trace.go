@@ -0,0 +1,55 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+)
+
+// Tracer starts the spans a pass is made of: one for the pass, one for each company in it, and one for each
+// company's removals. Implementations, such as an OpenTelemetry exporter, must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span named name, a child of the span in ctx if there is one, and returns ctx with the new span
+	// in it. Attribute values are strings, ints, int64s or bools.
+	Start(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute adds an attribute, or replaces one with the same key.
+	SetAttribute(key string, value interface{})
+	// End ends the span, as failed if err isn't nil.
+	End(err error)
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string, _ map[string]interface{}) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, interface{}) {}
+func (nopSpan) End(error)                        {}
+
+// endSpan ends a pass or company span with what was done in it, as failed if there were errors.
+func endSpan(span Span, stats CompanyStats) {
+	span.SetAttribute("deleter.dirs_scanned", stats.DirsScanned)
+	span.SetAttribute("deleter.dirs_deleted", stats.DirsDeleted)
+	span.SetAttribute("deleter.files_deleted", stats.FilesDeleted)
+	span.SetAttribute("deleter.bytes_freed", stats.BytesFreed)
+	span.SetAttribute("deleter.errors", stats.Errors)
+	var err error
+	if stats.Errors > 0 {
+		err = fmt.Errorf("%d errors", stats.Errors)
+	}
+	span.End(err)
+}
+
+// tracer returns the Pruner's Tracer, or one that does nothing if it has none.
+func (p *Pruner) tracer() Tracer {
+	if p.Tracer == nil {
+		return nopTracer{}
+	}
+	return p.Tracer
+}
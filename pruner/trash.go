@@ -0,0 +1,77 @@
+package pruner
+
+import (
+	"path/filepath"
+	"time"
+)
+
+const (
+	// trashDirName is the directory inside each company directory that holds trashed data.
+	trashDirName = ".trash"
+	// trashTimeLayout names the per-pass directories inside the trash, so their age is known without a stat.
+	trashTimeLayout = "20060102T150405Z"
+)
+
+// moveToTrash moves path into the company's trash, keeping its position relative to the company directory so it
+// can be restored by moving it back, and returns where it is now.
+func (c *companyRun) moveToTrash(path string) (string, error) {
+	rel, err := filepath.Rel(c.dir, path)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(c.dir, trashDirName, c.now.UTC().Format(trashTimeLayout), rel)
+	if err := c.fs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	return target, c.fs.Rename(path, target)
+}
+
+// emptyTrash permanently deletes whatever has been in the company's trash for longer than TrashGrace.
+func (c *companyRun) emptyTrash() {
+	trashDir := filepath.Join(c.dir, trashDirName)
+	entries, err := c.fs.ReadDir(trashDir)
+	if err != nil {
+		// No trash yet.
+		return
+	}
+	for _, entry := range entries {
+		if c.ctx.Err() != nil {
+			return
+		}
+		path := filepath.Join(trashDir, entry.Name())
+		pathLog := c.log.WithField("path", path)
+		trashedAt, err := time.Parse(trashTimeLayout, entry.Name())
+		if err != nil {
+			pathLog.Warnln("Leaving unrecognised trash entry alone")
+			continue
+		}
+		if c.now.Sub(trashedAt) < c.p.TrashGrace {
+			continue
+		}
+		if c.dryRun {
+			pathLog.WithField("trashed", trashedAt.Format(time.RFC3339)).Infoln("Would empty trash")
+			continue
+		}
+		size, files, sizeErr := c.dirSize(path)
+		if sizeErr != nil {
+			pathLog.Errorf("Error sizing path : %+v", sizeErr)
+		}
+		if err := c.p.pace(c.ctx); err != nil {
+			return
+		}
+		pathLog.Debugln("Emptying trash")
+		if err := c.inside(path); err != nil {
+			c.error(path, "Refusing to remove path", err)
+			continue
+		}
+		if err := c.fs.RemoveAll(path); err != nil {
+			c.error(path, "Error removing path", err)
+			continue
+		}
+		c.audit(AuditDeleted, path, size)
+		c.mu.Lock()
+		c.stats.countRemoved(true, size, files)
+		c.mu.Unlock()
+		c.recorder.DirDeleted(c.stats.Company, size, files)
+	}
+}
@@ -0,0 +1,461 @@
+package pruner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigProblem is a mistake found in a config document. Line is 1-based, or 0 when the problem isn't tied to one
+// place in the document.
+type ConfigProblem struct {
+	Line int
+	Msg  string
+}
+
+func (p ConfigProblem) String() string {
+	if p.Line == 0 {
+		return p.Msg
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Msg)
+}
+
+// ValidateConfig checks a JSON config document: that it is well-formed with no unknown fields, that it has a
+// default entry, that company ids are present and unique, that the groups entries name exist, and that every value
+// parses.
+func ValidateConfig(data []byte) []ConfigProblem {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		line, _ := jsonPosition(data, err)
+		return []ConfigProblem{{Line: line, Msg: err.Error()}}
+	}
+	// Entries decode through CompanyConfig.UnmarshalJSON, which can't reject unknown fields itself, so they are
+	// checked against the decoded document instead.
+	var document struct {
+		Default   interface{}   `json:"default"`
+		Companies []interface{} `json:"companies"`
+		Groups    []interface{} `json:"groups"`
+		BaseDirs  []interface{} `json:"baseDirs"`
+	}
+	var keys map[string]interface{}
+	json.Unmarshal(data, &document)
+	json.Unmarshal(data, &keys)
+	var problems []ConfigProblem
+	for _, key := range sortedKeys(keys) {
+		switch key {
+		case "default", "companies", "groups", "protectedPaths", "allowCompanies", "unknownCompanies", "baseDirs":
+		default:
+			problems = append(problems, ConfigProblem{Msg: fmt.Sprintf("unknown field %q", key)})
+		}
+	}
+	switch config.UnknownCompanies {
+	case "", UnknownDefault, UnknownSkip, UnknownFail:
+	default:
+		problems = append(problems, ConfigProblem{Msg: fmt.Sprintf("unknownCompanies: %q is not one of %q, %q or %q",
+			config.UnknownCompanies, UnknownDefault, UnknownSkip, UnknownFail)})
+	}
+	entryType := reflect.TypeOf(CompanyConfig{})
+	defaultLine, companyLines := entryLines(data)
+	if defaultLine == 0 {
+		problems = append(problems, ConfigProblem{Msg: `no "default" entry, so company directories without their own entry would be skipped`})
+	} else {
+		msgs := append(unknownFields(document.Default, entryType, ""), config.DefaultConfig.Problems()...)
+		for _, msg := range msgs {
+			problems = append(problems, ConfigProblem{Line: defaultLine, Msg: "default: " + msg})
+		}
+	}
+	for _, pattern := range config.ProtectedPaths {
+		if pattern == "" || strings.HasPrefix(pattern, "/") {
+			problems = append(problems, ConfigProblem{Msg: fmt.Sprintf("protectedPaths: %q must be a glob relative to the company directory", pattern)})
+		}
+	}
+	paths := make(map[string]bool)
+	for i, base := range config.BaseDirs {
+		add := func(msg string) {
+			problems = append(problems, ConfigProblem{Msg: fmt.Sprintf("baseDirs %q: %s", base.Path, msg)})
+		}
+		switch clean := filepath.Clean(base.Path); {
+		case base.Path == "":
+			add("missing path")
+		case paths[clean]:
+			add("listed more than once")
+		}
+		paths[filepath.Clean(base.Path)] = true
+		if i < len(document.BaseDirs) {
+			for _, msg := range unknownFields(document.BaseDirs[i], reflect.TypeOf(BaseDirConfig{}), "") {
+				add(msg)
+			}
+		}
+		if base.Default == nil {
+			continue
+		}
+		// Retention left out comes from the default entry.
+		checked := *base.Default
+		if checked.Retention == "" {
+			checked.Retention = "0"
+		}
+		for _, msg := range checked.Problems() {
+			add("default: " + msg)
+		}
+	}
+	groups := make(map[string]GroupConfig)
+	for i, group := range config.Groups {
+		add := func(msg string) {
+			problems = append(problems, ConfigProblem{Msg: fmt.Sprintf("group %q: %s", group.Name, msg)})
+		}
+		if _, duplicate := groups[group.Name]; duplicate {
+			add("duplicate group name; only the last group takes effect")
+		}
+		if group.Name == "" {
+			add("missing name")
+		}
+		groups[group.Name] = group
+		var unknown []string
+		if i < len(document.Groups) {
+			if object, ok := document.Groups[i].(map[string]interface{}); ok {
+				settings := make(map[string]interface{}, len(object))
+				for key, value := range object {
+					if key != "name" {
+						settings[key] = value
+					}
+				}
+				unknown = unknownFields(settings, entryType, "")
+			}
+		}
+		// A group may leave retention to its members.
+		checked := group.CompanyConfig
+		if checked.Retention == "" {
+			checked.Retention = "0"
+		}
+		if group.Group != "" {
+			unknown = append(unknown, "groups can't belong to another group")
+		}
+		for _, msg := range append(unknown, checked.Problems()...) {
+			add(msg)
+		}
+	}
+	seen := make(map[string]int)
+	for i, entry := range config.CompanyConfigs {
+		line := 0
+		if i < len(companyLines) {
+			line = companyLines[i]
+		}
+		add := func(msg string) {
+			problems = append(problems, ConfigProblem{Line: line, Msg: fmt.Sprintf("company %q: %s", entry.Id, msg)})
+		}
+		switch first, duplicate := seen[entry.Id]; {
+		case entry.Id == "":
+			add("missing companyId")
+		case entry.Id == "default":
+			add(`companyId "default" is reserved for the default entry`)
+		case duplicate:
+			add(fmt.Sprintf("duplicate companyId, first used on line %d; only the last entry takes effect", first))
+		case IsPattern(entry.Id):
+			if err := checkPattern(entry.Id); err != nil {
+				add(fmt.Sprintf("invalid companyId pattern: %v", err))
+			}
+		}
+		seen[entry.Id] = line
+		var unknown []string
+		if i < len(document.Companies) {
+			unknown = unknownFields(document.Companies[i], entryType, "")
+		}
+		if group, exists := groups[entry.Group]; exists {
+			entry = group.inherit(entry)
+		} else if entry.Group != "" {
+			unknown = append(unknown, fmt.Sprintf("group %q is not defined", entry.Group))
+		}
+		for _, msg := range unknown {
+			add(msg)
+		}
+		// What the entry and its group leave unset comes from the default, whose problems are reported once, above.
+		defaultProblems := config.DefaultConfig.Problems()
+		for _, msg := range inherit(entry, config.DefaultConfig).Problems() {
+			if !containsString(defaultProblems, msg) {
+				add(msg)
+			}
+		}
+	}
+	return problems
+}
+
+// Problems describes every value in the entry that does not parse.
+func (c CompanyConfig) Problems() []string {
+	var msgs []string
+	if _, err := ParseRetention(c.Retention); err != nil {
+		msgs = append(msgs, fmt.Sprintf("retentionDays: %v", err))
+	}
+	if c.MinKeepDays != "" {
+		if _, err := ParseRetention(c.MinKeepDays); err != nil {
+			msgs = append(msgs, fmt.Sprintf("minKeepDays: %v", err))
+		}
+	}
+	if c.MinKeepCount < 0 {
+		msgs = append(msgs, "minKeepCount is negative")
+	}
+	if c.Workers < 0 {
+		msgs = append(msgs, "workers is negative")
+	}
+	if c.MinDepth < 0 {
+		msgs = append(msgs, "minDepth is negative")
+	}
+	if layout, err := c.ParseLayout(); err != nil {
+		msgs = append(msgs, err.Error())
+	} else if c.Mode != ModeMtime && c.MinDepth > layout.Depth() {
+		msgs = append(msgs, fmt.Sprintf("minDepth %d is deeper than the layout's %d levels, nothing could be removed", c.MinDepth, layout.Depth()))
+	}
+	if c.CompressAfter != "" {
+		if compressAfter, err := ParseRetention(c.CompressAfter); err != nil {
+			msgs = append(msgs, fmt.Sprintf("compressAfter: %v", err))
+		} else if retention, err := ParseRetention(c.Retention); err == nil && !compressAfter.Cutoff(time.Now()).After(retention.Cutoff(time.Now())) {
+			msgs = append(msgs, fmt.Sprintf("compressAfter %s is not shorter than the retention %s, nothing would be compressed", compressAfter, retention))
+		} else if c.Mode == ModeMtime {
+			msgs = append(msgs, "compressAfter is ignored in mtime mode")
+		}
+	}
+	if c.ArchiveTo != "" && !filepath.IsAbs(c.ArchiveTo) {
+		msgs = append(msgs, fmt.Sprintf("archiveTo %s is not an absolute path", c.ArchiveTo))
+	}
+	if c.ArchiveRetention != "" {
+		if archiveRetention, err := ParseRetention(c.ArchiveRetention); err != nil {
+			msgs = append(msgs, fmt.Sprintf("archiveRetention: %v", err))
+		} else if c.ArchiveTo == "" {
+			msgs = append(msgs, "archiveRetention is ignored unless archiveTo is set")
+		} else if retention, err := ParseRetention(c.Retention); err == nil && !archiveRetention.Cutoff(time.Now()).Before(retention.Cutoff(time.Now())) {
+			msgs = append(msgs, fmt.Sprintf("archiveRetention %s is not longer than the retention %s, data would be removed from archiveTo as soon as it arrives", archiveRetention, retention))
+		}
+	}
+	if c.FileLayout != "" {
+		if _, err := ParseFileLayout(c.FileLayout); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	for _, field := range []struct {
+		name   string
+		values map[string]string
+	}{{"subtenantRetention", c.SubtenantRetention}, {"categories", c.Categories}} {
+		keys := make([]string, 0, len(field.values))
+		for key := range field.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, err := ParseRetention(field.values[key]); err != nil {
+				msgs = append(msgs, fmt.Sprintf("%s %s: %v", field.name, key, err))
+			}
+		}
+	}
+	if len(c.SubtenantRetention) > 0 && !c.HasSubtenants() {
+		msgs = append(msgs, "subtenantRetention is ignored unless subtenants is set")
+	}
+	if c.StrayFiles != "" && c.StrayFiles != StrayIgnore && c.StrayFiles != StrayMtime && c.StrayFiles != StrayReport {
+		msgs = append(msgs, fmt.Sprintf("unknown strayFiles %q, expected %q, %q or %q", c.StrayFiles, StrayIgnore, StrayMtime, StrayReport))
+	}
+	if c.Subvolumes != "" && c.Subvolumes != SubvolumesAuto && c.Subvolumes != SubvolumesBtrfs && c.Subvolumes != SubvolumesZFS {
+		msgs = append(msgs, fmt.Sprintf("unknown subvolumes %q, expected %q, %q or %q", c.Subvolumes, SubvolumesAuto, SubvolumesBtrfs, SubvolumesZFS))
+	}
+	if c.OpenFiles != "" && c.OpenFiles != OpenFilesProc && c.OpenFiles != OpenFilesFlock {
+		msgs = append(msgs, fmt.Sprintf("unknown openFiles %q, expected %q or %q", c.OpenFiles, OpenFilesProc, OpenFilesFlock))
+	}
+	if c.Mode != "" && c.Mode != ModePath && c.Mode != ModeMtime {
+		msgs = append(msgs, fmt.Sprintf("unknown mode %q, expected %q or %q", c.Mode, ModePath, ModeMtime))
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			msgs = append(msgs, fmt.Sprintf("timezone: %v", err))
+		}
+	}
+	if c.Schedule != "" {
+		if _, err := ParseSchedule(c.Schedule); err != nil {
+			msgs = append(msgs, fmt.Sprintf("schedule: %v", err))
+		}
+	}
+	if c.Storage != nil {
+		if err := c.Storage.Check(); err != nil {
+			msgs = append(msgs, fmt.Sprintf("storage: %v", err))
+		} else if c.Storage.Type == "azure" {
+			msgs = append(msgs, "storage: azure is not supported, only s3 and gcs")
+		}
+	}
+	if c.SFTP != nil {
+		if err := c.SFTP.Check(); err != nil {
+			msgs = append(msgs, fmt.Sprintf("sftp: %v", err))
+		}
+		if c.Storage != nil {
+			msgs = append(msgs, "storage and sftp can't both be set")
+		}
+	}
+	if c.remote() {
+		for _, field := range []struct {
+			name string
+			set  bool
+		}{{"archive", c.Archive != nil}, {"archiveTo", c.ArchiveTo != ""}, {"compressAfter", c.CompressAfter != ""}, {"subvolumes", c.Subvolumes != ""}, {"openFiles", c.OpenFiles != ""}} {
+			if field.set {
+				msgs = append(msgs, fmt.Sprintf("%s is not supported with storage or sftp", field.name))
+			}
+		}
+	}
+	if c.Archive != nil {
+		if err := c.Archive.Check(); err != nil {
+			msgs = append(msgs, fmt.Sprintf("archive: %v", err))
+		}
+	}
+	for i, rule := range c.CalendarRules {
+		if _, err := parseDays(rule.Days); err != nil {
+			msgs = append(msgs, fmt.Sprintf("calendarRules %d: %v", i+1, err))
+		}
+		if rule.Retention != "" {
+			if _, err := ParseRetention(rule.Retention); err != nil {
+				msgs = append(msgs, fmt.Sprintf("calendarRules %d: %v", i+1, err))
+			}
+		}
+	}
+	if len(c.CalendarRules) > 0 && c.Mode == ModeMtime {
+		msgs = append(msgs, "calendarRules are ignored in mtime mode")
+	}
+	if c.Policy != nil {
+		if err := c.Policy.Check(); err != nil {
+			msgs = append(msgs, fmt.Sprintf("policy: %v", err))
+		}
+		if c.Mode == ModeMtime {
+			msgs = append(msgs, "policy is ignored in mtime mode")
+		}
+	}
+	if c.SkipModifiedWithin != "" {
+		if window, err := time.ParseDuration(c.SkipModifiedWithin); err != nil || window <= 0 {
+			msgs = append(msgs, fmt.Sprintf("skipModifiedWithin %q is not a positive duration", c.SkipModifiedWithin))
+		}
+	}
+	for _, hook := range []struct {
+		name string
+		hook *Hook
+	}{{HookPreDelete, c.PreDelete}, {HookPostDelete, c.PostDelete}} {
+		if hook.hook != nil {
+			if err := hook.hook.Check(); err != nil {
+				msgs = append(msgs, fmt.Sprintf("%s: %v", hook.name, err))
+			}
+		}
+	}
+	return msgs
+}
+
+// unknownFields describes the keys of a decoded JSON object, and of the objects inside it, that t has no field for.
+// prefix is prepended to the key names.
+func unknownFields(value interface{}, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		var msgs []string
+		for _, key := range sortedKeys(object) {
+			fieldType, known := fields[key]
+			if !known {
+				msgs = append(msgs, fmt.Sprintf("unknown field %q", prefix+key))
+				continue
+			}
+			msgs = append(msgs, unknownFields(object[key], fieldType, prefix+key+".")...)
+		}
+		return msgs
+	case reflect.Slice:
+		elements, _ := value.([]interface{})
+		var msgs []string
+		for i, element := range elements {
+			msgs = append(msgs, unknownFields(element, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i))...)
+		}
+		return msgs
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a decoded JSON object in order.
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// entryLines finds the lines that the default entry and each company entry start on, 0 for a missing default.
+// data must already have decoded successfully.
+func entryLines(data []byte) (defaultLine int, companyLines []int) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return 0, nil
+	}
+	var skip json.RawMessage
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return defaultLine, companyLines
+		}
+		switch key {
+		case "default":
+			defaultLine = lineAt(data, decoder.InputOffset())
+			err = decoder.Decode(&skip)
+		case "companies":
+			var token json.Token
+			if token, err = decoder.Token(); err != nil || token != json.Delim('[') {
+				break
+			}
+			for decoder.More() && err == nil {
+				companyLines = append(companyLines, lineAt(data, decoder.InputOffset()))
+				err = decoder.Decode(&skip)
+			}
+			if err == nil {
+				_, err = decoder.Token()
+			}
+		default:
+			err = decoder.Decode(&skip)
+		}
+		if err != nil {
+			return defaultLine, companyLines
+		}
+	}
+	return defaultLine, companyLines
+}
+
+// lineAt returns the line of the first value at or after offset, skipping whitespace and separators.
+func lineAt(data []byte, offset int64) int {
+	i := int(offset)
+	for i < len(data) && bytes.IndexByte([]byte(" \t\r\n:,"), data[i]) >= 0 {
+		i++
+	}
+	return bytes.Count(data[:i], []byte("\n")) + 1
+}
+
+// jsonPosition returns the line and column a JSON decoding error refers to, or zeros if it doesn't say.
+func jsonPosition(data []byte, err error) (line int, column int) {
+	var offset int64
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		offset = err.Offset
+	case *json.UnmarshalTypeError:
+		offset = err.Offset
+	default:
+		return 0, 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	return bytes.Count(before, []byte("\n")) + 1, len(before) - bytes.LastIndexByte(before, '\n')
+}
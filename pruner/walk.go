@@ -0,0 +1,145 @@
+package pruner
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// walkDir is FS.WalkDir, except that with FollowSymlinks it also walks into symlinked directories inside a base
+// directory, reporting what it finds under the link's path, and with OneFileSystem it leaves out directories on a
+// different filesystem from root. Each link target is only walked once, so links can't make it loop.
+func (p *Pruner) walkDir(root string, fn fs.WalkDirFunc) error {
+	w := walker{p: p, visited: make(map[string]bool)}
+	if p.OneFileSystem {
+		if info, err := p.FS.Stat(root); err == nil {
+			w.device, w.oneFS = device(info)
+		}
+	}
+	return w.walk(root, fn)
+}
+
+type walker struct {
+	p       *Pruner
+	visited map[string]bool
+	// device is the filesystem root is on, if oneFS is set.
+	device uint64
+	oneFS  bool
+}
+
+func (w *walker) walk(root string, fn fs.WalkDirFunc) error {
+	return w.p.localFS().WalkDir(root, func(path string, f fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(path, f, err)
+		}
+		if f.Type()&fs.ModeSymlink == 0 {
+			if f.IsDir() && path != root && w.elsewhere(path, f.Info) {
+				return filepath.SkipDir
+			}
+			return fn(path, f, err)
+		}
+		target := w.p.followLink(path)
+		if target == "" || w.visited[target] {
+			return fn(path, f, err)
+		}
+		w.visited[target] = true
+		info, err := w.p.FS.Stat(target)
+		if err != nil {
+			return fn(path, f, err)
+		}
+		if w.elsewhere(path, func() (fs.FileInfo, error) { return info, nil }) {
+			return nil
+		}
+		if err := fn(path, fs.FileInfoToDirEntry(info), nil); err != nil {
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+		return w.walk(target, func(sub string, f fs.DirEntry, err error) error {
+			if sub == target {
+				return nil
+			}
+			return fn(filepath.Join(path, strings.TrimPrefix(sub, target)), f, err)
+		})
+	})
+}
+
+// elsewhere reports whether OneFileSystem is set and the directory at path is on a different filesystem from the
+// walk's root.
+func (w *walker) elsewhere(path string, stat func() (fs.FileInfo, error)) bool {
+	if !w.oneFS {
+		return false
+	}
+	info, err := stat()
+	if err != nil {
+		return false
+	}
+	if dev, ok := device(info); ok && dev != w.device {
+		w.p.Log.WithField("path", path).Infoln("Skipping directory on another filesystem")
+		return true
+	}
+	return false
+}
+
+// mountBelow returns the first directory below path that is on a different filesystem from path, or "" if there is
+// none or OneFileSystem isn't set.
+func (p *Pruner) mountBelow(path string) string {
+	if !p.OneFileSystem {
+		return ""
+	}
+	info, err := p.FS.Stat(path)
+	if err != nil {
+		return ""
+	}
+	root, ok := device(info)
+	if !ok {
+		return ""
+	}
+	var mount string
+	p.localFS().WalkDir(path, func(sub string, f fs.DirEntry, err error) error {
+		if err != nil || !f.IsDir() {
+			return nil
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil
+		}
+		if dev, ok := device(info); ok && dev != root {
+			mount = sub
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return mount
+}
+
+// readDirWalk walks the tree at root in lexical order, as filepath.WalkDir does, using only fsys's Stat and ReadDir.
+// It is WalkDir for FileSystems that have no faster way of their own.
+func readDirWalk(fsys FileSystem, root string, fn fs.WalkDirFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkEntries(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkEntries(fsys FileSystem, name string, entry fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, entry, nil); err != nil || !entry.IsDir() {
+		return err
+	}
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		if err := walkError(name, entry, fn, err); err != nil {
+			return err
+		}
+	}
+	return walkChildren(name, entries, func(path string, child fs.DirEntry) error {
+		return walkEntries(fsys, path, child, fn)
+	})
+}
@@ -0,0 +1,111 @@
+package pruner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RunWindow is a daily time range, such as off-peak hours, outside of which nothing is removed. A window whose end
+// is before its start runs past midnight.
+type RunWindow struct {
+	// Start and End are offsets from midnight.
+	Start time.Duration
+	End   time.Duration
+}
+
+// ErrOutsideWindow is returned for a pass asked for outside the run window when WindowWait is off.
+var ErrOutsideWindow = errors.New("outside the run window")
+
+// ParseRunWindow parses a window written as HH:MM-HH:MM, e.g. 01:00-05:00 or 22:00-04:00.
+func ParseRunWindow(spec string) (*RunWindow, error) {
+	parts := strings.Split(spec, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("run window [%s] is not HH:MM-HH:MM", spec)
+	}
+	var window RunWindow
+	for i, bound := range []*time.Duration{&window.Start, &window.End} {
+		t, err := time.Parse("15:04", strings.TrimSpace(parts[i]))
+		if err != nil {
+			return nil, fmt.Errorf("run window [%s] is not HH:MM-HH:MM", spec)
+		}
+		*bound = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if window.Start == window.End {
+		return nil, fmt.Errorf("run window [%s] is empty", spec)
+	}
+	return &window, nil
+}
+
+func (w RunWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// Contains reports whether t falls inside the window, read in t's location.
+func (w RunWindow) Contains(t time.Time) bool {
+	offset := sinceMidnight(t)
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// NextOpen returns when the window next opens after t, or t itself if it is open.
+func (w RunWindow) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	open := midnight(t).Add(w.Start)
+	if !open.After(t) {
+		open = midnight(t).AddDate(0, 0, 1).Add(w.Start)
+	}
+	return open
+}
+
+// Close returns when the window that is open at t closes.
+func (w RunWindow) Close(t time.Time) time.Time {
+	end := midnight(t).Add(w.End)
+	if !end.After(t) {
+		end = midnight(t).AddDate(0, 0, 1).Add(w.End)
+	}
+	return end
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return t.Sub(midnight(t))
+}
+
+// enterWindow holds a pass back until the run window is open, or refuses it if WindowWait is off, and returns a
+// context that is cancelled when the window closes. The pass's deletions in progress then finish, as on shutdown,
+// and nothing new is started.
+func (p *Pruner) enterWindow(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := p.Clock.Now().In(loc)
+	if !p.Window.Contains(now) {
+		if !p.WindowWait {
+			return nil, nil, ErrOutsideWindow
+		}
+		open := p.Window.NextOpen(now)
+		p.Log.Infof("Outside the run window %s, waiting until %s", p.Window, open.Format(time.RFC3339))
+		select {
+		case <-time.After(open.Sub(now)):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		now = p.Clock.Now().In(loc)
+	}
+	windowCtx, cancel := context.WithTimeout(ctx, p.Window.Close(now).Sub(now))
+	return windowCtx, cancel, nil
+}
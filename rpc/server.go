@@ -0,0 +1,163 @@
+// Package rpc is the deleter daemon's gRPC API. deleter.proto defines it; deleter.pb.go and deleter_grpc.pb.go are
+// generated from it.
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/moriarty-s3a/deleter/pruner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements DeleterServer on top of a Pruner.
+type Server struct {
+	UnimplementedDeleterServer
+	p *pruner.Pruner
+}
+
+// NewServer returns a gRPC server with the Deleter service registered. Every call must carry the metadata
+// "authorization: Bearer <token>".
+func NewServer(p *pruner.Pruner, token string) *grpc.Server {
+	auth := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(value, "Bearer ")), []byte(token)) == 1 {
+				return nil
+			}
+		}
+		return status.Error(codes.Unauthenticated, "missing or wrong bearer token")
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := auth(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := auth(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	RegisterDeleterServer(server, &Server{p: p})
+	return server
+}
+
+// Prune runs a pass and streams its events. If the client goes away, the pass stops as it would on shutdown: no new
+// deletions are started and those in progress finish.
+func (s *Server) Prune(req *PruneRequest, stream Deleter_PruneServer) error {
+	events := make(chan *PruneEvent, 64)
+	var summary pruner.Summary
+	var err error
+	go func() {
+		defer close(events)
+		summary, err = s.p.RunCompanies(stream.Context(), req.Companies, eventRecorder(events))
+	}()
+	var sendErr error
+	for event := range events {
+		// Keep draining after a failed send so the pass isn't blocked.
+		if sendErr == nil {
+			sendErr = stream.Send(event)
+		}
+	}
+	if err != nil && err != stream.Context().Err() {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	done := &PassDone{RunId: summary.RunID, Interrupted: summary.Interrupted, Aborted: summary.Aborted, UnknownCompanies: summary.UnknownCompanies}
+	for _, stats := range summary.Companies {
+		done.Companies = append(done.Companies, companyStats(stats))
+	}
+	return stream.Send(&PruneEvent{Event: &PruneEvent_PassDone{PassDone: done}})
+}
+
+func (s *Server) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	response := &StatusResponse{}
+	for _, company := range s.p.Status() {
+		entry := &CompanyStatus{Stats: companyStats(company.Stats), RunId: company.RunID, Paused: company.Paused}
+		if !company.Finished.IsZero() {
+			entry.Finished = timestamppb.New(company.Finished)
+		}
+		response.Companies = append(response.Companies, entry)
+	}
+	return response, nil
+}
+
+func (s *Server) Pause(_ context.Context, req *CompanyRequest) (*CompanyResponse, error) {
+	if req.Company == "" {
+		return nil, status.Error(codes.InvalidArgument, "no company")
+	}
+	s.p.Pause(req.Company)
+	return &CompanyResponse{}, nil
+}
+
+func (s *Server) Resume(_ context.Context, req *CompanyRequest) (*CompanyResponse, error) {
+	if req.Company == "" {
+		return nil, status.Error(codes.InvalidArgument, "no company")
+	}
+	s.p.Resume(req.Company)
+	return &CompanyResponse{}, nil
+}
+
+func (s *Server) GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error) {
+	data, err := json.Marshal(s.p.Config())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &GetConfigResponse{ConfigJson: string(data)}, nil
+}
+
+func companyStats(stats pruner.CompanyStats) *CompanyStats {
+	message := &CompanyStats{
+		Company:      stats.Company,
+		DirsScanned:  int64(stats.DirsScanned),
+		DirsDeleted:  int64(stats.DirsDeleted),
+		FilesDeleted: int64(stats.FilesDeleted),
+		DirsTrashed:  int64(stats.DirsTrashed),
+		BytesFreed:   stats.BytesFreed,
+		Errors:       int64(stats.Errors),
+		DirsExcluded: int64(stats.DirsExcluded),
+		DirsUnparsed: int64(stats.DirsUnparsed),
+		LegalHold:    stats.LegalHold,
+		Paused:       stats.Paused,
+		Completed:    stats.Completed,
+		FilesRemoved: int64(stats.FilesRemoved),
+		Unknown:      stats.Unknown,
+		BaseDir:      stats.BaseDir,
+	}
+	if !stats.Cutoff.IsZero() {
+		message.Cutoff = timestamppb.New(stats.Cutoff)
+	}
+	return message
+}
+
+// eventRecorder turns pruning events into stream events.
+type eventRecorder chan<- *PruneEvent
+
+func (r eventRecorder) DirDeleted(company string, bytes int64, files int) {
+	r <- &PruneEvent{Event: &PruneEvent_Removed{Removed: &Removed{Company: company, Directory: true, Bytes: bytes, Files: int64(files)}}}
+}
+
+func (r eventRecorder) FileDeleted(company string, bytes int64) {
+	r <- &PruneEvent{Event: &PruneEvent_Removed{Removed: &Removed{Company: company, Bytes: bytes, Files: 1}}}
+}
+
+func (r eventRecorder) Error(company string) {
+	r <- &PruneEvent{Event: &PruneEvent_Error{Error: &CompanyError{Company: company}}}
+}
+
+func (r eventRecorder) CompanyDone(company string, duration time.Duration, success bool) {
+	r <- &PruneEvent{Event: &PruneEvent_CompanyDone{CompanyDone: &CompanyDone{Company: company, Seconds: duration.Seconds(), Success: success}}}
+}
@@ -0,0 +1,197 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// Packet types and constants from draft-ietf-secsh-filexfer-02, which is version 3 of the protocol.
+const (
+	protocolVersion = 3
+
+	fxpInit          = 1
+	fxpVersion       = 2
+	fxpOpen          = 3
+	fxpClose         = 4
+	fxpRead          = 5
+	fxpLstat         = 7
+	fxpOpendir       = 11
+	fxpReaddir       = 12
+	fxpRemove        = 13
+	fxpMkdir         = 14
+	fxpRmdir         = 15
+	fxpRename        = 18
+	fxpStatus        = 101
+	fxpHandle        = 102
+	fxpData          = 103
+	fxpName          = 104
+	fxpAttrs         = 105
+	fxpExtended      = 200
+	fxpExtendedReply = 201
+
+	fxfRead = 0x1
+
+	attrSize        = 0x1
+	attrUIDGID      = 0x2
+	attrPermissions = 0x4
+	attrACModTime   = 0x8
+	attrExtended    = 0x80000000
+
+	statusOK               = 0
+	statusEOF              = 1
+	statusNoSuchFile       = 2
+	statusPermissionDenied = 3
+
+	modeType    = 0170000
+	modeDir     = 0040000
+	modeSymlink = 0120000
+	modeRegular = 0100000
+
+	// readSize is how much each read request asks for. Servers may return less.
+	readSize = 32 * 1024
+	// maxPacket bounds the replies accepted, well above anything a server sends for our requests.
+	maxPacket = 4 * 1024 * 1024
+)
+
+// errEOF is the status a server ends directory listings and reads with.
+var errEOF = errors.New("end of file")
+
+// statusError returns the error a status reply reports, nil for OK.
+func statusError(data []byte, op string, name string) error {
+	r := reader{data: data}
+	code := r.uint32()
+	message := r.string()
+	if r.err != nil {
+		return r.err
+	}
+	switch code {
+	case statusOK:
+		return nil
+	case statusEOF:
+		return errEOF
+	case statusNoSuchFile:
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case statusPermissionDenied:
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	if message == "" {
+		message = fmt.Sprintf("status %d", code)
+	}
+	return fmt.Errorf("sftp %s %s: %s", op, name, message)
+}
+
+// buffer builds a packet payload.
+type buffer []byte
+
+func (b *buffer) uint32(v uint32) *buffer {
+	*b = binary.BigEndian.AppendUint32(*b, v)
+	return b
+}
+
+func (b *buffer) uint64(v uint64) *buffer {
+	*b = binary.BigEndian.AppendUint64(*b, v)
+	return b
+}
+
+func (b *buffer) string(s string) *buffer {
+	b.uint32(uint32(len(s)))
+	*b = append(*b, s...)
+	return b
+}
+
+func writePacket(w io.Writer, typ byte, payload *buffer) error {
+	packet := make([]byte, 5, 5+len(*payload))
+	binary.BigEndian.PutUint32(packet, uint32(1+len(*payload)))
+	packet[4] = typ
+	_, err := w.Write(append(packet, *payload...))
+	return err
+}
+
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > maxPacket {
+		return 0, nil, fmt.Errorf("bad packet length %d", length)
+	}
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[4], data, nil
+}
+
+// reader decodes a packet payload. The first value that runs past the end sets err, and every value read after
+// that is zero.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.data) {
+		if r.err == nil {
+			r.err = errors.New("sftp: short packet")
+		}
+		return nil
+	}
+	taken := r.data[:n]
+	r.data = r.data[n:]
+	return taken
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *reader) string() string {
+	return string(r.take(int(r.uint32())))
+}
+
+// attrs is the part of a file's attributes we use.
+type attrs struct {
+	size        uint64
+	permissions uint32
+	mtime       uint32
+}
+
+func (r *reader) attrs() attrs {
+	var a attrs
+	flags := r.uint32()
+	if flags&attrSize != 0 {
+		a.size = r.uint64()
+	}
+	if flags&attrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if flags&attrPermissions != 0 {
+		a.permissions = r.uint32()
+	}
+	if flags&attrACModTime != 0 {
+		r.uint32()
+		a.mtime = r.uint32()
+	}
+	if flags&attrExtended != 0 {
+		for count := r.uint32(); count > 0 && r.err == nil; count-- {
+			r.string()
+			r.string()
+		}
+	}
+	return a
+}
@@ -0,0 +1,164 @@
+package sftp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		typ     byte
+		payload *buffer
+	}{
+		{fxpInit, new(buffer).uint32(protocolVersion)},
+		{fxpVersion, new(buffer).uint32(protocolVersion).string("statvfs@openssh.com").string("2")},
+		{fxpOpen, new(buffer).uint32(1).string("/data/acme/file").uint32(fxfRead).uint32(0)},
+		{fxpClose, new(buffer).uint32(2).string("handle")},
+		{fxpRead, new(buffer).uint32(3).string("handle").uint64(1 << 40).uint32(readSize)},
+		{fxpLstat, new(buffer).uint32(4).string("/data/acme")},
+		{fxpOpendir, new(buffer).uint32(5).string("/data/acme")},
+		{fxpReaddir, new(buffer).uint32(6).string("handle")},
+		{fxpRemove, new(buffer).uint32(7).string("/data/acme/file")},
+		{fxpMkdir, new(buffer).uint32(8).string("/data/acme/.trash").uint32(0)},
+		{fxpRmdir, new(buffer).uint32(9).string("/data/acme/2020")},
+		{fxpRename, new(buffer).uint32(10).string("/data/acme/2020").string("/data/acme/.trash/2020")},
+		{fxpStatus, new(buffer).uint32(11).uint32(statusNoSuchFile).string("No such file").string("en")},
+		{fxpHandle, new(buffer).uint32(12).string("\x00\x01handle")},
+		{fxpData, new(buffer).uint32(13).string(strings.Repeat("data", 1000))},
+		{fxpName, new(buffer).uint32(14).uint32(1).string("file").string("-rw-r--r-- file").uint32(attrSize).uint64(4)},
+		{fxpAttrs, new(buffer).uint32(15).uint32(attrPermissions).uint32(modeDir | 0755)},
+		{fxpExtended, new(buffer).uint32(16).string("statvfs@openssh.com").string("/data")},
+		{fxpExtendedReply, new(buffer).uint32(17).uint64(4096).uint64(4096)},
+		{fxpReaddir, new(buffer)},
+	} {
+		var wire bytes.Buffer
+		if err := writePacket(&wire, test.typ, test.payload); err != nil {
+			t.Fatal(err)
+		}
+		if wire.Len() != 5+len(*test.payload) {
+			t.Errorf("type %d: wrote %d bytes for a %d byte payload", test.typ, wire.Len(), len(*test.payload))
+		}
+		typ, data, err := readPacket(&wire)
+		if err != nil {
+			t.Errorf("type %d: %v", test.typ, err)
+			continue
+		}
+		if typ != test.typ || !bytes.Equal(data, *test.payload) {
+			t.Errorf("type %d: read back type %d with %q, want %q", test.typ, typ, data, *test.payload)
+		}
+		if wire.Len() != 0 {
+			t.Errorf("type %d: %d bytes left over", test.typ, wire.Len())
+		}
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	for _, test := range []struct {
+		wire string
+		err  string
+	}{
+		{"", "EOF"},
+		// The connection closes in the header, or before the whole payload has arrived.
+		{"\x00\x00\x00", "unexpected EOF"},
+		{"\x00\x00\x00\x09\x65\x00\x00", "unexpected EOF"},
+		{"\x00\x00\x00\x00\x65", "bad packet length 0"},
+		{"\x01\x00\x00\x00\x65", "bad packet length 16777216"},
+	} {
+		if _, _, err := readPacket(strings.NewReader(test.wire)); err == nil || err.Error() != test.err {
+			t.Errorf("%q: got %v, want %s", test.wire, err, test.err)
+		}
+	}
+}
+
+func TestReaderValues(t *testing.T) {
+	payload := new(buffer).uint32(7).uint64(1<<63 + 1).string("").string("name")
+	r := reader{data: *payload}
+	if got := r.uint32(); got != 7 {
+		t.Errorf("got uint32 %d", got)
+	}
+	if got := r.uint64(); got != 1<<63+1 {
+		t.Errorf("got uint64 %d", got)
+	}
+	if got := r.string(); got != "" {
+		t.Errorf("got string %q", got)
+	}
+	if got := r.string(); got != "name" {
+		t.Errorf("got string %q", got)
+	}
+	if r.err != nil || len(r.data) != 0 {
+		t.Errorf("got %v with %d bytes left", r.err, len(r.data))
+	}
+	// Past the end everything reads as zero, and the error sticks.
+	if r.uint32() != 0 || r.string() != "" || r.err == nil {
+		t.Errorf("read past the end without an error")
+	}
+}
+
+func TestReaderShortString(t *testing.T) {
+	// A string that claims to be longer than the packet, as a truncated or hostile reply would.
+	r := reader{data: *new(buffer).uint32(100).uint32(1)}
+	if s := r.string(); s != "" || r.err == nil {
+		t.Errorf("got %q, %v, want a short packet error", s, r.err)
+	}
+	r = reader{data: *new(buffer).uint32(0xffffffff)}
+	if s := r.string(); s != "" || r.err == nil {
+		t.Errorf("got %q, %v, want a short packet error", s, r.err)
+	}
+}
+
+func TestReaderAttrs(t *testing.T) {
+	payload := new(buffer).
+		uint32(attrSize | attrUIDGID | attrPermissions | attrACModTime | attrExtended).
+		uint64(1234).
+		uint32(1000).uint32(1000).
+		uint32(modeRegular | 0640).
+		uint32(1600000000).uint32(1609459200).
+		uint32(2).string("a@example.com").string("1").string("b@example.com").string("2").
+		string("after")
+	r := reader{data: *payload}
+	a := r.attrs()
+	if a != (attrs{size: 1234, permissions: modeRegular | 0640, mtime: 1609459200}) {
+		t.Errorf("got %+v", a)
+	}
+	// Everything after the attributes is still where it was.
+	if got := r.string(); got != "after" || r.err != nil {
+		t.Errorf("read %q, %v after the attributes", got, r.err)
+	}
+	r = reader{data: *new(buffer).uint32(attrSize).uint32(0)}
+	if r.attrs(); r.err == nil {
+		t.Error("decoded attributes that were cut short")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	for _, test := range []struct {
+		code    uint32
+		message string
+		want    error
+		text    string
+	}{
+		{statusOK, "", nil, ""},
+		{statusEOF, "End of file", errEOF, ""},
+		{statusNoSuchFile, "No such file", fs.ErrNotExist, "remove /data/acme/file: file does not exist"},
+		{statusPermissionDenied, "Permission denied", fs.ErrPermission, "remove /data/acme/file: permission denied"},
+		{4, "Failure", nil, "sftp remove /data/acme/file: Failure"},
+		{8, "", nil, "sftp remove /data/acme/file: status 8"},
+	} {
+		err := statusError(*new(buffer).uint32(test.code).string(test.message).string("en"), "remove", "/data/acme/file")
+		switch {
+		case test.want != nil && !errors.Is(err, test.want):
+			t.Errorf("status %d: got %v, want %v", test.code, err, test.want)
+		case test.text != "" && (err == nil || err.Error() != test.text):
+			t.Errorf("status %d: got %v, want %s", test.code, err, test.text)
+		case test.want == nil && test.text == "" && err != nil:
+			t.Errorf("status %d: got %v", test.code, err)
+		}
+	}
+	if err := statusError([]byte{0, 0}, "remove", "/data/acme/file"); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("got %v for a short status, want a short packet error", err)
+	}
+}
@@ -0,0 +1,406 @@
+// Package sftp is a minimal SFTP version 3 client for pruning company data kept on remote servers. It runs the
+// system's ssh client with the sftp subsystem and speaks the protocol over its stdin and stdout, so authentication,
+// host key checking and ssh_config all work as they do for ssh itself.
+package sftp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config is where a company's data is kept on a remote server, and how to log in to it.
+type Config struct {
+	Host string `json:"host"`
+	// Port defaults to ssh's, normally 22.
+	Port int    `json:"port,omitempty"`
+	User string `json:"user,omitempty"`
+	// Path is the absolute remote directory that stands for the company directory.
+	Path string `json:"path"`
+	// IdentityFile is the private key to log in with. Without it ssh tries its defaults and any agent. Logins are
+	// never interactive, so password authentication isn't possible.
+	IdentityFile string `json:"identityFile,omitempty"`
+	// KnownHosts, if set, is the only known_hosts file the server's key is checked against, and an unknown key is
+	// refused rather than added.
+	KnownHosts string `json:"knownHosts,omitempty"`
+	// Command, if set, is run instead of ssh and must speak SFTP on its stdin and stdout, e.g. an sftp-server
+	// reached some other way.
+	Command []string `json:"command,omitempty"`
+}
+
+// Check returns an error if config is missing something every connection needs.
+func (c Config) Check() error {
+	if c.Host == "" && len(c.Command) == 0 {
+		return fmt.Errorf("missing host")
+	}
+	if !path.IsAbs(c.Path) {
+		return fmt.Errorf("path %q is not absolute", c.Path)
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", c.Port)
+	}
+	return nil
+}
+
+// Connection returns what identifies the connection config makes, which is the same for configs that differ only
+// in Path.
+func (c Config) Connection() string {
+	c.Path = ""
+	return fmt.Sprintf("%+v", c)
+}
+
+func (c Config) command() *exec.Cmd {
+	if len(c.Command) > 0 {
+		return exec.Command(c.Command[0], c.Command[1:]...)
+	}
+	args := []string{"-o", "BatchMode=yes", "-o", "ServerAliveInterval=30"}
+	if c.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(c.Port))
+	}
+	if c.User != "" {
+		args = append(args, "-l", c.User)
+	}
+	if c.IdentityFile != "" {
+		args = append(args, "-i", c.IdentityFile, "-o", "IdentitiesOnly=yes")
+	}
+	if c.KnownHosts != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+c.KnownHosts, "-o", "StrictHostKeyChecking=yes")
+	}
+	args = append(args, "-s", c.Host, "sftp")
+	return exec.Command("ssh", args...)
+}
+
+// Client is a connection to an SFTP server. It connects on first use and again after the connection fails, and
+// sends one request at a time, so it is safe for concurrent use.
+type Client struct {
+	config Config
+
+	mu         sync.Mutex
+	cmd        *exec.Cmd
+	w          io.WriteCloser
+	r          *bufio.Reader
+	id         uint32
+	extensions map[string]string
+}
+
+// NewClient returns a client for config. It doesn't connect until it is first used.
+func NewClient(config Config) *Client {
+	return &Client{config: config}
+}
+
+// Lstat returns what the server knows about name, without following a final symlink.
+func (c *Client) Lstat(name string) (os.FileInfo, error) {
+	typ, data, err := c.call(fxpLstat, new(buffer).string(name))
+	if err != nil {
+		return nil, err
+	}
+	if err := expect(typ, data, fxpAttrs, "lstat", name); err != nil {
+		return nil, err
+	}
+	r := reader{data: data}
+	attrs := r.attrs()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return fileInfo{name: path.Base(name), attrs: attrs}, nil
+}
+
+// ReadDir returns the entries of the directory name, sorted by name and without "." and "..".
+func (c *Client) ReadDir(name string) ([]os.FileInfo, error) {
+	handle, err := c.open(fxpOpendir, new(buffer).string(name), "opendir", name)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close(handle)
+	var infos []os.FileInfo
+	for {
+		typ, data, err := c.call(fxpReaddir, new(buffer).string(handle))
+		if err != nil {
+			return nil, err
+		}
+		if typ == fxpStatus {
+			if err := statusError(data, "readdir", name); err != errEOF {
+				return nil, err
+			}
+			break
+		}
+		if err := expect(typ, data, fxpName, "readdir", name); err != nil {
+			return nil, err
+		}
+		r := reader{data: data}
+		for count := r.uint32(); count > 0 && r.err == nil; count-- {
+			filename := r.string()
+			r.string() // The ls -l style long name.
+			attrs := r.attrs()
+			if filename != "." && filename != ".." {
+				infos = append(infos, fileInfo{name: filename, attrs: attrs})
+			}
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// ReadFile returns the contents of the file name.
+func (c *Client) ReadFile(name string) ([]byte, error) {
+	handle, err := c.open(fxpOpen, new(buffer).string(name).uint32(fxfRead).uint32(0), "open", name)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close(handle)
+	var contents []byte
+	for {
+		typ, data, err := c.call(fxpRead, new(buffer).string(handle).uint64(uint64(len(contents))).uint32(readSize))
+		if err != nil {
+			return nil, err
+		}
+		if typ == fxpStatus {
+			if err := statusError(data, "read", name); err != errEOF {
+				return nil, err
+			}
+			return contents, nil
+		}
+		if err := expect(typ, data, fxpData, "read", name); err != nil {
+			return nil, err
+		}
+		r := reader{data: data}
+		chunk := r.string()
+		if r.err != nil {
+			return nil, r.err
+		}
+		contents = append(contents, chunk...)
+	}
+}
+
+// Remove removes the file or symlink name.
+func (c *Client) Remove(name string) error {
+	return c.status(fxpRemove, new(buffer).string(name), "remove", name)
+}
+
+// RemoveDir removes the empty directory name.
+func (c *Client) RemoveDir(name string) error {
+	return c.status(fxpRmdir, new(buffer).string(name), "rmdir", name)
+}
+
+// Mkdir creates the directory name.
+func (c *Client) Mkdir(name string) error {
+	return c.status(fxpMkdir, new(buffer).string(name).uint32(0), "mkdir", name)
+}
+
+// Rename renames oldname to newname, which must not exist.
+func (c *Client) Rename(oldname, newname string) error {
+	return c.status(fxpRename, new(buffer).string(oldname).string(newname), "rename", oldname)
+}
+
+// StatVFS returns the bytes available to us and the total size of the filesystem holding name. It needs the
+// server to support OpenSSH's statvfs extension.
+func (c *Client) StatVFS(name string) (free uint64, total uint64, err error) {
+	if err := c.connect(); err != nil {
+		return 0, 0, err
+	}
+	c.mu.Lock()
+	_, supported := c.extensions["statvfs@openssh.com"]
+	c.mu.Unlock()
+	if !supported {
+		return 0, 0, fmt.Errorf("sftp statvfs %s: server does not support statvfs@openssh.com", name)
+	}
+	typ, data, err := c.call(fxpExtended, new(buffer).string("statvfs@openssh.com").string(name))
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := expect(typ, data, fxpExtendedReply, "statvfs", name); err != nil {
+		return 0, 0, err
+	}
+	r := reader{data: data}
+	r.uint64() // f_bsize
+	frsize, blocks := r.uint64(), r.uint64()
+	r.uint64() // f_bfree
+	avail := r.uint64()
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+	return avail * frsize, blocks * frsize, nil
+}
+
+// Close ends the connection. The client connects again if it is used afterwards.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.disconnect()
+}
+
+// open sends a request that returns a handle and returns it.
+func (c *Client) open(typ byte, payload *buffer, op string, name string) (string, error) {
+	reply, data, err := c.call(typ, payload)
+	if err != nil {
+		return "", err
+	}
+	if err := expect(reply, data, fxpHandle, op, name); err != nil {
+		return "", err
+	}
+	r := reader{data: data}
+	handle := r.string()
+	return handle, r.err
+}
+
+func (c *Client) close(handle string) {
+	c.call(fxpClose, new(buffer).string(handle))
+}
+
+// status sends a request whose reply is only a status.
+func (c *Client) status(typ byte, payload *buffer, op string, name string) error {
+	reply, data, err := c.call(typ, payload)
+	if err != nil {
+		return err
+	}
+	if reply != fxpStatus {
+		return fmt.Errorf("sftp %s %s: unexpected reply type %d", op, name, reply)
+	}
+	return statusError(data, op, name)
+}
+
+func (c *Client) connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connectLocked()
+}
+
+func (c *Client) connectLocked() error {
+	if c.cmd != nil {
+		return nil
+	}
+	cmd := c.config.command()
+	cmd.Stderr = os.Stderr
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("sftp: starting %s: %v", cmd.Path, err)
+	}
+	c.cmd, c.w, c.r = cmd, w, bufio.NewReaderSize(r, 64*1024)
+	// INIT has no request id; the version takes its place.
+	if err := writePacket(c.w, fxpInit, new(buffer).uint32(protocolVersion)); err != nil {
+		c.disconnect()
+		return fmt.Errorf("sftp: connecting to %s: %v", c.config.Host, err)
+	}
+	typ, data, err := readPacket(c.r)
+	if err == nil && typ != fxpVersion {
+		err = fmt.Errorf("unexpected reply type %d to init", typ)
+	}
+	if err != nil {
+		c.disconnect()
+		return fmt.Errorf("sftp: connecting to %s: %v", c.config.Host, err)
+	}
+	version := reader{data: data}
+	version.uint32()
+	c.extensions = make(map[string]string)
+	for len(version.data) > 0 && version.err == nil {
+		name, value := version.string(), version.string()
+		c.extensions[name] = value
+	}
+	return nil
+}
+
+func (c *Client) disconnect() error {
+	if c.cmd == nil {
+		return nil
+	}
+	c.w.Close()
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		c.cmd.Process.Kill()
+		err = <-done
+	}
+	c.cmd, c.w, c.r = nil, nil, nil
+	return err
+}
+
+// call sends a request and returns the type and the payload after the request id of its reply. A failed connection
+// is dropped, so that the next call makes a new one.
+func (c *Client) call(typ byte, payload *buffer) (byte, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connectLocked(); err != nil {
+		return 0, nil, err
+	}
+	c.id++
+	id := c.id
+	request := new(buffer).uint32(id)
+	*request = append(*request, *payload...)
+	if err := writePacket(c.w, typ, request); err != nil {
+		c.disconnect()
+		return 0, nil, fmt.Errorf("sftp: %s: %v", c.config.Host, err)
+	}
+	reply, data, err := readPacket(c.r)
+	if err != nil {
+		c.disconnect()
+		return 0, nil, fmt.Errorf("sftp: %s: %v", c.config.Host, err)
+	}
+	r := reader{data: data}
+	if got := r.uint32(); r.err != nil || got != id {
+		c.disconnect()
+		return 0, nil, fmt.Errorf("sftp: %s: reply for request %d, expected %d", c.config.Host, got, id)
+	}
+	return reply, r.data, nil
+}
+
+// expect returns nil if a reply is of type want, and otherwise the error it reports.
+func expect(typ byte, data []byte, want byte, op string, name string) error {
+	if typ == want {
+		return nil
+	}
+	if typ == fxpStatus {
+		if err := statusError(data, op, name); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("sftp %s %s: unexpected reply type %d", op, name, typ)
+}
+
+// fileInfo is the os.FileInfo of a remote file.
+type fileInfo struct {
+	name  string
+	attrs attrs
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return int64(i.attrs.size) }
+func (i fileInfo) ModTime() time.Time { return time.Unix(int64(i.attrs.mtime), 0) }
+func (i fileInfo) IsDir() bool        { return i.Mode().IsDir() }
+func (i fileInfo) Sys() interface{}   { return nil }
+
+func (i fileInfo) Mode() os.FileMode {
+	mode := os.FileMode(i.attrs.permissions & 0777)
+	switch i.attrs.permissions & modeType {
+	case modeDir:
+		mode |= fs.ModeDir
+	case modeSymlink:
+		mode |= fs.ModeSymlink
+	case modeRegular:
+	default:
+		if i.attrs.permissions&modeType != 0 {
+			mode |= fs.ModeIrregular
+		}
+	}
+	return mode
+}
@@ -0,0 +1,353 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The client tests run the test binary itself as the server, through Config.Command: with $SFTP_FAKE_SERVER set,
+// TestMain serves SFTP on stdin and stdout instead of running the tests.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("SFTP_FAKE_SERVER"); mode != "" {
+		serveFake(mode, os.Getenv("SFTP_FAKE_CONNECTIONS"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeFiles is the tree every fake server starts with: directories end in a slash, and the rest are files with
+// their contents.
+var fakeFiles = map[string]string{
+	"/data/":                     "",
+	"/data/acme/":                "",
+	"/data/acme/2020/":           "",
+	"/data/acme/2020/12/":        "",
+	"/data/acme/2020/12/log":     strings.Repeat("line\n", 20000),
+	"/data/acme/2020/12/.keep":   "",
+	"/data/acme/2020/protected/": "",
+}
+
+// fakeMtime is the modification time of every fake file.
+var fakeMtime = time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// serveFake is a scripted SFTP server. Every request is answered from a fresh copy of fakeFiles, except as mode
+// says:
+//
+//	"ok"          answer everything
+//	"short-once"  on the first connection, answer the first request with half a packet and hang up
+//	"bad-id"      answer every request with the wrong request id
+//	"no-statvfs"  don't offer the statvfs extension
+//
+// Connections are counted in the file named by connections.
+func serveFake(mode string, connections string) {
+	count := 1
+	if data, err := os.ReadFile(connections); err == nil {
+		count, _ = strconv.Atoi(string(data))
+		count++
+	}
+	os.WriteFile(connections, []byte(strconv.Itoa(count)), 0644)
+	files := make(map[string]string)
+	for name, contents := range fakeFiles {
+		files[name] = contents
+	}
+	in, out := os.Stdin, os.Stdout
+	typ, _, err := readPacket(in)
+	if err != nil || typ != fxpInit {
+		return
+	}
+	version := new(buffer).uint32(protocolVersion)
+	if mode != "no-statvfs" {
+		version.string("statvfs@openssh.com").string("2")
+	}
+	writePacket(out, fxpVersion, version)
+	handles := make(map[string]string)
+	for requests := 0; ; requests++ {
+		typ, data, err := readPacket(in)
+		if err != nil {
+			return
+		}
+		r := reader{data: data}
+		id := r.uint32()
+		if mode == "short-once" && count == 1 && requests == 0 {
+			// The length says 100 bytes, and only the header and half the request id follow.
+			out.Write([]byte{0, 0, 0, 100, fxpStatus, 0, 0})
+			return
+		}
+		if mode == "bad-id" {
+			id++
+		}
+		reply := new(buffer).uint32(id)
+		status := func(code uint32, message string) (byte, *buffer) {
+			return fxpStatus, reply.uint32(code).string(message).string("en")
+		}
+		replyType, payload := func() (byte, *buffer) {
+			switch typ {
+			case fxpLstat:
+				name := r.string()
+				if _, ok := files[name+"/"]; ok {
+					return fxpAttrs, reply.uint32(attrPermissions | attrACModTime).uint32(modeDir | 0755).uint32(0).uint32(uint32(fakeMtime.Unix()))
+				}
+				if contents, ok := files[name]; ok {
+					return fxpAttrs, reply.uint32(attrSize | attrPermissions | attrACModTime).uint64(uint64(len(contents))).uint32(modeRegular | 0644).uint32(0).uint32(uint32(fakeMtime.Unix()))
+				}
+				return status(statusNoSuchFile, "No such file")
+			case fxpOpendir, fxpOpen:
+				name := r.string()
+				key := name
+				if typ == fxpOpendir {
+					key += "/"
+				}
+				if _, ok := files[key]; !ok {
+					return status(statusNoSuchFile, "No such file")
+				}
+				handle := strconv.Itoa(len(handles))
+				handles[handle] = key
+				return fxpHandle, reply.string(handle)
+			case fxpReaddir:
+				handle := r.string()
+				dir, ok := handles[handle]
+				if !ok {
+					return status(4, "invalid handle")
+				}
+				if dir == "" {
+					return status(statusEOF, "")
+				}
+				// Everything is listed in one reply, then the listing ends.
+				handles[handle] = ""
+				var names []string
+				for name := range files {
+					if name != dir && strings.HasPrefix(name, dir) && !strings.Contains(strings.TrimSuffix(name[len(dir):], "/"), "/") {
+						names = append(names, name)
+					}
+				}
+				sort.Sort(sort.Reverse(sort.StringSlice(names)))
+				reply.uint32(uint32(len(names) + 2))
+				for _, name := range append([]string{dir + ".", dir + ".."}, names...) {
+					base := path.Base(name)
+					if strings.HasSuffix(name, "/") {
+						reply.string(base).string("drwxr-xr-x " + base).uint32(attrPermissions).uint32(modeDir | 0755)
+					} else {
+						reply.string(base).string("-rw-r--r-- " + base).uint32(attrSize).uint64(uint64(len(files[name])))
+					}
+				}
+				return fxpName, reply
+			case fxpRead:
+				name, ok := handles[r.string()]
+				offset, length := r.uint64(), r.uint32()
+				if !ok {
+					return status(4, "invalid handle")
+				}
+				contents := files[name]
+				if offset >= uint64(len(contents)) {
+					return status(statusEOF, "")
+				}
+				// Servers may return less than was asked for.
+				end := offset + uint64(length)/3
+				if end > uint64(len(contents)) {
+					end = uint64(len(contents))
+				}
+				return fxpData, reply.string(contents[offset:end])
+			case fxpClose:
+				delete(handles, r.string())
+				return status(statusOK, "")
+			case fxpRemove:
+				name := r.string()
+				if _, ok := files[name+"/"]; ok {
+					return status(4, "Is a directory")
+				}
+				if _, ok := files[name]; !ok {
+					return status(statusNoSuchFile, "No such file")
+				}
+				delete(files, name)
+				return status(statusOK, "")
+			case fxpRmdir:
+				name := r.string()
+				if strings.HasSuffix(name, "/protected") {
+					return status(statusPermissionDenied, "Permission denied")
+				}
+				for other := range files {
+					if strings.HasPrefix(other, name+"/") && other != name+"/" {
+						return status(4, "Directory not empty")
+					}
+				}
+				delete(files, name+"/")
+				return status(statusOK, "")
+			case fxpMkdir:
+				files[r.string()+"/"] = ""
+				return status(statusOK, "")
+			case fxpRename:
+				from, to := r.string(), r.string()
+				if _, ok := files[to]; ok {
+					return status(4, "File exists")
+				}
+				files[to] = files[from]
+				delete(files, from)
+				return status(statusOK, "")
+			case fxpExtended:
+				if r.string() != "statvfs@openssh.com" {
+					return status(8, "Operation unsupported")
+				}
+				// 4 KiB fragments, 1000 blocks, 250 of them available.
+				return fxpExtendedReply, reply.uint64(4096).uint64(4096).uint64(1000).uint64(300).uint64(250).uint64(0).uint64(0).uint64(0).uint64(0).uint64(0).uint64(255)
+			}
+			return status(8, "Operation unsupported")
+		}()
+		writePacket(out, replyType, payload)
+	}
+}
+
+// fakeClient returns a client of a fake server in mode, and a function returning how many times it has connected.
+func fakeClient(t *testing.T, mode string) (*Client, func() int) {
+	t.Setenv("SFTP_FAKE_SERVER", mode)
+	connections := path.Join(t.TempDir(), "connections")
+	t.Setenv("SFTP_FAKE_CONNECTIONS", connections)
+	client := NewClient(Config{Host: "fake", Path: "/data/acme", Command: []string{os.Args[0]}})
+	t.Cleanup(func() { client.Close() })
+	return client, func() int {
+		data, _ := os.ReadFile(connections)
+		n, _ := strconv.Atoi(string(data))
+		return n
+	}
+}
+
+func TestClientReads(t *testing.T) {
+	client, connections := fakeClient(t, "ok")
+	info, err := client.Lstat("/data/acme/2020/12/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "log" || info.Size() != 100000 || !info.Mode().IsRegular() || info.Mode().Perm() != 0644 || !info.ModTime().Equal(fakeMtime) {
+		t.Errorf("got %s %d %s %s", info.Name(), info.Size(), info.Mode(), info.ModTime())
+	}
+	if info, err := client.Lstat("/data/acme/2020"); err != nil || !info.IsDir() {
+		t.Errorf("got %v, %v, want a directory", info, err)
+	}
+	infos, err := client.ReadDir("/data/acme/2020/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, fmt.Sprintf("%s %d", info.Name(), info.Size()))
+	}
+	if fmt.Sprint(names) != "[.keep 0 log 100000]" {
+		t.Errorf("listed %q, sorted and without . and ..", names)
+	}
+	// The server returns a third of each read, so the file takes many.
+	contents, err := client.ReadFile("/data/acme/2020/12/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != fakeFiles["/data/acme/2020/12/log"] {
+		t.Errorf("read %d bytes, want %d", len(contents), len(fakeFiles["/data/acme/2020/12/log"]))
+	}
+	free, total, err := client.StatVFS("/data")
+	if err != nil || free != 250*4096 || total != 1000*4096 {
+		t.Errorf("got %d free of %d, %v", free, total, err)
+	}
+	if n := connections(); n != 1 {
+		t.Errorf("connected %d times, want once", n)
+	}
+}
+
+func TestClientStatusErrors(t *testing.T) {
+	client, _ := fakeClient(t, "ok")
+	if _, err := client.Lstat("/data/acme/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("lstat got %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := client.ReadDir("/data/acme/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("readdir got %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := client.ReadFile("/data/acme/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("read got %v, want %v", err, fs.ErrNotExist)
+	}
+	if err := client.RemoveDir("/data/acme/2020/protected"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("rmdir got %v, want %v", err, fs.ErrPermission)
+	}
+	if err := client.Remove("/data/acme/2020"); err == nil || err.Error() != "sftp remove /data/acme/2020: Is a directory" {
+		t.Errorf("remove of a directory got %v", err)
+	}
+	if err := client.RemoveDir("/data/acme/2020/12"); err == nil || !strings.Contains(err.Error(), "Directory not empty") {
+		t.Errorf("rmdir of a full directory got %v", err)
+	}
+	// The same connection carries on after errors.
+	for _, name := range []string{"/data/acme/2020/12/log", "/data/acme/2020/12/.keep"} {
+		if err := client.Remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.RemoveDir("/data/acme/2020/12"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Mkdir("/data/acme/.trash"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Rename("/data/acme/2020/protected/", "/data/acme/.trash/"); err == nil {
+		t.Error("renamed onto a directory that exists")
+	}
+}
+
+func TestClientStatVFSUnsupported(t *testing.T) {
+	client, _ := fakeClient(t, "no-statvfs")
+	if _, _, err := client.StatVFS("/data"); err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClientReconnectsAfterShortRead(t *testing.T) {
+	client, connections := fakeClient(t, "short-once")
+	// The server hangs up half way through its reply.
+	if _, err := client.Lstat("/data/acme/2020"); err == nil || !strings.Contains(err.Error(), "unexpected EOF") {
+		t.Fatalf("got %v, want the short read", err)
+	}
+	// The next request makes a new connection, which works.
+	if info, err := client.Lstat("/data/acme/2020"); err != nil || !info.IsDir() {
+		t.Fatalf("got %v, %v after reconnecting", info, err)
+	}
+	if n := connections(); n != 2 {
+		t.Errorf("connected %d times, want twice", n)
+	}
+	// Close ends the connection, and the client connects again when used.
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Lstat("/data/acme/2020"); err != nil {
+		t.Fatal(err)
+	}
+	if n := connections(); n != 3 {
+		t.Errorf("connected %d times, want three times", n)
+	}
+}
+
+func TestClientRejectsMismatchedReply(t *testing.T) {
+	client, connections := fakeClient(t, "bad-id")
+	for i := 0; i < 2; i++ {
+		if _, err := client.Lstat("/data/acme/2020"); err == nil || !strings.Contains(err.Error(), "reply for request") {
+			t.Errorf("got %v, want a mismatched reply", err)
+		}
+	}
+	// Replies can't be matched to requests on a connection once one is out of step, so each is dropped.
+	if n := connections(); n != 2 {
+		t.Errorf("connected %d times, want twice", n)
+	}
+}
+
+func TestClientCommandFails(t *testing.T) {
+	client := NewClient(Config{Host: "fake", Path: "/data", Command: []string{"/nonexistent/sftp-server"}})
+	if _, err := client.Lstat("/data"); err == nil || !strings.Contains(err.Error(), "starting") {
+		t.Errorf("got %v", err)
+	}
+	// A server that exits before answering init.
+	client = NewClient(Config{Host: "fake", Path: "/data", Command: []string{"true"}})
+	if _, err := client.Lstat("/data"); err == nil || !strings.Contains(err.Error(), "connecting to fake") {
+		t.Errorf("got %v", err)
+	}
+}
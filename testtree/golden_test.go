@@ -0,0 +1,171 @@
+package testtree
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/moriarty-s3a/deleter/pruner"
+)
+
+// goldenBase is the base directory golden trees are generated under. They are only ever in memory.
+const goldenBase = "/data"
+
+// goldenDir holds the golden case files.
+const goldenDir = "../resources/golden"
+
+var update = flag.Bool("update", false, "rewrite each golden case's removed paths with what its pass removes now")
+
+// goldenCase is a pass over a synthetic tree at a fixed time, and exactly what it must remove.
+type goldenCase struct {
+	Description string          `json:"description"`
+	Tree        Spec            `json:"tree"`
+	Now         time.Time       `json:"now"`
+	Config      json.RawMessage `json:"config"`
+	// Removed are the paths, relative to the base directory, the pass removes or trashes, sorted.
+	Removed []string `json:"removed"`
+}
+
+// readCase reads a case from a JSON file.
+func readCase(path string) (goldenCase, error) {
+	var c goldenCase
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// writeCase writes c to a JSON file, as readCase reads it.
+func writeCase(path string, c goldenCase) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// run generates the case's tree in memory, runs one pass over it with the clock at Now, and returns the paths the
+// pass removed, relative to the base directory and sorted.
+func (c goldenCase) run(ctx context.Context) ([]string, error) {
+	config, err := pruner.ParseConfig(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	fsys := &pruner.MemFS{}
+	if _, err := Generate(fsys, goldenBase, c.Tree); err != nil {
+		return nil, err
+	}
+	quiet := log.New()
+	quiet.Out = ioutil.Discard
+	p := pruner.New(goldenBase, config)
+	p.FS = fsys
+	p.Clock = pruner.FixedClock(c.Now)
+	p.Log = quiet
+	var mu sync.Mutex
+	var removed []string
+	p.OnRemove = func(record pruner.AuditRecord) {
+		rel, err := filepath.Rel(goldenBase, record.Path)
+		if err != nil {
+			rel = record.Path
+		}
+		mu.Lock()
+		removed = append(removed, filepath.ToSlash(rel))
+		mu.Unlock()
+	}
+	summary, err := p.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if errs := summary.Totals().Errors; errs > 0 {
+		return nil, fmt.Errorf("pass had %d errors", errs)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// diff returns a line for every path that only one of want and got has, prefixed with "-" for those only want has
+// and "+" for those only got has. Both must be sorted.
+func diff(want, got []string) []string {
+	var lines []string
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case j == len(got) || (i < len(want) && want[i] < got[j]):
+			lines = append(lines, "-"+want[i])
+			i++
+		case i == len(want) || got[j] < want[i]:
+			lines = append(lines, "+"+got[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return lines
+}
+
+// cases lists the golden case files in dir.
+func cases(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// TestGolden runs every golden case and fails those whose pass no longer removes exactly the paths the case lists.
+// With -update it records what each pass removes now instead.
+func TestGolden(t *testing.T) {
+	paths, err := cases(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no golden cases in %s", goldenDir)
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			c, err := readCase(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			removed, err := c.run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := diff(c.Removed, removed)
+			switch {
+			case len(lines) == 0:
+			case *update:
+				c.Removed = removed
+				if err := writeCase(path, c); err != nil {
+					t.Fatal(err)
+				}
+				t.Logf("updated %s (%d changes)", path, len(lines))
+			default:
+				t.Errorf("%d paths differ (- expected, + removed):\n    %s", len(lines), strings.Join(lines, "\n    "))
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Package testtree generates synthetic company trees. Its tests run the golden cases in resources/golden against
+// them, so that changes to the pruner are checked against exactly which paths earlier versions removed; go test
+// -update rewrites the cases with what passes remove now.
+package testtree
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Spec describes a synthetic tree laid out as <company>/<device>/<year>/<month>/<day>/<hour>/<minute>, the default
+// layout, cut off after Depth date levels.
+type Spec struct {
+	// Companies are named company0, company1 and so on, and devices dev0, dev1 and so on.
+	Companies int `json:"companies"`
+	Devices   int `json:"devices"`
+	// Depth is how many date levels there are below each device, from 1 for years only to 5 for minutes.
+	Depth int `json:"depth"`
+	// Fanout is how many directories there are at each date level below their parent: the Fanout years up to
+	// End's, and values spread evenly over the range of each level below. Directories after End are left out.
+	Fanout int `json:"fanout"`
+	// FilesPerDir files are written to every directory at the deepest date level, modified at its date.
+	FilesPerDir int `json:"filesPerDir"`
+	// FileSize is the size of every file, or with MaxFileSize above it the smallest size, the others being spread
+	// up to MaxFileSize at random from Seed.
+	FileSize    int64 `json:"fileSize"`
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+	Seed        int64 `json:"seed,omitempty"`
+	// End is the latest date in the tree.
+	End time.Time `json:"end"`
+}
+
+// Writer is where a tree is generated. pruner.MemFS is one, and Disk writes to the local disk.
+type Writer interface {
+	MkdirAll(path string, perm os.FileMode) error
+	WriteFile(name string, data []byte, modified time.Time) error
+}
+
+// Disk is a Writer backed by the local disk.
+type Disk struct{}
+
+func (Disk) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (Disk) WriteFile(name string, data []byte, modified time.Time) error {
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(name, modified, modified)
+}
+
+// Stats counts what Generate wrote.
+type Stats struct {
+	Dirs  int
+	Files int
+	Bytes int64
+}
+
+// levelRanges are the values each date level below the year can take.
+var levelRanges = [][2]int{{1, 12}, {1, 28}, {0, 23}, {0, 59}}
+
+// Generate writes the tree spec describes under base.
+func Generate(w Writer, base string, spec Spec) (Stats, error) {
+	if spec.Depth < 1 || spec.Depth > 1+len(levelRanges) {
+		return Stats{}, fmt.Errorf("depth %d is not between 1 and %d", spec.Depth, 1+len(levelRanges))
+	}
+	if spec.Fanout < 1 {
+		return Stats{}, fmt.Errorf("fanout must be at least 1")
+	}
+	g := generator{w: w, spec: spec, random: rand.New(rand.NewSource(spec.Seed))}
+	for company := 0; company < spec.Companies; company++ {
+		for device := 0; device < spec.Devices; device++ {
+			dir := filepath.Join(base, fmt.Sprintf("company%d", company), fmt.Sprintf("dev%d", device))
+			if err := w.MkdirAll(dir, 0755); err != nil {
+				return g.stats, err
+			}
+			g.stats.Dirs++
+			for i := spec.Fanout - 1; i >= 0; i-- {
+				year := spec.End.Year() - i
+				if err := g.level(filepath.Join(dir, fmt.Sprint(year)), []int{year}); err != nil {
+					return g.stats, err
+				}
+			}
+		}
+	}
+	return g.stats, nil
+}
+
+type generator struct {
+	w      Writer
+	spec   Spec
+	random *rand.Rand
+	stats  Stats
+}
+
+// level writes the directory dir, dated by the date fields so far, and everything below it.
+func (g *generator) level(dir string, fields []int) error {
+	if err := g.w.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	g.stats.Dirs++
+	if len(fields) == g.spec.Depth {
+		return g.files(dir, fields)
+	}
+	bounds := levelRanges[len(fields)-1]
+	for _, value := range spread(bounds[0], bounds[1], g.spec.Fanout) {
+		next := append(append([]int(nil), fields...), value)
+		if date(next).After(g.spec.End) {
+			continue
+		}
+		if err := g.level(filepath.Join(dir, fmt.Sprintf("%02d", value)), next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *generator) files(dir string, fields []int) error {
+	modified := date(fields)
+	for i := 0; i < g.spec.FilesPerDir; i++ {
+		size := g.spec.FileSize
+		if g.spec.MaxFileSize > size {
+			size += g.random.Int63n(g.spec.MaxFileSize - size + 1)
+		}
+		if err := g.w.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.bin", i)), make([]byte, size), modified); err != nil {
+			return err
+		}
+		g.stats.Files++
+		g.stats.Bytes += size
+	}
+	return nil
+}
+
+// spread returns n values from lo to hi, as evenly spaced as whole numbers allow, without repeats.
+func spread(lo, hi, n int) []int {
+	if n > hi-lo+1 {
+		n = hi - lo + 1
+	}
+	if n == 1 {
+		return []int{hi}
+	}
+	values := make([]int, n)
+	for i := range values {
+		values[i] = lo + i*(hi-lo)/(n-1)
+	}
+	return values
+}
+
+// date returns the start, in UTC, of the period the date fields describe, year first.
+func date(fields []int) time.Time {
+	parts := []int{fields[0], 1, 1, 0, 0}
+	copy(parts, fields)
+	return time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], 0, 0, time.UTC)
+}